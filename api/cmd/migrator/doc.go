@@ -1,2 +1,2 @@
-// Package migrator provides database migration utilities for api.
-package migrator
+// Command migrator runs the database migrations of api, see migrator.Usage.
+package main
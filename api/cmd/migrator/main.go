@@ -0,0 +1,27 @@
+package main
+
+import (
+	"context"
+	"embed"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/dosanma1/forge/go/kit/migrator"
+)
+
+//go:embed all:migrations
+var migrationsFS embed.FS
+
+func main() {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	err := migrator.Command(ctx, migrationsFS, os.Stdout, os.Args[1:], migrator.WithServiceName("api"))
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		stop()
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,30 @@
+package ctrl
+
+import (
+	"context"
+
+	"github.com/dosanma1/forge/go/kit/application/usecase"
+	"github.com/dosanma1/forge/go/kit/search"
+	"github.com/dosanma1/forge/go/kit/search/query"
+)
+
+type Aggregator interface {
+	Aggregate(ctx context.Context, opts []query.Option) ([]query.Bucket, error)
+}
+
+type aggregator struct {
+	usecase          usecase.Aggregator
+	defaultQueryOpts []query.Option
+}
+
+func NewAggregator(uc usecase.Aggregator, defaultQueryOpts ...query.Option) *aggregator {
+	return &aggregator{
+		usecase:          uc,
+		defaultQueryOpts: defaultQueryOpts,
+	}
+}
+
+func (c *aggregator) Aggregate(ctx context.Context, opts []query.Option) ([]query.Bucket, error) {
+	opts = append(c.defaultQueryOpts, opts...)
+	return c.usecase.Aggregate(ctx, search.WithQueryOpts(opts...))
+}
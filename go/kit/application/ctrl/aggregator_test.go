@@ -0,0 +1,42 @@
+package ctrl_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/dosanma1/forge/go/kit/application/ctrl"
+	"github.com/dosanma1/forge/go/kit/application/usecase/usecasetest"
+	"github.com/dosanma1/forge/go/kit/filter"
+	"github.com/dosanma1/forge/go/kit/search"
+	"github.com/dosanma1/forge/go/kit/search/query"
+	"github.com/dosanma1/forge/go/kit/search/searchtest"
+)
+
+func TestNewAggregator(t *testing.T) {
+	ctx := context.Background()
+	defaultOpts := []query.Option{
+		query.FilterBy(filter.OpEq, "tenant", "123"),
+	}
+	opts := []query.Option{query.Aggregate(query.Count()), query.GroupBy("status")}
+	buckets := []query.Bucket{
+		{Keys: map[string]any{"status": "open"}, Metrics: map[string]any{"count": int64(3)}},
+	}
+
+	aggregatorUcase := usecasetest.NewAggregatorStub(
+		buckets, assert.AnError, usecasetest.WithStubInterceptor(
+			func(gotCtx context.Context, gotOpts ...search.Option) {
+				assert.Equal(t, gotCtx, ctx)
+				searchtest.OptsEqual(t,
+					[]search.Option{search.WithQueryOpts(append(defaultOpts, opts...)...)},
+					gotOpts,
+				)
+			},
+		),
+	)
+
+	gotRes, gotErr := ctrl.NewAggregator(aggregatorUcase, defaultOpts...).Aggregate(ctx, opts)
+	assert.Equal(t, buckets, gotRes)
+	assert.ErrorIs(t, gotErr, assert.AnError)
+}
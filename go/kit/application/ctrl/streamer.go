@@ -0,0 +1,32 @@
+package ctrl
+
+import (
+	"context"
+	"iter"
+
+	"github.com/dosanma1/forge/go/kit/application/usecase"
+	"github.com/dosanma1/forge/go/kit/resource"
+	"github.com/dosanma1/forge/go/kit/search"
+	"github.com/dosanma1/forge/go/kit/search/query"
+)
+
+type Streamer[R resource.Resource] interface {
+	Stream(ctx context.Context, opts []query.Option) iter.Seq2[R, error]
+}
+
+type streamer[R resource.Resource] struct {
+	usecase          usecase.Streamer[R]
+	defaultQueryOpts []query.Option
+}
+
+func NewStreamer[R resource.Resource](uc usecase.Streamer[R], defaultQueryOpts ...query.Option) *streamer[R] {
+	return &streamer[R]{
+		usecase:          uc,
+		defaultQueryOpts: defaultQueryOpts,
+	}
+}
+
+func (c *streamer[R]) Stream(ctx context.Context, opts []query.Option) iter.Seq2[R, error] {
+	opts = append(c.defaultQueryOpts, opts...)
+	return c.usecase.Stream(ctx, search.WithQueryOpts(opts...))
+}
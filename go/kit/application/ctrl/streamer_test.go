@@ -0,0 +1,49 @@
+package ctrl_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/dosanma1/forge/go/kit/application/ctrl"
+	"github.com/dosanma1/forge/go/kit/application/usecase/usecasetest"
+	"github.com/dosanma1/forge/go/kit/filter"
+	"github.com/dosanma1/forge/go/kit/resource/resourcetest"
+	"github.com/dosanma1/forge/go/kit/search"
+	"github.com/dosanma1/forge/go/kit/search/query"
+	"github.com/dosanma1/forge/go/kit/search/searchtest"
+)
+
+func TestNewStreamer(t *testing.T) {
+	ctx := context.Background()
+	defaultOpts := []query.Option{
+		query.FilterBy(filter.OpEq, "tenant", "123"),
+	}
+	opts := []query.Option{query.SortBy("created_at", query.SortDesc)}
+	resources := []*resourcetest.ResourceStub{resourcetest.NewStub(), resourcetest.NewStub()}
+
+	streamerUcase := usecasetest.NewStreamerStub(
+		resources, assert.AnError, usecasetest.WithStubInterceptor(
+			func(gotCtx context.Context, gotOpts ...search.Option) {
+				assert.Equal(t, gotCtx, ctx)
+				searchtest.OptsEqual(t,
+					[]search.Option{search.WithQueryOpts(append(defaultOpts, opts...)...)},
+					gotOpts,
+				)
+			},
+		),
+	)
+
+	var got []*resourcetest.ResourceStub
+	var gotErr error
+	for res, err := range ctrl.NewStreamer(streamerUcase, defaultOpts...).Stream(ctx, opts) {
+		if err != nil {
+			gotErr = err
+			break
+		}
+		got = append(got, res)
+	}
+	assert.Equal(t, resources, got)
+	assert.ErrorIs(t, gotErr, assert.AnError)
+}
@@ -1,18 +1,54 @@
 package repository
 
-import "context"
+import (
+	"context"
+	"time"
+)
 
 type LockLevel string
 
 const (
+	// LockLevelRow locks the rows read by the query, e.g. SELECT ... FOR UPDATE.
 	LockLevelRow LockLevel = "ROW"
+	// LockLevelTable locks the whole table read by the query until the end of
+	// the transaction, e.g. LOCK TABLE ... IN SHARE MODE.
+	LockLevelTable LockLevel = "TABLE"
+	// LockLevelAdvisory takes the application defined advisory lock of the
+	// lock key, see WithLockKey, until the end of the transaction.
+	LockLevelAdvisory LockLevel = "ADVISORY"
 )
 
 type LockMode string
 
 const (
+	// Row, table and advisory lock modes.
 	LockModeExclusive LockMode = "EXCLUSIVE"
 	LockModeShare     LockMode = "SHARE"
+
+	// Row lock modes.
+	LockModeNoKeyUpdate LockMode = "NO KEY UPDATE"
+	LockModeKeyShare    LockMode = "KEY SHARE"
+
+	// Table lock modes.
+	LockModeAccessShare          LockMode = "ACCESS SHARE"
+	LockModeRowShare             LockMode = "ROW SHARE"
+	LockModeRowExclusive         LockMode = "ROW EXCLUSIVE"
+	LockModeShareUpdateExclusive LockMode = "SHARE UPDATE EXCLUSIVE"
+	LockModeShareRowExclusive    LockMode = "SHARE ROW EXCLUSIVE"
+	LockModeAccessExclusive      LockMode = "ACCESS EXCLUSIVE"
+)
+
+// LockWait is what a query does when the lock it requests is held.
+type LockWait string
+
+const (
+	// LockWaitBlock waits for the lock, up to the lock timeout if any.
+	LockWaitBlock LockWait = ""
+	// LockWaitNoWait fails right away with a conflict error.
+	LockWaitNoWait LockWait = "NOWAIT"
+	// LockWaitSkipLocked skips the locked rows, e.g. to consume a work queue
+	// from several workers.
+	LockWaitSkipLocked LockWait = "SKIP LOCKED"
 )
 
 // Lock defines the interface for locking mechanisms.
@@ -22,9 +58,93 @@ type Lock interface {
 	Contains(mode LockMode) bool
 }
 
+// LockPolicy is optionally implemented by the locks that do not simply wait
+// for the lock, e.g. the locks of NewLock. See LockWaitOf, LockTimeoutOf and
+// LockKeyOf.
+type LockPolicy interface {
+	// Wait returns the wait policy of the lock.
+	Wait() LockWait
+	// Timeout returns how long to wait for the lock before failing with a
+	// conflict error, 0 to wait as long as the database does.
+	Timeout() time.Duration
+	// Key returns the key of an advisory lock.
+	Key() int64
+}
+
+// LockWaitOf returns the wait policy of l, LockWaitBlock when l has no
+// LockPolicy.
+func LockWaitOf(l Lock) LockWait {
+	if p, ok := l.(LockPolicy); ok {
+		return p.Wait()
+	}
+	return LockWaitBlock
+}
+
+// LockTimeoutOf returns the lock timeout of l, 0 when l has no LockPolicy.
+func LockTimeoutOf(l Lock) time.Duration {
+	if p, ok := l.(LockPolicy); ok {
+		return p.Timeout()
+	}
+	return 0
+}
+
+// LockKeyOf returns the advisory lock key of l, 0 when l has no LockPolicy.
+func LockKeyOf(l Lock) int64 {
+	if p, ok := l.(LockPolicy); ok {
+		return p.Key()
+	}
+	return 0
+}
+
+// LockOption configures a Lock.
+type LockOption func(l *lock)
+
+// NoWait fails with a conflict error instead of waiting for the lock.
+func NoWait() LockOption {
+	return func(l *lock) {
+		l.wait = LockWaitNoWait
+	}
+}
+
+// SkipLocked skips the locked rows instead of waiting for them.
+func SkipLocked() LockOption {
+	return func(l *lock) {
+		l.wait = LockWaitSkipLocked
+	}
+}
+
+// WithLockTimeout fails with a conflict error when the lock is not granted
+// within d. The timeout only applies to the locked query, not to the rest of
+// its transaction.
+func WithLockTimeout(d time.Duration) LockOption {
+	return func(l *lock) {
+		l.timeout = d
+	}
+}
+
+// WithLockKey sets the key of an advisory lock.
+func WithLockKey(key int64) LockOption {
+	return func(l *lock) {
+		l.key = key
+	}
+}
+
 type lock struct {
-	lvl   LockLevel
-	modes []LockMode
+	lvl     LockLevel
+	modes   []LockMode
+	wait    LockWait
+	timeout time.Duration
+	key     int64
+}
+
+// NewLock returns a lock of the level in the modes.
+func NewLock(lockLevel LockLevel, lockModes []LockMode, opts ...LockOption) Lock {
+	l := &lock{lvl: lockLevel, modes: lockModes}
+	for _, opt := range opts {
+		opt(l)
+	}
+
+	return l
 }
 
 func (l *lock) Modes() []LockMode {
@@ -44,6 +164,18 @@ func (l *lock) Contains(mode LockMode) bool {
 	return false
 }
 
+func (l *lock) Wait() LockWait {
+	return l.wait
+}
+
+func (l *lock) Timeout() time.Duration {
+	return l.timeout
+}
+
+func (l *lock) Key() int64 {
+	return l.key
+}
+
 // contextKeyType is a type for context key related to locking.
 type contextKeyType int
 
@@ -51,7 +183,16 @@ const lockCtxKey contextKeyType = iota
 
 // WithLockingCtx sets the lock context with the provided lock level and modes.
 func WithLockingCtx(ctx context.Context, lockLevel LockLevel, lockModes ...LockMode) context.Context {
-	return context.WithValue(ctx, lockCtxKey, &lock{lvl: lockLevel, modes: lockModes})
+	return WithLock(ctx, NewLock(lockLevel, lockModes))
+}
+
+// WithLock sets the lock of the context, e.g.:
+//
+//	ctx = repository.WithLock(ctx, repository.NewLock(
+//		repository.LockLevelRow, []repository.LockMode{repository.LockModeExclusive}, repository.SkipLocked(),
+//	))
+func WithLock(ctx context.Context, l Lock) context.Context {
+	return context.WithValue(ctx, lockCtxKey, l)
 }
 
 // LockFromCtx retrieves the lock from the context.
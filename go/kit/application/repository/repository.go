@@ -2,9 +2,11 @@ package repository
 
 import (
 	"context"
+	"iter"
 
 	"github.com/dosanma1/forge/go/kit/resource"
 	"github.com/dosanma1/forge/go/kit/search"
+	"github.com/dosanma1/forge/go/kit/search/query"
 )
 
 type Creator[R resource.Resource] interface {
@@ -23,6 +25,18 @@ type Lister[R resource.Resource] interface {
 	List(ctx context.Context, opts ...search.Option) (resource.ListResponse[R], error)
 }
 
+// Streamer yields the resources matching the search options one at a time,
+// without materializing the whole result. Iteration stops at the first error.
+type Streamer[R resource.Resource] interface {
+	Stream(ctx context.Context, opts ...search.Option) iter.Seq2[R, error]
+}
+
+// Aggregator computes the buckets of the aggregation carried by the query of
+// the search options.
+type Aggregator interface {
+	Aggregate(ctx context.Context, opts ...search.Option) ([]query.Bucket, error)
+}
+
 type Updater[R resource.Resource] interface {
 	Update(context.Context, R) (R, error)
 }
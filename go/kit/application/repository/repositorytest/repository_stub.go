@@ -3,9 +3,11 @@ package repositorytest
 
 import (
 	"context"
+	"iter"
 
 	"github.com/dosanma1/forge/go/kit/resource"
 	"github.com/dosanma1/forge/go/kit/search"
+	"github.com/dosanma1/forge/go/kit/search/query"
 )
 
 type StubOption func(c *stubConfig)
@@ -74,3 +76,64 @@ func NewListerStub[R resource.Resource](res resource.ListResponse[R], err error,
 		res: res,
 	}
 }
+
+type AggregatorStub struct {
+	res []query.Bucket
+	baseStub
+}
+
+func (as *AggregatorStub) Aggregate(ctx context.Context, opts ...search.Option) ([]query.Bucket, error) {
+	if as.config.paramsInterceptor != nil {
+		as.config.paramsInterceptor(ctx, opts...)
+	}
+	return as.res, as.err
+}
+
+func NewAggregatorStub(res []query.Bucket, err error, opts ...StubOption) *AggregatorStub {
+	c := new(stubConfig)
+	for _, opt := range opts {
+		opt(c)
+	}
+	return &AggregatorStub{
+		baseStub: baseStub{
+			config: c, err: err,
+		},
+		res: res,
+	}
+}
+
+// StreamerStub yields its resources and then its error, if any.
+type StreamerStub[R resource.Resource] struct {
+	res []R
+	baseStub
+}
+
+func (ss *StreamerStub[R]) Stream(ctx context.Context, opts ...search.Option) iter.Seq2[R, error] {
+	if ss.config.paramsInterceptor != nil {
+		ss.config.paramsInterceptor(ctx, opts...)
+	}
+	return func(yield func(R, error) bool) {
+		for _, r := range ss.res {
+			if !yield(r, nil) {
+				return
+			}
+		}
+		if ss.err != nil {
+			var zero R
+			yield(zero, ss.err)
+		}
+	}
+}
+
+func NewStreamerStub[R resource.Resource](res []R, err error, opts ...StubOption) *StreamerStub[R] {
+	c := new(stubConfig)
+	for _, opt := range opts {
+		opt(c)
+	}
+	return &StreamerStub[R]{
+		baseStub: baseStub{
+			config: c, err: err,
+		},
+		res: res,
+	}
+}
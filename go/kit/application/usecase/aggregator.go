@@ -0,0 +1,35 @@
+package usecase
+
+import (
+	"context"
+
+	"github.com/dosanma1/forge/go/kit/application/repository"
+	"github.com/dosanma1/forge/go/kit/search"
+	"github.com/dosanma1/forge/go/kit/search/query"
+)
+
+type Aggregator interface {
+	Aggregate(ctx context.Context, opts ...search.Option) ([]query.Bucket, error)
+}
+
+type aggregator struct {
+	repo        repository.Aggregator
+	defaultOpts []search.Option
+}
+
+func NewAggregator(repo repository.Aggregator, defaultOpts ...search.Option) *aggregator {
+	return &aggregator{
+		repo:        repo,
+		defaultOpts: defaultOpts,
+	}
+}
+
+func (c *aggregator) Aggregate(ctx context.Context, opts ...search.Option) ([]query.Bucket, error) {
+	opts = append(c.defaultOpts, opts...)
+	res, err := c.repo.Aggregate(ctx, opts...)
+	if err != nil || res == nil {
+		return []query.Bucket{}, err
+	}
+
+	return res, nil
+}
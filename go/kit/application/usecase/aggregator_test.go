@@ -0,0 +1,65 @@
+package usecase_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/dosanma1/forge/go/kit/application/repository/repositorytest"
+	"github.com/dosanma1/forge/go/kit/application/usecase"
+	"github.com/dosanma1/forge/go/kit/search"
+	"github.com/dosanma1/forge/go/kit/search/query"
+	"github.com/dosanma1/forge/go/kit/search/searchtest"
+)
+
+func TestAggregatorAggregate(t *testing.T) {
+	ctx := context.Background()
+	inOpts := searchtest.AnyOpts()
+	defaultOpts := searchtest.AnyOpts()
+
+	buckets := []query.Bucket{
+		{Keys: map[string]any{"status": "open"}, Metrics: map[string]any{"count": int64(3)}},
+	}
+
+	tests := []struct {
+		name      string
+		inRepoRes []query.Bucket
+		inRepoErr error
+		want      []query.Bucket
+		wantErr   error
+	}{
+		{
+			name:      "repo returning error",
+			inRepoErr: assert.AnError,
+			want:      []query.Bucket{},
+			wantErr:   assert.AnError,
+		},
+		{
+			name: "repo returning nil buckets",
+			want: []query.Bucket{},
+		},
+		{
+			name:      "repo returning buckets",
+			inRepoRes: buckets,
+			want:      buckets,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			aggregatorRepo := repositorytest.NewAggregatorStub(
+				test.inRepoRes, test.inRepoErr, repositorytest.WithStubInterceptor(
+					func(gotCtx context.Context, gotOpts ...search.Option) {
+						assert.Equal(t, gotCtx, ctx)
+						searchtest.OptsEqual(t, gotOpts, append(defaultOpts, inOpts...))
+					},
+				),
+			)
+
+			gotRes, gotErr := usecase.NewAggregator(aggregatorRepo, defaultOpts...).Aggregate(ctx, inOpts...)
+			assert.Equal(t, test.want, gotRes)
+			assert.ErrorIs(t, gotErr, test.wantErr)
+		})
+	}
+}
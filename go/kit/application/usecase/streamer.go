@@ -0,0 +1,31 @@
+package usecase
+
+import (
+	"context"
+	"iter"
+
+	"github.com/dosanma1/forge/go/kit/application/repository"
+	"github.com/dosanma1/forge/go/kit/resource"
+	"github.com/dosanma1/forge/go/kit/search"
+)
+
+type Streamer[R resource.Resource] interface {
+	Stream(ctx context.Context, opts ...search.Option) iter.Seq2[R, error]
+}
+
+type streamer[R resource.Resource] struct {
+	repo        repository.Streamer[R]
+	defaultOpts []search.Option
+}
+
+func NewStreamer[R resource.Resource](repo repository.Streamer[R], defaultOpts ...search.Option) *streamer[R] {
+	return &streamer[R]{
+		repo:        repo,
+		defaultOpts: defaultOpts,
+	}
+}
+
+func (c *streamer[R]) Stream(ctx context.Context, opts ...search.Option) iter.Seq2[R, error] {
+	opts = append(c.defaultOpts, opts...)
+	return c.repo.Stream(ctx, opts...)
+}
@@ -0,0 +1,73 @@
+package usecase_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/dosanma1/forge/go/kit/application/repository/repositorytest"
+	"github.com/dosanma1/forge/go/kit/application/usecase"
+	"github.com/dosanma1/forge/go/kit/resource/resourcetest"
+	"github.com/dosanma1/forge/go/kit/search"
+	"github.com/dosanma1/forge/go/kit/search/searchtest"
+)
+
+func TestStreamerStream(t *testing.T) {
+	ctx := context.Background()
+	inOpts := searchtest.AnyOpts()
+	defaultOpts := searchtest.AnyOpts()
+	resources := []*resourcetest.ResourceStub{resourcetest.NewStub(), resourcetest.NewStub()}
+
+	tests := []struct {
+		name      string
+		inRepoErr error
+		stopAfter int
+		want      []*resourcetest.ResourceStub
+		wantErr   error
+	}{
+		{
+			name: "repo yielding every resource",
+			want: resources,
+		},
+		{
+			name:      "repo failing after the resources",
+			inRepoErr: assert.AnError,
+			want:      resources,
+			wantErr:   assert.AnError,
+		},
+		{
+			name:      "caller stopping early",
+			stopAfter: 1,
+			want:      resources[:1],
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			streamerRepo := repositorytest.NewStreamerStub(
+				resources, test.inRepoErr, repositorytest.WithStubInterceptor(
+					func(gotCtx context.Context, gotOpts ...search.Option) {
+						assert.Equal(t, gotCtx, ctx)
+						searchtest.OptsEqual(t, gotOpts, append(defaultOpts, inOpts...))
+					},
+				),
+			)
+
+			var got []*resourcetest.ResourceStub
+			var gotErr error
+			for res, err := range usecase.NewStreamer(streamerRepo, defaultOpts...).Stream(ctx, inOpts...) {
+				if err != nil {
+					gotErr = err
+					break
+				}
+				got = append(got, res)
+				if test.stopAfter > 0 && len(got) == test.stopAfter {
+					break
+				}
+			}
+			assert.Equal(t, test.want, got)
+			assert.ErrorIs(t, gotErr, test.wantErr)
+		})
+	}
+}
@@ -18,10 +18,27 @@ const (
 	OpContainsLike
 	OpIs
 	OpIsNot
+	// OpSearch is a full-text match of the value, a web search like query
+	// such as `"exact phrase" -excluded`.
+	OpSearch
+	// OpFuzzy is a trigram similarity match tolerant to typos.
+	OpFuzzy
+	// OpJSONContains matches JSON documents containing the JSON value, e.g.
+	// {"tags":["a"]}.
+	OpJSONContains
+	// OpHasKey matches JSON objects having the key.
+	OpHasKey
+	// OpHasAnyKeys matches JSON objects having any of the keys.
+	OpHasAnyKeys
+	// OpHasAllKeys matches JSON objects having all the keys.
+	OpHasAllKeys
+	// OpJSONPath matches JSON documents for which the JSON path, e.g.
+	// $.items[*] ? (@.qty > 2), returns any item.
+	OpJSONPath
 )
 
 func (op Operator) Valid() bool {
-	return op != OpUndefined && op <= OpIsNot
+	return op != OpUndefined && op <= OpJSONPath
 }
 
 func (op Operator) String() string {
@@ -54,6 +71,20 @@ func (op Operator) String() string {
 		return "IS"
 	case OpIsNot:
 		return "IS NOT"
+	case OpSearch:
+		return "@@"
+	case OpFuzzy:
+		return "%"
+	case OpJSONContains:
+		return "@>"
+	case OpHasKey:
+		return "?"
+	case OpHasAnyKeys:
+		return "?|"
+	case OpHasAllKeys:
+		return "?&"
+	case OpJSONPath:
+		return "@?"
 	case OpUndefined:
 		return ""
 	default:
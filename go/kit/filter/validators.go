@@ -1,8 +1,12 @@
 package filter
 
 import (
+	"encoding/json"
 	"fmt"
+	"slices"
 	"strconv"
+	"strings"
+	"unicode/utf8"
 
 	"github.com/dosanma1/forge/go/kit/errors"
 	"github.com/google/uuid"
@@ -151,3 +155,42 @@ func ValidateIntegerString(f FieldFilter[any]) error {
 	}
 	return nil
 }
+
+// ValidateSearchText returns a validator for search and fuzzy filters
+// accepting non blank texts of at most maxLen characters.
+func ValidateSearchText(maxLen int) func(FieldFilter[any]) error {
+	return func(f FieldFilter[any]) error {
+		s, ok := f.Value().(string)
+		if !ok || strings.TrimSpace(s) == "" {
+			return errors.InvalidArgument(fmt.Sprintf("search text for field %s cannot be empty", f.Name()))
+		}
+		if utf8.RuneCountInString(s) > maxLen {
+			return errors.InvalidArgument(fmt.Sprintf("search text for field %s exceeds %d characters", f.Name(), maxLen))
+		}
+		return nil
+	}
+}
+
+// ValidateJSONOperand checks the value of the JSON operators: a valid JSON
+// document for OpJSONContains, non empty keys for the key existence
+// operators and a non empty path for OpJSONPath. Other operators pass.
+func ValidateJSONOperand(f FieldFilter[any]) error {
+	switch f.Operator() {
+	case OpJSONContains:
+		s, ok := f.Value().(string)
+		if !ok || !json.Valid([]byte(s)) {
+			return errors.InvalidArgument(fmt.Sprintf("invalid JSON value for field %s", f.Name()))
+		}
+	case OpHasKey, OpJSONPath:
+		s, ok := f.Value().(string)
+		if !ok || s == "" {
+			return errors.InvalidArgument(fmt.Sprintf("invalid %s value for field %s", f.Operator(), f.Name()))
+		}
+	case OpHasAnyKeys, OpHasAllKeys:
+		keys, ok := f.Value().([]string)
+		if !ok || len(keys) == 0 || slices.Contains(keys, "") {
+			return errors.InvalidArgument(fmt.Sprintf("invalid keys for field %s", f.Name()))
+		}
+	}
+	return nil
+}
@@ -20,6 +20,7 @@ var filenamePattern = regexp.MustCompile(`^(\d+)_.*\.sql$`)
 type loader struct {
 	db     *sqldb.DBClient
 	logger logger.Logger
+	driver sqldb.DriverType
 }
 
 // option configures a loader.
@@ -32,10 +33,19 @@ func WithLogger(log logger.Logger) option {
 	}
 }
 
+// WithDriver sets the database driver Load connects to. Defaults to
+// sqldb.DriverTypePostgres.
+func WithDriver(driver sqldb.DriverType) option {
+	return func(l *loader) {
+		l.driver = driver
+	}
+}
+
 // defaultOptions returns the default options for a loader.
 func defaultOptions() []option {
 	return []option{
 		WithLogger(logger.New()),
+		WithDriver(sqldb.DriverTypePostgres),
 	}
 }
 
@@ -104,9 +114,14 @@ func (l *loader) Load(ctx context.Context, fixturesFS fs.FS) error {
 }
 
 // Load is a convenience function that creates DB from environment and loads fixtures.
-func Load(ctx context.Context, fixturesFS fs.FS) error {
+func Load(ctx context.Context, fixturesFS fs.FS, opts ...option) error {
+	cfg := new(loader)
+	for _, opt := range append(defaultOptions(), opts...) {
+		opt(cfg)
+	}
+
 	// Create DB from environment variables
-	dsn, err := sqldb.NewDSN(sqldb.DriverTypePostgres)
+	dsn, err := sqldb.NewDSN(cfg.driver)
 	if err != nil {
 		return fmt.Errorf("failed to generate DSN: %w", err)
 	}
@@ -120,7 +135,7 @@ func Load(ctx context.Context, fixturesFS fs.FS) error {
 	sqldb.ConfigureDefaultPool(db)
 	dbClient := sqldb.NewDBClient(db)
 
-	loader, err := New(dbClient)
+	loader, err := New(dbClient, opts...)
 	if err != nil {
 		return err
 	}
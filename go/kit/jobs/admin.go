@@ -0,0 +1,106 @@
+package jobs
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+
+	"github.com/dosanma1/forge/go/kit/application/repository"
+	"github.com/dosanma1/forge/go/kit/filter"
+	"github.com/dosanma1/forge/go/kit/persistence/sqldb"
+	"github.com/dosanma1/forge/go/kit/resource"
+	"github.com/dosanma1/forge/go/kit/search"
+	"github.com/dosanma1/forge/go/kit/search/query"
+)
+
+var (
+	_ repository.Lister[Job] = (*Client)(nil)
+	_ repository.Getter[Job] = (*Client)(nil)
+)
+
+//nolint:gochecknoglobals // maps the query fields of the job listings to their columns
+var fieldMapper = map[string]string{
+	"maxAttempts": "max_attempts",
+	"uniqueKey":   "unique_key",
+	"lastError":   "last_error",
+	"runAt":       "run_at",
+	"attemptedAt": "attempted_at",
+	"finalizedAt": "finalized_at",
+	"createdAt":   "created_at",
+	"updatedAt":   "updated_at",
+}
+
+// List lists the jobs matching the search options, e.g. the failed jobs of
+// a kind:
+//
+//	client.List(ctx, search.WithQueryOpts(
+//		query.FilterBy(filter.OpEq, "state", jobs.StateFailed),
+//		query.FilterBy(filter.OpEq, "kind", "send_email"),
+//		query.SortBy("finalizedAt", query.SortDesc),
+//	))
+func (c *Client) List(ctx context.Context, opts ...search.Option) (resource.ListResponse[Job], error) {
+	return c.repo.List(ctx, opts...)
+}
+
+// Get returns the first job matching the search options.
+func (c *Client) Get(ctx context.Context, opts ...search.Option) (Job, error) {
+	return c.repo.Get(ctx, opts...)
+}
+
+// Retry makes a failed or cancelled job available again right away, with
+// its attempts reset.
+func (c *Client) Retry(ctx context.Context, id string) (Job, error) {
+	return c.transition(ctx, id, "retry", []State{StateFailed, StateCancelled}, func(s *stmt) {
+		fmt.Fprintf(s, "state = %s, attempt = 0, run_at = %s, finalized_at = NULL",
+			s.arg(string(StateAvailable)), s.arg(c.timestamp()))
+	})
+}
+
+// Cancel cancels an available job so that it never runs.
+func (c *Client) Cancel(ctx context.Context, id string) (Job, error) {
+	return c.transition(ctx, id, "cancel", []State{StateAvailable}, func(s *stmt) {
+		fmt.Fprintf(s, "state = %s, finalized_at = %s", s.arg(string(StateCancelled)), s.arg(c.timestamp()))
+	})
+}
+
+// transition updates the job of the id with set when it is in one of the
+// states, failing with a conflict error otherwise or when the job would
+// duplicate the unique key of another available or running job.
+func (c *Client) transition(
+	ctx context.Context, id, action string, from []State, set func(s *stmt),
+) (Job, error) {
+	pk, err := strconv.ParseInt(id, 10, 64)
+	if err != nil {
+		return nil, newErrNotFound(id)
+	}
+	j, err := c.Get(ctx, search.WithQueryOpts(query.FilterBy(filter.OpEq, "id", pk)))
+	if err != nil {
+		return nil, err
+	}
+
+	s := c.stmt()
+	fmt.Fprintf(s, "UPDATE %s SET ", c.quote(c.table))
+	set(s)
+	fmt.Fprintf(s, ", updated_at = %s WHERE id = %s AND state IN (", s.arg(c.timestamp()), s.arg(pk))
+	for i, state := range from {
+		if i > 0 {
+			s.WriteString(", ")
+		}
+		s.WriteString(s.arg(string(state)))
+	}
+	fmt.Fprintf(s, ") RETURNING %s", c.quoteAll(c.columns))
+
+	models, err := c.query(ctx, c.querier(ctx), s)
+	if sqldb.IsUniqueViolation(err) {
+		// another available or running job holds the unique key of j
+		return nil, newErrState(j, action)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if len(models) == 0 {
+		return nil, newErrState(j, action)
+	}
+
+	return toJob(&models[0]), nil
+}
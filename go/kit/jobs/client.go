@@ -0,0 +1,459 @@
+package jobs
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/dosanma1/forge/go/kit/application/repository"
+	"github.com/dosanma1/forge/go/kit/errors"
+	"github.com/dosanma1/forge/go/kit/filter"
+	"github.com/dosanma1/forge/go/kit/persistence/gormdb"
+	"github.com/dosanma1/forge/go/kit/persistence/sqldb"
+	"github.com/dosanma1/forge/go/kit/search/query"
+)
+
+const (
+	// DefaultQueue is the queue of the jobs enqueued without WithQueue.
+	DefaultQueue = "default"
+
+	defaultTable       = "jobs"
+	defaultMaxAttempts = 10
+)
+
+type (
+	// Option configures a Client.
+	Option func(c *config)
+	config struct {
+		table   string
+		dialect sqldb.Dialect
+		querier func(ctx context.Context) sqldb.Querier
+		now     func() time.Time
+	}
+)
+
+// WithTable sets the table of the jobs. Defaults to jobs.
+func WithTable(table string) Option {
+	return func(c *config) {
+		c.table = table
+	}
+}
+
+// WithDialect sets the SQL dialect of the database. Defaults to
+// sqldb.DialectPostgres.
+func WithDialect(d sqldb.Dialect) Option {
+	return func(c *config) {
+		c.dialect = d
+	}
+}
+
+// WithGormDB enqueues the jobs within the gormdb transaction of the context,
+// for the services using the gormdb transactioner. The jobs are enqueued
+// within the sqldb transaction of the context otherwise.
+func WithGormDB(cli *gormdb.DBClient) Option {
+	return func(c *config) {
+		c.querier = func(ctx context.Context) sqldb.Querier {
+			return cli.WithContext(ctx).Statement.ConnPool
+		}
+	}
+}
+
+// WithNowFunc sets the clock of the queue, e.g. to test scheduled jobs.
+// Defaults to time.Now.
+func WithNowFunc(now func() time.Time) Option {
+	return func(c *config) {
+		c.now = now
+	}
+}
+
+type (
+	// EnqueueOption configures an enqueued job.
+	EnqueueOption func(c *enqueueConfig)
+	enqueueConfig struct {
+		queue       string
+		priority    int
+		maxAttempts int
+		uniqueKey   string
+		runAt       time.Time
+		delay       time.Duration
+	}
+)
+
+// WithQueue sets the queue of the job, see WithQueues. Defaults to
+// DefaultQueue.
+func WithQueue(queue string) EnqueueOption {
+	return func(c *enqueueConfig) {
+		c.queue = queue
+	}
+}
+
+// WithPriority sets the priority of the job, the jobs of higher priority
+// running first. Defaults to 0.
+func WithPriority(priority int) EnqueueOption {
+	return func(c *enqueueConfig) {
+		c.priority = priority
+	}
+}
+
+// WithMaxAttempts sets how many times the job is worked before it fails for
+// good. Defaults to 10.
+func WithMaxAttempts(attempts int) EnqueueOption {
+	return func(c *enqueueConfig) {
+		c.maxAttempts = attempts
+	}
+}
+
+// WithUniqueKey makes the job unique among the available and running jobs
+// of its kind with the same key: enqueuing a duplicate returns the existing
+// job instead.
+func WithUniqueKey(key string) EnqueueOption {
+	return func(c *enqueueConfig) {
+		c.uniqueKey = key
+	}
+}
+
+// WithRunAt schedules the job to run from t on.
+func WithRunAt(t time.Time) EnqueueOption {
+	return func(c *enqueueConfig) {
+		c.runAt = t
+	}
+}
+
+// WithDelay schedules the job to run once d has elapsed.
+func WithDelay(d time.Duration) EnqueueOption {
+	return func(c *enqueueConfig) {
+		c.delay = d
+	}
+}
+
+// Client enqueues jobs and administrates them. It implements
+// repository.Lister and repository.Getter of the jobs.
+type Client struct {
+	db      *sql.DB
+	builder *sqldb.Builder
+	repo    *sqldb.CRUDRepo[model, Job]
+	columns []string
+	config
+}
+
+// NewClient returns a client of the jobs stored in db, see Schema.
+func NewClient(db *sql.DB, opts ...Option) (*Client, error) {
+	if db == nil {
+		return nil, sqldb.NewErrEmptyDBConnection()
+	}
+
+	cfg := config{
+		table:   defaultTable,
+		dialect: sqldb.DialectPostgres,
+		now:     time.Now,
+	}
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+	if cfg.dialect != sqldb.DialectPostgres && cfg.dialect != sqldb.DialectSQLite {
+		return nil, errors.InvalidArgument("jobs are only supported by PostgreSQL and SQLite")
+	}
+	if cfg.querier == nil {
+		cfg.querier = func(ctx context.Context) sqldb.Querier {
+			return sqldb.GetTx(ctx, db)
+		}
+	}
+
+	repo, err := sqldb.NewCRUDRepo(db, cfg.table, toModel, toJob,
+		sqldb.WithDialect(cfg.dialect),
+		sqldb.WithFieldMapper(fieldMapper),
+		sqldb.WithResourceName(string(ResourceType)),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Client{
+		db:      db,
+		builder: sqldb.NewBuilder(cfg.dialect, fieldMapper),
+		repo:    repo,
+		columns: sqldb.Columns[model](),
+		config:  cfg,
+	}, nil
+}
+
+// Enqueue enqueues a job of args within the transaction of the context
+// when there is one, so that the job only exists once the transaction
+// commits.
+func (c *Client) Enqueue(ctx context.Context, args Args, opts ...EnqueueOption) (Job, error) {
+	cfg := enqueueConfig{queue: DefaultQueue, maxAttempts: defaultMaxAttempts}
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+	if cfg.maxAttempts < 1 {
+		return nil, errors.InvalidArgument("max attempts must be positive")
+	}
+	payload, err := json.Marshal(args)
+	if err != nil {
+		return nil, errors.InvalidArgument(fmt.Sprintf("invalid %s job args: %v", args.Kind(), err))
+	}
+
+	now := c.timestamp()
+	m := model{
+		Kind:        args.Kind(),
+		Queue:       cfg.queue,
+		Args:        string(payload),
+		State:       StateAvailable,
+		Priority:    cfg.priority,
+		MaxAttempts: cfg.maxAttempts,
+		RunAt:       now.Add(cfg.delay),
+		CreatedAt:   now,
+		UpdatedAt:   now,
+	}
+	if !cfg.runAt.IsZero() {
+		m.RunAt = cfg.runAt.UTC().Truncate(time.Microsecond)
+	}
+	if cfg.uniqueKey != "" {
+		key := m.Kind + ":" + cfg.uniqueKey
+		m.UniqueKey = &key
+	}
+
+	s := c.stmt()
+	cols := c.columns[1:]
+	vals := []any{
+		m.Kind, m.Queue, m.Args, string(m.State), m.Priority, m.Attempt, m.MaxAttempts, m.UniqueKey,
+		m.LastError, m.RunAt, m.AttemptedAt, m.HeartbeatAt, m.FinalizedAt, m.CreatedAt, m.UpdatedAt,
+	}
+	fmt.Fprintf(s, "INSERT INTO %s (%s) VALUES (", c.quote(c.table), c.quoteAll(cols))
+	for i, v := range vals {
+		if i > 0 {
+			s.WriteString(", ")
+		}
+		s.WriteString(s.arg(v))
+	}
+	fmt.Fprintf(s, ") ON CONFLICT DO NOTHING RETURNING %s", c.quoteAll(c.columns))
+
+	db := c.querier(ctx)
+	models, err := c.query(ctx, db, s)
+	if err != nil {
+		return nil, err
+	}
+	if len(models) == 0 {
+		if m.UniqueKey == nil {
+			return nil, newErrQuery(sql.ErrNoRows)
+		}
+		return c.unique(ctx, db, *m.UniqueKey)
+	}
+
+	return toJob(&models[0]), nil
+}
+
+// unique returns the available or running job of the unique key.
+func (c *Client) unique(ctx context.Context, db sqldb.Querier, key string) (Job, error) {
+	stmt, args, err := c.builder.Select(ctx, c.table, c.columns, query.New(
+		query.FilterBy(filter.OpEq, "uniqueKey", key),
+		query.FilterBy(filter.OpIn, "state", []string{string(StateAvailable), string(StateRunning)}),
+	))
+	if err != nil {
+		return nil, err
+	}
+	rows, err := db.QueryContext(ctx, stmt, args...)
+	if err != nil {
+		return nil, newErrQuery(err)
+	}
+	m, err := sqldb.ScanOne[model](rows)
+	if err != nil {
+		return nil, newErrQuery(err)
+	}
+
+	return toJob(&m), nil
+}
+
+// fetch locks the available jobs of the queues and kinds, skipping the jobs
+// locked by the other workers, and marks them as running.
+func (c *Client) fetch(ctx context.Context, queues, kinds []string, limit int) ([]Job, error) {
+	if limit <= 0 || len(queues) == 0 || len(kinds) == 0 {
+		return nil, nil
+	}
+
+	tx, err := c.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, newErrQuery(err)
+	}
+	defer tx.Rollback() //nolint:errcheck // rolling back a committed transaction is a no-op
+
+	now := c.timestamp()
+	lockCtx := repository.WithLock(ctx, repository.NewLock(
+		repository.LockLevelRow, []repository.LockMode{repository.LockModeExclusive}, repository.SkipLocked(),
+	))
+	stmt, args, err := c.builder.Select(lockCtx, c.table, c.columns, query.New(
+		query.FilterBy(filter.OpEq, "state", string(StateAvailable)),
+		query.FilterBy(filter.OpIn, "queue", queues),
+		query.FilterBy(filter.OpIn, "kind", kinds),
+		query.FilterBy(filter.OpLTEq, "runAt", now),
+		query.SortBy("priority", query.SortDesc, "runAt", query.SortAsc, "id", query.SortAsc),
+		query.Pagination(limit, 0),
+	))
+	if err != nil {
+		return nil, err
+	}
+	rows, err := tx.QueryContext(ctx, stmt, args...)
+	if err != nil {
+		return nil, newErrQuery(err)
+	}
+	models, err := sqldb.ScanRows[model](rows)
+	if err != nil {
+		return nil, newErrQuery(err)
+	}
+	if len(models) == 0 {
+		return nil, nil
+	}
+
+	s := c.stmt()
+	fmt.Fprintf(s, "UPDATE %s SET state = %s, attempt = attempt + 1, attempted_at = %s, heartbeat_at = %s, updated_at = %s WHERE id IN (",
+		c.quote(c.table), s.arg(string(StateRunning)), s.arg(now), s.arg(now), s.arg(now))
+	jobs := make([]Job, len(models))
+	for i := range models {
+		if i > 0 {
+			s.WriteString(", ")
+		}
+		s.WriteString(s.arg(models[i].ID))
+
+		models[i].State = StateRunning
+		models[i].Attempt++
+		models[i].AttemptedAt = &now
+		models[i].HeartbeatAt = &now
+		models[i].UpdatedAt = now
+		jobs[i] = toJob(&models[i])
+	}
+	s.WriteString(")")
+	if _, err := tx.ExecContext(ctx, s.String(), s.args...); err != nil {
+		return nil, newErrQuery(err)
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, newErrQuery(err)
+	}
+
+	return jobs, nil
+}
+
+// complete records the outcome of the current attempt of j, rescheduling
+// it after the retry delay when it failed, or failing it for good when the
+// retry delay is negative.
+func (c *Client) complete(ctx context.Context, j Job, workErr error, retryDelay time.Duration) error {
+	now := c.timestamp()
+	s := c.stmt()
+	fmt.Fprintf(s, "UPDATE %s SET ", c.quote(c.table))
+	switch {
+	case workErr == nil:
+		fmt.Fprintf(s, "state = %s, finalized_at = %s", s.arg(string(StateCompleted)), s.arg(now))
+	case retryDelay < 0:
+		fmt.Fprintf(s, "state = %s, finalized_at = %s, last_error = %s",
+			s.arg(string(StateFailed)), s.arg(now), s.arg(workErr.Error()))
+	default:
+		fmt.Fprintf(s, "state = %s, run_at = %s, last_error = %s",
+			s.arg(string(StateAvailable)), s.arg(now.Add(retryDelay)), s.arg(workErr.Error()))
+	}
+	// the attempt guard leaves alone the jobs reclaimed meanwhile, see reclaim
+	fmt.Fprintf(s, ", heartbeat_at = NULL, updated_at = %s WHERE id = %s AND state = %s AND attempt = %s",
+		s.arg(now), s.arg(toModel(j).ID), s.arg(string(StateRunning)), s.arg(j.Attempt()))
+
+	if _, err := c.db.ExecContext(ctx, s.String(), s.args...); err != nil {
+		return newErrQuery(err)
+	}
+
+	return nil
+}
+
+// heartbeat refreshes the heartbeat of the running jobs.
+func (c *Client) heartbeat(ctx context.Context, ids []int64) error {
+	if len(ids) == 0 {
+		return nil
+	}
+
+	s := c.stmt()
+	fmt.Fprintf(s, "UPDATE %s SET heartbeat_at = %s WHERE state = %s AND id IN (",
+		c.quote(c.table), s.arg(c.timestamp()), s.arg(string(StateRunning)))
+	for i, id := range ids {
+		if i > 0 {
+			s.WriteString(", ")
+		}
+		s.WriteString(s.arg(id))
+	}
+	s.WriteString(")")
+	if _, err := c.db.ExecContext(ctx, s.String(), s.args...); err != nil {
+		return newErrQuery(err)
+	}
+
+	return nil
+}
+
+// reclaim makes the running jobs without a heartbeat for longer than
+// timeout available again, or failed when they have no attempts left, and
+// returns how many it reclaimed.
+func (c *Client) reclaim(ctx context.Context, timeout time.Duration) (int64, error) {
+	now := c.timestamp()
+	s := c.stmt()
+	fmt.Fprintf(s, "UPDATE %s SET state = CASE WHEN attempt >= max_attempts THEN %s ELSE %s END, "+
+		"finalized_at = CASE WHEN attempt >= max_attempts THEN %s ELSE NULL END, "+
+		"last_error = %s, heartbeat_at = NULL, updated_at = %s WHERE state = %s AND heartbeat_at < %s",
+		c.quote(c.table), s.arg(string(StateFailed)), s.arg(string(StateAvailable)), s.arg(now),
+		s.arg("heartbeat lost"), s.arg(now), s.arg(string(StateRunning)), s.arg(now.Add(-timeout)))
+
+	res, err := c.db.ExecContext(ctx, s.String(), s.args...)
+	if err != nil {
+		return 0, newErrQuery(err)
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return 0, newErrQuery(err)
+	}
+
+	return n, nil
+}
+
+func (c *Client) query(ctx context.Context, db sqldb.Querier, s *stmt) ([]model, error) {
+	rows, err := db.QueryContext(ctx, s.String(), s.args...)
+	if err != nil {
+		return nil, newErrQuery(err)
+	}
+	models, err := sqldb.ScanRows[model](rows)
+	if err != nil {
+		return nil, newErrQuery(err)
+	}
+
+	return models, nil
+}
+
+// timestamp returns the current time at the precision of the database.
+func (c *Client) timestamp() time.Time {
+	return c.now().UTC().Truncate(time.Microsecond)
+}
+
+func (c *Client) stmt() *stmt {
+	return &stmt{dialect: c.dialect}
+}
+
+func (c *Client) quote(ident string) string {
+	return c.dialect.Quote(ident)
+}
+
+func (c *Client) quoteAll(idents []string) string {
+	quoted := make([]string, len(idents))
+	for i, ident := range idents {
+		quoted[i] = c.quote(ident)
+	}
+
+	return strings.Join(quoted, ", ")
+}
+
+// stmt accumulates a statement and its arguments, numbering the
+// placeholders as they are written.
+type stmt struct {
+	strings.Builder
+	dialect sqldb.Dialect
+	args    []any
+}
+
+func (s *stmt) arg(v any) string {
+	s.args = append(s.args, v)
+	return s.dialect.Placeholder(len(s.args))
+}
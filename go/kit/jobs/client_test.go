@@ -0,0 +1,358 @@
+package jobs
+
+import (
+	"context"
+	"database/sql"
+	stderrors "errors"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	apierrors "github.com/dosanma1/forge/go/kit/errors"
+	"github.com/dosanma1/forge/go/kit/filter"
+	"github.com/dosanma1/forge/go/kit/persistence/sqldb"
+	"github.com/dosanma1/forge/go/kit/search"
+	"github.com/dosanma1/forge/go/kit/search/query"
+)
+
+type sendEmail struct {
+	To string `json:"to"`
+}
+
+func (sendEmail) Kind() string { return "send_email" }
+
+type resizeImage struct {
+	URL string `json:"url"`
+}
+
+func (resizeImage) Kind() string { return "resize_image" }
+
+type clock struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+func (c *clock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *clock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+}
+
+// sqliteDB opens a sqlite database holding the jobs table.
+func sqliteDB(t *testing.T) *sql.DB {
+	t.Helper()
+
+	db, err := sqldb.Connect(sqldb.MustGenerateDSN(
+		sqldb.DriverTypeSQLite, sqldb.WithConnDBName(filepath.Join(t.TempDir(), "jobs.db")),
+	))
+	require.NoError(t, err)
+	t.Cleanup(func() { db.Close() })
+	db.SetMaxOpenConns(1)
+
+	schema, err := Schema(sqldb.DialectSQLite, defaultTable)
+	require.NoError(t, err)
+	_, err = db.Exec(schema)
+	require.NoError(t, err)
+
+	return db
+}
+
+func newTestClient(t *testing.T) (*Client, *clock) {
+	t.Helper()
+
+	clk := &clock{now: time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC)}
+	c, err := NewClient(sqliteDB(t), WithDialect(sqldb.DialectSQLite), WithNowFunc(clk.Now))
+	require.NoError(t, err)
+
+	return c, clk
+}
+
+func assertCode(t *testing.T, err error, code apierrors.Code) {
+	t.Helper()
+
+	var apiErr apierrors.Error
+	require.ErrorAs(t, err, &apiErr)
+	assert.Equal(t, code, apiErr.Code())
+}
+
+func TestSchema(t *testing.T) {
+	schema, err := Schema(sqldb.DialectPostgres, "background_jobs")
+	require.NoError(t, err)
+	assert.Contains(t, schema, "CREATE TABLE IF NOT EXISTS background_jobs (")
+	assert.Contains(t, schema, "ON background_jobs (unique_key)")
+
+	_, err = Schema(sqldb.DialectMySQL, "jobs")
+	assertCode(t, err, apierrors.CodeInvalidArgument)
+}
+
+func TestNewClientDialect(t *testing.T) {
+	_, err := NewClient(sqliteDB(t), WithDialect(sqldb.DialectMySQL))
+	assertCode(t, err, apierrors.CodeInvalidArgument)
+}
+
+func TestClientEnqueue(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("defaults", func(t *testing.T) {
+		c, clk := newTestClient(t)
+
+		j, err := c.Enqueue(ctx, sendEmail{To: "john@doe.com"})
+		require.NoError(t, err)
+		assert.NotEmpty(t, j.ID())
+		assert.Equal(t, "send_email", j.Kind())
+		assert.Equal(t, DefaultQueue, j.Queue())
+		assert.JSONEq(t, `{"to":"john@doe.com"}`, string(j.Args()))
+		assert.Equal(t, StateAvailable, j.State())
+		assert.Equal(t, 0, j.Attempt())
+		assert.Equal(t, defaultMaxAttempts, j.MaxAttempts())
+		assert.True(t, clk.Now().Equal(j.RunAt()))
+		assert.Equal(t, ResourceType, j.Type())
+	})
+
+	t.Run("options", func(t *testing.T) {
+		c, clk := newTestClient(t)
+
+		j, err := c.Enqueue(ctx, sendEmail{}, WithQueue("mail"), WithPriority(5), WithMaxAttempts(3), WithDelay(time.Minute))
+		require.NoError(t, err)
+		assert.Equal(t, "mail", j.Queue())
+		assert.Equal(t, 5, j.Priority())
+		assert.Equal(t, 3, j.MaxAttempts())
+		assert.True(t, clk.Now().Add(time.Minute).Equal(j.RunAt()))
+
+		runAt := clk.Now().Add(time.Hour)
+		j, err = c.Enqueue(ctx, sendEmail{}, WithRunAt(runAt))
+		require.NoError(t, err)
+		assert.True(t, runAt.Equal(j.RunAt()))
+
+		_, err = c.Enqueue(ctx, sendEmail{}, WithMaxAttempts(0))
+		assertCode(t, err, apierrors.CodeInvalidArgument)
+	})
+
+	t.Run("unique jobs", func(t *testing.T) {
+		c, _ := newTestClient(t)
+
+		first, err := c.Enqueue(ctx, sendEmail{To: "a"}, WithUniqueKey("welcome:1"))
+		require.NoError(t, err)
+		assert.Equal(t, "send_email:welcome:1", first.UniqueKey())
+
+		dup, err := c.Enqueue(ctx, sendEmail{To: "b"}, WithUniqueKey("welcome:1"))
+		require.NoError(t, err)
+		assert.Equal(t, first.ID(), dup.ID())
+
+		other, err := c.Enqueue(ctx, resizeImage{}, WithUniqueKey("welcome:1"))
+		require.NoError(t, err)
+		assert.NotEqual(t, first.ID(), other.ID())
+
+		jobs, err := c.fetch(ctx, []string{DefaultQueue}, []string{"send_email"}, 10)
+		require.NoError(t, err)
+		require.Len(t, jobs, 1)
+		require.NoError(t, c.complete(ctx, jobs[0], nil, 0))
+
+		again, err := c.Enqueue(ctx, sendEmail{To: "c"}, WithUniqueKey("welcome:1"))
+		require.NoError(t, err)
+		assert.NotEqual(t, first.ID(), again.ID())
+	})
+
+	t.Run("within the transaction of the context", func(t *testing.T) {
+		c, _ := newTestClient(t)
+		tr := sqldb.NewTransactioner(c.db)
+		errRollback := stderrors.New("rollback")
+
+		err := tr.Exec(ctx, func(ctx context.Context) error {
+			_, err := c.Enqueue(ctx, sendEmail{To: "rolled@back.com"})
+			require.NoError(t, err)
+			return errRollback
+		})
+		require.ErrorIs(t, err, errRollback)
+		err = tr.Exec(ctx, func(ctx context.Context) error {
+			_, err := c.Enqueue(ctx, sendEmail{To: "committed@doe.com"})
+			return err
+		})
+		require.NoError(t, err)
+
+		list, err := c.List(ctx)
+		require.NoError(t, err)
+		require.Equal(t, 1, list.TotalCount())
+		assert.JSONEq(t, `{"to":"committed@doe.com"}`, string(list.Results()[0].Args()))
+	})
+}
+
+func TestClientFetch(t *testing.T) {
+	ctx := context.Background()
+	c, clk := newTestClient(t)
+
+	low, err := c.Enqueue(ctx, sendEmail{To: "low"})
+	require.NoError(t, err)
+	high, err := c.Enqueue(ctx, sendEmail{To: "high"}, WithPriority(10))
+	require.NoError(t, err)
+	_, err = c.Enqueue(ctx, sendEmail{To: "later"}, WithDelay(time.Hour))
+	require.NoError(t, err)
+	_, err = c.Enqueue(ctx, sendEmail{To: "other queue"}, WithQueue("other"))
+	require.NoError(t, err)
+	_, err = c.Enqueue(ctx, resizeImage{URL: "other kind"})
+	require.NoError(t, err)
+
+	jobs, err := c.fetch(ctx, []string{DefaultQueue}, []string{"send_email"}, 10)
+	require.NoError(t, err)
+	require.Len(t, jobs, 2)
+	assert.Equal(t, high.ID(), jobs[0].ID())
+	assert.Equal(t, low.ID(), jobs[1].ID())
+	for _, j := range jobs {
+		assert.Equal(t, StateRunning, j.State())
+		assert.Equal(t, 1, j.Attempt())
+		require.NotNil(t, j.AttemptedAt())
+	}
+
+	jobs, err = c.fetch(ctx, []string{DefaultQueue}, []string{"send_email"}, 10)
+	require.NoError(t, err)
+	assert.Empty(t, jobs, "running jobs are not fetched again")
+
+	clk.Advance(time.Hour)
+	jobs, err = c.fetch(ctx, []string{DefaultQueue}, []string{"send_email"}, 10)
+	require.NoError(t, err)
+	require.Len(t, jobs, 1)
+	assert.JSONEq(t, `{"to":"later"}`, string(jobs[0].Args()))
+}
+
+func TestClientComplete(t *testing.T) {
+	ctx := context.Background()
+	c, clk := newTestClient(t)
+	workErr := stderrors.New("smtp unavailable")
+
+	_, err := c.Enqueue(ctx, sendEmail{}, WithMaxAttempts(2))
+	require.NoError(t, err)
+
+	jobs, err := c.fetch(ctx, []string{DefaultQueue}, []string{"send_email"}, 1)
+	require.NoError(t, err)
+	require.NoError(t, c.complete(ctx, jobs[0], workErr, time.Minute))
+
+	j, err := c.Get(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, StateAvailable, j.State())
+	assert.Equal(t, "smtp unavailable", j.LastError())
+	assert.True(t, clk.Now().Add(time.Minute).Equal(j.RunAt()))
+
+	clk.Advance(time.Minute)
+	jobs, err = c.fetch(ctx, []string{DefaultQueue}, []string{"send_email"}, 1)
+	require.NoError(t, err)
+	require.Len(t, jobs, 1)
+	assert.Equal(t, 2, jobs[0].Attempt())
+	require.NoError(t, c.complete(ctx, jobs[0], workErr, -1))
+
+	j, err = c.Get(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, StateFailed, j.State())
+	assert.NotNil(t, j.FinalizedAt())
+}
+
+func TestClientReclaim(t *testing.T) {
+	ctx := context.Background()
+	c, clk := newTestClient(t)
+
+	_, err := c.Enqueue(ctx, sendEmail{To: "retried"})
+	require.NoError(t, err)
+	_, err = c.Enqueue(ctx, sendEmail{To: "exhausted"}, WithMaxAttempts(1))
+	require.NoError(t, err)
+	jobs, err := c.fetch(ctx, []string{DefaultQueue}, []string{"send_email"}, 10)
+	require.NoError(t, err)
+	require.Len(t, jobs, 2)
+
+	clk.Advance(30 * time.Second)
+	n, err := c.reclaim(ctx, time.Minute)
+	require.NoError(t, err)
+	assert.Zero(t, n)
+
+	clk.Advance(time.Minute)
+	require.NoError(t, c.heartbeat(ctx, []int64{toModel(jobs[0]).ID}))
+	n, err = c.reclaim(ctx, time.Minute)
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), n, "the job with a recent heartbeat is not reclaimed")
+
+	clk.Advance(2 * time.Minute)
+	n, err = c.reclaim(ctx, time.Minute)
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), n)
+
+	list, err := c.List(ctx, search.WithQueryOpts(query.SortBy("id", query.SortAsc)))
+	require.NoError(t, err)
+	require.Equal(t, 2, list.TotalCount())
+	assert.Equal(t, StateAvailable, list.Results()[0].State())
+	assert.Equal(t, "heartbeat lost", list.Results()[0].LastError())
+	assert.Equal(t, StateFailed, list.Results()[1].State())
+
+	require.NoError(t, c.complete(ctx, jobs[0], nil, 0))
+	j, err := c.Get(ctx, search.WithQueryOpts(query.FilterBy(filter.OpEq, "id", toModel(jobs[0]).ID)))
+	require.NoError(t, err)
+	assert.Equal(t, StateAvailable, j.State(), "reclaimed jobs ignore the outcome of their stale attempt")
+}
+
+func TestClientAdmin(t *testing.T) {
+	ctx := context.Background()
+	c, _ := newTestClient(t)
+
+	failed, err := c.Enqueue(ctx, sendEmail{}, WithMaxAttempts(1))
+	require.NoError(t, err)
+	available, err := c.Enqueue(ctx, resizeImage{})
+	require.NoError(t, err)
+	jobs, err := c.fetch(ctx, []string{DefaultQueue}, []string{"send_email"}, 1)
+	require.NoError(t, err)
+	require.NoError(t, c.complete(ctx, jobs[0], stderrors.New("boom"), -1))
+
+	list, err := c.List(ctx, search.WithQueryOpts(query.FilterBy(filter.OpEq, "state", StateFailed)))
+	require.NoError(t, err)
+	require.Equal(t, 1, list.TotalCount())
+	assert.Equal(t, failed.ID(), list.Results()[0].ID())
+
+	_, err = c.Retry(ctx, available.ID())
+	assertCode(t, err, apierrors.CodeConflict)
+	_, err = c.Retry(ctx, "999")
+	assertCode(t, err, apierrors.CodeNotFound)
+	_, err = c.Retry(ctx, "not a number")
+	assertCode(t, err, apierrors.CodeNotFound)
+
+	retried, err := c.Retry(ctx, failed.ID())
+	require.NoError(t, err)
+	assert.Equal(t, StateAvailable, retried.State())
+	assert.Equal(t, 0, retried.Attempt())
+	assert.Nil(t, retried.FinalizedAt())
+
+	cancelled, err := c.Cancel(ctx, available.ID())
+	require.NoError(t, err)
+	assert.Equal(t, StateCancelled, cancelled.State())
+	_, err = c.Cancel(ctx, available.ID())
+	assertCode(t, err, apierrors.CodeConflict)
+}
+
+func TestClientRetryUnique(t *testing.T) {
+	ctx := context.Background()
+	c, _ := newTestClient(t)
+
+	failed, err := c.Enqueue(ctx, sendEmail{}, WithUniqueKey("welcome"), WithMaxAttempts(1))
+	require.NoError(t, err)
+	jobs, err := c.fetch(ctx, []string{DefaultQueue}, []string{"send_email"}, 1)
+	require.NoError(t, err)
+	require.NoError(t, c.complete(ctx, jobs[0], stderrors.New("boom"), -1))
+
+	duplicate, err := c.Enqueue(ctx, sendEmail{}, WithUniqueKey("welcome"))
+	require.NoError(t, err)
+	require.NotEqual(t, failed.ID(), duplicate.ID())
+
+	_, err = c.Retry(ctx, failed.ID())
+	assertCode(t, err, apierrors.CodeConflict)
+
+	_, err = c.Cancel(ctx, duplicate.ID())
+	require.NoError(t, err)
+	retried, err := c.Retry(ctx, failed.ID())
+	require.NoError(t, err)
+	assert.Equal(t, StateAvailable, retried.State())
+}
@@ -0,0 +1,30 @@
+// Package jobs implements a background job queue stored in a SQL table,
+// without any broker besides the database.
+//
+// It includes functionality for:
+//   - Enqueuing typed jobs, see Args, with priorities, run at scheduling and
+//     unique keys, within the transaction of the caller when there is one
+//   - Working the jobs with typed handlers, see Register, fetching them with
+//     FOR UPDATE SKIP LOCKED so that any number of workers share a queue
+//   - Retrying the failed jobs up to their max attempts with a retry back off
+//   - Heartbeats reclaiming the jobs of the workers that stopped responding
+//   - Listing, retrying and cancelling jobs from an admin, see Client.List
+//
+// Jobs are stored in PostgreSQL, SQLite being supported for development and
+// tests, see Schema:
+//
+//	type SendEmail struct {
+//		To string `json:"to"`
+//	}
+//
+//	func (SendEmail) Kind() string { return "send_email" }
+//
+//	client, err := jobs.NewClient(db)
+//	worker := jobs.NewWorker(client, log)
+//	jobs.Register(worker, jobs.HandlerFunc[SendEmail](func(ctx context.Context, job jobs.Job, args SendEmail) error {
+//		return mailer.Send(ctx, args.To)
+//	}))
+//	err = worker.Start(ctx)
+//
+//	_, err = client.Enqueue(ctx, SendEmail{To: "john@doe.com"}, jobs.WithPriority(10))
+package jobs
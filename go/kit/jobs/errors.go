@@ -0,0 +1,19 @@
+package jobs
+
+import (
+	"fmt"
+
+	"github.com/dosanma1/forge/go/kit/errors"
+)
+
+func newErrQuery(err error) error {
+	return errors.Wrap(err, errors.CodeDatabaseError, errors.WithMessage("jobs query failed"))
+}
+
+func newErrNotFound(id string) error {
+	return errors.NotFound(string(ResourceType), id)
+}
+
+func newErrState(j Job, action string) error {
+	return errors.Conflict(fmt.Sprintf("cannot %s job %s in state %s", action, j.ID(), j.State()))
+}
@@ -0,0 +1,170 @@
+package jobs
+
+import (
+	"encoding/json"
+	"strconv"
+	"time"
+
+	"github.com/dosanma1/forge/go/kit/resource"
+)
+
+// ResourceType is the resource type of the jobs.
+const ResourceType resource.Type = "jobs"
+
+// Args is the payload of a job, marshaled as JSON. Kind identifies the
+// handler of the job, see Register, and must be implemented on the value
+// type since it is called on its zero value.
+type Args interface {
+	Kind() string
+}
+
+// State is the state of a job.
+type State string
+
+const (
+	// StateAvailable jobs wait for a worker, from their run at time on.
+	StateAvailable State = "available"
+	// StateRunning jobs are being worked.
+	StateRunning State = "running"
+	// StateCompleted jobs were worked successfully.
+	StateCompleted State = "completed"
+	// StateFailed jobs failed their last attempt, see Client.Retry.
+	StateFailed State = "failed"
+	// StateCancelled jobs were cancelled before running, see Client.Cancel.
+	StateCancelled State = "cancelled"
+)
+
+// Job is an enqueued job.
+type Job interface {
+	resource.Resource
+	Kind() string
+	Queue() string
+	// Args returns the JSON payload of the job.
+	Args() json.RawMessage
+	State() State
+	Priority() int
+	// Attempt returns the number of times the job was worked, the current
+	// attempt included.
+	Attempt() int
+	MaxAttempts() int
+	// UniqueKey returns the unique key of the job, see WithUniqueKey.
+	UniqueKey() string
+	// LastError returns the error of the last failed attempt.
+	LastError() string
+	// RunAt returns the time the job becomes available from.
+	RunAt() time.Time
+	AttemptedAt() *time.Time
+	FinalizedAt() *time.Time
+}
+
+// model is the row of a job.
+type model struct {
+	ID          int64      `db:"id,omitempty"`
+	Kind        string     `db:"kind"`
+	Queue       string     `db:"queue"`
+	Args        string     `db:"args"`
+	State       State      `db:"state"`
+	Priority    int        `db:"priority"`
+	Attempt     int        `db:"attempt"`
+	MaxAttempts int        `db:"max_attempts"`
+	UniqueKey   *string    `db:"unique_key"`
+	LastError   string     `db:"last_error"`
+	RunAt       time.Time  `db:"run_at"`
+	AttemptedAt *time.Time `db:"attempted_at"`
+	HeartbeatAt *time.Time `db:"heartbeat_at"`
+	FinalizedAt *time.Time `db:"finalized_at"`
+	CreatedAt   time.Time  `db:"created_at"`
+	UpdatedAt   time.Time  `db:"updated_at"`
+}
+
+type job struct {
+	m model
+}
+
+func toJob(m *model) Job {
+	return &job{m: *m}
+}
+
+func toModel(j Job) model {
+	if j, ok := j.(*job); ok {
+		return j.m
+	}
+
+	return model{}
+}
+
+func (j *job) ID() string {
+	return strconv.FormatInt(j.m.ID, 10)
+}
+
+func (j *job) LID() string {
+	return ""
+}
+
+func (j *job) Type() resource.Type {
+	return ResourceType
+}
+
+func (j *job) CreatedAt() time.Time {
+	return j.m.CreatedAt
+}
+
+func (j *job) UpdatedAt() time.Time {
+	return j.m.UpdatedAt
+}
+
+func (j *job) DeletedAt() *time.Time {
+	return nil
+}
+
+func (j *job) Kind() string {
+	return j.m.Kind
+}
+
+func (j *job) Queue() string {
+	return j.m.Queue
+}
+
+func (j *job) Args() json.RawMessage {
+	return json.RawMessage(j.m.Args)
+}
+
+func (j *job) State() State {
+	return j.m.State
+}
+
+func (j *job) Priority() int {
+	return j.m.Priority
+}
+
+func (j *job) Attempt() int {
+	return j.m.Attempt
+}
+
+func (j *job) MaxAttempts() int {
+	return j.m.MaxAttempts
+}
+
+func (j *job) UniqueKey() string {
+	if j.m.UniqueKey == nil {
+		return ""
+	}
+
+	return *j.m.UniqueKey
+}
+
+func (j *job) LastError() string {
+	return j.m.LastError
+}
+
+func (j *job) RunAt() time.Time {
+	return j.m.RunAt
+}
+
+func (j *job) AttemptedAt() *time.Time {
+	return j.m.AttemptedAt
+}
+
+func (j *job) FinalizedAt() *time.Time {
+	return j.m.FinalizedAt
+}
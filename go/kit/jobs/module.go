@@ -0,0 +1,44 @@
+package jobs
+
+import (
+	"context"
+	"database/sql"
+
+	"go.uber.org/fx"
+
+	"github.com/dosanma1/forge/go/kit/monitoring/logger"
+)
+
+// FxModule provides the jobs Client of the *sql.DB, e.g. the one of
+// sqldb.FxModule.
+func FxModule(opts ...Option) fx.Option {
+	return fx.Module("jobs",
+		fx.Provide(func(db *sql.DB) (*Client, error) {
+			return NewClient(db, opts...)
+		}),
+	)
+}
+
+// NewWorkerFx provides a Worker started and stopped along with the
+// application. The handlers are registered on it from an fx.Invoke:
+//
+//	fx.Invoke(func(w *jobs.Worker, mailer Mailer) {
+//		jobs.Register(w, NewSendEmailHandler(mailer))
+//	})
+func NewWorkerFx(opts ...WorkerOption) fx.Option {
+	return fx.Module("jobs:worker",
+		fx.Provide(func(c *Client, log logger.Logger) *Worker {
+			return NewWorker(c, log, opts...)
+		}),
+		fx.Invoke(func(lc fx.Lifecycle, w *Worker) {
+			lc.Append(fx.Hook{
+				OnStart: func(ctx context.Context) error {
+					return w.Start(ctx)
+				},
+				OnStop: func(ctx context.Context) error {
+					return w.Stop(ctx)
+				},
+			})
+		}),
+	)
+}
@@ -0,0 +1,145 @@
+package jobs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/dosanma1/forge/go/kit/filter"
+	"github.com/dosanma1/forge/go/kit/monitoring/logger/loggertest"
+	"github.com/dosanma1/forge/go/kit/persistence/gormdb"
+	"github.com/dosanma1/forge/go/kit/persistence/gormdb/gormdbtest"
+	"github.com/dosanma1/forge/go/kit/persistence/sqldb"
+	"github.com/dosanma1/forge/go/kit/search"
+	"github.com/dosanma1/forge/go/kit/search/query"
+)
+
+// postgresClient returns a client of a jobs table of its own in the
+// postgres test database, enqueueing within the gormdb transactions, and
+// skips the test when docker is not available.
+func postgresClient(t *testing.T) (*Client, *gormdb.DBClient) {
+	t.Helper()
+	if testing.Short() {
+		t.Skip("skipping integration test")
+	}
+
+	testDB := gormdbtest.GetDB(t, gormdbtest.TestSchema)
+	if testDB == nil {
+		t.Skip("skipping integration test: postgres is not available")
+	}
+	db, err := testDB.DB.DB()
+	require.NoError(t, err)
+
+	table := "jobs_" + strings.ToLower(strings.ReplaceAll(t.Name(), "/", "_"))
+	schema, err := Schema(sqldb.DialectPostgres, table)
+	require.NoError(t, err)
+	_, err = db.Exec(schema)
+	require.NoError(t, err)
+	t.Cleanup(func() { _, _ = db.Exec("DROP TABLE " + table) })
+
+	c, err := NewClient(db, WithTable(table), WithGormDB(testDB.DBClient))
+	require.NoError(t, err)
+
+	return c, testDB.DBClient
+}
+
+func TestPostgresFetch(t *testing.T) {
+	ctx := context.Background()
+	c, cli := postgresClient(t)
+
+	const total = 20
+	ids := map[string]bool{}
+	for i := range total {
+		j, err := c.Enqueue(ctx, sendEmail{To: fmt.Sprintf("user%d", i)})
+		require.NoError(t, err)
+		ids[j.ID()] = true
+	}
+
+	t.Run("skips the jobs locked by another worker", func(t *testing.T) {
+		tx := cli.DB.Begin()
+		require.NoError(t, tx.Error)
+		defer tx.Rollback()
+		locked := ""
+		require.NoError(t, tx.Raw(
+			fmt.Sprintf("SELECT id FROM %s ORDER BY id LIMIT 1 FOR UPDATE", c.table),
+		).Scan(&locked).Error)
+
+		fetchCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+		defer cancel()
+		jobs, err := c.fetch(fetchCtx, []string{DefaultQueue}, []string{"send_email"}, 1)
+		require.NoError(t, err)
+		require.Len(t, jobs, 1)
+		assert.NotEqual(t, locked, jobs[0].ID())
+		delete(ids, jobs[0].ID())
+	})
+
+	t.Run("concurrent workers fetch every job once", func(t *testing.T) {
+		var (
+			mu      sync.Mutex
+			fetched []string
+			wg      sync.WaitGroup
+		)
+		for range 4 {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for {
+					jobs, err := c.fetch(ctx, []string{DefaultQueue}, []string{"send_email"}, 2)
+					if !assert.NoError(t, err) || len(jobs) == 0 {
+						return
+					}
+					mu.Lock()
+					for _, j := range jobs {
+						fetched = append(fetched, j.ID())
+					}
+					mu.Unlock()
+				}
+			}()
+		}
+		wg.Wait()
+
+		assert.Len(t, fetched, len(ids))
+		for _, id := range fetched {
+			assert.True(t, ids[id], "job %s fetched twice", id)
+			delete(ids, id)
+		}
+	})
+}
+
+func TestPostgresEnqueueWithGormDB(t *testing.T) {
+	ctx := context.Background()
+	c, cli := postgresClient(t)
+	txer := gormdb.NewTransactioner(cli, loggertest.NewStubLogger(t))
+
+	var rolledBack, committed Job
+	errRollback := errors.New("rollback")
+	err := txer.Exec(ctx, func(ctx context.Context) error {
+		var err error
+		rolledBack, err = c.Enqueue(ctx, sendEmail{To: "rolled back"})
+		require.NoError(t, err)
+		return errRollback
+	})
+	require.ErrorIs(t, err, errRollback)
+
+	err = txer.Exec(ctx, func(ctx context.Context) error {
+		var err error
+		committed, err = c.Enqueue(ctx, sendEmail{To: "committed"})
+		return err
+	})
+	require.NoError(t, err)
+
+	list, err := c.List(ctx, search.WithQueryOpts(query.FilterBy(filter.OpEq, "id", rolledBack.ID())))
+	require.NoError(t, err)
+	assert.Zero(t, list.TotalCount(), "the job is rolled back with the transaction")
+
+	j, err := c.Get(ctx, search.WithQueryOpts(query.FilterBy(filter.OpEq, "id", committed.ID())))
+	require.NoError(t, err)
+	assert.Equal(t, StateAvailable, j.State())
+}
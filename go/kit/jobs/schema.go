@@ -0,0 +1,71 @@
+package jobs
+
+import (
+	"fmt"
+
+	"github.com/dosanma1/forge/go/kit/errors"
+	"github.com/dosanma1/forge/go/kit/persistence/sqldb"
+)
+
+const postgresSchema = `CREATE TABLE IF NOT EXISTS %[1]s (
+	id BIGSERIAL PRIMARY KEY,
+	kind TEXT NOT NULL,
+	queue TEXT NOT NULL DEFAULT 'default',
+	args JSONB NOT NULL DEFAULT '{}',
+	state TEXT NOT NULL DEFAULT 'available',
+	priority INTEGER NOT NULL DEFAULT 0,
+	attempt INTEGER NOT NULL DEFAULT 0,
+	max_attempts INTEGER NOT NULL,
+	unique_key TEXT,
+	last_error TEXT NOT NULL DEFAULT '',
+	run_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+	attempted_at TIMESTAMPTZ,
+	heartbeat_at TIMESTAMPTZ,
+	finalized_at TIMESTAMPTZ,
+	created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+	updated_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+`
+
+const sqliteSchema = `CREATE TABLE IF NOT EXISTS %[1]s (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	kind TEXT NOT NULL,
+	queue TEXT NOT NULL DEFAULT 'default',
+	args TEXT NOT NULL DEFAULT '{}',
+	state TEXT NOT NULL DEFAULT 'available',
+	priority INTEGER NOT NULL DEFAULT 0,
+	attempt INTEGER NOT NULL DEFAULT 0,
+	max_attempts INTEGER NOT NULL,
+	unique_key TEXT,
+	last_error TEXT NOT NULL DEFAULT '',
+	run_at DATETIME NOT NULL,
+	attempted_at DATETIME,
+	heartbeat_at DATETIME,
+	finalized_at DATETIME,
+	created_at DATETIME NOT NULL,
+	updated_at DATETIME NOT NULL
+);
+`
+
+const indexes = `CREATE INDEX IF NOT EXISTS %[1]s_fetch_idx ON %[1]s (queue, priority DESC, run_at, id) WHERE state = 'available';
+CREATE INDEX IF NOT EXISTS %[1]s_heartbeat_idx ON %[1]s (heartbeat_at) WHERE state = 'running';
+CREATE UNIQUE INDEX IF NOT EXISTS %[1]s_unique_idx ON %[1]s (unique_key)
+	WHERE unique_key IS NOT NULL AND state IN ('available', 'running');
+`
+
+// Schema returns the statements creating the jobs table, e.g. to add them
+// to the migrations of the service. Only PostgreSQL and SQLite are
+// supported, the unique jobs relying on partial unique indexes.
+func Schema(d sqldb.Dialect, table string) (string, error) {
+	var schema string
+	switch d {
+	case sqldb.DialectPostgres:
+		schema = postgresSchema
+	case sqldb.DialectSQLite:
+		schema = sqliteSchema
+	default:
+		return "", errors.InvalidArgument("jobs are only supported by PostgreSQL and SQLite")
+	}
+
+	return fmt.Sprintf(schema+indexes, table), nil
+}
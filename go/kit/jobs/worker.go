@@ -0,0 +1,313 @@
+package jobs
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"maps"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/dosanma1/forge/go/kit/errors"
+	"github.com/dosanma1/forge/go/kit/monitoring/logger"
+	"github.com/dosanma1/forge/go/kit/retry"
+)
+
+const (
+	defaultConcurrency       = 10
+	defaultPollInterval      = time.Second
+	defaultHeartbeatInterval = 10 * time.Second
+	defaultStuckTimeout      = time.Minute
+)
+
+type (
+	// Handler works the jobs of args T.
+	Handler[T Args] interface {
+		Handle(ctx context.Context, job Job, args T) error
+	}
+
+	// HandlerFunc is a function implementing Handler.
+	HandlerFunc[T Args] func(ctx context.Context, job Job, args T) error
+
+	workFunc func(ctx context.Context, job Job) error
+)
+
+func (f HandlerFunc[T]) Handle(ctx context.Context, job Job, args T) error {
+	return f(ctx, job, args)
+}
+
+// Register registers h as the handler of the jobs of kind T on w. A handler
+// failing with an error wrapped by retry.Permanent fails the job without
+// retrying it.
+func Register[T Args](w *Worker, h Handler[T]) {
+	var zero T
+	w.register(zero.Kind(), func(ctx context.Context, job Job) error {
+		var args T
+		if err := json.Unmarshal(job.Args(), &args); err != nil {
+			return retry.Permanent(errors.InvalidArgument(fmt.Sprintf("invalid %s job args: %v", job.Kind(), err)))
+		}
+
+		return h.Handle(ctx, job, args)
+	})
+}
+
+type (
+	// WorkerOption configures a Worker.
+	WorkerOption func(c *workerConfig)
+	workerConfig struct {
+		queues            []string
+		concurrency       int
+		pollInterval      time.Duration
+		heartbeatInterval time.Duration
+		stuckTimeout      time.Duration
+		retryOpts         []retry.Option
+	}
+)
+
+// WithQueues sets the queues the worker works. Defaults to DefaultQueue.
+func WithQueues(queues ...string) WorkerOption {
+	return func(c *workerConfig) {
+		c.queues = queues
+	}
+}
+
+// WithConcurrency sets how many jobs the worker works at once. Defaults to
+// 10.
+func WithConcurrency(n int) WorkerOption {
+	return func(c *workerConfig) {
+		c.concurrency = n
+	}
+}
+
+// WithPollInterval sets how often the worker fetches jobs while it has free
+// slots. Defaults to 1s.
+func WithPollInterval(d time.Duration) WorkerOption {
+	return func(c *workerConfig) {
+		c.pollInterval = d
+	}
+}
+
+// WithHeartbeat sets how often the worker records the heartbeat of its
+// running jobs, and the time without heartbeat after which a running job,
+// e.g. of a crashed worker, is reclaimed to run again. Defaults to 10s and
+// 1m.
+func WithHeartbeat(interval, stuckTimeout time.Duration) WorkerOption {
+	return func(c *workerConfig) {
+		c.heartbeatInterval = interval
+		c.stuckTimeout = stuckTimeout
+	}
+}
+
+// WithRetryOptions sets the back off delaying the retries of the failed
+// jobs, see retry.Delay. Defaults to an exponential back off from 1s up to
+// 1h.
+func WithRetryOptions(opts ...retry.Option) WorkerOption {
+	return func(c *workerConfig) {
+		c.retryOpts = opts
+	}
+}
+
+func defaultWorkerOptions() []WorkerOption {
+	return []WorkerOption{
+		WithQueues(DefaultQueue),
+		WithConcurrency(defaultConcurrency),
+		WithPollInterval(defaultPollInterval),
+		WithHeartbeat(defaultHeartbeatInterval, defaultStuckTimeout),
+		WithRetryOptions(
+			retry.WithExponentialPolicy(),
+			retry.WithInitialInterval(time.Second),
+			retry.WithMaxInterval(time.Hour),
+		),
+	}
+}
+
+// Worker works the jobs of its queues with the handlers registered on it,
+// see Register.
+type Worker struct {
+	client *Client
+	log    logger.Logger
+	cfg    workerConfig
+
+	mu       sync.Mutex
+	handlers map[string]workFunc
+	running  map[int64]struct{}
+	started  bool
+
+	stop       chan struct{}
+	done       chan struct{}
+	jobs       sync.WaitGroup
+	jobsCtx    context.Context
+	cancelJobs context.CancelFunc
+}
+
+// NewWorker returns a worker of the jobs of the client.
+func NewWorker(client *Client, log logger.Logger, opts ...WorkerOption) *Worker {
+	cfg := workerConfig{}
+	for _, opt := range append(defaultWorkerOptions(), opts...) {
+		opt(&cfg)
+	}
+
+	return &Worker{
+		client:   client,
+		log:      log,
+		cfg:      cfg,
+		handlers: map[string]workFunc{},
+		running:  map[int64]struct{}{},
+	}
+}
+
+func (w *Worker) register(kind string, fn workFunc) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.handlers[kind] = fn
+}
+
+// Start starts working the jobs in the background until Stop is called.
+func (w *Worker) Start(ctx context.Context) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.started {
+		return errors.Conflict("jobs worker already started")
+	}
+
+	w.started = true
+	w.stop, w.done = make(chan struct{}), make(chan struct{})
+	w.jobsCtx, w.cancelJobs = context.WithCancel(context.WithoutCancel(ctx))
+	go w.run()
+
+	w.log.InfoContext(ctx, "jobs:worker -> started", "queues", w.cfg.queues, "concurrency", w.cfg.concurrency)
+	return nil
+}
+
+// Stop stops fetching jobs and waits for the running ones, cancelling their
+// context when ctx is done first.
+func (w *Worker) Stop(ctx context.Context) error {
+	w.mu.Lock()
+	if !w.started {
+		w.mu.Unlock()
+		return nil
+	}
+	w.started = false
+	w.mu.Unlock()
+
+	close(w.stop)
+	<-w.done
+
+	finished := make(chan struct{})
+	go func() {
+		w.jobs.Wait()
+		close(finished)
+	}()
+	defer w.cancelJobs()
+
+	select {
+	case <-finished:
+		return nil
+	case <-ctx.Done():
+		w.cancelJobs()
+		<-finished
+		return ctx.Err()
+	}
+}
+
+func (w *Worker) run() {
+	defer close(w.done)
+
+	poll := time.NewTicker(w.cfg.pollInterval)
+	defer poll.Stop()
+	heartbeat := time.NewTicker(w.cfg.heartbeatInterval)
+	defer heartbeat.Stop()
+
+	for {
+		w.work()
+
+		select {
+		case <-w.stop:
+			return
+		case <-poll.C:
+		case <-heartbeat.C:
+			w.heartbeat()
+		}
+	}
+}
+
+// work fetches as many jobs as the worker has free slots and runs them.
+func (w *Worker) work() {
+	w.mu.Lock()
+	kinds := slices.Sorted(maps.Keys(w.handlers))
+	limit := w.cfg.concurrency - len(w.running)
+	w.mu.Unlock()
+
+	jobs, err := w.client.fetch(w.jobsCtx, w.cfg.queues, kinds, limit)
+	if err != nil {
+		w.log.ErrorContext(w.jobsCtx, "jobs:worker -> error fetching jobs", "err", err)
+		return
+	}
+
+	for _, j := range jobs {
+		w.mu.Lock()
+		fn := w.handlers[j.Kind()]
+		w.running[toModel(j).ID] = struct{}{}
+		w.mu.Unlock()
+
+		w.jobs.Add(1)
+		go w.execute(j, fn)
+	}
+}
+
+func (w *Worker) execute(j Job, fn workFunc) {
+	defer w.jobs.Done()
+	defer func() {
+		w.mu.Lock()
+		delete(w.running, toModel(j).ID)
+		w.mu.Unlock()
+	}()
+
+	ctx := w.jobsCtx
+	err := safeWork(ctx, j, fn)
+
+	retryDelay := time.Duration(-1)
+	if err != nil && !retry.IsPermanent(err) && j.Attempt() < j.MaxAttempts() {
+		retryDelay = retry.Delay(j.Attempt(), w.cfg.retryOpts...)
+	}
+	if err != nil {
+		w.log.WarnContext(ctx, "jobs:worker -> job failed",
+			"id", j.ID(), "kind", j.Kind(), "attempt", j.Attempt(), "err", err)
+	}
+
+	if err := w.client.complete(context.WithoutCancel(ctx), j, err, retryDelay); err != nil {
+		w.log.ErrorContext(ctx, "jobs:worker -> error completing job", "id", j.ID(), "err", err)
+	}
+}
+
+// heartbeat records the heartbeat of the running jobs and reclaims the
+// stuck ones.
+func (w *Worker) heartbeat() {
+	w.mu.Lock()
+	ids := slices.Collect(maps.Keys(w.running))
+	w.mu.Unlock()
+
+	if err := w.client.heartbeat(w.jobsCtx, ids); err != nil {
+		w.log.ErrorContext(w.jobsCtx, "jobs:worker -> error recording heartbeats", "err", err)
+	}
+	n, err := w.client.reclaim(w.jobsCtx, w.cfg.stuckTimeout)
+	if err != nil {
+		w.log.ErrorContext(w.jobsCtx, "jobs:worker -> error reclaiming stuck jobs", "err", err)
+		return
+	}
+	if n > 0 {
+		w.log.WarnContext(w.jobsCtx, "jobs:worker -> reclaimed stuck jobs", "count", n)
+	}
+}
+
+// safeWork runs fn, turning its panics into errors.
+func safeWork(ctx context.Context, j Job, fn workFunc) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = errors.InternalError(fmt.Sprintf("job %s panicked: %v", j.ID(), r))
+		}
+	}()
+
+	return fn(ctx, j)
+}
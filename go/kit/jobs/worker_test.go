@@ -0,0 +1,176 @@
+package jobs_test
+
+import (
+	"context"
+	stderrors "errors"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/dosanma1/forge/go/kit/filter"
+	"github.com/dosanma1/forge/go/kit/jobs"
+	"github.com/dosanma1/forge/go/kit/monitoring/logger/loggertest"
+	"github.com/dosanma1/forge/go/kit/persistence/sqldb"
+	"github.com/dosanma1/forge/go/kit/retry"
+	"github.com/dosanma1/forge/go/kit/search"
+	"github.com/dosanma1/forge/go/kit/search/query"
+)
+
+type chargeCard struct {
+	Amount int `json:"amount"`
+}
+
+func (chargeCard) Kind() string { return "charge_card" }
+
+func newClient(t *testing.T) *jobs.Client {
+	t.Helper()
+
+	db, err := sqldb.Connect(sqldb.MustGenerateDSN(
+		sqldb.DriverTypeSQLite, sqldb.WithConnDBName(filepath.Join(t.TempDir(), "jobs.db")),
+	))
+	require.NoError(t, err)
+	t.Cleanup(func() { db.Close() })
+	db.SetMaxOpenConns(1)
+
+	schema, err := jobs.Schema(sqldb.DialectSQLite, "jobs")
+	require.NoError(t, err)
+	_, err = db.Exec(schema)
+	require.NoError(t, err)
+
+	c, err := jobs.NewClient(db, jobs.WithDialect(sqldb.DialectSQLite))
+	require.NoError(t, err)
+
+	return c
+}
+
+func startWorker(t *testing.T, c *jobs.Client, register func(w *jobs.Worker)) {
+	t.Helper()
+
+	w := jobs.NewWorker(c, loggertest.NewStubLogger(t),
+		jobs.WithPollInterval(10*time.Millisecond),
+		jobs.WithRetryOptions(retry.WithConstantPolicy(0)),
+	)
+	register(w)
+	require.NoError(t, w.Start(context.Background()))
+	t.Cleanup(func() {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+		require.NoError(t, w.Stop(ctx))
+	})
+}
+
+func waitState(t *testing.T, c *jobs.Client, id string, state jobs.State) jobs.Job {
+	t.Helper()
+
+	var j jobs.Job
+	require.Eventually(t, func() bool {
+		var err error
+		j, err = c.Get(context.Background(), search.WithQueryOpts(query.FilterBy(filter.OpEq, "id", id)))
+		require.NoError(t, err)
+		return j.State() == state
+	}, 5*time.Second, 10*time.Millisecond)
+
+	return j
+}
+
+func TestWorker(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("works the jobs with their typed handler", func(t *testing.T) {
+		c := newClient(t)
+		charged := make(chan int, 1)
+		startWorker(t, c, func(w *jobs.Worker) {
+			jobs.Register(w, jobs.HandlerFunc[chargeCard](func(ctx context.Context, job jobs.Job, args chargeCard) error {
+				charged <- args.Amount
+				return nil
+			}))
+		})
+
+		j, err := c.Enqueue(ctx, chargeCard{Amount: 42})
+		require.NoError(t, err)
+
+		assert.Equal(t, 42, <-charged)
+		done := waitState(t, c, j.ID(), jobs.StateCompleted)
+		assert.Equal(t, 1, done.Attempt())
+		assert.NotNil(t, done.FinalizedAt())
+	})
+
+	t.Run("retries the failed jobs", func(t *testing.T) {
+		c := newClient(t)
+		var attempts atomic.Int32
+		startWorker(t, c, func(w *jobs.Worker) {
+			jobs.Register(w, jobs.HandlerFunc[chargeCard](func(ctx context.Context, job jobs.Job, args chargeCard) error {
+				if attempts.Add(1) < 3 {
+					return stderrors.New("gateway timeout")
+				}
+				return nil
+			}))
+		})
+
+		j, err := c.Enqueue(ctx, chargeCard{})
+		require.NoError(t, err)
+
+		done := waitState(t, c, j.ID(), jobs.StateCompleted)
+		assert.Equal(t, 3, done.Attempt())
+		assert.Equal(t, "gateway timeout", done.LastError())
+	})
+
+	t.Run("fails the jobs out of attempts, panicking or failing permanently", func(t *testing.T) {
+		c := newClient(t)
+		var attempts atomic.Int32
+		startWorker(t, c, func(w *jobs.Worker) {
+			jobs.Register(w, jobs.HandlerFunc[chargeCard](func(ctx context.Context, job jobs.Job, args chargeCard) error {
+				attempts.Add(1)
+				switch args.Amount {
+				case 1:
+					return stderrors.New("declined")
+				case 2:
+					panic("boom")
+				default:
+					return retry.Permanent(stderrors.New("card expired"))
+				}
+			}))
+		})
+
+		exhausted, err := c.Enqueue(ctx, chargeCard{Amount: 1}, jobs.WithMaxAttempts(2))
+		require.NoError(t, err)
+		panicked, err := c.Enqueue(ctx, chargeCard{Amount: 2}, jobs.WithMaxAttempts(1))
+		require.NoError(t, err)
+		permanent, err := c.Enqueue(ctx, chargeCard{Amount: 3})
+		require.NoError(t, err)
+
+		assert.Equal(t, 2, waitState(t, c, exhausted.ID(), jobs.StateFailed).Attempt())
+		assert.Contains(t, waitState(t, c, panicked.ID(), jobs.StateFailed).LastError(), "panicked: boom")
+		failed := waitState(t, c, permanent.ID(), jobs.StateFailed)
+		assert.Equal(t, 1, failed.Attempt())
+		assert.Equal(t, "card expired", failed.LastError())
+		assert.Equal(t, int32(4), attempts.Load())
+	})
+
+	t.Run("stop cancels the running jobs once its context is done", func(t *testing.T) {
+		c := newClient(t)
+		started := make(chan struct{})
+		w := jobs.NewWorker(c, loggertest.NewStubLogger(t), jobs.WithPollInterval(10*time.Millisecond))
+		jobs.Register(w, jobs.HandlerFunc[chargeCard](func(ctx context.Context, job jobs.Job, args chargeCard) error {
+			close(started)
+			<-ctx.Done()
+			return ctx.Err()
+		}))
+		require.NoError(t, w.Start(ctx))
+
+		j, err := c.Enqueue(ctx, chargeCard{})
+		require.NoError(t, err)
+		<-started
+
+		stopCtx, cancel := context.WithTimeout(ctx, 50*time.Millisecond)
+		defer cancel()
+		require.ErrorIs(t, w.Stop(stopCtx), context.DeadlineExceeded)
+
+		stopped := waitState(t, c, j.ID(), jobs.StateAvailable)
+		assert.Equal(t, context.Canceled.Error(), stopped.LastError())
+	})
+}
@@ -0,0 +1,266 @@
+package migrator
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"sort"
+
+	"github.com/golang-migrate/migrate/v4"
+	"github.com/golang-migrate/migrate/v4/source"
+)
+
+// migration is an up migration of the migrations filesystem or a Go
+// migration, which has no checksum.
+type migration struct {
+	version  uint
+	name     string
+	checksum string
+	goMig    bool
+}
+
+// MigrationStatus is the state of a migration, see Status.
+type MigrationStatus struct {
+	Version uint
+	Name    string
+	Applied bool
+	// Go reports whether it is a Go migration, see GoMigration. Go migrations
+	// have no checksum.
+	Go bool
+	// Checksum is the SHA-256 of the up migration file, empty when the file
+	// is missing.
+	Checksum string
+	// AppliedChecksum is the checksum of the file when it was applied, empty
+	// when unknown.
+	AppliedChecksum string
+}
+
+// Drifted reports whether the migration file changed after it was applied.
+func (s MigrationStatus) Drifted() bool {
+	return s.AppliedChecksum != "" && s.AppliedChecksum != s.Checksum
+}
+
+// Status is the state of the migrations of a database.
+type Status struct {
+	// Version is the current version, 0 when none was applied.
+	Version uint
+	// Dirty reports whether the migration of Version failed, see Force.
+	Dirty      bool
+	Migrations []MigrationStatus
+}
+
+// Status returns the applied and pending migrations, with those applied but
+// missing from migrationsFS.
+func (m *migrator) Status(ctx context.Context, migrationsFS fs.FS) (*Status, error) {
+	migrations, err := m.loadMigrations(migrationsFS)
+	if err != nil {
+		return nil, err
+	}
+	applied, err := m.appliedChecksums(ctx)
+	if err != nil {
+		return nil, err
+	}
+	src, err := m.source(migrationsFS)
+	if err != nil {
+		return nil, err
+	}
+	mg, closeFn, err := m.open(src)
+	if err != nil {
+		return nil, err
+	}
+	defer closeFn()
+
+	version, dirty, err := mg.Version()
+	if err != nil && !errors.Is(err, migrate.ErrNilVersion) {
+		return nil, fmt.Errorf("failed to get version: %w", err)
+	}
+	hasVersion := err == nil
+
+	status := &Status{Version: version, Dirty: dirty}
+	for _, mig := range migrations {
+		status.Migrations = append(status.Migrations, MigrationStatus{
+			Version:         mig.version,
+			Name:            mig.name,
+			Applied:         hasVersion && mig.version <= version,
+			Go:              mig.goMig,
+			Checksum:        mig.checksum,
+			AppliedChecksum: applied[mig.version],
+		})
+		delete(applied, mig.version)
+	}
+	for v, checksum := range applied {
+		status.Migrations = append(status.Migrations, MigrationStatus{
+			Version:         v,
+			Applied:         true,
+			AppliedChecksum: checksum,
+		})
+	}
+	sort.Slice(status.Migrations, func(i, j int) bool {
+		return status.Migrations[i].Version < status.Migrations[j].Version
+	})
+
+	return status, nil
+}
+
+// Validate fails when applied migrations were edited or removed from
+// migrationsFS since. The migrations applied before checksums were recorded
+// are trusted as they are the first time.
+func (m *migrator) Validate(ctx context.Context, migrationsFS fs.FS) error {
+	migrations, err := m.loadMigrations(migrationsFS)
+	if err != nil {
+		return err
+	}
+
+	return m.validateChecksums(ctx, migrations)
+}
+
+func (m *migrator) validateChecksums(ctx context.Context, migrations []migration) error {
+	applied, err := m.appliedChecksums(ctx)
+	if err != nil {
+		return err
+	}
+
+	var drifted []string
+	for _, mig := range migrations {
+		if checksum, ok := applied[mig.version]; ok && checksum != mig.checksum {
+			drifted = append(drifted, fmt.Sprintf("%d_%s", mig.version, mig.name))
+		}
+		delete(applied, mig.version)
+	}
+	for v := range applied {
+		drifted = append(drifted, fmt.Sprintf("%d (missing)", v))
+	}
+	if len(drifted) > 0 {
+		sort.Strings(drifted)
+		return newErrChecksumMismatch(drifted)
+	}
+
+	return nil
+}
+
+// syncChecksums records the checksums of the migrations applied up to the
+// current version of mg, and forgets those of the versions above it.
+func (m *migrator) syncChecksums(ctx context.Context, mg *migrate.Migrate, migrations []migration) error {
+	version, dirty, err := mg.Version()
+	if err != nil && !errors.Is(err, migrate.ErrNilVersion) {
+		return fmt.Errorf("failed to get version: %w", err)
+	}
+	hasVersion := err == nil
+
+	applied, err := m.appliedChecksums(ctx)
+	if err != nil {
+		return err
+	}
+
+	dialect := m.driver.Dialect()
+	table := m.checksumTable()
+	for v := range applied {
+		if hasVersion && v <= version {
+			continue
+		}
+		stmt := fmt.Sprintf("DELETE FROM %s WHERE version = %s", table, dialect.Placeholder(1))
+		if _, err := m.db.Exec(ctx, stmt, int64(v)); err != nil {
+			return fmt.Errorf("failed to delete checksum of version %d: %w", v, err)
+		}
+	}
+	for _, mig := range migrations {
+		if _, ok := applied[mig.version]; ok || mig.goMig || !hasVersion || mig.version > version || (mig.version == version && dirty) {
+			continue
+		}
+		stmt := fmt.Sprintf("INSERT INTO %s (version, checksum) VALUES (%s, %s)",
+			table, dialect.Placeholder(1), dialect.Placeholder(2))
+		if _, err := m.db.Exec(ctx, stmt, int64(mig.version), mig.checksum); err != nil {
+			return fmt.Errorf("failed to record checksum of version %d: %w", mig.version, err)
+		}
+	}
+
+	return nil
+}
+
+// appliedChecksums returns the recorded checksums of the applied migrations
+// by version.
+func (m *migrator) appliedChecksums(ctx context.Context) (map[uint]string, error) {
+	table := m.checksumTable()
+	stmt := fmt.Sprintf("CREATE TABLE IF NOT EXISTS %s (version BIGINT NOT NULL PRIMARY KEY, checksum VARCHAR(64) NOT NULL)", table)
+	if _, err := m.db.Exec(ctx, stmt); err != nil {
+		return nil, fmt.Errorf("failed to create checksums table: %w", err)
+	}
+
+	rows, err := m.db.Query(ctx, fmt.Sprintf("SELECT version, checksum FROM %s", table))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read checksums: %w", err)
+	}
+	defer rows.Close()
+
+	checksums := map[uint]string{}
+	for rows.Next() {
+		var (
+			version  int64
+			checksum string
+		)
+		if err := rows.Scan(&version, &checksum); err != nil {
+			return nil, fmt.Errorf("failed to read checksums: %w", err)
+		}
+		checksums[uint(version)] = checksum
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read checksums: %w", err)
+	}
+
+	return checksums, nil
+}
+
+// checksumTable returns the quoted table of the checksums of the service.
+func (m *migrator) checksumTable() string {
+	return m.driver.Dialect().Quote(m.serviceName + "_schema_checksums")
+}
+
+// loadMigrations returns the up migrations of migrationsFS and the Go
+// migrations by version.
+func (m *migrator) loadMigrations(migrationsFS fs.FS) ([]migration, error) {
+	src, err := m.source(migrationsFS)
+	if err != nil {
+		return nil, err
+	}
+	defer src.Close()
+
+	var migrations []migration
+	for _, version := range src.versions {
+		if g, ok := src.migrations[version]; ok {
+			migrations = append(migrations, migration{version: version, name: g.Name, goMig: true})
+			continue
+		}
+		mig, err := readMigration(src, version)
+		if err != nil {
+			return nil, err
+		}
+		if mig != nil {
+			migrations = append(migrations, *mig)
+		}
+	}
+
+	return migrations, nil
+}
+
+// readMigration returns the up migration of version, nil when it has none.
+func readMigration(d source.Driver, version uint) (*migration, error) {
+	r, name, err := d.ReadUp(version)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read migration %d: %w", version, err)
+	}
+	defer r.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, r); err != nil {
+		return nil, fmt.Errorf("failed to read migration %d: %w", version, err)
+	}
+
+	return &migration{version: version, name: name, checksum: hex.EncodeToString(h.Sum(nil))}, nil
+}
@@ -0,0 +1,184 @@
+package migrator
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"io/fs"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+)
+
+// Usage describes the subcommands of Command.
+const Usage = `usage: migrator <command> [argument]
+
+commands:
+  up            run the pending migrations with the pre/post scripts
+  down [steps]  revert the last steps migrations, 1 by default
+  goto version  migrate up or down to version
+  force version set the version, -1 for none, and clear the dirty state
+                without migrating
+  status        list the applied and pending migrations
+  validate      check that the applied migrations were not changed
+  lint          list the risky statements of the pending migrations, failing
+                when there are any
+  dry-run       run the pending migrations in a transaction rolled back and
+                list the schema changes`
+
+// Command runs the subcommand of args, see Usage, on the database of the
+// environment, as Up does, writing its output to out. It lets services build
+// their migration binaries from their embedded migrations:
+//
+//	//go:embed all:migrations
+//	var migrationsFS embed.FS
+//
+//	func main() {
+//	    err := migrator.Command(ctx, migrationsFS, os.Stdout, os.Args[1:], migrator.WithServiceName("myservice"))
+//	    ...
+//	}
+func Command(ctx context.Context, migrationsFS fs.FS, out io.Writer, args []string, opts ...option) error {
+	if len(args) == 0 {
+		return fmt.Errorf("missing command\n%s", Usage)
+	}
+	cmd, args := args[0], args[1:]
+
+	var run func(m *migrator) error
+	switch cmd {
+	case "up":
+		run = func(m *migrator) error { return m.Run(ctx, migrationsFS) }
+	case "down":
+		steps := 1
+		if len(args) > 0 {
+			n, err := strconv.Atoi(args[0])
+			if err != nil {
+				return fmt.Errorf("invalid steps %q: %w", args[0], err)
+			}
+			steps = n
+		}
+		run = func(m *migrator) error { return m.Down(ctx, migrationsFS, steps) }
+	case "goto":
+		if len(args) == 0 {
+			return fmt.Errorf("missing version\n%s", Usage)
+		}
+		version, err := strconv.ParseUint(args[0], 10, 0)
+		if err != nil {
+			return fmt.Errorf("invalid version %q: %w", args[0], err)
+		}
+		run = func(m *migrator) error { return m.Goto(ctx, migrationsFS, uint(version)) }
+	case "force":
+		if len(args) == 0 {
+			return fmt.Errorf("missing version\n%s", Usage)
+		}
+		version, err := strconv.Atoi(args[0])
+		if err != nil {
+			return fmt.Errorf("invalid version %q: %w", args[0], err)
+		}
+		run = func(m *migrator) error { return m.Force(ctx, migrationsFS, version) }
+	case "status":
+		run = func(m *migrator) error {
+			status, err := m.Status(ctx, migrationsFS)
+			if err != nil {
+				return err
+			}
+			return writeStatus(out, status)
+		}
+	case "validate":
+		run = func(m *migrator) error {
+			if err := m.Validate(ctx, migrationsFS); err != nil {
+				return err
+			}
+			_, err := fmt.Fprintln(out, "applied migrations are unchanged")
+			return err
+		}
+	case "lint":
+		run = func(m *migrator) error {
+			issues, err := m.Lint(ctx, migrationsFS)
+			if err != nil {
+				return err
+			}
+			for _, issue := range issues {
+				if _, err := fmt.Fprintln(out, issue); err != nil {
+					return err
+				}
+			}
+			if len(issues) > 0 {
+				return newErrLintIssues(len(issues))
+			}
+			_, err = fmt.Fprintln(out, "pending migrations have no risky statements")
+			return err
+		}
+	case "dry-run":
+		run = func(m *migrator) error {
+			report, err := m.DryRun(ctx, migrationsFS)
+			if err != nil {
+				return err
+			}
+			return writeDryRun(out, report)
+		}
+	default:
+		return fmt.Errorf("unknown command %q\n%s", cmd, Usage)
+	}
+
+	return withEnvDB(run, opts...)
+}
+
+// writeDryRun writes the migrations of report and the schema changes they
+// made.
+func writeDryRun(out io.Writer, report *DryRunReport) error {
+	var b strings.Builder
+	fmt.Fprintf(&b, "applied: %s\n", strings.Join(report.Applied, ", "))
+	if len(report.Skipped) > 0 {
+		fmt.Fprintf(&b, "skipped: %s\n", strings.Join(report.Skipped, ", "))
+	}
+	b.WriteString("\nchanges:\n")
+	for _, c := range report.Changes {
+		fmt.Fprintf(&b, "  %s\n", c)
+	}
+	if len(report.Changes) == 0 {
+		b.WriteString("  none\n")
+	}
+
+	_, err := io.WriteString(out, b.String())
+	return err
+}
+
+// writeStatus writes status as a table of the migrations.
+func writeStatus(out io.Writer, status *Status) error {
+	version := "none"
+	if status.Version > 0 {
+		version = strconv.FormatUint(uint64(status.Version), 10)
+	}
+	if status.Dirty {
+		version += " (dirty)"
+	}
+	if _, err := fmt.Fprintf(out, "version: %s\n\n", version); err != nil {
+		return err
+	}
+
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "VERSION\tNAME\tSTATE\tCHECKSUM")
+	for _, mig := range status.Migrations {
+		state := "pending"
+		switch {
+		case mig.Applied && mig.Checksum == "" && !mig.Go:
+			state = "missing"
+		case mig.Drifted():
+			state = "changed"
+		case mig.Applied && status.Dirty && mig.Version == status.Version:
+			state = "dirty"
+		case mig.Applied:
+			state = "applied"
+		}
+		checksum := mig.Checksum
+		switch {
+		case mig.Go:
+			checksum = "go"
+		case checksum == "":
+			checksum = mig.AppliedChecksum
+		}
+		fmt.Fprintf(w, "%d\t%s\t%s\t%.12s\n", mig.Version, mig.Name, state, checksum)
+	}
+
+	return w.Flush()
+}
@@ -0,0 +1,59 @@
+package migrator_test
+
+import (
+	"bytes"
+	"context"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/dosanma1/forge/go/kit/migrator"
+	"github.com/dosanma1/forge/go/kit/persistence/sqldb"
+)
+
+func TestCommand(t *testing.T) {
+	ctx := context.Background()
+	t.Setenv("DB_NAME", filepath.Join(t.TempDir(), "test.db"))
+	migrationsFS := versionedFS()
+
+	run := func(args ...string) (string, error) {
+		var out bytes.Buffer
+		err := migrator.Command(ctx, migrationsFS, &out, args,
+			migrator.WithDriver(sqldb.DriverTypeSQLite), migrator.WithServiceName("test"))
+		return out.String(), err
+	}
+
+	_, err := run("up")
+	require.NoError(t, err)
+	_, err = run("down", "2")
+	require.NoError(t, err)
+
+	out, err := run("status")
+	require.NoError(t, err)
+	assert.Regexp(t, `^version: 1\n\nVERSION +NAME +STATE +CHECKSUM\n1 +create_users +applied +[0-9a-f]{12}\n2 +create_teams +pending`, out)
+
+	out, err = run("validate")
+	require.NoError(t, err)
+	assert.Equal(t, "applied migrations are unchanged\n", out)
+
+	out, err = run("lint")
+	require.NoError(t, err)
+	assert.Equal(t, "pending migrations have no risky statements\n", out)
+
+	out, err = run("dry-run")
+	require.NoError(t, err)
+	assert.Equal(t, "applied: 2_create_teams, 3_create_roles\n\nchanges:\n  added table roles\n  added table teams\n", out)
+
+	_, err = run("goto", "3")
+	require.NoError(t, err)
+	out, err = run("status")
+	require.NoError(t, err)
+	assert.Contains(t, out, "version: 3\n")
+
+	for _, args := range [][]string{{}, {"sideways"}, {"goto"}, {"force", "latest"}, {"down", "two"}} {
+		_, err = run(args...)
+		assert.Error(t, err, args)
+	}
+}
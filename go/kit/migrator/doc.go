@@ -25,7 +25,7 @@
 //
 // Migration files follow golang-migrate naming convention:
 //   - {version}_{description}.up.sql    (up migrations)
-//   - {version}_{description}.down.sql  (down migrations - not used in up-only mode)
+//   - {version}_{description}.down.sql  (down migrations, see Down and Goto)
 //
 // Example:
 //   - 000001_create_users.up.sql
@@ -43,12 +43,64 @@
 //
 //	migrator.Run(ctx, migrationsFS)
 //
+// # Versions
+//
+// Down reverts the last migrations and Goto migrates up or down to a version.
+// Status lists the applied and pending migrations, and Force clears the dirty
+// state a failed migration leaves once the database is repaired by hand.
+//
+// The SHA-256 checksums of the applied up migrations are recorded in the
+// {service}_schema_checksums table. Migrating fails when an applied migration
+// was changed or removed since, see Validate and WithChecksumValidation.
+//
+// # Go Migrations
+//
+// Migrations SQL cannot express are written in Go and registered by version
+// with WithGoMigrations, running between the SQL migrations in version order
+// and tracked in the same version table:
+//
+//	migrator.WithGoMigrations(migrator.GoMigration{
+//	    Version: 3,
+//	    Name:    "reencrypt_tokens",
+//	    Batch: func(ctx context.Context, env migrator.Env, cursor string, size int) (string, int, error) {
+//	        rows, err := env.Tx(ctx).QueryContext(ctx, "SELECT id, token FROM users WHERE id > $1 ORDER BY id LIMIT $2", cursor, size)
+//	        ...
+//	    },
+//	})
+//
+// Up runs in a single transaction, Batch in one transaction per batch with
+// the progress logged and recorded in the {service}_schema_go_progress
+// table, so that a failed or stopped backfill resumes after the last batch
+// committed.
+//
+// # Lint and Dry Run
+//
+// Lint flags the statements of the pending migrations that lock or rewrite
+// the tables of a live PostgreSQL database: indexes built without
+// CONCURRENTLY, NOT NULL columns added without default, volatile defaults,
+// type changes, constraints validated under lock and migrations locking
+// tables without lock_timeout, see LintRule.
+//
+// DryRun runs the pending SQL migrations in a transaction it rolls back and
+// reports the tables, columns and indexes they add, drop or alter. The Go
+// migrations are skipped, see Env.Tx.
+//
+// # Command
+//
+// Command runs these operations from command line arguments, for the
+// migration binaries of the services, see Usage.
+//
 // # Features
 //
 //   - Uses golang-migrate/migrate for migration management
 //   - Service-specific migration tracking table ({service}_schema_migrations)
+//   - Down, targeted version and dirty state repair
+//   - Checksum validation of the applied migrations
+//   - Transactional and resumable Go migrations
+//   - Linting and dry runs of the pending migrations
 //   - Optional pre/post migration script execution
 //   - Automatic sorting and execution order
 //   - Transaction support per migration file
+//   - PostgreSQL, MySQL and SQLite support, see WithDriver
 //   - Integration with sqldb package
 package migrator
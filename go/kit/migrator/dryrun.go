@@ -0,0 +1,240 @@
+package migrator
+
+import (
+	"context"
+	"fmt"
+	"io/fs"
+	"sort"
+	"strings"
+
+	"github.com/dosanma1/forge/go/kit/persistence/sqldb"
+)
+
+// SchemaChangeKind is the kind of a schema change, see DryRun.
+type SchemaChangeKind string
+
+const (
+	SchemaAdded   SchemaChangeKind = "added"
+	SchemaDropped SchemaChangeKind = "dropped"
+	SchemaAltered SchemaChangeKind = "altered"
+)
+
+// SchemaChange is a change of a table, column or index of the schema.
+type SchemaChange struct {
+	Kind SchemaChangeKind
+	// Object is table, column or index.
+	Object string
+	// Name is the name of the table or index, table.column for columns.
+	Name string
+	// From and To are the definitions of the column before and after the
+	// change, empty when it is added or dropped and for the other objects.
+	From string
+	To   string
+}
+
+func (c SchemaChange) String() string {
+	switch {
+	case c.Kind == SchemaAltered:
+		return fmt.Sprintf("%s %s %s: %s -> %s", c.Kind, c.Object, c.Name, c.From, c.To)
+	case c.To != "":
+		return fmt.Sprintf("%s %s %s %s", c.Kind, c.Object, c.Name, c.To)
+	case c.From != "":
+		return fmt.Sprintf("%s %s %s %s", c.Kind, c.Object, c.Name, c.From)
+	default:
+		return fmt.Sprintf("%s %s %s", c.Kind, c.Object, c.Name)
+	}
+}
+
+// DryRunReport is the outcome of a dry run, see DryRun.
+type DryRunReport struct {
+	// Applied are the migrations run in the transaction, as 2_add_email.
+	Applied []string
+	// Skipped are the migrations that cannot run in a transaction and the
+	// Go migrations, not run.
+	Skipped []string
+	// Changes are the changes of the schema made by the migrations applied.
+	Changes []SchemaChange
+}
+
+// DryRun runs the pending migrations in a transaction it rolls back,
+// reporting the changes they made to the schema. The SQL migrations that
+// cannot run in a transaction, using CONCURRENTLY for instance, and the Go
+// migrations are skipped, the following migrations running without them.
+// Go migrations are free to write through Env.DB, outside the transaction,
+// so running them would commit their changes. MySQL commits its DDL
+// statements, it cannot dry run.
+func (m *migrator) DryRun(ctx context.Context, migrationsFS fs.FS) (*DryRunReport, error) {
+	if m.driver == sqldb.DriverTypeMySQL {
+		return nil, newErrDryRunUnsupported(m.driver)
+	}
+
+	pending, err := m.pending(migrationsFS)
+	if err != nil {
+		return nil, err
+	}
+	before, err := m.schema(ctx, m.db.DB())
+	if err != nil {
+		return nil, err
+	}
+
+	tx, err := m.db.DB().BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin dry run: %w", err)
+	}
+	defer tx.Rollback()
+
+	report := &DryRunReport{}
+	for _, p := range pending {
+		if p.goMig != nil || !isTransactional(p.body) {
+			report.Skipped = append(report.Skipped, p.id())
+			continue
+		}
+		if _, err := tx.ExecContext(ctx, p.body); err != nil {
+			return nil, fmt.Errorf("dry run of migration %s failed: %w", p.id(), err)
+		}
+		report.Applied = append(report.Applied, p.id())
+	}
+
+	after, err := m.schema(ctx, tx)
+	if err != nil {
+		return nil, err
+	}
+	report.Changes = diffSchemas(before, after)
+
+	return report, nil
+}
+
+// schema is the tables, columns and indexes of a database.
+type schema struct {
+	tables map[string]bool
+	// columns are the definitions of the columns by table.column.
+	columns map[string]string
+	// indexes are the tables of the indexes by name.
+	indexes map[string]string
+}
+
+// schemaQueries returns the queries listing the table, name, type and
+// nullability of the columns, and the name and table of the indexes, of the
+// current schema.
+func schemaQueries(driver sqldb.DriverType) (columns, indexes string) {
+	switch driver {
+	case sqldb.DriverTypeSQLite:
+		return `SELECT m.name, p.name, p.type, CASE p."notnull" WHEN 0 THEN 'YES' ELSE 'NO' END
+				FROM sqlite_master m, pragma_table_info(m.name) p
+				WHERE m.type = 'table' AND m.name NOT LIKE 'sqlite_%'`,
+			`SELECT name, tbl_name FROM sqlite_master WHERE type = 'index' AND name NOT LIKE 'sqlite_%'`
+	default:
+		return `SELECT table_name, column_name, data_type, is_nullable
+				FROM information_schema.columns WHERE table_schema = current_schema()`,
+			`SELECT indexname, tablename FROM pg_indexes WHERE schemaname = current_schema()`
+	}
+}
+
+// schema returns the schema q sees, without the tables of the migrator.
+func (m *migrator) schema(ctx context.Context, q sqldb.Querier) (*schema, error) {
+	columnsQuery, indexesQuery := schemaQueries(m.driver)
+	own := m.serviceName + "_schema_"
+	s := &schema{tables: map[string]bool{}, columns: map[string]string{}, indexes: map[string]string{}}
+
+	rows, err := q.QueryContext(ctx, columnsQuery)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read schema: %w", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var table, column, typ, nullable string
+		if err := rows.Scan(&table, &column, &typ, &nullable); err != nil {
+			return nil, fmt.Errorf("failed to read schema: %w", err)
+		}
+		if strings.HasPrefix(table, own) {
+			continue
+		}
+		def := strings.ToLower(typ)
+		if nullable == "NO" {
+			def += " not null"
+		}
+		s.tables[table] = true
+		s.columns[table+"."+column] = def
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read schema: %w", err)
+	}
+
+	idxRows, err := q.QueryContext(ctx, indexesQuery)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read schema: %w", err)
+	}
+	defer idxRows.Close()
+	for idxRows.Next() {
+		var name, table string
+		if err := idxRows.Scan(&name, &table); err != nil {
+			return nil, fmt.Errorf("failed to read schema: %w", err)
+		}
+		if !strings.HasPrefix(table, own) {
+			s.indexes[name] = table
+		}
+	}
+	if err := idxRows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read schema: %w", err)
+	}
+
+	return s, nil
+}
+
+// diffSchemas returns the changes from before to after, the tables first,
+// then their columns and indexes. The columns and indexes of the tables
+// added or dropped are not reported.
+func diffSchemas(before, after *schema) []SchemaChange {
+	var tables, others []SchemaChange
+	for t := range after.tables {
+		if !before.tables[t] {
+			tables = append(tables, SchemaChange{Kind: SchemaAdded, Object: "table", Name: t})
+		}
+	}
+	for t := range before.tables {
+		if !after.tables[t] {
+			tables = append(tables, SchemaChange{Kind: SchemaDropped, Object: "table", Name: t})
+		}
+	}
+
+	tableOf := func(column string) string { return column[:strings.IndexByte(column, '.')] }
+	kept := func(table string) bool { return before.tables[table] && after.tables[table] }
+	for c, to := range after.columns {
+		from, ok := before.columns[c]
+		switch {
+		case !kept(tableOf(c)):
+		case !ok:
+			others = append(others, SchemaChange{Kind: SchemaAdded, Object: "column", Name: c, To: to})
+		case from != to:
+			others = append(others, SchemaChange{Kind: SchemaAltered, Object: "column", Name: c, From: from, To: to})
+		}
+	}
+	for c, from := range before.columns {
+		if _, ok := after.columns[c]; !ok && kept(tableOf(c)) {
+			others = append(others, SchemaChange{Kind: SchemaDropped, Object: "column", Name: c, From: from})
+		}
+	}
+	for i, table := range after.indexes {
+		if _, ok := before.indexes[i]; !ok && kept(table) {
+			others = append(others, SchemaChange{Kind: SchemaAdded, Object: "index", Name: i})
+		}
+	}
+	for i, table := range before.indexes {
+		if _, ok := after.indexes[i]; !ok && kept(table) {
+			others = append(others, SchemaChange{Kind: SchemaDropped, Object: "index", Name: i})
+		}
+	}
+
+	byName := func(changes []SchemaChange) {
+		sort.Slice(changes, func(i, j int) bool {
+			if changes[i].Object != changes[j].Object {
+				return changes[i].Object < changes[j].Object
+			}
+			return changes[i].Name < changes[j].Name
+		})
+	}
+	byName(tables)
+	byName(others)
+
+	return append(tables, others...)
+}
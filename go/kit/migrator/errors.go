@@ -0,0 +1,27 @@
+package migrator
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/dosanma1/forge/go/kit/errors"
+	"github.com/dosanma1/forge/go/kit/persistence/sqldb"
+)
+
+func newErrChecksumMismatch(migrations []string) error {
+	return errors.New(errors.CodeChecksumMismatch, errors.WithMessage(
+		fmt.Sprintf("applied migrations were changed: %s", strings.Join(migrations, ", ")),
+	))
+}
+
+func newErrDryRunUnsupported(driver sqldb.DriverType) error {
+	return errors.New(errors.CodeOperationNotAllowed, errors.WithMessage(
+		fmt.Sprintf("%s does not run DDL statements in transactions, it cannot dry run migrations", driver),
+	))
+}
+
+func newErrLintIssues(count int) error {
+	return errors.New(errors.CodeValidationFailed, errors.WithMessage(
+		fmt.Sprintf("pending migrations have %d risky statements", count),
+	))
+}
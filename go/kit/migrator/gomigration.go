@@ -0,0 +1,323 @@
+package migrator
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"sort"
+
+	"github.com/golang-migrate/migrate/v4/source"
+
+	"github.com/dosanma1/forge/go/kit/monitoring/logger"
+	"github.com/dosanma1/forge/go/kit/persistence/sqldb"
+)
+
+const defaultBatchSize = 1000
+
+// GoMigrationFunc migrates the database in the transaction of ctx, see Env.
+type GoMigrationFunc func(ctx context.Context, env Env) error
+
+// BatchFunc migrates the batch of at most size rows following cursor, empty
+// for the first batch, in the transaction of ctx. It returns the cursor of the
+// last row of the batch and how many rows it read, the migration being done
+// once a batch reads less than size.
+type BatchFunc func(ctx context.Context, env Env, cursor string, size int) (next string, n int, err error)
+
+// GoMigration is a migration written in Go, for the changes SQL cannot make
+// such as re-encrypting fields or backfilling from another service. It is
+// ordered by its version with the SQL migrations, none of which can have it.
+type GoMigration struct {
+	Version uint
+	Name    string
+	// Up migrates in a single transaction. Either Up or Batch is required.
+	Up GoMigrationFunc
+	// Batch migrates batch after batch, each in its own transaction. The
+	// cursor of the last batch committed is recorded with it, a failed or
+	// stopped migration resuming after it.
+	Batch BatchFunc
+	// BatchSize is the size of the batches, 1000 by default.
+	BatchSize int
+	// Down reverts the migration in a single transaction. Without it,
+	// reverting the migration only unsets its version. It runs again when
+	// the version could not be unset after it, so must be idempotent.
+	Down GoMigrationFunc
+}
+
+func (g *GoMigration) id() string {
+	return fmt.Sprintf("%d_%s", g.Version, g.Name)
+}
+
+func (g *GoMigration) batchSize() int {
+	if g.BatchSize > 0 {
+		return g.BatchSize
+	}
+	return defaultBatchSize
+}
+
+// Env is the database and logger of the migrator given to Go migrations.
+type Env struct {
+	DB     *sqldb.DBClient
+	Logger logger.Logger
+}
+
+// Tx returns the transaction of the migration of ctx. Only its queries are
+// transactional: those of DB run outside of it and commit right away, even
+// when the migration fails.
+func (e Env) Tx(ctx context.Context) sqldb.Querier {
+	return sqldb.GetTx(ctx, e.DB.DB())
+}
+
+// WithGoMigrations registers Go migrations, run between the SQL migrations
+// by version.
+func WithGoMigrations(migrations ...GoMigration) option {
+	return func(m *migrator) {
+		m.goMigrations = append(m.goMigrations, migrations...)
+	}
+}
+
+// indexGoMigrations returns the Go migrations by version.
+func indexGoMigrations(migrations []GoMigration) (map[uint]*GoMigration, error) {
+	byVersion := make(map[uint]*GoMigration, len(migrations))
+	for i := range migrations {
+		g := &migrations[i]
+		if (g.Up == nil) == (g.Batch == nil) {
+			return nil, fmt.Errorf("go migration %s requires either Up or Batch", g.id())
+		}
+		if _, ok := byVersion[g.Version]; ok {
+			return nil, fmt.Errorf("duplicate go migration version %d", g.Version)
+		}
+		byVersion[g.Version] = g
+	}
+
+	return byVersion, nil
+}
+
+// goSource adds the versions of the Go migrations to those of the SQL
+// migrations. golang-migrate reads no body for them and only sets their
+// version, the migrator running them first.
+type goSource struct {
+	source.Driver
+	versions   []uint
+	migrations map[uint]*GoMigration
+}
+
+func newGoSource(d source.Driver, migrations map[uint]*GoMigration) (*goSource, error) {
+	s := &goSource{Driver: d, migrations: migrations}
+
+	version, err := d.First()
+	for err == nil {
+		if g, ok := migrations[version]; ok {
+			return nil, fmt.Errorf("go migration %s has the version of a sql migration", g.id())
+		}
+		s.versions = append(s.versions, version)
+		version, err = d.Next(version)
+	}
+	if !errors.Is(err, fs.ErrNotExist) {
+		return nil, fmt.Errorf("failed to list migrations: %w", err)
+	}
+	for v := range migrations {
+		s.versions = append(s.versions, v)
+	}
+	sort.Slice(s.versions, func(i, j int) bool { return s.versions[i] < s.versions[j] })
+
+	return s, nil
+}
+
+// index returns the index of version, -1 when it has no migration.
+func (s *goSource) index(version uint) int {
+	i := sort.Search(len(s.versions), func(i int) bool { return s.versions[i] >= version })
+	if i < len(s.versions) && s.versions[i] == version {
+		return i
+	}
+	return -1
+}
+
+func (s *goSource) First() (uint, error) {
+	if len(s.versions) == 0 {
+		return 0, &fs.PathError{Op: "first", Path: "migrations", Err: fs.ErrNotExist}
+	}
+	return s.versions[0], nil
+}
+
+func (s *goSource) Prev(version uint) (uint, error) {
+	i := s.index(version)
+	if i < 1 {
+		return 0, &fs.PathError{Op: "prev for version " + fmt.Sprint(version), Path: "migrations", Err: fs.ErrNotExist}
+	}
+	return s.versions[i-1], nil
+}
+
+func (s *goSource) Next(version uint) (uint, error) {
+	i := s.index(version)
+	if i < 0 || i == len(s.versions)-1 {
+		return 0, &fs.PathError{Op: "next for version " + fmt.Sprint(version), Path: "migrations", Err: fs.ErrNotExist}
+	}
+	return s.versions[i+1], nil
+}
+
+func (s *goSource) ReadUp(version uint) (io.ReadCloser, string, error) {
+	if g, ok := s.migrations[version]; ok {
+		return nil, "", errGoBody{g}
+	}
+	return s.Driver.ReadUp(version)
+}
+
+func (s *goSource) ReadDown(version uint) (io.ReadCloser, string, error) {
+	if g, ok := s.migrations[version]; ok {
+		return nil, "", errGoBody{g}
+	}
+	return s.Driver.ReadDown(version)
+}
+
+// errGoBody is the error reading the body of a Go migration. golang-migrate
+// sets the version of a migration without body, but only knows the version
+// exists when reading its body fails with fs.ErrExist.
+type errGoBody struct{ g *GoMigration }
+
+func (e errGoBody) Error() string {
+	return fmt.Sprintf("go migration %s has no body", e.g.id())
+}
+
+func (e errGoBody) Is(target error) bool {
+	return target == fs.ErrNotExist || target == fs.ErrExist
+}
+
+// goProgress is the progress of a Go migration whose version is not set yet.
+type goProgress struct {
+	cursor   string
+	migrated int64
+	done     bool
+}
+
+// runGoUp runs the Up or batches of g not committed yet.
+func (m *migrator) runGoUp(ctx context.Context, g *GoMigration) error {
+	if err := m.createProgressTable(ctx); err != nil {
+		return err
+	}
+	env := Env{DB: m.db, Logger: m.logger.WithKeysAndValues("migration", g.id())}
+
+	for {
+		var (
+			p   goProgress
+			ran bool
+		)
+		err := m.tx.Exec(ctx, func(ctx context.Context) error {
+			var err error
+			if p, err = m.loadProgress(ctx, g.Version); err != nil || p.done {
+				return err
+			}
+			ran = true
+			if g.Up != nil {
+				if err := g.Up(ctx, env); err != nil {
+					return err
+				}
+				p.done = true
+			} else {
+				next, n, err := g.Batch(ctx, env, p.cursor, g.batchSize())
+				if err != nil {
+					return err
+				}
+				p = goProgress{cursor: next, migrated: p.migrated + int64(n), done: n < g.batchSize()}
+			}
+			return m.saveProgress(ctx, g.Version, p)
+		})
+		if err != nil {
+			return fmt.Errorf("go migration %s failed: %w", g.id(), err)
+		}
+		if ran && g.Batch != nil {
+			env.Logger.WithKeysAndValues("migrated", p.migrated, "cursor", p.cursor).Info("🔄 Migrated batch")
+		}
+		if p.done {
+			return nil
+		}
+	}
+}
+
+// runGoDown runs the Down of g, forgetting its progress.
+func (m *migrator) runGoDown(ctx context.Context, g *GoMigration) error {
+	if err := m.createProgressTable(ctx); err != nil {
+		return err
+	}
+	env := Env{DB: m.db, Logger: m.logger.WithKeysAndValues("migration", g.id())}
+
+	err := m.tx.Exec(ctx, func(ctx context.Context) error {
+		if g.Down != nil {
+			if err := g.Down(ctx, env); err != nil {
+				return err
+			}
+		}
+		return m.clearProgress(ctx, g.Version)
+	})
+	if err != nil {
+		return fmt.Errorf("go migration %s failed: %w", g.id(), err)
+	}
+
+	return nil
+}
+
+func (m *migrator) createProgressTable(ctx context.Context) error {
+	stmt := fmt.Sprintf("CREATE TABLE IF NOT EXISTS %s (version BIGINT NOT NULL PRIMARY KEY, cursor_value VARCHAR(255) NOT NULL, migrated BIGINT NOT NULL, done BOOLEAN NOT NULL)", m.progressTable())
+	if _, err := m.db.Exec(ctx, stmt); err != nil {
+		return fmt.Errorf("failed to create go migrations progress table: %w", err)
+	}
+	return nil
+}
+
+// loadProgress returns the progress of the migration of version, in the
+// transaction of ctx.
+func (m *migrator) loadProgress(ctx context.Context, version uint) (goProgress, error) {
+	stmt := fmt.Sprintf("SELECT cursor_value, migrated, done FROM %s WHERE version = %s",
+		m.progressTable(), m.driver.Dialect().Placeholder(1))
+
+	var p goProgress
+	err := sqldb.GetTx(ctx, m.db.DB()).QueryRowContext(ctx, stmt, int64(version)).Scan(&p.cursor, &p.migrated, &p.done)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return p, fmt.Errorf("failed to read progress of version %d: %w", version, err)
+	}
+
+	return p, nil
+}
+
+// saveProgress records the progress of the migration of version, in the
+// transaction of ctx.
+func (m *migrator) saveProgress(ctx context.Context, version uint, p goProgress) error {
+	dialect := m.driver.Dialect()
+	q := sqldb.GetTx(ctx, m.db.DB())
+
+	stmt := fmt.Sprintf("UPDATE %s SET cursor_value = %s, migrated = %s, done = %s WHERE version = %s",
+		m.progressTable(), dialect.Placeholder(1), dialect.Placeholder(2), dialect.Placeholder(3), dialect.Placeholder(4))
+	res, err := q.ExecContext(ctx, stmt, p.cursor, p.migrated, p.done, int64(version))
+	if err != nil {
+		return fmt.Errorf("failed to record progress of version %d: %w", version, err)
+	}
+	if n, err := res.RowsAffected(); err != nil || n > 0 {
+		return err
+	}
+
+	stmt = fmt.Sprintf("INSERT INTO %s (version, cursor_value, migrated, done) VALUES (%s, %s, %s, %s)",
+		m.progressTable(), dialect.Placeholder(1), dialect.Placeholder(2), dialect.Placeholder(3), dialect.Placeholder(4))
+	if _, err := q.ExecContext(ctx, stmt, int64(version), p.cursor, p.migrated, p.done); err != nil {
+		return fmt.Errorf("failed to record progress of version %d: %w", version, err)
+	}
+
+	return nil
+}
+
+// clearProgress forgets the progress of the migration of version once its
+// version is set or unset.
+func (m *migrator) clearProgress(ctx context.Context, version uint) error {
+	stmt := fmt.Sprintf("DELETE FROM %s WHERE version = %s", m.progressTable(), m.driver.Dialect().Placeholder(1))
+	if _, err := sqldb.GetTx(ctx, m.db.DB()).ExecContext(ctx, stmt, int64(version)); err != nil {
+		return fmt.Errorf("failed to clear progress of version %d: %w", version, err)
+	}
+	return nil
+}
+
+// progressTable returns the quoted table of the progress of the Go
+// migrations of the service.
+func (m *migrator) progressTable() string {
+	return m.driver.Dialect().Quote(m.serviceName + "_schema_go_progress")
+}
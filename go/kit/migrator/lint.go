@@ -0,0 +1,397 @@
+package migrator
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"regexp"
+	"strings"
+
+	"github.com/golang-migrate/migrate/v4"
+)
+
+// LintRule is a kind of risky statement, see Lint.
+type LintRule string
+
+const (
+	// RuleIndexNotConcurrent flags index builds and drops locking the writes
+	// of their table, without CONCURRENTLY.
+	RuleIndexNotConcurrent LintRule = "index-not-concurrent"
+	// RuleNotNullWithoutDefault flags the NOT NULL columns added without
+	// default, failing on tables with rows.
+	RuleNotNullWithoutDefault LintRule = "not-null-without-default"
+	// RuleVolatileDefault flags the columns added with a volatile default,
+	// rewriting their table.
+	RuleVolatileDefault LintRule = "volatile-default"
+	// RuleTypeChange flags column type changes, rewriting their table.
+	RuleTypeChange LintRule = "type-change"
+	// RuleLongLock flags the statements holding an exclusive lock while
+	// scanning or rewriting their table.
+	RuleLongLock LintRule = "long-lock"
+	// RuleMissingLockTimeout flags the migrations locking existing tables
+	// without setting lock_timeout, queuing all their queries behind a lock
+	// they wait for.
+	RuleMissingLockTimeout LintRule = "missing-lock-timeout"
+)
+
+// LintIssue is a risky statement of a migration.
+type LintIssue struct {
+	// Migration is the version and name of the migration, as 2_add_email.
+	Migration string
+	Rule      LintRule
+	// Statement is the statement flagged, empty for the issues of the whole
+	// migration.
+	Statement string
+	Message   string
+}
+
+func (i LintIssue) String() string {
+	if i.Statement == "" {
+		return fmt.Sprintf("%s: %s: %s", i.Migration, i.Rule, i.Message)
+	}
+	return fmt.Sprintf("%s: %s: %s\n    %s", i.Migration, i.Rule, i.Message, i.Statement)
+}
+
+// Lint returns the risky statements of the pending SQL migrations, those
+// whose locks or table rewrites block the queries of a live PostgreSQL
+// database. Tables created by the same migration are empty, their
+// statements are not flagged.
+func (m *migrator) Lint(ctx context.Context, migrationsFS fs.FS) ([]LintIssue, error) {
+	pending, err := m.pending(migrationsFS)
+	if err != nil {
+		return nil, err
+	}
+
+	var issues []LintIssue
+	for _, p := range pending {
+		if p.goMig == nil {
+			issues = append(issues, lintMigration(p.id(), p.body)...)
+		}
+	}
+
+	return issues, nil
+}
+
+//nolint:gochecknoglobals // compiled once
+var (
+	reCreateTable     = regexp.MustCompile(`^CREATE (?:(?:GLOBAL |LOCAL )?(?:TEMP|TEMPORARY|UNLOGGED) )?TABLE (?:IF NOT EXISTS )?([^\s(]+)`)
+	reCreateIndex     = regexp.MustCompile(`^CREATE (?:UNIQUE )?INDEX (CONCURRENTLY )?.*? ON (?:ONLY )?([^\s(]+)`)
+	reDropIndex       = regexp.MustCompile(`^DROP INDEX (CONCURRENTLY )?`)
+	reReindex         = regexp.MustCompile(`^REINDEX (?:\(.*?\) )?(?:INDEX|TABLE|SCHEMA|DATABASE|SYSTEM) (CONCURRENTLY )?`)
+	reAlterTable      = regexp.MustCompile(`^ALTER TABLE (?:IF EXISTS )?(?:ONLY )?([^\s]+) (.*)$`)
+	reAddColumn       = regexp.MustCompile(`^ADD (?:COLUMN )?(?:IF NOT EXISTS )?[^\s]+ (.*)$`)
+	reAddConstraint   = regexp.MustCompile(`^ADD (?:CONSTRAINT [^\s]+ )?(FOREIGN KEY|CHECK|PRIMARY KEY|UNIQUE|EXCLUDE)\b`)
+	reTypeChange      = regexp.MustCompile(`^ALTER (?:COLUMN )?[^\s]+ (?:SET DATA )?TYPE\b`)
+	reSetNotNull      = regexp.MustCompile(`^ALTER (?:COLUMN )?[^\s]+ SET NOT NULL\b`)
+	reVolatile        = regexp.MustCompile(`\b(?:CLOCK_TIMESTAMP|RANDOM|GEN_RANDOM_UUID|UUID_GENERATE_V[14]|NEXTVAL|TIMEOFDAY)\s*\(|\b(?:SMALL|BIG)?SERIAL\b`)
+	reLongLock        = regexp.MustCompile(`^(?:VACUUM (?:\(.*?FULL.*?\)|FULL)|CLUSTER\b|LOCK (?:TABLE )?)`)
+	reLocking         = regexp.MustCompile(`^(?:ALTER TABLE|DROP TABLE|DROP INDEX|TRUNCATE|LOCK|CLUSTER|VACUUM|REINDEX|CREATE (?:UNIQUE )?INDEX)\b`)
+	reSetLockTimeout  = regexp.MustCompile(`^SET (?:LOCAL |SESSION )?LOCK_TIMEOUT\b`)
+	reNotValidClause  = regexp.MustCompile(`\bNOT VALID\b`)
+	reUsingIndex      = regexp.MustCompile(`\bUSING INDEX\b`)
+	reNotNull         = regexp.MustCompile(`\bNOT NULL\b`)
+	reDefault         = regexp.MustCompile(`\bDEFAULT\b`)
+	reConcurrently    = regexp.MustCompile(`\bCONCURRENTLY\b`)
+	reTransactionStmt = regexp.MustCompile(`^(?:BEGIN|START TRANSACTION|COMMIT|END|ROLLBACK)\b`)
+	reNonTransaction  = regexp.MustCompile(`^(?:VACUUM|CREATE DATABASE|DROP DATABASE|ALTER SYSTEM|CREATE TABLESPACE|DROP TABLESPACE)\b`)
+	reSpaces          = regexp.MustCompile(`\s+`)
+)
+
+// lintMigration returns the issues of the statements of the SQL migration.
+func lintMigration(migration, body string) []LintIssue {
+	stmts := splitStatements(body)
+
+	created := map[string]bool{}
+	for _, stmt := range stmts {
+		if match := reCreateTable.FindStringSubmatch(normalize(stmt)); match != nil {
+			created[tableName(match[1])] = true
+		}
+	}
+
+	var (
+		issues      []LintIssue
+		locking     bool
+		lockTimeout bool
+	)
+	flag := func(stmt string, rule LintRule, msg string) {
+		issues = append(issues, LintIssue{Migration: migration, Rule: rule, Statement: stmt, Message: msg})
+	}
+	for _, stmt := range stmts {
+		s := normalize(stmt)
+		if reSetLockTimeout.MatchString(s) {
+			lockTimeout = true
+			continue
+		}
+
+		switch {
+		case reCreateIndex.MatchString(s):
+			match := reCreateIndex.FindStringSubmatch(s)
+			if created[tableName(match[2])] {
+				continue
+			}
+			if match[1] == "" {
+				flag(stmt, RuleIndexNotConcurrent, "building the index blocks the writes of the table, create it CONCURRENTLY")
+			}
+		case reDropIndex.MatchString(s):
+			if reDropIndex.FindStringSubmatch(s)[1] == "" {
+				flag(stmt, RuleIndexNotConcurrent, "dropping the index blocks the queries of the table, drop it CONCURRENTLY")
+			}
+		case reReindex.MatchString(s):
+			if reReindex.FindStringSubmatch(s)[1] == "" {
+				flag(stmt, RuleIndexNotConcurrent, "rebuilding the index blocks the writes of the table, reindex CONCURRENTLY")
+			}
+		case reAlterTable.MatchString(s):
+			match := reAlterTable.FindStringSubmatch(s)
+			if created[tableName(match[1])] {
+				continue
+			}
+			for _, action := range splitTopLevel(match[2], ',') {
+				lintAlterAction(stmt, action, flag)
+			}
+		case reLongLock.MatchString(s):
+			flag(stmt, RuleLongLock, "the statement locks the table exclusively while it runs")
+		}
+		if reLocking.MatchString(s) && !reConcurrently.MatchString(s) {
+			locking = true
+		}
+	}
+	if locking && !lockTimeout {
+		issues = append(issues, LintIssue{
+			Migration: migration,
+			Rule:      RuleMissingLockTimeout,
+			Message:   "the migration locks existing tables without SET lock_timeout first",
+		})
+	}
+
+	return issues
+}
+
+// lintAlterAction flags the action of an ALTER TABLE statement of an
+// existing table.
+func lintAlterAction(stmt, action string, flag func(stmt string, rule LintRule, msg string)) {
+	action = strings.TrimSpace(action)
+	switch {
+	case reAddConstraint.MatchString(action):
+		kind := reAddConstraint.FindStringSubmatch(action)[1]
+		switch {
+		case (kind == "FOREIGN KEY" || kind == "CHECK") && !reNotValidClause.MatchString(action):
+			flag(stmt, RuleLongLock, "validating the constraint scans the table under lock, add it NOT VALID and VALIDATE it apart")
+		case (kind == "PRIMARY KEY" || kind == "UNIQUE") && !reUsingIndex.MatchString(action):
+			flag(stmt, RuleLongLock, "the constraint builds its index under lock, build it CONCURRENTLY and add it USING INDEX")
+		case kind == "EXCLUDE":
+			flag(stmt, RuleLongLock, "the constraint builds its index under lock")
+		}
+	case reAddColumn.MatchString(action):
+		def := reAddColumn.FindStringSubmatch(action)[1]
+		if reNotNull.MatchString(def) && !reDefault.MatchString(def) {
+			flag(stmt, RuleNotNullWithoutDefault, "adding a NOT NULL column without default fails on a table with rows")
+		}
+		if reVolatile.MatchString(def) {
+			flag(stmt, RuleVolatileDefault, "the volatile default rewrites the table under lock, add the column without it and backfill")
+		}
+	case reTypeChange.MatchString(action):
+		flag(stmt, RuleTypeChange, "changing the column type rewrites the table under lock")
+	case reSetNotNull.MatchString(action):
+		flag(stmt, RuleLongLock, "setting NOT NULL scans the table under lock, validate a CHECK (col IS NOT NULL) NOT VALID constraint first")
+	}
+}
+
+// isTransactional reports whether the statements of the SQL migration can
+// run in a transaction.
+func isTransactional(body string) bool {
+	for _, stmt := range splitStatements(body) {
+		s := normalize(stmt)
+		if reConcurrently.MatchString(s) || reTransactionStmt.MatchString(s) || reNonTransaction.MatchString(s) {
+			return false
+		}
+	}
+	return true
+}
+
+// normalize returns the statement upper-cased, with its spaces collapsed.
+func normalize(stmt string) string {
+	return strings.ToUpper(reSpaces.ReplaceAllString(strings.TrimSpace(stmt), " "))
+}
+
+// tableName returns the normalized name of a table, without its quotes.
+func tableName(name string) string {
+	return strings.ToLower(strings.ReplaceAll(name, `"`, ""))
+}
+
+// splitStatements returns the statements of the SQL, without their
+// comments. Semicolons within quotes, quoted identifiers and dollar-quoted
+// bodies do not end statements.
+func splitStatements(sql string) []string {
+	var (
+		stmts []string
+		cur   strings.Builder
+	)
+	end := func() {
+		if s := strings.TrimSpace(cur.String()); s != "" {
+			stmts = append(stmts, s)
+		}
+		cur.Reset()
+	}
+
+	for i := 0; i < len(sql); i++ {
+		c := sql[i]
+		switch {
+		case c == '-' && strings.HasPrefix(sql[i:], "--"):
+			n := strings.IndexByte(sql[i:], '\n')
+			if n < 0 {
+				n = len(sql) - i
+			}
+			i += n - 1
+			cur.WriteByte(' ')
+		case c == '/' && strings.HasPrefix(sql[i:], "/*"):
+			n := strings.Index(sql[i+2:], "*/")
+			if n < 0 {
+				i = len(sql)
+			} else {
+				i += n + 3
+			}
+			cur.WriteByte(' ')
+		case c == '\'' || c == '"':
+			n := quoteEnd(sql[i+1:], c)
+			cur.WriteString(sql[i : i+n+2])
+			i += n + 1
+		case c == '$':
+			quoted, ok := dollarQuote(sql[i:])
+			if !ok {
+				cur.WriteByte(c)
+				continue
+			}
+			cur.WriteString(quoted)
+			i += len(quoted) - 1
+		case c == ';':
+			end()
+		default:
+			cur.WriteByte(c)
+		}
+	}
+	end()
+
+	return stmts
+}
+
+// quoteEnd returns the index in s of the quote q closing a quoted string,
+// doubled quotes being escaped ones.
+func quoteEnd(s string, q byte) int {
+	for i := 0; i < len(s); i++ {
+		if s[i] != q {
+			continue
+		}
+		if i+1 < len(s) && s[i+1] == q {
+			i++
+			continue
+		}
+		return i
+	}
+	return len(s) - 1
+}
+
+// dollarQuote returns the dollar-quoted string s starts with, as
+// $tag$...$tag$, false when s does not start with a tag.
+func dollarQuote(s string) (string, bool) {
+	n := strings.IndexByte(s[1:], '$')
+	if n < 0 {
+		return "", false
+	}
+	tag := s[:n+2]
+	for _, r := range tag[1 : len(tag)-1] {
+		if r != '_' && (r < 'a' || r > 'z') && (r < 'A' || r > 'Z') && (r < '0' || r > '9') {
+			return "", false
+		}
+	}
+	end := strings.Index(s[len(tag):], tag)
+	if end < 0 {
+		return s, true
+	}
+
+	return s[:len(tag)+end+len(tag)], true
+}
+
+// splitTopLevel splits s on sep outside of parentheses.
+func splitTopLevel(s string, sep rune) []string {
+	var (
+		parts []string
+		depth int
+		start int
+	)
+	for i, r := range s {
+		switch r {
+		case '(':
+			depth++
+		case ')':
+			depth--
+		case sep:
+			if depth == 0 {
+				parts = append(parts, s[start:i])
+				start = i + 1
+			}
+		}
+	}
+
+	return append(parts, s[start:])
+}
+
+// pendingMigration is a migration above the current version.
+type pendingMigration struct {
+	version uint
+	name    string
+	body    string
+	goMig   *GoMigration
+}
+
+func (p pendingMigration) id() string {
+	return fmt.Sprintf("%d_%s", p.version, p.name)
+}
+
+// pending returns the migrations above the current version, with the body
+// of the SQL ones.
+func (m *migrator) pending(migrationsFS fs.FS) ([]pendingMigration, error) {
+	src, err := m.source(migrationsFS)
+	if err != nil {
+		return nil, err
+	}
+	mg, closeFn, err := m.open(src)
+	if err != nil {
+		return nil, err
+	}
+	defer closeFn()
+
+	version, _, err := mg.Version()
+	if err != nil && !errors.Is(err, migrate.ErrNilVersion) {
+		return nil, fmt.Errorf("failed to get version: %w", err)
+	}
+	hasVersion := err == nil
+
+	var pending []pendingMigration
+	for _, v := range src.versions {
+		if hasVersion && v <= version {
+			continue
+		}
+		if g, ok := src.migrations[v]; ok {
+			pending = append(pending, pendingMigration{version: v, name: g.Name, goMig: g})
+			continue
+		}
+
+		r, name, err := src.ReadUp(v)
+		if errors.Is(err, fs.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read migration %d: %w", v, err)
+		}
+		body, err := io.ReadAll(r)
+		r.Close()
+		if err != nil {
+			return nil, fmt.Errorf("failed to read migration %d: %w", v, err)
+		}
+		pending = append(pending, pendingMigration{version: v, name: name, body: string(body)})
+	}
+
+	return pending, nil
+}
@@ -5,15 +5,20 @@ import (
 	"errors"
 	"fmt"
 	"io/fs"
+	"net/url"
 	"path/filepath"
 	"sort"
 	"strings"
 
 	"github.com/golang-migrate/migrate/v4"
+	_ "github.com/golang-migrate/migrate/v4/database/mysql"
 	_ "github.com/golang-migrate/migrate/v4/database/postgres"
+	_ "github.com/golang-migrate/migrate/v4/database/sqlite"
+	"github.com/golang-migrate/migrate/v4/source"
 	"github.com/golang-migrate/migrate/v4/source/iofs"
 
 	"github.com/dosanma1/forge/go/kit/monitoring/logger"
+	"github.com/dosanma1/forge/go/kit/persistence"
 	"github.com/dosanma1/forge/go/kit/persistence/sqldb"
 )
 
@@ -22,6 +27,12 @@ type migrator struct {
 	db          *sqldb.DBClient
 	logger      logger.Logger
 	serviceName string
+	driver      sqldb.DriverType
+	validate    bool
+	tx          persistence.Transactioner
+
+	goMigrations []GoMigration
+	goByVersion  map[uint]*GoMigration
 }
 
 // option configures a migrator.
@@ -41,11 +52,29 @@ func WithServiceName(name string) option {
 	}
 }
 
+// WithDriver sets the database driver the migrations run on. Defaults to
+// sqldb.DriverTypePostgres.
+func WithDriver(driver sqldb.DriverType) option {
+	return func(m *migrator) {
+		m.driver = driver
+	}
+}
+
+// WithChecksumValidation sets whether migrating fails when applied migrations
+// were edited since, see Validate. Defaults to true.
+func WithChecksumValidation(enabled bool) option {
+	return func(m *migrator) {
+		m.validate = enabled
+	}
+}
+
 // defaultOptions returns the default options for a migrator.
 func defaultOptions() []option {
 	return []option{
 		WithLogger(logger.New()),
 		WithServiceName("default"),
+		WithDriver(sqldb.DriverTypePostgres),
+		WithChecksumValidation(true),
 	}
 }
 
@@ -58,6 +87,7 @@ func New(db *sqldb.DBClient, opts ...option) (*migrator, error) {
 
 	m := &migrator{
 		db: db,
+		tx: sqldb.NewTransactioner(db.DB()),
 	}
 
 	// Apply default options first, then user options
@@ -65,6 +95,12 @@ func New(db *sqldb.DBClient, opts ...option) (*migrator, error) {
 		opt(m)
 	}
 
+	byVersion, err := indexGoMigrations(m.goMigrations)
+	if err != nil {
+		return nil, err
+	}
+	m.goByVersion = byVersion
+
 	return m, nil
 }
 
@@ -100,34 +136,100 @@ func (m *migrator) Run(ctx context.Context, migrationsFS fs.FS) error {
 func (m *migrator) runMigrations(ctx context.Context, migrationsFS fs.FS) error {
 	m.logger.Info("📦 Running database migrations...")
 
-	// Create source from embedded filesystem
-	d, err := iofs.New(migrationsFS, "migrations")
-	if err != nil {
-		return fmt.Errorf("failed to read migrations folder: %w", err)
+	return m.migrate(ctx, migrationsFS, func(versions []uint, _ int) (int, error) {
+		return len(versions) - 1, nil
+	})
+}
+
+// Down reverts the last steps applied migrations.
+func (m *migrator) Down(ctx context.Context, migrationsFS fs.FS, steps int) error {
+	if steps < 1 {
+		return fmt.Errorf("steps must be positive, got %d", steps)
 	}
+	m.logger.WithKeysAndValues("steps", steps).Info("⏪ Reverting database migrations...")
 
-	// Build DSN with service-specific migration table
-	dsn, err := sqldb.NewDSN(sqldb.DriverTypePostgres)
+	return m.migrate(ctx, migrationsFS, func(_ []uint, current int) (int, error) {
+		if steps > current+1 {
+			return 0, fmt.Errorf("cannot revert %d migrations, %d are applied", steps, current+1)
+		}
+		return current - steps, nil
+	})
+}
+
+// Goto migrates up or down to version.
+func (m *migrator) Goto(ctx context.Context, migrationsFS fs.FS, version uint) error {
+	m.logger.WithKeysAndValues("version", version).Info("🎯 Migrating to version...")
+
+	return m.migrate(ctx, migrationsFS, func(versions []uint, _ int) (int, error) {
+		for i, v := range versions {
+			if v == version {
+				return i, nil
+			}
+		}
+		return 0, fmt.Errorf("no migration of version %d", version)
+	})
+}
+
+// Force sets the current version, -1 meaning none, and clears the dirty
+// state without migrating. It repairs the database after a failed migration
+// once its changes were completed or undone by hand.
+func (m *migrator) Force(ctx context.Context, migrationsFS fs.FS, version int) error {
+	m.logger.WithKeysAndValues("version", version).Warn("🔧 Forcing version")
+
+	migrations, err := m.loadMigrations(migrationsFS)
+	if err != nil {
+		return err
+	}
+	src, err := m.source(migrationsFS)
+	if err != nil {
+		return err
+	}
+	mg, closeFn, err := m.open(src)
 	if err != nil {
-		return fmt.Errorf("failed to create DSN: %w", err)
+		return err
 	}
+	defer closeFn()
 
-	// Add migration table parameter
-	serviceDSN := fmt.Sprintf("%s&x-migrations-table=%s_schema_migrations", dsn, m.serviceName)
+	if err := mg.Force(version); err != nil {
+		return fmt.Errorf("force failed: %w", err)
+	}
 
-	// Create migrate instance
-	migrator, err := migrate.NewWithSourceInstance("iofs", d, serviceDSN)
+	return m.syncChecksums(ctx, mg, migrations)
+}
+
+// targetFunc returns the index of the version to migrate to among the
+// versions, from the index of the current one, -1 meaning none.
+type targetFunc func(versions []uint, current int) (int, error)
+
+// migrate validates the checksums of the applied migrations, migrates to the
+// version of target and records the checksums of the migrations applied then.
+func (m *migrator) migrate(ctx context.Context, migrationsFS fs.FS, target targetFunc) error {
+	migrations, err := m.loadMigrations(migrationsFS)
 	if err != nil {
-		return fmt.Errorf("failed to create migrate instance: %w", err)
+		return err
 	}
-	defer func() {
-		if srcErr, dbErr := migrator.Close(); srcErr != nil || dbErr != nil {
-			m.logger.WithKeysAndValues("source_err", srcErr, "db_err", dbErr).Warn("⚠️  Failed to close migrate instance")
+	if len(migrations) == 0 {
+		m.logger.Info("⏭️  No migrations found")
+		return nil
+	}
+	if m.validate {
+		if err := m.validateChecksums(ctx, migrations); err != nil {
+			return err
 		}
-	}()
+	}
+
+	src, err := m.source(migrationsFS)
+	if err != nil {
+		return err
+	}
+	mg, closeFn, err := m.open(src)
+	if err != nil {
+		return err
+	}
+	defer closeFn()
 
 	// Get current version
-	currentVersion, dirty, err := migrator.Version()
+	currentVersion, dirty, err := mg.Version()
 	if err != nil && !errors.Is(err, migrate.ErrNilVersion) {
 		m.logger.WithKeysAndValues("error", err).Warn("⚠️  Could not determine current version")
 	} else if errors.Is(err, migrate.ErrNilVersion) {
@@ -136,17 +238,24 @@ func (m *migrator) runMigrations(ctx context.Context, migrationsFS fs.FS) error
 		m.logger.WithKeysAndValues("version", currentVersion, "dirty", dirty).Info("📊 Current version")
 	}
 
-	// Run migration up
-	if err := migrator.Up(); err != nil {
-		if errors.Is(err, migrate.ErrNoChange) {
-			m.logger.Info("✅ No new migrations to apply")
-			return nil
+	err = m.step(ctx, mg, src, target)
+	// The migrations applied before a failure are recorded too
+	if syncErr := m.syncChecksums(ctx, mg, migrations); syncErr != nil {
+		if err == nil || errors.Is(err, migrate.ErrNoChange) {
+			return syncErr
 		}
-		return fmt.Errorf("migration up failed: %w", err)
+		m.logger.WithKeysAndValues("error", syncErr).Warn("⚠️  Could not record the migration checksums")
+	}
+	if errors.Is(err, migrate.ErrNoChange) {
+		m.logger.Info("✅ No new migrations to apply")
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("migration failed: %w", err)
 	}
 
 	// Get final version
-	finalVersion, finalDirty, err := migrator.Version()
+	finalVersion, finalDirty, err := mg.Version()
 	if err == nil {
 		m.logger.WithKeysAndValues("version", finalVersion, "dirty", finalDirty).Info("📊 Final version")
 	}
@@ -155,6 +264,105 @@ func (m *migrator) runMigrations(ctx context.Context, migrationsFS fs.FS) error
 	return nil
 }
 
+// step migrates one version at a time to the version of target, running the
+// Go migrations before setting their version and after unsetting it.
+func (m *migrator) step(ctx context.Context, mg *migrate.Migrate, src *goSource, target targetFunc) error {
+	version, dirty, err := mg.Version()
+	current := -1
+	switch {
+	case errors.Is(err, migrate.ErrNilVersion):
+	case err != nil:
+		return err
+	case dirty:
+		return migrate.ErrDirty{Version: int(version)}
+	default:
+		if current = src.index(version); current < 0 {
+			return fmt.Errorf("no migration of the current version %d", version)
+		}
+	}
+
+	to, err := target(src.versions, current)
+	if err != nil {
+		return err
+	}
+	if to == current {
+		return migrate.ErrNoChange
+	}
+
+	for ; current < to; current++ {
+		g := src.migrations[src.versions[current+1]]
+		if g != nil {
+			if err := m.runGoUp(ctx, g); err != nil {
+				return err
+			}
+		}
+		if err := mg.Steps(1); err != nil {
+			return err
+		}
+		if g != nil {
+			if err := m.clearProgress(ctx, g.Version); err != nil {
+				return err
+			}
+		}
+	}
+	for ; current > to; current-- {
+		if g := src.migrations[src.versions[current]]; g != nil {
+			if err := m.runGoDown(ctx, g); err != nil {
+				return err
+			}
+		}
+		if err := mg.Steps(-1); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// source returns the SQL migrations of migrationsFS with the Go migrations.
+func (m *migrator) source(migrationsFS fs.FS) (*goSource, error) {
+	d, err := iofs.New(migrationsFS, "migrations")
+	if err != nil {
+		return nil, fmt.Errorf("failed to read migrations folder: %w", err)
+	}
+
+	src, err := newGoSource(d, m.goByVersion)
+	if err != nil {
+		_ = d.Close()
+		return nil, err
+	}
+
+	return src, nil
+}
+
+// open returns the golang-migrate instance of the migrations of d, and the
+// function closing it.
+func (m *migrator) open(d source.Driver) (*migrate.Migrate, func(), error) {
+	// Build DSN with service-specific migration table
+	dsn, err := sqldb.NewDSN(m.driver)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to create DSN: %w", err)
+	}
+
+	// Add migration table parameter
+	serviceDSN, err := migrateURL(dsn, m.serviceName+"_schema_migrations")
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to create DSN: %w", err)
+	}
+
+	// Create migrate instance
+	mg, err := migrate.NewWithSourceInstance("iofs", d, serviceDSN)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to create migrate instance: %w", err)
+	}
+
+	return mg, func() {
+		if srcErr, dbErr := mg.Close(); srcErr != nil || dbErr != nil {
+			m.logger.WithKeysAndValues("source_err", srcErr, "db_err", dbErr).Warn("⚠️  Failed to close migrate instance")
+		}
+	}, nil
+}
+
 // executeScripts executes SQL scripts from a directory.
 // Silently skips if directory doesn't exist (scripts are optional).
 func (m *migrator) executeScripts(ctx context.Context, migrationsFS fs.FS, scriptPath, scriptType string) error {
@@ -211,8 +419,15 @@ func (m *migrator) executeScripts(ctx context.Context, migrationsFS fs.FS, scrip
 
 // Up is a convenience function that creates DB from environment and runs migrations.
 func Up(ctx context.Context, migrationsFS fs.FS, opts ...option) error {
+	return withEnvDB(func(m *migrator) error {
+		return m.Run(ctx, migrationsFS)
+	}, opts...)
+}
+
+// withEnvDB runs fn with a migrator of the database of the environment.
+func withEnvDB(fn func(m *migrator) error, opts ...option) error {
 	// Create DB from environment variables
-	dsn, err := sqldb.NewDSN(sqldb.DriverTypePostgres)
+	dsn, err := sqldb.NewDSN(driverOf(opts...))
 	if err != nil {
 		return fmt.Errorf("failed to generate DSN: %w", err)
 	}
@@ -231,5 +446,40 @@ func Up(ctx context.Context, migrationsFS fs.FS, opts ...option) error {
 		return err
 	}
 
-	return m.Run(ctx, migrationsFS)
+	return fn(m)
+}
+
+// driverOf returns the driver configured by the options.
+func driverOf(opts ...option) sqldb.DriverType {
+	m := new(migrator)
+	for _, opt := range append(defaultOptions(), opts...) {
+		opt(m)
+	}
+
+	return m.driver
+}
+
+// migrateURL returns the golang-migrate database URL of the connection URL,
+// tracking the migrations in table.
+func migrateURL(connURL *url.URL, table string) (string, error) {
+	var migrateURL string
+	switch driver := sqldb.DriverType(connURL.Scheme); driver {
+	case sqldb.DriverTypePostgres:
+		migrateURL = connURL.String()
+	case sqldb.DriverTypeMySQL, sqldb.DriverTypeSQLite:
+		dsn, err := sqldb.DriverDSN(connURL)
+		if err != nil {
+			return "", err
+		}
+		migrateURL = fmt.Sprintf("%s://%s", driver, dsn)
+	default:
+		return "", fmt.Errorf("unsupported driver: %s", driver)
+	}
+
+	sep := "?"
+	if strings.Contains(migrateURL, "?") {
+		sep = "&"
+	}
+
+	return migrateURL + sep + "x-migrations-table=" + url.QueryEscape(table), nil
 }
@@ -2,12 +2,16 @@ package migrator_test
 
 import (
 	"context"
+	"database/sql"
 	"embed"
 	"fmt"
 	"io/fs"
+	"path/filepath"
 	"reflect"
 	"testing"
+	"testing/fstest"
 
+	apierrors "github.com/dosanma1/forge/go/kit/errors"
 	"github.com/dosanma1/forge/go/kit/migrator"
 	"github.com/dosanma1/forge/go/kit/monitoring/logger"
 	"github.com/dosanma1/forge/go/kit/persistence/sqldb"
@@ -337,3 +341,461 @@ func (e *emptyFS) ReadDir(name string) ([]fs.DirEntry, error) {
 func (e *emptyFS) ReadFile(name string) ([]byte, error) {
 	return nil, fs.ErrNotExist
 }
+
+// TestUpSQLite tests running migrations on a sqlite database.
+func TestUpSQLite(t *testing.T) {
+	ctx := context.Background()
+	dbFile := filepath.Join(t.TempDir(), "test.db")
+	t.Setenv("DB_NAME", dbFile)
+
+	migrationsFS := fstest.MapFS{
+		"migrations/000001_create_users.up.sql": {Data: []byte(
+			"CREATE TABLE users (id INTEGER PRIMARY KEY, username TEXT NOT NULL UNIQUE);",
+		)},
+		"migrations/000002_add_users.up.sql": {Data: []byte(
+			"INSERT INTO users (username) VALUES ('testuser1'), ('testuser2');",
+		)},
+	}
+	up := func() error {
+		return migrator.Up(ctx, migrationsFS, migrator.WithDriver(sqldb.DriverTypeSQLite), migrator.WithServiceName("test"))
+	}
+	require.NoError(t, up())
+	// Running again is a no-op
+	require.NoError(t, up())
+
+	db, err := sqldb.Connect(sqldb.MustGenerateDSN(sqldb.DriverTypeSQLite, sqldb.WithConnDBName(dbFile)))
+	require.NoError(t, err)
+	defer db.Close()
+
+	var count int
+	require.NoError(t, db.QueryRowContext(ctx, "SELECT COUNT(*) FROM users").Scan(&count))
+	assert.Equal(t, 2, count)
+	require.NoError(t, db.QueryRowContext(ctx, "SELECT version FROM test_schema_migrations").Scan(&count))
+	assert.Equal(t, 2, count)
+}
+
+// newSQLiteMigrator returns a migrator of a new sqlite database.
+func newSQLiteMigrator(t *testing.T, validate bool) (*sql.DB, interface {
+	Run(context.Context, fs.FS) error
+	Down(context.Context, fs.FS, int) error
+	Goto(context.Context, fs.FS, uint) error
+	Force(context.Context, fs.FS, int) error
+	Status(context.Context, fs.FS) (*migrator.Status, error)
+	Validate(context.Context, fs.FS) error
+	Lint(context.Context, fs.FS) ([]migrator.LintIssue, error)
+	DryRun(context.Context, fs.FS) (*migrator.DryRunReport, error)
+}) {
+	t.Helper()
+
+	db := newSQLiteDB(t)
+	m, err := migrator.New(sqldb.NewDBClient(db),
+		migrator.WithDriver(sqldb.DriverTypeSQLite),
+		migrator.WithServiceName("test"),
+		migrator.WithChecksumValidation(validate),
+	)
+	require.NoError(t, err)
+
+	return db, m
+}
+
+// newSQLiteDB returns a new sqlite database, the one of the environment.
+func newSQLiteDB(t *testing.T) *sql.DB {
+	t.Helper()
+
+	dbFile := filepath.Join(t.TempDir(), "test.db")
+	t.Setenv("DB_NAME", dbFile)
+	db, err := sqldb.Connect(sqldb.MustGenerateDSN(sqldb.DriverTypeSQLite, sqldb.WithConnDBName(dbFile)))
+	require.NoError(t, err)
+	t.Cleanup(func() { db.Close() })
+
+	return db
+}
+
+func versionedFS() fstest.MapFS {
+	migrationsFS := fstest.MapFS{}
+	for i, table := range []string{"users", "teams", "roles"} {
+		prefix := fmt.Sprintf("migrations/%06d_create_%s", i+1, table)
+		migrationsFS[prefix+".up.sql"] = &fstest.MapFile{Data: []byte(fmt.Sprintf("CREATE TABLE %s (id INTEGER PRIMARY KEY);", table))}
+		migrationsFS[prefix+".down.sql"] = &fstest.MapFile{Data: []byte(fmt.Sprintf("DROP TABLE %s;", table))}
+	}
+	return migrationsFS
+}
+
+func tableExists(t *testing.T, db *sql.DB, table string) bool {
+	t.Helper()
+
+	var n int
+	require.NoError(t, db.QueryRow("SELECT COUNT(*) FROM sqlite_master WHERE type = 'table' AND name = ?", table).Scan(&n))
+	return n > 0
+}
+
+func appliedVersions(t *testing.T, status *migrator.Status) []uint {
+	t.Helper()
+
+	var versions []uint
+	for _, mig := range status.Migrations {
+		if mig.Applied {
+			versions = append(versions, mig.Version)
+		}
+	}
+	return versions
+}
+
+// TestDownGotoSQLite tests reverting and targeting versions.
+func TestDownGotoSQLite(t *testing.T) {
+	ctx := context.Background()
+	db, m := newSQLiteMigrator(t, true)
+	migrationsFS := versionedFS()
+
+	require.NoError(t, m.Run(ctx, migrationsFS))
+	require.NoError(t, m.Down(ctx, migrationsFS, 2))
+	assert.True(t, tableExists(t, db, "users"))
+	assert.False(t, tableExists(t, db, "teams"))
+
+	status, err := m.Status(ctx, migrationsFS)
+	require.NoError(t, err)
+	assert.Equal(t, uint(1), status.Version)
+	assert.Equal(t, []uint{1}, appliedVersions(t, status))
+
+	require.NoError(t, m.Goto(ctx, migrationsFS, 3))
+	assert.True(t, tableExists(t, db, "roles"))
+	require.NoError(t, m.Goto(ctx, migrationsFS, 2))
+	assert.False(t, tableExists(t, db, "roles"))
+
+	assert.Error(t, m.Down(ctx, migrationsFS, 0))
+}
+
+// TestStatusSQLite tests listing the migrations with their checksums.
+func TestStatusSQLite(t *testing.T) {
+	ctx := context.Background()
+	_, m := newSQLiteMigrator(t, true)
+	migrationsFS := versionedFS()
+	require.NoError(t, m.Goto(ctx, migrationsFS, 2))
+
+	status, err := m.Status(ctx, migrationsFS)
+	require.NoError(t, err)
+	assert.Equal(t, uint(2), status.Version)
+	assert.False(t, status.Dirty)
+	require.Len(t, status.Migrations, 3)
+	assert.Equal(t, "create_users", status.Migrations[0].Name)
+	assert.Equal(t, []uint{1, 2}, appliedVersions(t, status))
+	for _, mig := range status.Migrations[:2] {
+		assert.Len(t, mig.Checksum, 64)
+		assert.Equal(t, mig.Checksum, mig.AppliedChecksum)
+	}
+	assert.Empty(t, status.Migrations[2].AppliedChecksum)
+}
+
+// TestChecksumDriftSQLite tests that changed applied migrations are detected.
+func TestChecksumDriftSQLite(t *testing.T) {
+	ctx := context.Background()
+	migrationsFS := versionedFS()
+
+	t.Run("changed migrations stop migrating", func(t *testing.T) {
+		_, m := newSQLiteMigrator(t, true)
+		require.NoError(t, m.Goto(ctx, migrationsFS, 2))
+
+		changedFS := versionedFS()
+		changedFS["migrations/000001_create_users.up.sql"].Data = []byte("CREATE TABLE users (id INTEGER PRIMARY KEY, name TEXT);")
+		delete(changedFS, "migrations/000002_create_teams.up.sql")
+
+		assert.ErrorContains(t, m.Run(ctx, changedFS), "applied migrations were changed: 1_create_users, 2 (missing)")
+		assert.True(t, apierrors.Is(m.Validate(ctx, changedFS), apierrors.CodeChecksumMismatch))
+
+		status, err := m.Status(ctx, changedFS)
+		require.NoError(t, err)
+		assert.True(t, status.Migrations[0].Drifted())
+		assert.True(t, status.Migrations[1].Drifted())
+		assert.NoError(t, m.Validate(ctx, migrationsFS))
+	})
+
+	t.Run("validation can be disabled", func(t *testing.T) {
+		_, m := newSQLiteMigrator(t, false)
+		require.NoError(t, m.Goto(ctx, migrationsFS, 1))
+
+		changedFS := versionedFS()
+		changedFS["migrations/000001_create_users.up.sql"].Data = []byte("-- edited\n")
+		assert.NoError(t, m.Run(ctx, changedFS))
+	})
+}
+
+// TestForceSQLite tests repairing a dirty database.
+func TestForceSQLite(t *testing.T) {
+	ctx := context.Background()
+	db, m := newSQLiteMigrator(t, true)
+	brokenFS := versionedFS()
+	brokenFS["migrations/000002_create_teams.up.sql"].Data = []byte("CREATE TABLE teams (id INTEGER PRIMARY KEY,);")
+
+	require.Error(t, m.Run(ctx, brokenFS))
+	status, err := m.Status(ctx, brokenFS)
+	require.NoError(t, err)
+	assert.Equal(t, uint(2), status.Version)
+	assert.True(t, status.Dirty)
+	assert.Error(t, m.Run(ctx, brokenFS), "a dirty database cannot be migrated")
+
+	require.NoError(t, m.Force(ctx, brokenFS, 1))
+	require.NoError(t, m.Run(ctx, versionedFS()))
+	assert.True(t, tableExists(t, db, "roles"))
+}
+
+// goMigrationsFS creates users with names, then the column the Go migration
+// of version 2 backfills.
+func goMigrationsFS() fstest.MapFS {
+	return fstest.MapFS{
+		"migrations/000001_create_users.up.sql": {Data: []byte(
+			"CREATE TABLE users (id INTEGER PRIMARY KEY, name TEXT NOT NULL, upper_name TEXT);" +
+				"INSERT INTO users (name) VALUES ('ann'), ('bob'), ('cid'), ('dan'), ('eve');",
+		)},
+		"migrations/000001_create_users.down.sql": {Data: []byte("DROP TABLE users;")},
+		"migrations/000003_create_teams.up.sql":   {Data: []byte("CREATE TABLE teams (id INTEGER PRIMARY KEY);")},
+		"migrations/000003_create_teams.down.sql": {Data: []byte("DROP TABLE teams;")},
+	}
+}
+
+// backfillUpperNames returns the Go migration of version 2 backfilling
+// upper_name, in batches of 2, failing on the batch of failAt.
+func backfillUpperNames(batches *[]string, failAt string) migrator.GoMigration {
+	return migrator.GoMigration{
+		Version:   2,
+		Name:      "backfill_upper_names",
+		BatchSize: 2,
+		Batch: func(ctx context.Context, env migrator.Env, cursor string, size int) (string, int, error) {
+			if cursor == "" {
+				cursor = "0"
+			}
+			if cursor == failAt {
+				return "", 0, fmt.Errorf("failed at %s", cursor)
+			}
+			*batches = append(*batches, cursor)
+
+			tx := env.Tx(ctx)
+			rows, err := tx.QueryContext(ctx, "SELECT id FROM users WHERE id > ? ORDER BY id LIMIT ?", cursor, size)
+			if err != nil {
+				return "", 0, err
+			}
+			var ids []string
+			for rows.Next() {
+				var id string
+				if err := rows.Scan(&id); err != nil {
+					rows.Close()
+					return "", 0, err
+				}
+				ids = append(ids, id)
+			}
+			rows.Close()
+
+			for _, id := range ids {
+				if _, err := tx.ExecContext(ctx, "UPDATE users SET upper_name = UPPER(name) WHERE id = ?", id); err != nil {
+					return "", 0, err
+				}
+				cursor = id
+			}
+			return cursor, len(ids), nil
+		},
+		Down: func(ctx context.Context, env migrator.Env) error {
+			_, err := env.Tx(ctx).ExecContext(ctx, "UPDATE users SET upper_name = NULL")
+			return err
+		},
+	}
+}
+
+func countUpperNames(t *testing.T, db *sql.DB) int {
+	t.Helper()
+
+	var n int
+	require.NoError(t, db.QueryRow("SELECT COUNT(upper_name) FROM users").Scan(&n))
+	return n
+}
+
+// TestGoMigrationsSQLite tests running Go migrations between the SQL ones.
+func TestGoMigrationsSQLite(t *testing.T) {
+	ctx := context.Background()
+	migrationsFS := goMigrationsFS()
+
+	t.Run("runs in version order and reverts", func(t *testing.T) {
+		db := newSQLiteDB(t)
+		var batches []string
+		m, err := migrator.New(sqldb.NewDBClient(db),
+			migrator.WithDriver(sqldb.DriverTypeSQLite),
+			migrator.WithServiceName("test"),
+			migrator.WithGoMigrations(backfillUpperNames(&batches, ""), migrator.GoMigration{
+				Version: 4,
+				Name:    "rename_ann",
+				Up: func(ctx context.Context, env migrator.Env) error {
+					var teams int
+					if err := env.Tx(ctx).QueryRowContext(ctx, "SELECT COUNT(*) FROM teams").Scan(&teams); err != nil {
+						return err
+					}
+					_, err := env.Tx(ctx).ExecContext(ctx, "UPDATE users SET name = 'anna' WHERE name = 'ann'")
+					return err
+				},
+			}),
+		)
+		require.NoError(t, err)
+
+		require.NoError(t, m.Run(ctx, migrationsFS))
+		assert.Equal(t, []string{"0", "2", "4"}, batches)
+		assert.Equal(t, 5, countUpperNames(t, db))
+		var name string
+		require.NoError(t, db.QueryRow("SELECT name FROM users WHERE id = 1").Scan(&name))
+		assert.Equal(t, "anna", name)
+
+		status, err := m.Status(ctx, migrationsFS)
+		require.NoError(t, err)
+		assert.Equal(t, []uint{1, 2, 3, 4}, appliedVersions(t, status))
+		assert.True(t, status.Migrations[1].Go)
+		assert.Empty(t, status.Migrations[1].Checksum)
+		require.NoError(t, m.Validate(ctx, migrationsFS))
+
+		require.NoError(t, m.Goto(ctx, migrationsFS, 1))
+		assert.Equal(t, 0, countUpperNames(t, db))
+		assert.False(t, tableExists(t, db, "teams"))
+	})
+
+	t.Run("resumes after the last batch committed", func(t *testing.T) {
+		db := newSQLiteDB(t)
+		newMigrator := func(batches *[]string, failAt string) interface {
+			Run(context.Context, fs.FS) error
+		} {
+			m, err := migrator.New(sqldb.NewDBClient(db),
+				migrator.WithDriver(sqldb.DriverTypeSQLite),
+				migrator.WithServiceName("test"),
+				migrator.WithGoMigrations(backfillUpperNames(batches, failAt)),
+			)
+			require.NoError(t, err)
+			return m
+		}
+
+		var batches []string
+		require.ErrorContains(t, newMigrator(&batches, "4").Run(ctx, migrationsFS), "failed at 4")
+		assert.Equal(t, 4, countUpperNames(t, db))
+		assert.False(t, tableExists(t, db, "teams"))
+
+		batches = nil
+		require.NoError(t, newMigrator(&batches, "").Run(ctx, migrationsFS))
+		assert.Equal(t, []string{"4"}, batches)
+		assert.Equal(t, 5, countUpperNames(t, db))
+		assert.True(t, tableExists(t, db, "teams"))
+
+		var progress int
+		require.NoError(t, db.QueryRow("SELECT COUNT(*) FROM test_schema_go_progress").Scan(&progress))
+		assert.Zero(t, progress)
+	})
+
+	t.Run("rejects invalid migrations", func(t *testing.T) {
+		db := newSQLiteDB(t)
+		noop := func(context.Context, migrator.Env) error { return nil }
+
+		_, err := migrator.New(sqldb.NewDBClient(db), migrator.WithGoMigrations(migrator.GoMigration{Version: 2, Name: "empty"}))
+		assert.Error(t, err)
+		_, err = migrator.New(sqldb.NewDBClient(db), migrator.WithGoMigrations(
+			migrator.GoMigration{Version: 2, Name: "a", Up: noop},
+			migrator.GoMigration{Version: 2, Name: "b", Up: noop},
+		))
+		assert.Error(t, err)
+
+		m, err := migrator.New(sqldb.NewDBClient(db),
+			migrator.WithDriver(sqldb.DriverTypeSQLite),
+			migrator.WithServiceName("test"),
+			migrator.WithGoMigrations(migrator.GoMigration{Version: 3, Name: "conflict", Up: noop}),
+		)
+		require.NoError(t, err)
+		assert.ErrorContains(t, m.Run(ctx, migrationsFS), "version of a sql migration")
+	})
+}
+
+// TestLintSQLite tests flagging the risky statements of pending migrations.
+func TestLintSQLite(t *testing.T) {
+	ctx := context.Background()
+	_, m := newSQLiteMigrator(t, true)
+
+	migrationsFS := fstest.MapFS{
+		"migrations/000001_create_users.up.sql": {Data: []byte(`
+			CREATE TABLE users (id BIGINT PRIMARY KEY, email TEXT NOT NULL);
+			CREATE INDEX users_email_idx ON users (email);`)},
+		"migrations/000002_alter_users.up.sql": {Data: []byte(`
+			-- a comment; with a semicolon
+			ALTER TABLE users ADD COLUMN name TEXT NOT NULL, ADD COLUMN token UUID DEFAULT gen_random_uuid();
+			ALTER TABLE users ALTER COLUMN email TYPE VARCHAR(320);
+			ALTER TABLE users ADD CONSTRAINT users_email_check CHECK (email <> ';');
+			CREATE INDEX users_name_idx ON users (name);`)},
+		"migrations/000003_safe_users.up.sql": {Data: []byte(`
+			SET lock_timeout = '5s';
+			ALTER TABLE users ADD COLUMN age INT DEFAULT 0 NOT NULL;
+			ALTER TABLE users ADD CONSTRAINT users_age_check CHECK (age >= 0) NOT VALID;
+			CREATE INDEX CONCURRENTLY users_age_idx ON users (age);
+			CREATE FUNCTION f() RETURNS void AS $$ BEGIN ALTER TABLE users ALTER COLUMN age TYPE BIGINT; END $$ LANGUAGE plpgsql;`)},
+	}
+
+	issues, err := m.Lint(ctx, migrationsFS)
+	require.NoError(t, err)
+	var rules []string
+	for _, issue := range issues {
+		assert.Equal(t, "2_alter_users", issue.Migration)
+		rules = append(rules, string(issue.Rule))
+	}
+	assert.Equal(t, []string{
+		string(migrator.RuleNotNullWithoutDefault),
+		string(migrator.RuleVolatileDefault),
+		string(migrator.RuleTypeChange),
+		string(migrator.RuleLongLock),
+		string(migrator.RuleIndexNotConcurrent),
+		string(migrator.RuleMissingLockTimeout),
+	}, rules)
+	assert.Equal(t, "ALTER TABLE users ALTER COLUMN email TYPE VARCHAR(320)", issues[2].Statement)
+}
+
+// TestDryRunSQLite tests reporting the schema changes of pending migrations
+// without applying them.
+func TestDryRunSQLite(t *testing.T) {
+	ctx := context.Background()
+	db, m := newSQLiteMigrator(t, true)
+	migrationsFS := versionedFS()
+	require.NoError(t, m.Goto(ctx, migrationsFS, 1))
+
+	migrationsFS["migrations/000004_alter_users.up.sql"] = &fstest.MapFile{Data: []byte(
+		"ALTER TABLE users ADD COLUMN email TEXT NOT NULL DEFAULT ''; CREATE INDEX users_email_idx ON users (email);",
+	)}
+	migrationsFS["migrations/000005_vacuum.up.sql"] = &fstest.MapFile{Data: []byte("VACUUM;")}
+
+	report, err := m.DryRun(ctx, migrationsFS)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"2_create_teams", "3_create_roles", "4_alter_users"}, report.Applied)
+	assert.Equal(t, []string{"5_vacuum"}, report.Skipped)
+	assert.Equal(t, []migrator.SchemaChange{
+		{Kind: migrator.SchemaAdded, Object: "table", Name: "roles"},
+		{Kind: migrator.SchemaAdded, Object: "table", Name: "teams"},
+		{Kind: migrator.SchemaAdded, Object: "column", Name: "users.email", To: "text not null"},
+		{Kind: migrator.SchemaAdded, Object: "index", Name: "users_email_idx"},
+	}, report.Changes)
+
+	assert.False(t, tableExists(t, db, "teams"))
+	status, err := m.Status(ctx, migrationsFS)
+	require.NoError(t, err)
+	assert.Equal(t, uint(1), status.Version)
+
+	withGo, err := migrator.New(sqldb.NewDBClient(db),
+		migrator.WithDriver(sqldb.DriverTypeSQLite),
+		migrator.WithServiceName("test"),
+		migrator.WithGoMigrations(migrator.GoMigration{
+			Version: 6,
+			Name:    "seed_users",
+			Up: func(ctx context.Context, env migrator.Env) error {
+				_, err := env.DB.DB().ExecContext(ctx, "INSERT INTO users (id) VALUES (1)")
+				return err
+			},
+		}),
+	)
+	require.NoError(t, err)
+	report, err = withGo.DryRun(ctx, migrationsFS)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"5_vacuum", "6_seed_users"}, report.Skipped)
+	var users int
+	require.NoError(t, db.QueryRow("SELECT COUNT(*) FROM users").Scan(&users))
+	assert.Zero(t, users, "go migrations do not run in dry runs")
+
+	mysql, err := migrator.New(sqldb.NewDBClient(db), migrator.WithDriver(sqldb.DriverTypeMySQL))
+	require.NoError(t, err)
+	_, err = mysql.DryRun(ctx, migrationsFS)
+	assert.True(t, apierrors.Is(err, apierrors.CodeOperationNotAllowed))
+}
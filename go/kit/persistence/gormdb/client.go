@@ -3,6 +3,7 @@ package gormdb
 import (
 	"context"
 	"database/sql"
+	"errors"
 	"time"
 
 	"gorm.io/gorm"
@@ -21,6 +22,8 @@ type config struct {
 	gConfig           *gorm.Config
 	connectionOptions []sqldb.ConnectionOption
 	monitorOpts       []MonitorOption
+	replicas          []sqldb.Replica
+	replicaOpts       []sqldb.ReplicaOption
 }
 
 // Option defines the contract for options applied to a gormdb.DBClient.
@@ -89,6 +92,7 @@ func newConfig(options ...Option) (*config, error) {
 // DBClient implements a sqldb.Client using gorm.
 type DBClient struct {
 	*gorm.DB
+	replicas *sqldb.ReplicaSet
 }
 
 // Database allows to retrieve the sql.DB database handle.
@@ -116,8 +120,18 @@ func (cli *DBClient) Ping() error {
 	return cli.PingContext(context.Background())
 }
 
-// Close closes to connections with the database.
+// Replicas returns the read replicas of the client, nil when it has none.
+func (cli *DBClient) Replicas() *sqldb.ReplicaSet {
+	return cli.replicas
+}
+
+// Close closes to connections with the database and its replicas.
 func (cli *DBClient) Close() error {
+	if cli.replicas != nil {
+		if err := cli.replicas.Close(); err != nil {
+			return err
+		}
+	}
 	conn, err := cli.Database()
 	if err != nil {
 		return err
@@ -193,6 +207,19 @@ func New(dialector gorm.Dialector, m monitoring.Monitor, options ...Option) (*DB
 			}
 		}
 	}
+	if len(config.replicas) > 0 {
+		conn, err := cli.Database()
+		if err != nil {
+			return nil, err
+		}
+		cli.replicas, err = sqldb.NewReplicaSet(conn, config.replicas, config.replicaOpts...)
+		if err != nil {
+			return nil, err
+		}
+		if err := registerReplicas(db, cli.replicas); err != nil {
+			return nil, errors.Join(err, cli.replicas.Close())
+		}
+	}
 
 	return cli, nil
 }
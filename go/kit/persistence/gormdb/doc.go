@@ -2,9 +2,13 @@
 //
 // It defines the generic DBClient wrapper, configuration options, transaction management,
 // and monitoring hooks. This package is designed to be used in conjunction with a
-// driver-specific implementation (e.g., gormpg for PostgreSQL, gormmysql for MySQL),
+// driver-specific implementation (gormpg for PostgreSQL, gormmysql for MySQL, gormsqlite for SQLite),
 // which provides the specific dialector and connection logic.
 //
 // This separation of concerns allows for easy extensibility to support multiple
 // database drivers while reusing the core GORM interaction logic.
+//
+// DriftDetector compares the registered models with the live schema before
+// deploys, catching models and migrations diverging, and WriteMigration turns
+// the drifts found into a candidate migration of the migrator.
 package gormdb
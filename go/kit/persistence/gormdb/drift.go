@@ -0,0 +1,413 @@
+package gormdb
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/schema"
+)
+
+// DriftKind is a kind of difference between a model and the database, see
+// DriftDetector.
+type DriftKind string
+
+const (
+	DriftMissingTable  DriftKind = "missing-table"
+	DriftMissingColumn DriftKind = "missing-column"
+	DriftExtraColumn   DriftKind = "extra-column"
+	DriftColumnType    DriftKind = "column-type"
+	DriftNullability   DriftKind = "nullability"
+	DriftMissingIndex  DriftKind = "missing-index"
+	DriftExtraIndex    DriftKind = "extra-index"
+	DriftIndex         DriftKind = "index-definition"
+)
+
+// Drift is a difference between a model and the database.
+type Drift struct {
+	Kind   DriftKind `json:"kind"`
+	Table  string    `json:"table"`
+	Column string    `json:"column,omitempty"`
+	Index  string    `json:"index,omitempty"`
+	// Model and Database are the definitions of the column or index in the
+	// model and in the database, empty when it has none.
+	Model    string `json:"model,omitempty"`
+	Database string `json:"database,omitempty"`
+	// Fix is the PostgreSQL statement making the database match the model,
+	// and Revert the one undoing it, both left empty on the other databases.
+	// The statements dropping data are commented out, to be reviewed.
+	Fix    string `json:"fix"`
+	Revert string `json:"revert,omitempty"`
+}
+
+func (d Drift) String() string {
+	name := d.Table
+	switch {
+	case d.Column != "":
+		name += "." + d.Column
+	case d.Index != "":
+		name += " index " + d.Index
+	}
+	if d.Model == "" && d.Database == "" {
+		return fmt.Sprintf("%s: %s", d.Kind, name)
+	}
+
+	return fmt.Sprintf("%s: %s: model %q, database %q", d.Kind, name, d.Model, d.Database)
+}
+
+const postgresDialector = "postgres"
+
+// DriftDetector compares the tables of registered models, with their
+// columns, types, nullability and indexes, to the database. The models are
+// parsed as gorm does, embedding postgres.Model and postgres.Timestamps
+// adds their id and timestamp columns.
+type DriftDetector struct {
+	db     *DBClient
+	models []any
+}
+
+func NewDriftDetector(db *DBClient, models ...any) *DriftDetector {
+	return &DriftDetector{db: db, models: models}
+}
+
+// Register adds models to compare.
+func (d *DriftDetector) Register(models ...any) *DriftDetector {
+	d.models = append(d.models, models...)
+	return d
+}
+
+// Detect returns the differences between the models and the database,
+// model by model in registration order.
+func (d *DriftDetector) Detect(ctx context.Context) ([]Drift, error) {
+	db := d.db.WithContext(ctx)
+
+	var drifts []Drift
+	for _, model := range d.models {
+		stmt := &gorm.Statement{DB: db}
+		if err := stmt.Parse(model); err != nil {
+			return nil, fmt.Errorf("failed to parse model %T: %w", model, err)
+		}
+		modelDrifts, err := detectTable(db, stmt, model)
+		if err != nil {
+			return nil, fmt.Errorf("failed to compare model %T: %w", model, err)
+		}
+		drifts = append(drifts, modelDrifts...)
+	}
+	// the fixes are PostgreSQL statements, e.g. ALTER COLUMN ... TYPE is
+	// not valid SQLite
+	if db.Dialector.Name() != postgresDialector {
+		for i := range drifts {
+			drifts[i].Fix, drifts[i].Revert = "", ""
+		}
+	}
+
+	return drifts, nil
+}
+
+// detectTable returns the differences between the model of stmt and its
+// table.
+func detectTable(db *gorm.DB, stmt *gorm.Statement, model any) ([]Drift, error) {
+	m := db.Migrator()
+	sch, table := stmt.Schema, stmt.Table
+	q := stmt.Quote
+
+	if !m.HasTable(model) {
+		cols := make([]string, 0, len(sch.DBNames))
+		var pks []string
+		for _, name := range sch.DBNames {
+			f := sch.FieldsByDBName[name]
+			cols = append(cols, q(name)+" "+m.FullDataTypeOf(f).SQL)
+			if f.PrimaryKey {
+				pks = append(pks, q(name))
+			}
+		}
+		if len(pks) > 0 {
+			cols = append(cols, "PRIMARY KEY ("+strings.Join(pks, ",")+")")
+		}
+		drifts := []Drift{{
+			Kind:   DriftMissingTable,
+			Table:  table,
+			Fix:    fmt.Sprintf("CREATE TABLE %s (%s);", q(table), strings.Join(cols, ",")),
+			Revert: fmt.Sprintf("DROP TABLE %s;", q(table)),
+		}}
+		for _, idx := range sch.ParseIndexes() {
+			drifts = append(drifts, missingIndex(stmt, idx))
+		}
+		return drifts, nil
+	}
+
+	columns, err := m.ColumnTypes(model)
+	if err != nil {
+		return nil, err
+	}
+	live := make(map[string]gorm.ColumnType, len(columns))
+	for _, c := range columns {
+		live[c.Name()] = c
+	}
+
+	var drifts []Drift
+	for _, name := range sch.DBNames {
+		f := sch.FieldsByDBName[name]
+		c, ok := live[name]
+		if !ok {
+			drifts = append(drifts, Drift{
+				Kind:   DriftMissingColumn,
+				Table:  table,
+				Column: name,
+				Model:  m.FullDataTypeOf(f).SQL,
+				Fix:    fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s %s;", q(table), q(name), m.FullDataTypeOf(f).SQL),
+				Revert: fmt.Sprintf("ALTER TABLE %s DROP COLUMN %s;", q(table), q(name)),
+			})
+			continue
+		}
+
+		want, got := db.Dialector.DataTypeOf(f), c.DatabaseTypeName()
+		if normalizeType(want) != normalizeType(got) {
+			drifts = append(drifts, Drift{
+				Kind:     DriftColumnType,
+				Table:    table,
+				Column:   name,
+				Model:    want,
+				Database: got,
+				Fix:      fmt.Sprintf("ALTER TABLE %s ALTER COLUMN %s TYPE %s;", q(table), q(name), want),
+				Revert:   fmt.Sprintf("ALTER TABLE %s ALTER COLUMN %s TYPE %s;", q(table), q(name), got),
+			})
+		}
+		// primary keys are not null whatever their declaration
+		if nullable, ok := c.Nullable(); ok && !f.PrimaryKey && nullable == f.NotNull {
+			drifts = append(drifts, nullabilityDrift(stmt, name, f.NotNull))
+		}
+	}
+	for _, c := range columns {
+		if _, ok := sch.FieldsByDBName[c.Name()]; !ok {
+			drifts = append(drifts, Drift{
+				Kind:     DriftExtraColumn,
+				Table:    table,
+				Column:   c.Name(),
+				Database: c.DatabaseTypeName(),
+				Fix:      fmt.Sprintf("-- ALTER TABLE %s DROP COLUMN %s;", q(table), q(c.Name())),
+			})
+		}
+	}
+
+	indexDrifts, err := detectIndexes(db, stmt, model)
+	if err != nil {
+		return nil, err
+	}
+
+	return append(drifts, indexDrifts...), nil
+}
+
+// detectIndexes returns the differences between the indexes of the model of
+// stmt and those of its table. The primary keys and the unique constraints
+// of the fields tagged unique are left out.
+func detectIndexes(db *gorm.DB, stmt *gorm.Statement, model any) ([]Drift, error) {
+	liveIndexes, err := db.Migrator().GetIndexes(model)
+	if err != nil {
+		return nil, err
+	}
+	live := make(map[string]gorm.Index, len(liveIndexes))
+	for _, idx := range liveIndexes {
+		if pk, _ := idx.PrimaryKey(); pk {
+			continue
+		}
+		if unique, _ := idx.Unique(); unique && len(idx.Columns()) == 1 {
+			if f := stmt.Schema.FieldsByDBName[idx.Columns()[0]]; f != nil && f.Unique {
+				continue
+			}
+		}
+		live[idx.Name()] = idx
+	}
+
+	var drifts []Drift
+	for _, idx := range stmt.Schema.ParseIndexes() {
+		got, ok := live[idx.Name]
+		delete(live, idx.Name)
+		if !ok {
+			drifts = append(drifts, missingIndex(stmt, idx))
+			continue
+		}
+		want := indexDefinition(idx.Class == "UNIQUE", indexColumns(idx))
+		unique, _ := got.Unique()
+		if have := indexDefinition(unique, got.Columns()); have != want {
+			d := missingIndex(stmt, idx)
+			d.Kind, d.Database = DriftIndex, have
+			d.Fix = fmt.Sprintf("DROP INDEX %s;\n%s", stmt.Quote(idx.Name), d.Fix)
+			d.Revert = ""
+			drifts = append(drifts, d)
+		}
+	}
+	names := make([]string, 0, len(live))
+	for name := range live {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		unique, _ := live[name].Unique()
+		drifts = append(drifts, Drift{
+			Kind:     DriftExtraIndex,
+			Table:    stmt.Table,
+			Index:    name,
+			Database: indexDefinition(unique, live[name].Columns()),
+			Fix:      fmt.Sprintf("-- DROP INDEX %s;", stmt.Quote(name)),
+		})
+	}
+
+	return drifts, nil
+}
+
+func missingIndex(stmt *gorm.Statement, idx *schema.Index) Drift {
+	cols := indexColumns(idx)
+	quoted := make([]string, len(cols))
+	for i, c := range cols {
+		quoted[i] = stmt.Quote(c)
+	}
+	create := "CREATE INDEX"
+	if idx.Class == "UNIQUE" {
+		create = "CREATE UNIQUE INDEX"
+	}
+
+	return Drift{
+		Kind:   DriftMissingIndex,
+		Table:  stmt.Table,
+		Index:  idx.Name,
+		Model:  indexDefinition(idx.Class == "UNIQUE", cols),
+		Fix:    fmt.Sprintf("%s %s ON %s (%s);", create, stmt.Quote(idx.Name), stmt.Quote(stmt.Table), strings.Join(quoted, ",")),
+		Revert: fmt.Sprintf("DROP INDEX %s;", stmt.Quote(idx.Name)),
+	}
+}
+
+func nullabilityDrift(stmt *gorm.Statement, column string, notNull bool) Drift {
+	set, unset := "SET NOT NULL", "DROP NOT NULL"
+	model, database := "not null", "null"
+	if !notNull {
+		set, unset = unset, set
+		model, database = database, model
+	}
+	alter := fmt.Sprintf("ALTER TABLE %s ALTER COLUMN %s ", stmt.Quote(stmt.Table), stmt.Quote(column))
+
+	return Drift{
+		Kind:     DriftNullability,
+		Table:    stmt.Table,
+		Column:   column,
+		Model:    model,
+		Database: database,
+		Fix:      alter + set + ";",
+		Revert:   alter + unset + ";",
+	}
+}
+
+func indexColumns(idx *schema.Index) []string {
+	cols := make([]string, 0, len(idx.Fields))
+	for _, f := range idx.Fields {
+		if f.Expression != "" {
+			cols = append(cols, f.Expression)
+		} else {
+			cols = append(cols, f.DBName)
+		}
+	}
+	return cols
+}
+
+func indexDefinition(unique bool, columns []string) string {
+	def := "(" + strings.Join(columns, ",") + ")"
+	if unique {
+		return "unique " + def
+	}
+	return def
+}
+
+//nolint:gochecknoglobals // read only
+var typeAliases = map[string]string{
+	"int":                         "integer",
+	"int2":                        "smallint",
+	"int4":                        "integer",
+	"int8":                        "bigint",
+	"serial":                      "integer",
+	"bigserial":                   "bigint",
+	"float4":                      "real",
+	"float8":                      "double precision",
+	"decimal":                     "numeric",
+	"bool":                        "boolean",
+	"varchar":                     "character varying",
+	"char":                        "character",
+	"bpchar":                      "character",
+	"timestamp without time zone": "timestamp",
+	"timestamptz":                 "timestamp with time zone",
+	"time without time zone":      "time",
+	"timetz":                      "time with time zone",
+}
+
+//nolint:gochecknoglobals // compiled once
+var (
+	reTypeParams       = regexp.MustCompile(`\s*\(.*?\)`)
+	reMigrationVersion = regexp.MustCompile(`^(\d+)_`)
+)
+
+// normalizeType returns the canonical name of a database type, without its
+// length or precision.
+func normalizeType(t string) string {
+	t = strings.ToLower(strings.TrimSpace(reTypeParams.ReplaceAllString(t, "")))
+	if strings.HasPrefix(t, "_") {
+		// the element type of PostgreSQL arrays
+		return normalizeType(t[1:]) + "[]"
+	}
+	if alias, ok := typeAliases[t]; ok {
+		return alias
+	}
+	return t
+}
+
+// WriteMigration writes the fixes of the drifts as the next migration of the
+// dir of a migrator, {version}_{name}.up.sql with the reverts in its
+// .down.sql, returning the path of the up migration. The version follows
+// the highest one of dir with the same width, 6 digits for the first one.
+// It is a candidate to review, PostgreSQL being assumed.
+func WriteMigration(dir, name string, drifts []Drift) (string, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil && !os.IsNotExist(err) {
+		return "", fmt.Errorf("failed to read migrations: %w", err)
+	}
+	var version uint64
+	width := 6
+	for _, e := range entries {
+		match := reMigrationVersion.FindStringSubmatch(e.Name())
+		if match == nil {
+			continue
+		}
+		v, err := strconv.ParseUint(match[1], 10, 64)
+		if err != nil {
+			continue
+		}
+		if v >= version {
+			version, width = v, len(match[1])
+		}
+	}
+
+	var up, down strings.Builder
+	for i, d := range drifts {
+		fmt.Fprintf(&up, "-- %s\n%s\n", d, d.Fix)
+		if r := drifts[len(drifts)-1-i].Revert; r != "" {
+			fmt.Fprintf(&down, "%s\n", r)
+		}
+	}
+
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return "", fmt.Errorf("failed to create migrations folder: %w", err)
+	}
+	prefix := filepath.Join(dir, fmt.Sprintf("%0*d_%s", width, version+1, name))
+	if err := os.WriteFile(prefix+".up.sql", []byte(up.String()), 0o644); err != nil {
+		return "", fmt.Errorf("failed to write migration: %w", err)
+	}
+	if err := os.WriteFile(prefix+".down.sql", []byte(down.String()), 0o644); err != nil {
+		return "", fmt.Errorf("failed to write migration: %w", err)
+	}
+
+	return prefix + ".up.sql", nil
+}
@@ -0,0 +1,168 @@
+package gormdb_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/sqlite"
+
+	"github.com/dosanma1/forge/go/kit/monitoring"
+	"github.com/dosanma1/forge/go/kit/monitoring/logger/loggertest"
+	"github.com/dosanma1/forge/go/kit/persistence/gormdb"
+	"github.com/dosanma1/forge/go/kit/persistence/gormdb/gormdbtest"
+	"github.com/dosanma1/forge/go/kit/persistence/postgres"
+	"github.com/dosanma1/forge/go/kit/persistence/sqldb"
+)
+
+type account struct {
+	postgres.Model
+	Email string `gorm:"not null;uniqueIndex:account_email_idx"`
+	Name  string
+	Age   int `gorm:"index:account_age_idx"`
+}
+
+type pgAccount struct {
+	ID        int32  `gorm:"primaryKey"`
+	Email     string `gorm:"size:100;not null;uniqueIndex:pg_account_email_idx"`
+	CreatedAt time.Time
+	Age       int    `gorm:"index:pg_account_age_idx"`
+	Name      string `gorm:"index:pg_account_name_idx"`
+}
+
+type team struct {
+	postgres.Model
+	Name string `gorm:"not null"`
+}
+
+func TestDriftDetector(t *testing.T) {
+	ctx := context.Background()
+	t.Setenv("DB_LOG_LEVEL", "error")
+	db, err := sqldb.Connect(sqldb.MustGenerateDSN(
+		sqldb.DriverTypeSQLite, sqldb.WithConnDBName(filepath.Join(t.TempDir(), "drift.db")),
+	))
+	require.NoError(t, err)
+	t.Cleanup(func() { db.Close() })
+	_, err = db.Exec(`CREATE TABLE account (
+		id uuid PRIMARY KEY, created_at timestamp, updated_at timestamp, deleted_at timestamp,
+		email text, nickname text, age text
+	); CREATE INDEX account_nickname_idx ON account (nickname);`)
+	require.NoError(t, err)
+
+	cli, err := gormdb.New(sqlite.New(sqlite.Config{DriverName: string(sqldb.DriverTypeSQLite), Conn: db}), monitoring.New(loggertest.NewStubLogger(t)))
+	require.NoError(t, err)
+
+	drifts, err := gormdb.NewDriftDetector(cli, &account{}).Register(&team{}).Detect(ctx)
+	require.NoError(t, err)
+	var kinds []gormdb.DriftKind
+	for _, d := range drifts {
+		kinds = append(kinds, d.Kind)
+	}
+	assert.Equal(t, []gormdb.DriftKind{
+		gormdb.DriftNullability, gormdb.DriftMissingColumn, gormdb.DriftColumnType, gormdb.DriftExtraColumn,
+		gormdb.DriftMissingIndex, gormdb.DriftMissingIndex, gormdb.DriftExtraIndex, gormdb.DriftMissingTable,
+	}, kinds)
+	assert.Equal(t, gormdb.Drift{
+		Kind:     gormdb.DriftColumnType,
+		Table:    "account",
+		Column:   "age",
+		Model:    "integer",
+		Database: "text",
+	}, drifts[2])
+	for _, d := range drifts {
+		assert.Empty(t, d.Fix+d.Revert, "the fixes are PostgreSQL statements")
+	}
+}
+
+func TestDriftDetectorPostgres(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping integration test")
+	}
+	testDB := gormdbtest.GetDB(t, gormdbtest.TestSchema)
+	if testDB == nil {
+		t.Skip("skipping integration test: postgres is not available")
+	}
+
+	require.NoError(t, testDB.DB.Exec(`CREATE TABLE pg_accounts (
+		id serial PRIMARY KEY, email varchar(100) NOT NULL, created_at timestamptz, age integer, name text
+	); CREATE UNIQUE INDEX pg_account_email_idx ON pg_accounts (email);
+	CREATE INDEX pg_account_name_idx ON pg_accounts (email);`).Error)
+	t.Cleanup(func() { testDB.DB.Exec("DROP TABLE pg_accounts") })
+
+	drifts, err := gormdb.NewDriftDetector(testDB.DBClient, &pgAccount{}).Detect(context.Background())
+	require.NoError(t, err)
+	// serial and int4, varchar(100) and varchar, timestamptz and the unique
+	// index on email match
+	assert.ElementsMatch(t, []gormdb.Drift{
+		{
+			Kind:     gormdb.DriftColumnType,
+			Table:    "pg_accounts",
+			Column:   "age",
+			Model:    "bigint",
+			Database: "int4",
+			Fix:      `ALTER TABLE "pg_accounts" ALTER COLUMN "age" TYPE bigint;`,
+			Revert:   `ALTER TABLE "pg_accounts" ALTER COLUMN "age" TYPE int4;`,
+		},
+		{
+			Kind:     gormdb.DriftIndex,
+			Table:    "pg_accounts",
+			Index:    "pg_account_name_idx",
+			Model:    "(name)",
+			Database: "(email)",
+			Fix:      "DROP INDEX \"pg_account_name_idx\";\nCREATE INDEX \"pg_account_name_idx\" ON \"pg_accounts\" (\"name\");",
+		},
+		{
+			Kind:   gormdb.DriftMissingIndex,
+			Table:  "pg_accounts",
+			Index:  "pg_account_age_idx",
+			Model:  "(age)",
+			Fix:    `CREATE INDEX "pg_account_age_idx" ON "pg_accounts" ("age");`,
+			Revert: `DROP INDEX "pg_account_age_idx";`,
+		},
+	}, drifts)
+}
+
+func TestWriteMigration(t *testing.T) {
+	drifts := []gormdb.Drift{
+		{
+			Kind:   gormdb.DriftMissingColumn,
+			Table:  "account",
+			Column: "name",
+			Model:  "text",
+			Fix:    `ALTER TABLE "account" ADD COLUMN "name" text;`,
+			Revert: `ALTER TABLE "account" DROP COLUMN "name";`,
+		},
+		{
+			Kind:     gormdb.DriftColumnType,
+			Table:    "account",
+			Column:   "age",
+			Model:    "integer",
+			Database: "text",
+			Fix:      `ALTER TABLE "account" ALTER COLUMN "age" TYPE integer;`,
+			Revert:   `ALTER TABLE "account" ALTER COLUMN "age" TYPE text;`,
+		},
+	}
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "0041_create_account.up.sql"), nil, 0o644))
+
+	path, err := gormdb.WriteMigration(dir, "fix_drift", drifts)
+	require.NoError(t, err)
+	assert.Equal(t, filepath.Join(dir, "0042_fix_drift.up.sql"), path)
+
+	up, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, `-- missing-column: account.name: model "text", database ""
+ALTER TABLE "account" ADD COLUMN "name" text;
+-- column-type: account.age: model "integer", database "text"
+ALTER TABLE "account" ALTER COLUMN "age" TYPE integer;
+`, string(up))
+	down, err := os.ReadFile(filepath.Join(dir, "0042_fix_drift.down.sql"))
+	require.NoError(t, err)
+	assert.Equal(t, `ALTER TABLE "account" ALTER COLUMN "age" TYPE text;
+ALTER TABLE "account" DROP COLUMN "name";
+`, string(down))
+}
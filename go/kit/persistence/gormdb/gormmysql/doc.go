@@ -0,0 +1,6 @@
+// Package gormmysql provides the MySQL-specific implementation for the gormdb client.
+//
+// It contains the factory logic to initialize a gormdb.DBClient using the MySQL driver
+// and proper DSN configuration. It also provides an Fx module for easy dependency injection
+// of the database client and transactioner.
+package gormmysql
@@ -0,0 +1,48 @@
+package gormsqlite
+
+import (
+	"database/sql"
+	"net/url"
+	"strings"
+
+	"gorm.io/driver/sqlite"
+
+	"github.com/dosanma1/forge/go/kit/monitoring"
+	"github.com/dosanma1/forge/go/kit/persistence/gormdb"
+	"github.com/dosanma1/forge/go/kit/persistence/sqldb"
+)
+
+const memoryDB = ":memory:"
+
+// NewClient creates a new instance of a gorm client connected to the sqlite database
+// given by connURL, e.g. sqlite:/var/lib/app.db or sqlite::memory:. Optionally, a set of
+// gormdb.Option can be provided to define extra behaviour on client initialization/interaction.
+//
+// In memory databases are limited to a single open connection, as every new connection
+// would open an empty database of its own.
+//
+// The connection is always opened with the pure-Go modernc driver registered by
+// sqldb, the gorm dialector only receives it, so the client builds and runs with
+// CGO_ENABLED=0.
+func NewClient(connURL *url.URL, m monitoring.Monitor, options ...gormdb.Option) (*gormdb.DBClient, error) {
+	if connURL == nil {
+		return nil, sqldb.NewErrEmptyDBConnection()
+	}
+
+	dsn, err := sqldb.DriverDSN(connURL)
+	if err != nil {
+		return nil, err
+	}
+	db, err := sql.Open(string(sqldb.DriverTypeSQLite), dsn)
+	if err != nil {
+		return nil, err
+	}
+	if strings.HasPrefix(dsn, memoryDB) {
+		options = append(options, gormdb.WithSQLConnectionOptions(sqldb.WithMaxOpenLimit(1)))
+	}
+
+	return gormdb.New(
+		sqlite.New(sqlite.Config{DriverName: string(sqldb.DriverTypeSQLite), Conn: db}), m,
+		options...,
+	)
+}
@@ -0,0 +1,6 @@
+// Package gormsqlite provides the SQLite-specific implementation for the gormdb client.
+//
+// It contains the factory logic to initialize a gormdb.DBClient using the pure Go SQLite
+// driver registered by sqldb, so no cgo is required. It also provides an Fx module for easy
+// dependency injection of the database client and transactioner.
+package gormsqlite
@@ -0,0 +1,102 @@
+package gormdb
+
+import (
+	"database/sql"
+	"errors"
+	"regexp"
+	"strings"
+
+	"gorm.io/gorm"
+
+	"github.com/dosanma1/forge/go/kit/persistence/sqldb"
+)
+
+const (
+	replicaReadCallback  = "gormdb:replica_read"
+	replicaWriteCallback = "gormdb:replica_write"
+
+	// lockingClause is the name of the gorm locking clause, see clause.Locking.
+	lockingClause = "FOR"
+)
+
+// LockSettingKey is the statement setting of the locking reads, e.g. the lock
+// prelude of the postgres repositories. They always run on the primary.
+const LockSettingKey = "forge:lock"
+
+// rawLocking matches the row locking clauses of raw SQL.
+var rawLocking = regexp.MustCompile(`(?i)\bFOR\s+(NO\s+KEY\s+UPDATE|UPDATE|KEY\s+SHARE|SHARE)\b`)
+
+// WithReplicas routes the queries of the client outside of transactions to
+// the read replicas, see sqldb.ReplicaSet for the routing policy: use
+// sqldb.Primary to read from the primary and sqldb.WithReadYourWrites to
+// read the writes of a request back. The client closes the replicas on
+// Close.
+func WithReplicas(replicas ...sqldb.Replica) Option {
+	return func(c *config) error {
+		c.replicas = append(c.replicas, replicas...)
+
+		return nil
+	}
+}
+
+// WithReplicaOptions configures the health checks of the replicas, see
+// WithReplicas.
+func WithReplicaOptions(options ...sqldb.ReplicaOption) Option {
+	return func(c *config) error {
+		c.replicaOpts = append(c.replicaOpts, options...)
+
+		return nil
+	}
+}
+
+// registerReplicas routes the plain reads of db to the replicas of rs and
+// marks the contexts of the successful writes as written.
+func registerReplicas(db *gorm.DB, rs *sqldb.ReplicaSet) error {
+	primary := rs.Primary()
+	route := func(tx *gorm.DB) {
+		// anything but the primary pool is a transaction or a prepared
+		// statement pool which must be kept
+		if pool, ok := tx.Statement.ConnPool.(*sql.DB); !ok || pool != primary {
+			return
+		}
+		if !plainRead(tx) {
+			return
+		}
+		tx.Statement.ConnPool = rs.ReadDB(tx.Statement.Context)
+	}
+	written := func(tx *gorm.DB) {
+		if tx.Error == nil {
+			sqldb.MarkWritten(tx.Statement.Context)
+		}
+	}
+
+	cb := db.Callback()
+	return errors.Join(
+		cb.Query().Before("gorm:query").Register(replicaReadCallback, route),
+		cb.Row().Before("gorm:row").Register(replicaReadCallback, route),
+		cb.Create().After("gorm:create").Register(replicaWriteCallback, written),
+		cb.Update().After("gorm:update").Register(replicaWriteCallback, written),
+		cb.Delete().After("gorm:delete").Register(replicaWriteCallback, written),
+		cb.Raw().After("gorm:raw").Register(replicaWriteCallback, written),
+	)
+}
+
+// plainRead reports whether the statement of tx is a read that a replica may
+// serve: locking reads and raw SQL other than a SELECT stay on the primary.
+func plainRead(tx *gorm.DB) bool {
+	if _, ok := tx.Statement.Clauses[lockingClause]; ok {
+		return false
+	}
+	if _, ok := tx.Get(LockSettingKey); ok {
+		return false
+	}
+	if tx.Statement.SQL.Len() == 0 {
+		return true
+	}
+	raw := strings.TrimSpace(tx.Statement.SQL.String())
+	if len(raw) < len("SELECT") || !strings.EqualFold(raw[:len("SELECT")], "SELECT") {
+		return false
+	}
+
+	return !rawLocking.MatchString(raw)
+}
@@ -0,0 +1,117 @@
+package gormdb_test
+
+import (
+	"context"
+	"database/sql"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"github.com/dosanma1/forge/go/kit/monitoring"
+	"github.com/dosanma1/forge/go/kit/monitoring/logger/loggertest"
+	"github.com/dosanma1/forge/go/kit/persistence/gormdb"
+	"github.com/dosanma1/forge/go/kit/persistence/sqldb"
+)
+
+type source struct {
+	Name string
+}
+
+func sqliteDB(t *testing.T, name string) *sql.DB {
+	t.Helper()
+
+	db, err := sqldb.Connect(sqldb.MustGenerateDSN(
+		sqldb.DriverTypeSQLite, sqldb.WithConnDBName(filepath.Join(t.TempDir(), name+".db")),
+	))
+	require.NoError(t, err)
+	t.Cleanup(func() { db.Close() })
+
+	_, err = db.Exec("CREATE TABLE source (name TEXT)")
+	require.NoError(t, err)
+	_, err = db.Exec("INSERT INTO source (name) VALUES (?)", name)
+	require.NoError(t, err)
+
+	return db
+}
+
+func TestReplicas(t *testing.T) {
+	t.Setenv("DB_LOG_LEVEL", "error")
+
+	primary, replica := sqliteDB(t, "primary"), sqliteDB(t, "replica")
+	cli, err := gormdb.New(
+		sqlite.New(sqlite.Config{DriverName: string(sqldb.DriverTypeSQLite), Conn: primary}),
+		monitoring.New(loggertest.NewStubLogger(t)),
+		gormdb.WithReplicas(sqldb.Replica{DB: replica}),
+		gormdb.WithReplicaOptions(sqldb.WithHealthCheckInterval(0)),
+	)
+	require.NoError(t, err)
+	defer cli.Close()
+
+	read := func(ctx context.Context) string {
+		var src source
+		require.NoError(t, cli.WithContext(ctx).Table("source").First(&src).Error)
+		return src.Name
+	}
+	ctx := context.Background()
+
+	require.NotNil(t, cli.Replicas())
+	assert.Equal(t, "replica", read(ctx))
+	assert.Equal(t, "primary", read(sqldb.Primary(ctx)))
+
+	t.Run("raw reads", func(t *testing.T) {
+		var name string
+		require.NoError(t, cli.WithContext(ctx).Raw("SELECT name FROM source").Scan(&name).Error)
+		assert.Equal(t, "replica", name)
+	})
+
+	t.Run("locking and writing reads stay on the primary", func(t *testing.T) {
+		dry := cli.Session(&gorm.Session{DryRun: true}).WithContext(ctx)
+		tests := []struct {
+			name string
+			tx   *gorm.DB
+			want *sql.DB
+		}{
+			{name: "plain read", tx: dry.Table("source").Find(&[]source{}), want: replica},
+			{name: "raw select", tx: dry.Raw("SELECT name FROM source").Find(&[]source{}), want: replica},
+			{
+				name: "locking clause",
+				tx:   dry.Clauses(clause.Locking{Strength: clause.LockingStrengthUpdate}).Table("source").Find(&[]source{}),
+				want: primary,
+			},
+			{name: "lock setting", tx: dry.Set(gormdb.LockSettingKey, true).Table("source").Find(&[]source{}), want: primary},
+			{
+				name: "raw write",
+				tx:   dry.Raw("UPDATE source SET name = ? RETURNING name", "x").Find(&[]source{}),
+				want: primary,
+			},
+			{name: "raw locking select", tx: dry.Raw("SELECT name FROM source FOR UPDATE").Find(&[]source{}), want: primary},
+		}
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				require.NoError(t, tt.tx.Error)
+				assert.Same(t, tt.want, tt.tx.Statement.ConnPool)
+			})
+		}
+	})
+
+	t.Run("transactions", func(t *testing.T) {
+		err := gormdb.NewTransactioner(cli, nil).Exec(ctx, func(ctx context.Context) error {
+			assert.Equal(t, "primary", read(ctx))
+			return nil
+		})
+		require.NoError(t, err)
+	})
+
+	t.Run("read your writes", func(t *testing.T) {
+		scoped := sqldb.WithReadYourWrites(ctx)
+		require.NoError(t, cli.WithContext(scoped).Table("source").Where("1 = 1").Update("name", "written").Error)
+
+		assert.Equal(t, "written", read(scoped))
+		assert.Equal(t, "replica", read(ctx))
+	})
+}
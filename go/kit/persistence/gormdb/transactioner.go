@@ -2,11 +2,16 @@ package gormdb
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"sync/atomic"
 
 	"gorm.io/gorm"
 
 	"github.com/dosanma1/forge/go/kit/monitoring/logger"
 	"github.com/dosanma1/forge/go/kit/persistence"
+	"github.com/dosanma1/forge/go/kit/persistence/sqldb"
+	"github.com/dosanma1/forge/go/kit/retry"
 )
 
 type txKey struct{}
@@ -45,10 +50,91 @@ func NewTransactioner(db *DBClient, log logger.Logger) *transactioner {
 }
 
 func (t *transactioner) Exec(ctx context.Context, fn persistence.TxFunc) error {
-	if gormTxExists(ctx) {
+	return t.ExecWith(ctx, fn)
+}
+
+// ExecWith runs fn within a transaction configured by the options, see
+// persistence.TxOption. The hooks registered by fn run once the transaction
+// ends, see persistence.AfterCommit.
+func (t *transactioner) ExecWith(ctx context.Context, fn persistence.TxFunc, opts ...persistence.TxOption) error {
+	cfg := persistence.NewTxConfig(opts...)
+	if tx := extractTx(ctx); tx != nil {
+		if cfg.Savepoint {
+			return execSavepoint(ctx, tx, fn)
+		}
 		return fn(ctx)
 	}
-	return t.db.Transaction(func(tx *gorm.DB) error {
-		return fn(injectTx(ctx, tx))
-	})
+	if !cfg.Retry {
+		return t.exec(ctx, cfg, fn)
+	}
+
+	return retry.RetryWithContext(ctx, func() error {
+		err := t.exec(ctx, cfg, fn)
+		if err != nil && !sqldb.IsSerializationFailure(err) {
+			return retry.Permanent(err)
+		}
+		return err
+	}, cfg.RetryOpts...)
+}
+
+func (t *transactioner) exec(ctx context.Context, cfg persistence.TxConfig, fn persistence.TxFunc) error {
+	tx := t.db.DB.WithContext(ctx).Begin(cfg.TxOptions())
+	if tx.Error != nil {
+		return tx.Error
+	}
+	if cfg.Deferrable {
+		if err := tx.Exec("SET TRANSACTION DEFERRABLE").Error; err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+
+	txCtx, hooks := persistence.NewTxHooks(ctx)
+	defer func() {
+		// a panicking fn must not leave the transaction open
+		if r := recover(); r != nil {
+			tx.Rollback()
+			hooks.RolledBack(ctx)
+			panic(r)
+		}
+	}()
+	if err := fn(injectTx(txCtx, tx)); err != nil {
+		tx.Rollback()
+		hooks.RolledBack(ctx)
+		return err
+	}
+	if err := tx.Commit().Error; err != nil {
+		hooks.RolledBack(ctx)
+		return err
+	}
+	hooks.Committed(ctx)
+
+	return nil
+}
+
+//nolint:gochecknoglobals // savepoint names only need to be unique within a transaction
+var savepointSeq atomic.Uint64
+
+// execSavepoint runs fn in a savepoint of tx, rolling back to it when fn
+// fails.
+func execSavepoint(ctx context.Context, tx *gorm.DB, fn persistence.TxFunc) error {
+	name := fmt.Sprintf("sp_%d", savepointSeq.Add(1))
+	if err := tx.SavePoint(name).Error; err != nil {
+		return err
+	}
+
+	spCtx, hooks := persistence.NewTxHooks(ctx)
+	if err := fn(spCtx); err != nil {
+		if rbErr := tx.RollbackTo(name).Error; rbErr != nil {
+			return errors.Join(err, rbErr)
+		}
+		hooks.RolledBack(ctx)
+		return err
+	}
+	if err := tx.Exec("RELEASE SAVEPOINT " + name).Error; err != nil {
+		return err
+	}
+	hooks.Committed(ctx)
+
+	return nil
 }
@@ -0,0 +1,102 @@
+package gormdb_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/sqlite"
+
+	"github.com/dosanma1/forge/go/kit/monitoring"
+	"github.com/dosanma1/forge/go/kit/monitoring/logger/loggertest"
+	"github.com/dosanma1/forge/go/kit/persistence"
+	"github.com/dosanma1/forge/go/kit/persistence/gormdb"
+	"github.com/dosanma1/forge/go/kit/persistence/sqldb"
+	"github.com/dosanma1/forge/go/kit/retry"
+)
+
+type deadlockErr struct{}
+
+func (deadlockErr) Error() string    { return "deadlock detected" }
+func (deadlockErr) SQLState() string { return "40P01" }
+
+func TestTransactionerExecWith(t *testing.T) {
+	t.Setenv("DB_LOG_LEVEL", "error")
+
+	cli, err := gormdb.New(
+		sqlite.New(sqlite.Config{DriverName: string(sqldb.DriverTypeSQLite), Conn: sqliteDB(t, "tx")}),
+		monitoring.New(loggertest.NewStubLogger(t)),
+	)
+	require.NoError(t, err)
+
+	trx := gormdb.NewTransactioner(cli, nil)
+	ctx := context.Background()
+	insert := func(ctx context.Context, name string) error {
+		return cli.WithContext(ctx).Table("source").Create(&source{Name: name}).Error
+	}
+	exists := func(name string) bool {
+		var count int64
+		require.NoError(t, cli.WithContext(ctx).Table("source").Where("name = ?", name).Count(&count).Error)
+		return count > 0
+	}
+
+	t.Run("savepoint rolls back the nested call only", func(t *testing.T) {
+		var events []string
+		err := trx.ExecWith(ctx, func(ctx context.Context) error {
+			require.NoError(t, insert(ctx, "outer"))
+			persistence.AfterCommit(ctx, func(context.Context) { events = append(events, "commit") })
+
+			err := trx.ExecWith(ctx, func(ctx context.Context) error {
+				require.NoError(t, insert(ctx, "inner"))
+				persistence.AfterCommit(ctx, func(context.Context) { events = append(events, "inner commit") })
+				persistence.AfterRollback(ctx, func(context.Context) { events = append(events, "inner rollback") })
+				return errors.New("abort")
+			}, persistence.WithSavepoint())
+			require.Error(t, err)
+
+			return nil
+		})
+		require.NoError(t, err)
+
+		assert.True(t, exists("outer"))
+		assert.False(t, exists("inner"))
+		assert.Equal(t, []string{"inner rollback", "commit"}, events)
+	})
+
+	t.Run("panics roll back", func(t *testing.T) {
+		rolledBack := false
+		assert.PanicsWithValue(t, "boom", func() {
+			_ = trx.ExecWith(ctx, func(ctx context.Context) error {
+				require.NoError(t, insert(ctx, "panicked"))
+				persistence.AfterRollback(ctx, func(context.Context) { rolledBack = true })
+				panic("boom")
+			})
+		})
+
+		assert.False(t, exists("panicked"))
+		assert.True(t, rolledBack)
+	})
+
+	t.Run("deadlocks are retried", func(t *testing.T) {
+		attempts := 0
+		err := trx.ExecWith(ctx, func(ctx context.Context) error {
+			attempts++
+			if err := insert(ctx, "retried"); err != nil {
+				return err
+			}
+			if attempts == 1 {
+				return deadlockErr{}
+			}
+			return nil
+		}, persistence.WithRetry(retry.WithConstantPolicy(time.Millisecond)))
+		require.NoError(t, err)
+
+		assert.Equal(t, 2, attempts)
+		var count int64
+		require.NoError(t, cli.Table("source").Where("name = ?", "retried").Count(&count).Error)
+		assert.Equal(t, int64(1), count)
+	})
+}
@@ -0,0 +1,90 @@
+package persistence
+
+import (
+	"context"
+	"sync"
+)
+
+type txHooksKey struct{}
+
+// TxHooks collects the hooks registered within a transaction, or within a
+// savepoint of one, see AfterCommit and AfterRollback. Transactioner
+// implementations run them once the outcome of the transaction is known.
+type TxHooks struct {
+	mu            sync.Mutex
+	parent        *TxHooks
+	afterCommit   []func(context.Context)
+	afterRollback []func(context.Context)
+}
+
+// NewTxHooks returns a context collecting the hooks registered within it in
+// the returned TxHooks, nested in the hooks of the enclosing transaction if
+// any.
+func NewTxHooks(ctx context.Context) (context.Context, *TxHooks) {
+	parent, _ := ctx.Value(txHooksKey{}).(*TxHooks)
+	h := &TxHooks{parent: parent}
+
+	return context.WithValue(ctx, txHooksKey{}, h), h
+}
+
+// Committed runs the after commit hooks, or hands them, along with the
+// after rollback ones, over to the enclosing transaction when h belongs to
+// a savepoint: they run once the outer transaction ends.
+func (h *TxHooks) Committed(ctx context.Context) {
+	h.mu.Lock()
+	afterCommit, afterRollback := h.afterCommit, h.afterRollback
+	h.afterCommit, h.afterRollback = nil, nil
+	h.mu.Unlock()
+
+	if h.parent != nil {
+		h.parent.mu.Lock()
+		h.parent.afterCommit = append(h.parent.afterCommit, afterCommit...)
+		h.parent.afterRollback = append(h.parent.afterRollback, afterRollback...)
+		h.parent.mu.Unlock()
+		return
+	}
+	for _, fn := range afterCommit {
+		fn(ctx)
+	}
+}
+
+// RolledBack runs the after rollback hooks and drops the after commit ones.
+func (h *TxHooks) RolledBack(ctx context.Context) {
+	h.mu.Lock()
+	afterRollback := h.afterRollback
+	h.afterCommit, h.afterRollback = nil, nil
+	h.mu.Unlock()
+
+	for _, fn := range afterRollback {
+		fn(ctx)
+	}
+}
+
+// AfterCommit registers fn to run once the transaction of ctx commits, e.g.
+// to publish an event only when the changes it announces are visible. fn
+// runs right away when ctx has no transaction.
+func AfterCommit(ctx context.Context, fn func(ctx context.Context)) {
+	h, ok := ctx.Value(txHooksKey{}).(*TxHooks)
+	if !ok {
+		fn(ctx)
+		return
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.afterCommit = append(h.afterCommit, fn)
+}
+
+// AfterRollback registers fn to run once the transaction of ctx, or the
+// savepoint it runs in, rolls back. fn never runs when ctx has no
+// transaction.
+func AfterRollback(ctx context.Context, fn func(ctx context.Context)) {
+	h, ok := ctx.Value(txHooksKey{}).(*TxHooks)
+	if !ok {
+		return
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.afterRollback = append(h.afterRollback, fn)
+}
@@ -19,3 +19,7 @@ func (ts *transactioner) Exec(ctx context.Context, fn persistence.TxFunc) error
 	}
 	return nil
 }
+
+func (ts *transactioner) ExecWith(ctx context.Context, fn persistence.TxFunc, _ ...persistence.TxOption) error {
+	return ts.Exec(ctx, fn)
+}
@@ -0,0 +1,267 @@
+package postgres
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"gorm.io/gorm"
+
+	apierrors "github.com/dosanma1/forge/go/kit/errors"
+	"github.com/dosanma1/forge/go/kit/search/query"
+)
+
+// aggregateIdentifier guards the fields interpolated in aggregate SQL.
+var aggregateIdentifier = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*(\.[A-Za-z_][A-Za-z0-9_]*)?$`)
+
+type aggregateColumn uint
+
+const (
+	aggregateKey aggregateColumn = iota
+	aggregateInt
+	aggregateFloat
+	aggregateRaw
+)
+
+// AggregateApply builds the GROUP BY query of the aggregation of q. The
+// filters of q apply as for QueryApply, sorting may reference group and
+// metric names (e.g. -count) and the pagination limit caps the number of
+// buckets.
+func (r *Repo) AggregateApply(ctx context.Context, model any, q query.Query) (tx *gorm.DB) {
+	tx, _ = r.aggregateApply(ctx, model, q, "")
+	return tx
+}
+
+func (r *Repo) AggregateApplyWithTableName(ctx context.Context, model any, q query.Query, tableName string) (tx *gorm.DB) {
+	tx, _ = r.aggregateApply(ctx, model, q, tableName)
+	return tx
+}
+
+// Aggregate runs the aggregation of q and returns its buckets.
+func (r *Repo) Aggregate(ctx context.Context, model any, q query.Query) ([]query.Bucket, error) {
+	return r.aggregate(ctx, model, q, "")
+}
+
+func (r *Repo) AggregateWithTableName(ctx context.Context, model any, q query.Query, tableName string) ([]query.Bucket, error) {
+	return r.aggregate(ctx, model, q, tableName)
+}
+
+func (r *Repo) aggregate(ctx context.Context, model any, q query.Query, tableName string) ([]query.Bucket, error) {
+	tx, columns := r.aggregateApply(ctx, model, q, tableName)
+	if tx.Error != nil {
+		return nil, tx.Error
+	}
+
+	rows, err := tx.Rows()
+	if err != nil {
+		return nil, NewErrUnknown(err)
+	}
+	defer rows.Close()
+
+	names, err := rows.Columns()
+	if err != nil {
+		return nil, NewErrUnknown(err)
+	}
+	buckets := []query.Bucket{}
+	for rows.Next() {
+		values := make([]any, len(names))
+		ptrs := make([]any, len(names))
+		for i := range values {
+			ptrs[i] = &values[i]
+		}
+		if err := rows.Scan(ptrs...); err != nil {
+			return nil, NewErrUnknown(err)
+		}
+
+		b := query.Bucket{Keys: map[string]any{}, Metrics: map[string]any{}}
+		for i, name := range names {
+			switch columns[name] {
+			case aggregateKey:
+				b.Keys[name] = bucketValue(values[i])
+			case aggregateInt:
+				b.Metrics[name] = toInt64(values[i])
+			case aggregateFloat:
+				b.Metrics[name] = toFloat64(values[i])
+			default:
+				b.Metrics[name] = bucketValue(values[i])
+			}
+		}
+		buckets = append(buckets, b)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, NewErrUnknown(err)
+	}
+
+	return buckets, nil
+}
+
+func (r *Repo) aggregateApply(ctx context.Context, model any, q query.Query, tableName string) (*gorm.DB, map[string]aggregateColumn) {
+	tx := r.DB.WithContext(ctx).Model(model)
+	if tableName != "" {
+		tx = tx.Table(tableName)
+	}
+	if q == nil || q.Aggregation() == nil {
+		_ = tx.AddError(apierrors.InvalidArgument("query has no aggregation"))
+		return tx, nil
+	}
+	tx = r.filterApply(tx, q, tableName)
+
+	agg := q.Aggregation()
+	metrics := agg.Metrics
+	if len(metrics) == 0 {
+		metrics = []query.Metric{query.Count()}
+	}
+
+	columns := make(map[string]aggregateColumn, len(agg.GroupBy)+len(metrics))
+	selects := make([]string, 0, len(agg.GroupBy)+len(metrics))
+	groups := make([]string, 0, len(agg.GroupBy))
+	for _, g := range agg.GroupBy {
+		if !aggregateIdentifier.MatchString(g.Field) {
+			_ = tx.AddError(apierrors.InvalidArgument(fmt.Sprintf("invalid group field: %s", g.Field)))
+			return tx, nil
+		}
+		expr, err := r.aggregateColumn(tx, g.Field, tableName)
+		if err != nil {
+			_ = tx.AddError(err)
+			return tx, nil
+		}
+		if g.Trunc != query.TruncNone {
+			if dialect := r.dialect(); dialect != dialectPostgres {
+				_ = tx.AddError(apierrors.InvalidArgument(fmt.Sprintf(
+					"grouping by %s is not supported by %s", g.Name(), dialect,
+				)))
+				return tx, nil
+			}
+			expr = fmt.Sprintf("date_trunc('%s', %s)", g.Trunc, expr)
+		}
+		selects = append(selects, fmt.Sprintf("%s AS %q", expr, g.Name()))
+		groups = append(groups, expr)
+		columns[g.Name()] = aggregateKey
+	}
+	for _, m := range metrics {
+		expr, kind, err := r.metricExpr(tx, m, tableName)
+		if err != nil {
+			_ = tx.AddError(err)
+			return tx, nil
+		}
+		selects = append(selects, fmt.Sprintf("%s AS %q", expr, m.Name()))
+		columns[m.Name()] = kind
+	}
+
+	tx = tx.Select(strings.Join(selects, ", "))
+	if len(groups) > 0 {
+		tx = tx.Group(strings.Join(groups, ", "))
+	}
+	tx = r.aggregateSortingApply(tx, q.Sorting(), agg, columns, tableName)
+	if p := q.Pagination(); p != nil {
+		tx = r.paginationApply(tx, p)
+	}
+
+	return tx, columns
+}
+
+func (r *Repo) metricExpr(tx *gorm.DB, m query.Metric, tableName string) (string, aggregateColumn, error) {
+	if m.Field == "" {
+		if m.Func != query.AggCount {
+			return "", 0, apierrors.InvalidArgument(fmt.Sprintf("aggregate function %s requires a field", m.Func))
+		}
+		return "count(*)", aggregateInt, nil
+	}
+	if !aggregateIdentifier.MatchString(m.Field) {
+		return "", 0, apierrors.InvalidArgument(fmt.Sprintf("invalid aggregate field: %s", m.Field))
+	}
+
+	col, err := r.aggregateColumn(tx, m.Field, tableName)
+	if err != nil {
+		return "", 0, err
+	}
+	switch m.Func {
+	case query.AggCount:
+		return fmt.Sprintf("count(%s)", col), aggregateInt, nil
+	case query.AggSum, query.AggAvg:
+		return fmt.Sprintf("CAST(%s(%s) AS double precision)", m.Func, col), aggregateFloat, nil
+	case query.AggMin, query.AggMax:
+		return fmt.Sprintf("%s(%s)", m.Func, col), aggregateRaw, nil
+	default:
+		return "", 0, apierrors.InvalidArgument(fmt.Sprintf("invalid aggregate function: %d", m.Func))
+	}
+}
+
+// aggregateColumn returns the quoted column of a group or metric field.
+// Aggregations come from the URL, so like sort keys only the mapped fields and
+// their JSON paths are accepted.
+func (r *Repo) aggregateColumn(tx *gorm.DB, field, tableName string) (string, error) {
+	col, err := r.mappedColumn(tx, field, tableName)
+	if err != nil {
+		return "", apierrors.InvalidArgument(fmt.Sprintf("aggregating by %s is not allowed", field))
+	}
+
+	return col, nil
+}
+
+// aggregateSortingApply orders the buckets by the requested keys, which may
+// be group or metric names, and by the group keys otherwise.
+func (r *Repo) aggregateSortingApply(
+	tx *gorm.DB, sorting *query.SortingParams, agg *query.Aggregation,
+	columns map[string]aggregateColumn, tableName string,
+) *gorm.DB {
+	order := []string{}
+	if sorting != nil {
+		for _, key := range sorting.Keys() {
+			if _, ok := columns[key]; ok {
+				order = append(order, fmt.Sprintf("%q %s", key, sorting.Get(key)))
+				continue
+			}
+			col, err := r.sortColumn(tx, sorting, key, tableName)
+			if err != nil {
+				_ = tx.AddError(err)
+				return tx
+			}
+			order = append(order, fmt.Sprintf("%s %s", col, sorting.Get(key)))
+		}
+	}
+	if len(order) == 0 {
+		for _, g := range agg.GroupBy {
+			order = append(order, fmt.Sprintf("%q %s", g.Name(), query.SortAsc))
+		}
+	}
+	if len(order) == 0 {
+		return tx
+	}
+
+	return tx.Order(strings.Join(order, ","))
+}
+
+func bucketValue(v any) any {
+	if b, ok := v.([]byte); ok {
+		return string(b)
+	}
+	return v
+}
+
+func toInt64(v any) int64 {
+	switch n := v.(type) {
+	case int64:
+		return n
+	case int32:
+		return int64(n)
+	case int:
+		return int64(n)
+	case float64:
+		return int64(n)
+	}
+	return 0
+}
+
+func toFloat64(v any) float64 {
+	switch n := v.(type) {
+	case float64:
+		return n
+	case float32:
+		return float64(n)
+	case int64:
+		return float64(n)
+	}
+	return 0
+}
@@ -0,0 +1,97 @@
+package postgres
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	pgdriver "gorm.io/driver/postgres"
+	"gorm.io/gorm"
+
+	apierrors "github.com/dosanma1/forge/go/kit/errors"
+	"github.com/dosanma1/forge/go/kit/filter"
+	"github.com/dosanma1/forge/go/kit/persistence/gormdb"
+	"github.com/dosanma1/forge/go/kit/search/query"
+)
+
+func dryRunRepo(t *testing.T, opts ...RepoOption) *Repo {
+	t.Helper()
+	db, err := gorm.Open(pgdriver.New(pgdriver.Config{DSN: "host=localhost"}), &gorm.Config{
+		DryRun:               true,
+		DisableAutomaticPing: true,
+	})
+	require.NoError(t, err)
+
+	repo, err := NewRepo(&gormdb.DBClient{DB: db}, map[string]string{"created_at": "created_on"}, opts...)
+	require.NoError(t, err)
+	return repo
+}
+
+func TestRepoAggregateApply(t *testing.T) {
+	repo := dryRunRepo(t)
+	repo.fMapper["status"] = "status"
+	repo.fMapper["amount"] = "amount"
+	ctx := context.Background()
+
+	tests := []struct {
+		name     string
+		q        query.Query
+		wantSQL  string
+		wantVars []any
+		wantErr  apierrors.Code
+	}{
+		{
+			name:    "count per status",
+			q:       query.New(query.Aggregate(), query.GroupBy("status")),
+			wantSQL: `SELECT "status" AS "status", count(*) AS "count" FROM "test_entities" GROUP BY "status" ORDER BY "status" ASC`,
+		},
+		{
+			name: "sums per day with filters and sorting by metric",
+			q: query.New(
+				query.FilterBy(filter.OpEq, "status", "paid"),
+				query.Aggregate(query.Sum("amount"), query.Max("amount")),
+				query.GroupByTrunc("created_at", query.TruncDay),
+				query.SortBy("sum_amount", query.SortDesc),
+				query.Pagination(10, 0),
+			),
+			wantSQL: `SELECT date_trunc('day', "created_on") AS "created_at_day", CAST(sum("amount") AS double precision) AS "sum_amount", ` +
+				`max("amount") AS "max_amount" FROM "test_entities" WHERE status = $1 GROUP BY date_trunc('day', "created_on") ` +
+				`ORDER BY "sum_amount" DESC LIMIT $2`,
+			wantVars: []any{"paid", 10},
+		},
+		{
+			name:    "missing aggregation",
+			q:       query.New(),
+			wantErr: apierrors.CodeInvalidArgument,
+		},
+		{
+			name:    "unmapped group field",
+			q:       query.New(query.Aggregate(), query.GroupBy("password")),
+			wantErr: apierrors.CodeInvalidArgument,
+		},
+		{
+			name:    "unmapped metric field",
+			q:       query.New(query.Aggregate(query.Max("password"))),
+			wantErr: apierrors.CodeInvalidArgument,
+		},
+		{
+			name:    "field injection",
+			q:       query.New(query.Aggregate(query.Sum("amount); drop table x; --"))),
+			wantErr: apierrors.CodeInvalidArgument,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tx := repo.AggregateApply(ctx, &TestEntity{}, tt.q).Find(&[]map[string]any{})
+			if tt.wantErr != "" {
+				assert.True(t, apierrors.Is(tx.Error, tt.wantErr), tx.Error)
+				return
+			}
+			require.NoError(t, tx.Error)
+			assert.Equal(t, tt.wantSQL, tx.Statement.SQL.String())
+			assert.Equal(t, tt.wantVars, tx.Statement.Vars)
+		})
+	}
+}
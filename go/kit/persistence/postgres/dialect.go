@@ -0,0 +1,56 @@
+package postgres
+
+import (
+	"fmt"
+
+	apierrors "github.com/dosanma1/forge/go/kit/errors"
+	"github.com/dosanma1/forge/go/kit/filter"
+	"github.com/dosanma1/forge/go/kit/search/query"
+)
+
+const dialectPostgres = "postgres"
+
+// dialect returns the name of the gorm dialector of the repo, postgres when
+// it has no connection.
+func (r *Repo) dialect() string {
+	if r.DB == nil || r.DB.DB == nil || r.DB.Dialector == nil {
+		return dialectPostgres
+	}
+
+	return r.DB.Dialector.Name()
+}
+
+// checkOperators fails when a filter of the tree uses an operator that only
+// PostgreSQL understands, e.g. @> or full text search, and the repo runs on
+// another database.
+func (r *Repo) checkOperators(node *query.FilterNode) error {
+	dialect := r.dialect()
+	if dialect == dialectPostgres {
+		return nil
+	}
+	if node.IsLeaf() {
+		if postgresOperator(node.Filter.Operator()) {
+			return apierrors.InvalidArgument(fmt.Sprintf(
+				"operator %s is not supported by %s", query.MarshalOperator(node.Filter.Operator()), dialect,
+			))
+		}
+		return nil
+	}
+	for _, child := range node.Children {
+		if err := r.checkOperators(child); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func postgresOperator(op filter.Operator) bool {
+	switch op {
+	case filter.OpContains, filter.OpContainsLike, filter.OpSearch, filter.OpFuzzy,
+		filter.OpJSONContains, filter.OpHasKey, filter.OpHasAnyKeys, filter.OpHasAllKeys, filter.OpJSONPath:
+		return true
+	default:
+		return false
+	}
+}
@@ -0,0 +1,202 @@
+package postgres
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+
+	"gorm.io/gorm"
+
+	apierrors "github.com/dosanma1/forge/go/kit/errors"
+	"github.com/dosanma1/forge/go/kit/search/query"
+)
+
+const (
+	relationLoadersKey      = "forge:relation_loaders"
+	relationLoadersCallback = "forge:relation_loaders"
+)
+
+// RelationLoader loads a relationship of the rows found by a query. rows is
+// the destination given to Find, e.g. *[]Article or *Article, and db runs on
+// the connection, hence the transaction, of the query.
+type RelationLoader func(ctx context.Context, db *gorm.DB, rows any) error
+
+type relation struct {
+	preload string
+	loader  RelationLoader
+}
+
+// WithRelation registers the JSON:API relationship name, e.g. author or
+// author.comments, loaded with a gorm Preload of association, e.g. Author or
+// Author.Comments.
+func WithRelation(name, association string) RepoOption {
+	return func(r *Repo) {
+		r.relations[name] = relation{preload: association}
+	}
+}
+
+// WithRelationLoader registers the JSON:API relationship name loaded by a
+// secondary query once the rows are found, see BatchLoader.
+func WithRelationLoader(name string, loader RelationLoader) RepoOption {
+	return func(r *Repo) {
+		r.relations[name] = relation{loader: loader}
+	}
+}
+
+// includeApply loads the relationships requested by the includes of q.
+// Repos without registered relationships leave includes to the caller;
+// otherwise unknown includes are rejected.
+func (r *Repo) includeApply(tx *gorm.DB, q query.Query) *gorm.DB {
+	if len(r.relations) == 0 {
+		return tx
+	}
+
+	loaders := []RelationLoader{}
+	seen := make(map[string]bool)
+	for _, name := range q.IncludedResourceObjects() {
+		if seen[name] {
+			continue
+		}
+		seen[name] = true
+
+		rel, ok := r.relations[name]
+		if !ok {
+			_ = tx.AddError(apierrors.InvalidArgument(fmt.Sprintf("unknown include: %s", name)))
+			return tx
+		}
+		if rel.preload != "" {
+			tx = tx.Preload(rel.preload)
+		}
+		if rel.loader != nil {
+			loaders = append(loaders, rel.loader)
+		}
+	}
+	if len(loaders) > 0 {
+		tx = tx.Set(relationLoadersKey, loaders)
+	}
+
+	return tx
+}
+
+// registerRelationLoaders runs the loaders set by includeApply after every
+// query, next to the gorm preloads.
+func registerRelationLoaders(db *gorm.DB) error {
+	if db.Callback().Query().Get(relationLoadersCallback) != nil {
+		return nil
+	}
+
+	return db.Callback().Query().After("gorm:preload").Register(relationLoadersCallback, loadRelations)
+}
+
+func loadRelations(db *gorm.DB) {
+	if db.Error != nil || db.DryRun {
+		return
+	}
+	v, ok := db.Get(relationLoadersKey)
+	if !ok {
+		return
+	}
+	loaders, _ := v.([]RelationLoader)
+
+	ctx := db.Statement.Context
+	// Scopes starts the fresh statement of the session right away, otherwise
+	// a WithContext call of a loader would clone the statement of the query.
+	session := db.Session(&gorm.Session{NewDB: true, Context: ctx}).Scopes()
+	for _, load := range loaders {
+		if err := load(ctx, session, db.Statement.Dest); err != nil {
+			_ = db.AddError(err)
+			return
+		}
+	}
+}
+
+// BatchLoader loads a relationship of a page of P rows with a single IN query
+// on the column of C matching the parent keys, instead of a query per row.
+// To-many relationships match the foreign key of the children:
+//
+//	postgres.BatchLoader("article_id",
+//		func(a *Article) string { return a.ID },
+//		func(c *Comment) string { return c.ArticleID },
+//		func(a *Article, cs []*Comment) { a.Comments = cs },
+//	)
+//
+// and to-one relationships the primary key of the related row:
+//
+//	postgres.BatchLoader("id",
+//		func(a *Article) string { return a.AuthorID },
+//		func(u *User) string { return u.ID },
+//		func(a *Article, us []*User) {
+//			if len(us) > 0 {
+//				a.Author = us[0]
+//			}
+//		},
+//	)
+func BatchLoader[P, C any, K comparable](
+	column string, parentKey func(P) K, childKey func(C) K, assign func(P, []C),
+) RelationLoader {
+	return func(ctx context.Context, db *gorm.DB, rows any) error {
+		parents := relationParents[P](rows)
+		if len(parents) == 0 {
+			return nil
+		}
+
+		keys := make([]K, 0, len(parents))
+		seen := make(map[K]bool, len(parents))
+		for _, p := range parents {
+			k := parentKey(p)
+			if !seen[k] {
+				seen[k] = true
+				keys = append(keys, k)
+			}
+		}
+
+		var children []C
+		if err := db.WithContext(ctx).Where(fmt.Sprintf("%s IN ?", column), keys).Find(&children).Error; err != nil {
+			return NewErrUnknown(err)
+		}
+		byParent := make(map[K][]C, len(keys))
+		for _, c := range children {
+			k := childKey(c)
+			byParent[k] = append(byParent[k], c)
+		}
+		for _, p := range parents {
+			assign(p, byParent[parentKey(p)])
+		}
+
+		return nil
+	}
+}
+
+// relationParents returns the rows of a Find destination as P, taking the
+// address of slice elements when P is a pointer type.
+func relationParents[P any](rows any) []P {
+	if p, ok := rows.(P); ok {
+		return []P{p}
+	}
+
+	v := reflect.Indirect(reflect.ValueOf(rows))
+	if v.Kind() != reflect.Slice && v.Kind() != reflect.Array {
+		if v.IsValid() && v.CanInterface() {
+			if p, ok := v.Interface().(P); ok {
+				return []P{p}
+			}
+		}
+		return nil
+	}
+
+	parents := make([]P, 0, v.Len())
+	for i := 0; i < v.Len(); i++ {
+		elem := v.Index(i)
+		if p, ok := elem.Interface().(P); ok {
+			parents = append(parents, p)
+			continue
+		}
+		if elem.CanAddr() {
+			if p, ok := elem.Addr().Interface().(P); ok {
+				parents = append(parents, p)
+			}
+		}
+	}
+
+	return parents
+}
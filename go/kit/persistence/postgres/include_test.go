@@ -0,0 +1,114 @@
+package postgres
+
+import (
+	"context"
+	"regexp"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	pgdriver "gorm.io/driver/postgres"
+	"gorm.io/gorm"
+
+	apierrors "github.com/dosanma1/forge/go/kit/errors"
+	"github.com/dosanma1/forge/go/kit/persistence/gormdb"
+	"github.com/dosanma1/forge/go/kit/search/query"
+)
+
+type testArticle struct {
+	ID       string         `gorm:"primaryKey;column:id"`
+	AuthorID string         `gorm:"column:author_id"`
+	Author   *testAuthor    `gorm:"foreignKey:AuthorID"`
+	Comments []*testComment `gorm:"-"`
+}
+
+func (testArticle) TableName() string { return "articles" }
+
+type testAuthor struct {
+	ID string `gorm:"primaryKey;column:id"`
+}
+
+func (testAuthor) TableName() string { return "authors" }
+
+type testComment struct {
+	ID        string `gorm:"primaryKey;column:id"`
+	ArticleID string `gorm:"column:article_id"`
+}
+
+func (testComment) TableName() string { return "comments" }
+
+func mockRepo(t *testing.T, opts ...RepoOption) (*Repo, sqlmock.Sqlmock) {
+	t.Helper()
+	conn, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = conn.Close() })
+
+	db, err := gorm.Open(pgdriver.New(pgdriver.Config{Conn: conn}), &gorm.Config{DisableAutomaticPing: true})
+	require.NoError(t, err)
+	repo, err := NewRepo(&gormdb.DBClient{DB: db}, map[string]string{"id": "id"}, opts...)
+	require.NoError(t, err)
+
+	return repo, mock
+}
+
+func TestRepoIncludeApply(t *testing.T) {
+	commentsLoader := BatchLoader("article_id",
+		func(a *testArticle) string { return a.ID },
+		func(c *testComment) string { return c.ArticleID },
+		func(a *testArticle, cs []*testComment) { a.Comments = cs },
+	)
+	ctx := context.Background()
+
+	t.Run("preloads and batch loads included relationships", func(t *testing.T) {
+		repo, mock := mockRepo(t,
+			WithRelation("author", "Author"),
+			WithRelationLoader("comments", commentsLoader),
+		)
+		mock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "articles"`)).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "author_id"}).AddRow("a1", "u1").AddRow("a2", "u1"))
+		mock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "authors" WHERE "authors"."id" = $1`)).
+			WithArgs("u1").
+			WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow("u1"))
+		mock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "comments" WHERE article_id IN ($1,$2)`)).
+			WithArgs("a1", "a2").
+			WillReturnRows(sqlmock.NewRows([]string{"id", "article_id"}).AddRow("c1", "a2").AddRow("c2", "a2"))
+
+		var articles []testArticle
+		q := query.New(query.IncludedResourceObjects("author", "comments"))
+		require.NoError(t, repo.QueryApply(ctx, q).Find(&articles).Error)
+		require.NoError(t, mock.ExpectationsWereMet())
+
+		require.Len(t, articles, 2)
+		assert.Equal(t, "u1", articles[0].Author.ID)
+		assert.Empty(t, articles[0].Comments)
+		assert.Equal(t, []*testComment{{ID: "c1", ArticleID: "a2"}, {ID: "c2", ArticleID: "a2"}}, articles[1].Comments)
+	})
+
+	t.Run("skips loaders without includes", func(t *testing.T) {
+		repo, mock := mockRepo(t, WithRelationLoader("comments", commentsLoader))
+		mock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "articles"`)).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "author_id"}).AddRow("a1", "u1"))
+
+		var articles []testArticle
+		require.NoError(t, repo.QueryApply(ctx, query.New()).Find(&articles).Error)
+		require.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("rejects unknown includes", func(t *testing.T) {
+		repo, _ := mockRepo(t, WithRelation("author", "Author"))
+
+		var articles []testArticle
+		err := repo.QueryApply(ctx, query.New(query.IncludedResourceObjects("author.comments"))).Find(&articles).Error
+		assert.True(t, apierrors.Is(err, apierrors.CodeInvalidArgument), err)
+	})
+}
+
+func TestRelationParents(t *testing.T) {
+	a1, a2 := testArticle{ID: "a1"}, testArticle{ID: "a2"}
+
+	assert.Len(t, relationParents[*testArticle](&[]testArticle{a1, a2}), 2)
+	assert.Len(t, relationParents[*testArticle](&[]*testArticle{&a1, &a2}), 2)
+	assert.Equal(t, []*testArticle{&a1}, relationParents[*testArticle](&a1))
+	assert.Empty(t, relationParents[*testArticle](&[]testComment{{ID: "c1"}}))
+}
@@ -0,0 +1,85 @@
+package postgres
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/lib/pq"
+
+	"github.com/dosanma1/forge/go/kit/filter"
+)
+
+// jsonField resolves a dot-notation field, e.g. metadata.color, whose first
+// segment is mapped to a JSONB column. Fields mapped as a whole take
+// precedence.
+func (r *Repo) jsonField(key string) (col string, path []string, ok bool) {
+	if _, mapped := r.fMapper[key]; mapped {
+		return "", nil, false
+	}
+	prefix, rest, found := strings.Cut(key, ".")
+	if !found || rest == "" {
+		return "", nil, false
+	}
+	col = r.fMapper[prefix]
+	if col == "" {
+		return "", nil, false
+	}
+
+	return col, strings.Split(rest, "."), true
+}
+
+// jsonPathExpr renders metadata->'dims'->>'width'. The last step extracts
+// text when asText is set and JSONB otherwise.
+func jsonPathExpr(col string, path []string, asText bool) string {
+	b := strings.Builder{}
+	b.WriteString(col)
+	for i, seg := range path {
+		op := "->"
+		if asText && i == len(path)-1 {
+			op = "->>"
+		}
+		fmt.Fprintf(&b, "%s'%s'", op, strings.ReplaceAll(seg, "'", "''"))
+	}
+
+	return b.String()
+}
+
+// jsonbColumn is the JSONB value of a field, for the JSON operators.
+func (r *Repo) jsonbColumn(key, tableName string) string {
+	if col, path, ok := r.jsonField(key); ok {
+		return jsonPathExpr(qualify(col, tableName), path, false)
+	}
+	return r.column(key, tableName)
+}
+
+// jsonCond renders the JSON operators. gorm takes every ? as a placeholder,
+// so the key existence and path operators (?, ?|, ?& and @?) are written
+// with their function forms.
+func (r *Repo) jsonCond(filt filter.FieldFilter[any], tableName string) (string, []any) {
+	col := r.jsonbColumn(filt.Name(), tableName)
+
+	switch filt.Operator() {
+	case filter.OpJSONContains:
+		return fmt.Sprintf("%s @> ?::jsonb", col), []any{jsonArg(filt.Value())}
+	case filter.OpHasKey:
+		return fmt.Sprintf("jsonb_exists(%s, ?)", col), []any{fmt.Sprint(filt.Value())}
+	case filter.OpHasAnyKeys:
+		return fmt.Sprintf("jsonb_exists_any(%s, ?)", col), []any{pq.Array(filt.Value())}
+	case filter.OpHasAllKeys:
+		return fmt.Sprintf("jsonb_exists_all(%s, ?)", col), []any{pq.Array(filt.Value())}
+	default:
+		return fmt.Sprintf("jsonb_path_exists(%s, ?::jsonpath)", col), []any{fmt.Sprint(filt.Value())}
+	}
+}
+
+func jsonArg(v any) string {
+	if s, ok := v.(string); ok {
+		return s
+	}
+	b, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Sprint(v)
+	}
+	return string(b)
+}
@@ -0,0 +1,95 @@
+package postgres
+
+import (
+	"context"
+	"testing"
+
+	"github.com/lib/pq"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	apierrors "github.com/dosanma1/forge/go/kit/errors"
+	"github.com/dosanma1/forge/go/kit/filter"
+	"github.com/dosanma1/forge/go/kit/search/query"
+)
+
+func TestRepoJSONBApply(t *testing.T) {
+	repo := dryRunRepo(t)
+	repo.fMapper["metadata"] = "attrs"
+	ctx := context.Background()
+
+	tests := []struct {
+		name     string
+		q        query.Query
+		wantSQL  string
+		wantVars []any
+	}{
+		{
+			name:     "dot notation compares text",
+			q:        query.New(query.FilterBy(filter.OpEq, "metadata.color", "red")),
+			wantSQL:  `SELECT * FROM "test_entities" WHERE attrs->>'color' = $1`,
+			wantVars: []any{"red"},
+		},
+		{
+			name: "nested dot notation sorted",
+			q: query.New(
+				query.FilterBy(filter.OpIn, "metadata.dims.unit", []string{"cm", "mm"}),
+				query.SortBy("metadata.dims.width", query.SortDesc),
+			),
+			wantSQL:  `SELECT * FROM "test_entities" WHERE attrs->'dims'->>'unit' IN ($1,$2) ORDER BY "attrs"->'dims'->>'width' DESC`,
+			wantVars: []any{"cm", "mm"},
+		},
+		{
+			name:     "json containment",
+			q:        query.New(query.Where(query.Field("metadata").JSONContains(map[string]any{"tags": []string{"a"}}))),
+			wantSQL:  `SELECT * FROM "test_entities" WHERE attrs @> $1::jsonb`,
+			wantVars: []any{`{"tags":["a"]}`},
+		},
+		{
+			name:     "key existence on a nested object",
+			q:        query.New(query.FilterBy(filter.OpHasKey, "metadata.dims", "width")),
+			wantSQL:  `SELECT * FROM "test_entities" WHERE jsonb_exists(attrs->'dims', $1)`,
+			wantVars: []any{"width"},
+		},
+		{
+			name: "any and all keys",
+			q: query.New(query.Where(
+				query.Field("metadata").HasAnyKeys("a", "b"),
+				query.Field("metadata").HasAllKeys("c"),
+			)),
+			wantSQL:  `SELECT * FROM "test_entities" WHERE jsonb_exists_any(attrs, $1) AND jsonb_exists_all(attrs, $2)`,
+			wantVars: []any{pq.Array([]string{"a", "b"}), pq.Array([]string{"c"})},
+		},
+		{
+			name:     "json path",
+			q:        query.New(query.FilterBy(filter.OpJSONPath, "metadata", `$.items[*] ? (@.qty > 2)`)),
+			wantSQL:  `SELECT * FROM "test_entities" WHERE jsonb_path_exists(attrs, $1::jsonpath)`,
+			wantVars: []any{`$.items[*] ? (@.qty > 2)`},
+		},
+		{
+			name:     "quotes in keys are escaped",
+			q:        query.New(query.FilterBy(filter.OpEq, "metadata.o'neil", "x")),
+			wantSQL:  `SELECT * FROM "test_entities" WHERE attrs->>'o''neil' = $1`,
+			wantVars: []any{"x"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tx := repo.QueryApply(ctx, tt.q).Find(&[]TestEntity{})
+			require.NoError(t, tx.Error)
+			assert.Equal(t, tt.wantSQL, tx.Statement.SQL.String())
+			assert.Equal(t, tt.wantVars, tx.Statement.Vars)
+		})
+	}
+
+	t.Run("json containment of a value failing to marshal", func(t *testing.T) {
+		q := query.New(query.Where(query.Or(
+			query.Field("metadata.color").Eq("red"),
+			query.Field("metadata").JSONContains(func() {}),
+		)))
+
+		tx := repo.QueryApply(ctx, q).Find(&[]TestEntity{})
+		assert.True(t, apierrors.Is(tx.Error, apierrors.CodeInvalidArgument), tx.Error)
+	})
+}
@@ -0,0 +1,395 @@
+package postgres
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+
+	"gorm.io/gorm"
+
+	apierrors "github.com/dosanma1/forge/go/kit/errors"
+	"github.com/dosanma1/forge/go/kit/search/query"
+)
+
+// keysetTieBreaker is appended to the sort keys when missing so that every
+// row has a unique position in the keyset ordering.
+const keysetTieBreaker = "id"
+
+// keysetKey is a column of the keyset ordering. Sort keys may be null, e.g. a
+// missing JSON path, so they are ordered with their nulls last and the cursor
+// conditions take the nulls into account; only the tie breaker is known to be
+// not null.
+type keysetKey struct {
+	name       string
+	col        string
+	dir        query.SortingDir
+	nullable   bool
+	nullsFirst bool
+}
+
+// keysetKeys returns the sort keys of the requested ordering, completed with
+// the tie breaker.
+func (r *Repo) keysetKeys(tx *gorm.DB, sorting *query.SortingParams, tableName string) ([]keysetKey, error) {
+	var keys []keysetKey
+	dir := query.SortAsc
+	if sorting != nil {
+		for _, key := range sorting.Keys() {
+			dir = sorting.Get(key)
+			col, err := r.sortColumn(tx, sorting, key, tableName)
+			if err != nil {
+				return nil, err
+			}
+			keys = append(keys, keysetKey{name: key, col: col, dir: dir, nullable: key != keysetTieBreaker})
+		}
+	}
+	if !slices.ContainsFunc(keys, func(k keysetKey) bool { return k.name == keysetTieBreaker }) {
+		col := tx.Statement.Quote(r.column(keysetTieBreaker, tableName))
+		keys = append(keys, keysetKey{name: keysetTieBreaker, col: col, dir: dir})
+	}
+
+	return keys, nil
+}
+
+func (r *Repo) keysetApply(tx *gorm.DB, q query.Query, tableName string) *gorm.DB {
+	page := q.CursorPagination()
+	if slices.Contains(q.Sorting().Keys(), query.SortRelevance) {
+		_ = tx.AddError(apierrors.InvalidArgument("sorting by relevance does not support cursor pagination"))
+		return tx
+	}
+	keys, err := r.keysetKeys(tx, q.Sorting(), tableName)
+	if err != nil {
+		_ = tx.AddError(err)
+		return tx
+	}
+	if page.Direction == query.CursorBefore {
+		// walk the ordering backwards, KeysetPage restores it afterwards
+		for i := range keys {
+			keys[i].dir = reverseDir(keys[i].dir)
+			keys[i].nullsFirst = !keys[i].nullsFirst
+		}
+	}
+
+	if page.Cursor != "" {
+		pc, err := r.codec().Decode(page.Cursor)
+		if err != nil {
+			_ = tx.AddError(err)
+			return tx
+		}
+		if !pc.Matches(keysetNames(keys)) {
+			_ = tx.AddError(apierrors.InvalidArgument("page cursor does not match the requested sorting"))
+			return tx
+		}
+		sql, args := keysetCondition(keys, pc.Values)
+		tx = tx.Where(sql, args...)
+	}
+
+	order := make([]string, len(keys))
+	for i, k := range keys {
+		order[i] = fmt.Sprintf("%s %s", k.col, k.dir)
+		if k.nullable {
+			order[i] += nullsOrder(k.nullsFirst)
+		}
+	}
+	tx = tx.Order(strings.Join(order, ","))
+	if page.Limit > 0 {
+		// fetch one extra row to know whether there is a further page
+		tx = tx.Limit(page.Limit + 1)
+	}
+
+	return tx
+}
+
+// keysetCondition builds the predicate selecting the rows strictly after the
+// cursor values. When all keys share a direction and none may be null a
+// row-value comparison is used, which lets postgres use a composite index;
+// otherwise it expands into (a > ?) OR (a = ? AND b < ?) ..., where a null
+// key is only followed by nulls, or by every value when nulls come first.
+func keysetCondition(keys []keysetKey, values []any) (string, []any) {
+	rowValues := true
+	for i, k := range keys {
+		if k.dir != keys[0].dir || k.nullable || values[i] == nil {
+			rowValues = false
+			break
+		}
+	}
+
+	if rowValues {
+		cols := make([]string, len(keys))
+		marks := make([]string, len(keys))
+		for i, k := range keys {
+			cols[i] = k.col
+			marks[i] = "?"
+		}
+		return fmt.Sprintf("(%s) %s (%s)", strings.Join(cols, ", "), keysetOp(keys[0].dir), strings.Join(marks, ", ")), values
+	}
+
+	ors := make([]string, 0, len(keys))
+	args := []any{}
+	for i, k := range keys {
+		after, afterArgs, ok := keysetAfter(k, values[i])
+		if !ok {
+			continue
+		}
+		ands := make([]string, 0, i+1)
+		for j := 0; j < i; j++ {
+			if values[j] == nil {
+				ands = append(ands, fmt.Sprintf("%s IS NULL", keys[j].col))
+				continue
+			}
+			ands = append(ands, fmt.Sprintf("%s = ?", keys[j].col))
+			args = append(args, values[j])
+		}
+		ands = append(ands, after)
+		args = append(args, afterArgs...)
+		ors = append(ors, "("+strings.Join(ands, " AND ")+")")
+	}
+	if len(ors) == 0 {
+		return "1 = 0", nil
+	}
+
+	return "(" + strings.Join(ors, " OR ") + ")", args
+}
+
+// keysetAfter returns the predicate selecting the values of k strictly after
+// v, false when there is none: v is null and nulls come last.
+func keysetAfter(k keysetKey, v any) (string, []any, bool) {
+	if v == nil {
+		if k.nullsFirst {
+			return fmt.Sprintf("%s IS NOT NULL", k.col), nil, true
+		}
+		return "", nil, false
+	}
+	if k.nullable && !k.nullsFirst {
+		return fmt.Sprintf("(%s %s ? OR %s IS NULL)", k.col, keysetOp(k.dir), k.col), []any{v}, true
+	}
+
+	return fmt.Sprintf("%s %s ?", k.col, keysetOp(k.dir)), []any{v}, true
+}
+
+// KeysetPage post-processes the rows fetched by a cursor paginated query: it
+// drops the look-ahead row, restores the requested order when paging
+// backwards and returns the next and previous page cursors. Rows must be
+// gorm models whose columns include every sort key.
+func KeysetPage[M any](ctx context.Context, r *Repo, q query.Query, rows []M) (page []M, next, prev string, err error) {
+	cp := q.CursorPagination()
+	if cp == nil {
+		return rows, "", "", nil
+	}
+
+	hasMore := cp.Limit > 0 && len(rows) > cp.Limit
+	if hasMore {
+		rows = rows[:cp.Limit]
+	}
+	if cp.Direction == query.CursorBefore {
+		slices.Reverse(rows)
+	}
+	if len(rows) == 0 {
+		return rows, "", "", nil
+	}
+
+	keys, err := r.keysetKeys(r.DB.DB, q.Sorting(), "")
+	if err != nil {
+		return nil, "", "", err
+	}
+	first, last := rows[0], rows[len(rows)-1]
+	cameFrom := cp.Cursor != ""
+
+	switch cp.Direction {
+	case query.CursorBefore:
+		if hasMore {
+			prev, err = r.encodeCursor(ctx, keys, first)
+		}
+		if err == nil && cameFrom {
+			next, err = r.encodeCursor(ctx, keys, last)
+		}
+	default:
+		if hasMore {
+			next, err = r.encodeCursor(ctx, keys, last)
+		}
+		if err == nil && cameFrom {
+			prev, err = r.encodeCursor(ctx, keys, first)
+		}
+	}
+	if err != nil {
+		return nil, "", "", err
+	}
+
+	return rows, next, prev, nil
+}
+
+func (r *Repo) encodeCursor(ctx context.Context, keys []keysetKey, row any) (string, error) {
+	stmt := &gorm.Statement{DB: r.DB.DB}
+	if err := stmt.Parse(row); err != nil {
+		return "", apierrors.WrapInternal(err, "parsing keyset model")
+	}
+
+	rv := reflect.ValueOf(row)
+	values := make([]any, len(keys))
+	for i, k := range keys {
+		col, path, isJSON := r.jsonField(k.name)
+		if !isJSON {
+			col = r.column(k.name, "")
+		}
+		if idx := strings.LastIndex(col, "."); idx >= 0 {
+			col = col[idx+1:]
+		}
+		field := stmt.Schema.LookUpField(col)
+		if field == nil {
+			return "", apierrors.InternalError(fmt.Sprintf("sort key %s is not a column of %s", k.name, stmt.Schema.Name))
+		}
+		values[i], _ = field.ValueOf(ctx, rv)
+		if isJSON {
+			v, err := jsonCursorValue(values[i], path)
+			if err != nil {
+				return "", apierrors.WrapInternal(err, fmt.Sprintf("reading sort key %s", k.name))
+			}
+			values[i] = v
+		}
+	}
+
+	return r.codec().Encode(query.PageCursor{Keys: keysetNames(keys), Values: values})
+}
+
+// jsonCursorValue returns the value at path of the JSON document doc as the
+// ->> operator of the sort column renders it: text, nil for null or missing
+// values.
+func jsonCursorValue(doc any, path []string) (any, error) {
+	var raw []byte
+	switch d := doc.(type) {
+	case nil:
+		return nil, nil
+	case []byte:
+		raw = d
+	case json.RawMessage:
+		raw = d
+	case string:
+		raw = []byte(d)
+	default:
+		b, err := json.Marshal(d)
+		if err != nil {
+			return nil, err
+		}
+		raw = b
+	}
+	if len(raw) == 0 {
+		return nil, nil
+	}
+
+	var v any
+	dec := json.NewDecoder(bytes.NewReader(raw))
+	dec.UseNumber()
+	if err := dec.Decode(&v); err != nil {
+		return nil, err
+	}
+	for _, seg := range path {
+		obj, ok := v.(map[string]any)
+		if !ok {
+			return nil, nil
+		}
+		v = obj[seg]
+	}
+
+	switch v := v.(type) {
+	case nil:
+		return nil, nil
+	case string:
+		return v, nil
+	case json.Number:
+		return v.String(), nil
+	case bool:
+		return strconv.FormatBool(v), nil
+	default:
+		b, err := json.Marshal(v)
+		if err != nil {
+			return nil, err
+		}
+		return string(b), nil
+	}
+}
+
+// sortColumn returns the column of a sort key. Only the mapped fields and
+// their JSON paths are accepted for the keys requested in the URL, the keys
+// set by the service fall back to the raw key.
+func (r *Repo) sortColumn(tx *gorm.DB, sorting *query.SortingParams, key, tableName string) (string, error) {
+	if !sorting.FromURL(key) {
+		if _, _, ok := r.jsonField(key); ok || r.fMapper[key] != "" {
+			return r.mappedColumn(tx, key, tableName)
+		}
+		return r.column(key, tableName), nil
+	}
+	col, err := r.mappedColumn(tx, key, tableName)
+	if err != nil {
+		return "", apierrors.InvalidArgument(fmt.Sprintf("sorting by %s is not allowed", key))
+	}
+
+	return col, nil
+}
+
+// mappedColumn returns the quoted column of a mapped field or JSON path,
+// failing for any other key.
+func (r *Repo) mappedColumn(tx *gorm.DB, key, tableName string) (string, error) {
+	if col, path, ok := r.jsonField(key); ok {
+		return jsonPathExpr(tx.Statement.Quote(qualify(col, tableName)), path, true), nil
+	}
+	col := r.fMapper[key]
+	if col == "" {
+		return "", apierrors.InvalidArgument(fmt.Sprintf("%s is not a mapped field", key))
+	}
+
+	return tx.Statement.Quote(qualify(col, tableName)), nil
+}
+
+func (r *Repo) column(key, tableName string) string {
+	if col, path, ok := r.jsonField(key); ok {
+		return jsonPathExpr(qualify(col, tableName), path, true)
+	}
+	col := r.fMapper[key]
+	if col == "" {
+		col = key
+	}
+
+	return qualify(col, tableName)
+}
+
+func keysetNames(keys []keysetKey) []string {
+	names := make([]string, len(keys))
+	for i, k := range keys {
+		names[i] = k.name
+	}
+	return names
+}
+
+func keysetOp(dir query.SortingDir) string {
+	if dir == query.SortDesc {
+		return "<"
+	}
+	return ">"
+}
+
+func nullsOrder(first bool) string {
+	if first {
+		return " NULLS FIRST"
+	}
+	return " NULLS LAST"
+}
+
+func reverseDir(dir query.SortingDir) query.SortingDir {
+	if dir == query.SortDesc {
+		return query.SortAsc
+	}
+	return query.SortDesc
+}
+
+// codec returns the cursor codec of the repo, the default one when none was
+// set.
+func (r *Repo) codec() *query.CursorCodec {
+	if r.cursorCodec != nil {
+		return r.cursorCodec
+	}
+	return query.DefaultCursorCodec()
+}
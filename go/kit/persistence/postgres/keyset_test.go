@@ -0,0 +1,162 @@
+package postgres
+
+import (
+	"context"
+	"encoding/json"
+	"regexp"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/dosanma1/forge/go/kit/resource"
+	"github.com/dosanma1/forge/go/kit/search/query"
+)
+
+func TestKeysetCondition(t *testing.T) {
+	tests := []struct {
+		name     string
+		keys     []keysetKey
+		values   []any
+		wantSQL  string
+		wantArgs []any
+	}{
+		{
+			name: "same direction uses row values",
+			keys: []keysetKey{
+				{name: "created_at", col: "created_at", dir: query.SortDesc},
+				{name: "id", col: "id", dir: query.SortDesc},
+			},
+			values:   []any{"2024-01-01", "a"},
+			wantSQL:  "(created_at, id) < (?, ?)",
+			wantArgs: []any{"2024-01-01", "a"},
+		},
+		{
+			name: "mixed directions expand",
+			keys: []keysetKey{
+				{name: "name", col: "t.name", dir: query.SortAsc},
+				{name: "id", col: "t.id", dir: query.SortDesc},
+			},
+			values:   []any{"bob", "b"},
+			wantSQL:  "((t.name > ?) OR (t.name = ? AND t.id < ?))",
+			wantArgs: []any{"bob", "bob", "b"},
+		},
+		{
+			name: "nullable key is followed by its nulls",
+			keys: []keysetKey{
+				{name: "name", col: "name", dir: query.SortAsc, nullable: true},
+				{name: "id", col: "id", dir: query.SortAsc},
+			},
+			values:   []any{"bob", "b"},
+			wantSQL:  "(((name > ? OR name IS NULL)) OR (name = ? AND id > ?))",
+			wantArgs: []any{"bob", "bob", "b"},
+		},
+		{
+			name: "null key is only followed by nulls",
+			keys: []keysetKey{
+				{name: "name", col: "name", dir: query.SortAsc, nullable: true},
+				{name: "id", col: "id", dir: query.SortAsc},
+			},
+			values:   []any{nil, "b"},
+			wantSQL:  "((name IS NULL AND id > ?))",
+			wantArgs: []any{"b"},
+		},
+		{
+			name: "null key walking backwards is followed by every value",
+			keys: []keysetKey{
+				{name: "name", col: "name", dir: query.SortDesc, nullable: true, nullsFirst: true},
+				{name: "id", col: "id", dir: query.SortDesc},
+			},
+			values:   []any{nil, "b"},
+			wantSQL:  "((name IS NOT NULL) OR (name IS NULL AND id < ?))",
+			wantArgs: []any{"b"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sql, args := keysetCondition(tt.keys, tt.values)
+			assert.Equal(t, tt.wantSQL, sql)
+			assert.Equal(t, tt.wantArgs, args)
+		})
+	}
+}
+
+type keysetItem struct {
+	ID    string          `gorm:"primaryKey;column:id"`
+	Attrs json.RawMessage `gorm:"column:attrs;type:jsonb"`
+}
+
+func TestKeysetPageJSONSortKey(t *testing.T) {
+	codec := query.NewCursorCodec([]byte("secret"))
+	repo := dryRunRepo(t, WithCursorCodec(codec))
+	repo.fMapper["attrs"] = "attrs"
+
+	q := query.New(query.CursorPage(1, "", query.CursorAfter), query.SortBy("attrs.dims.size", query.SortAsc))
+	rows := []keysetItem{
+		{ID: "a", Attrs: json.RawMessage(`{"dims": {"size": 10}}`)},
+		{ID: "b", Attrs: json.RawMessage(`{"dims": {"size": 20}}`)},
+	}
+
+	page, next, prev, err := KeysetPage(context.Background(), repo, q, rows)
+	require.NoError(t, err)
+	assert.Equal(t, rows[:1], page)
+	assert.Empty(t, prev)
+
+	pc, err := codec.Decode(next)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"attrs.dims.size", "id"}, pc.Keys)
+	assert.Equal(t, []any{"10", "a"}, pc.Values)
+}
+
+func TestList(t *testing.T) {
+	codec := query.NewCursorCodec([]byte("secret"))
+	repo, mock := mockRepo(t, WithCursorCodec(codec))
+	ctx := context.Background()
+
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "articles" ORDER BY "id" ASC LIMIT $1`)).
+		WithArgs(2).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "author_id"}).AddRow("a1", "u1").AddRow("a2", "u1"))
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT count(*) FROM "articles"`)).
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(3))
+
+	q := query.New(query.CursorPage(1, "", query.CursorAfter), query.SortBy("id", query.SortAsc))
+	list, err := List(ctx, repo, q, func(a testArticle) string { return a.ID })
+	require.NoError(t, err)
+	require.NoError(t, mock.ExpectationsWereMet())
+	assert.Equal(t, []string{"a1"}, list.Results())
+	assert.Equal(t, 3, list.TotalCount())
+
+	page, ok := list.(resource.CursorListResponse)
+	require.True(t, ok)
+	assert.Empty(t, page.PrevCursor())
+	pc, err := codec.Decode(page.NextCursor())
+	require.NoError(t, err)
+	assert.Equal(t, []any{"a1"}, pc.Values)
+}
+
+func TestJSONCursorValue(t *testing.T) {
+	tests := []struct {
+		name string
+		doc  any
+		path []string
+		want any
+	}{
+		{name: "string", doc: json.RawMessage(`{"color": "red"}`), path: []string{"color"}, want: "red"},
+		{name: "number", doc: []byte(`{"dims": {"size": 1.50}}`), path: []string{"dims", "size"}, want: "1.50"},
+		{name: "bool", doc: `{"vip": true}`, path: []string{"vip"}, want: "true"},
+		{name: "object", doc: map[string]any{"dims": map[string]any{"w": 1}}, path: []string{"dims"}, want: `{"w":1}`},
+		{name: "null", doc: json.RawMessage(`{"color": null}`), path: []string{"color"}, want: nil},
+		{name: "missing key", doc: json.RawMessage(`{"color": "red"}`), path: []string{"dims", "size"}, want: nil},
+		{name: "null document", doc: nil, path: []string{"color"}, want: nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := jsonCursorValue(tt.doc, tt.path)
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
@@ -2,24 +2,56 @@ package postgres
 
 import (
 	"context"
+	"fmt"
+	"slices"
 
-	"github.com/dosanma1/forge/go/kit/application/repository"
+	"gorm.io/gorm"
 	"gorm.io/gorm/clause"
+
+	"github.com/dosanma1/forge/go/kit/application/repository"
+	apierrors "github.com/dosanma1/forge/go/kit/errors"
+	"github.com/dosanma1/forge/go/kit/persistence/gormdb"
+	"github.com/dosanma1/forge/go/kit/persistence/sqldb"
+)
+
+const (
+	lockKey      = gormdb.LockSettingKey
+	lockCallback = "forge:lock"
+	lockErrors   = "forge:lock_errors"
+	lockRestore  = "forge:lock_restore"
+	// lockTimeoutKey holds the lock_timeout of the transaction before the query
+	lockTimeoutKey = "forge:lock_timeout"
 )
 
 type queryApplySetup struct {
-	lock *clause.Locking
+	lock    *clause.Locking
+	prelude repository.Lock
+	db      *gorm.DB
 }
 
 type queryApplyOption func(*queryApplySetup)
 
-// withLock applies database locking based on the lock information retrieved from the context.
-// It checks the lock level and mode to determine the appropriate locking clause for the database query.
-//
-// If the conditions don't match the expected cases, the function panics with an error message
-// indicating an unexpected locking mode or level.
+// withDB applies the query on db, e.g. a transaction, instead of the repo
+// connection.
+func withDB(db *gorm.DB) queryApplyOption {
+	return func(s *queryApplySetup) {
+		s.db = db
+	}
+}
+
+// withLock applies database locking based on the lock information retrieved from the context:
+//   - row locks add a FOR UPDATE, FOR NO KEY UPDATE, FOR SHARE or FOR KEY SHARE
+//     clause, followed by the NOWAIT or SKIP LOCKED wait policy if any
+//   - table locks run LOCK TABLE on the table of the query first
+//   - advisory locks take the transaction level advisory lock of the lock key first,
+//     failing with a conflict error when it is held and the lock does not wait
 //
-// The function returns a copy of the repository with the applied database clause.
+// Lock timeouts run SET LOCAL lock_timeout first and set the previous lock_timeout of
+// the transaction back once the query is read, so they only apply to the locked query.
+// Queries read row by row, i.e. Row and Rows, keep it until the transaction ends, as
+// their rows are still being read after the query. Everything but the row locks requires
+// the query to run in a transaction, and lock requests failing without waiting or timing
+// out return an apierrors.CodeConflict error.
 //
 // For more information regarding PostgreSQL locks visit: https://www.postgresql.org/docs/14/explicit-locking.html
 func withLock(ctx context.Context, tableName string) queryApplyOption {
@@ -28,24 +60,199 @@ func withLock(ctx context.Context, tableName string) queryApplyOption {
 		if lock == nil {
 			return
 		}
+		s.prelude = lock
 
 		if lock.Level() != repository.LockLevelRow {
-			// We only support row level locking for now
 			return
 		}
 
 		strength := ""
-		if lock.Contains(repository.LockModeExclusive) {
-			strength = "UPDATE"
-		} else if lock.Contains(repository.LockModeShare) {
-			strength = "SHARE"
+		switch {
+		case lock.Contains(repository.LockModeExclusive):
+			strength = clause.LockingStrengthUpdate
+		case lock.Contains(repository.LockModeNoKeyUpdate):
+			strength = string(repository.LockModeNoKeyUpdate)
+		case lock.Contains(repository.LockModeShare):
+			strength = clause.LockingStrengthShare
+		case lock.Contains(repository.LockModeKeyShare):
+			strength = string(repository.LockModeKeyShare)
 		}
 
 		if strength != "" {
 			s.lock = &clause.Locking{
 				Strength: strength,
 				Table:    clause.Table{Name: tableName},
+				Options:  string(repository.LockWaitOf(lock)),
 			}
 		}
 	}
 }
+
+// registerLocking runs the lock statements set by queryApply before every
+// query and maps the lock errors after it.
+func registerLocking(db *gorm.DB) error {
+	cb := db.Callback()
+	if cb.Query().Get(lockCallback) != nil {
+		return nil
+	}
+
+	for _, err := range []error{
+		cb.Query().Before("gorm:query").Register(lockCallback, lockPrelude(true)),
+		cb.Row().Before("gorm:row").Register(lockCallback, lockPrelude(false)),
+		cb.Query().After("gorm:query").Register(lockErrors, lockError),
+		cb.Row().After("gorm:row").Register(lockErrors, lockError),
+		cb.Query().After(lockErrors).Register(lockRestore, restoreLockTimeout),
+	} {
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// lockPrelude runs the lock statements of the query, saving the lock_timeout to set
+// back after the query when restore is set.
+func lockPrelude(restore bool) func(*gorm.DB) {
+	return func(db *gorm.DB) {
+		runLockPrelude(db, restore)
+	}
+}
+
+func runLockPrelude(db *gorm.DB, restore bool) {
+	if db.Error != nil || db.DryRun {
+		return
+	}
+	v, ok := db.Get(lockKey)
+	if !ok {
+		return
+	}
+	lock, _ := v.(repository.Lock)
+	if lock.Level() == repository.LockLevelRow && repository.LockTimeoutOf(lock) <= 0 {
+		return
+	}
+	if _, inTx := db.Statement.ConnPool.(gorm.TxCommitter); !inTx {
+		_ = db.AddError(apierrors.InvalidArgument(fmt.Sprintf(
+			"%s locks and lock timeouts require a transaction", lock.Level(),
+		)))
+		return
+	}
+
+	stmts, err := lockStatements(db, lock)
+	if err != nil {
+		_ = db.AddError(err)
+		return
+	}
+	ctx := db.Statement.Context
+	if restore && repository.LockTimeoutOf(lock) > 0 {
+		prev := ""
+		if err := db.Statement.ConnPool.QueryRowContext(ctx, "SHOW lock_timeout").Scan(&prev); err != nil {
+			_ = db.AddError(err)
+			return
+		}
+		db.Statement.Settings.Store(lockTimeoutKey, prev)
+	}
+	for _, stmt := range stmts {
+		if stmt.try {
+			acquired := false
+			if err := db.Statement.ConnPool.QueryRowContext(ctx, stmt.sql).Scan(&acquired); err != nil {
+				_ = db.AddError(err)
+				return
+			}
+			if !acquired {
+				_ = db.AddError(apierrors.Conflict(fmt.Sprintf("advisory lock %d is not available", repository.LockKeyOf(lock))))
+				return
+			}
+			continue
+		}
+		if _, err := db.Statement.ConnPool.ExecContext(ctx, stmt.sql); err != nil {
+			_ = db.AddError(err)
+			return
+		}
+	}
+}
+
+// tableLockModes are the modes of LOCK TABLE, interpolated in the statement.
+var tableLockModes = []repository.LockMode{
+	repository.LockModeAccessShare,
+	repository.LockModeRowShare,
+	repository.LockModeRowExclusive,
+	repository.LockModeShareUpdateExclusive,
+	repository.LockModeShare,
+	repository.LockModeShareRowExclusive,
+	repository.LockModeExclusive,
+	repository.LockModeAccessExclusive,
+}
+
+type lockStatement struct {
+	sql string
+	// try statements return whether the lock was acquired
+	try bool
+}
+
+func lockStatements(db *gorm.DB, lock repository.Lock) ([]lockStatement, error) {
+	stmts := []lockStatement{}
+	wait := repository.LockWaitOf(lock)
+	if timeout := repository.LockTimeoutOf(lock); timeout > 0 {
+		stmts = append(stmts, lockStatement{
+			sql: fmt.Sprintf("SET LOCAL lock_timeout = '%dms'", timeout.Milliseconds()),
+		})
+	}
+
+	switch lock.Level() {
+	case repository.LockLevelTable:
+		if len(lock.Modes()) == 0 {
+			return nil, apierrors.InvalidArgument("table lock requires a lock mode")
+		}
+		mode := lock.Modes()[0]
+		if !slices.Contains(tableLockModes, mode) {
+			return nil, apierrors.InvalidArgument(fmt.Sprintf("invalid table lock mode: %s", mode))
+		}
+		if db.Statement.Table == "" {
+			return nil, apierrors.InvalidArgument("table lock requires a table")
+		}
+		sql := fmt.Sprintf("LOCK TABLE %s IN %s MODE", db.Statement.Quote(db.Statement.Table), mode)
+		switch wait {
+		case repository.LockWaitNoWait:
+			sql += " NOWAIT"
+		case repository.LockWaitSkipLocked:
+			return nil, apierrors.InvalidArgument("table locks cannot skip locked rows")
+		}
+		stmts = append(stmts, lockStatement{sql: sql})
+	case repository.LockLevelAdvisory:
+		fn := "pg_advisory_xact_lock"
+		if lock.Contains(repository.LockModeShare) {
+			fn += "_shared"
+		}
+		try := wait != repository.LockWaitBlock
+		if try {
+			fn = "pg_try_" + fn[len("pg_"):]
+		}
+		stmts = append(stmts, lockStatement{sql: fmt.Sprintf("SELECT %s(%d)", fn, repository.LockKeyOf(lock)), try: try})
+	}
+
+	return stmts, nil
+}
+
+func lockError(db *gorm.DB) {
+	if db.Error == nil {
+		return
+	}
+	if _, ok := db.Get(lockKey); ok && sqldb.IsLockNotAvailable(db.Error) {
+		db.Error = sqldb.NewErrLockNotAvailable(db.Error)
+	}
+}
+
+// restoreLockTimeout sets the lock_timeout saved by lockPrelude back.
+func restoreLockTimeout(db *gorm.DB) {
+	prev, ok := db.Statement.Settings.LoadAndDelete(lockTimeoutKey)
+	// a failed query aborts the transaction, and its settings with it
+	if !ok || db.Error != nil {
+		return
+	}
+	if _, err := db.Statement.ConnPool.ExecContext(
+		db.Statement.Context, "SELECT set_config('lock_timeout', $1, true)", prev,
+	); err != nil {
+		_ = db.AddError(err)
+	}
+}
@@ -0,0 +1,210 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+
+	"github.com/dosanma1/forge/go/kit/application/repository"
+	apierrors "github.com/dosanma1/forge/go/kit/errors"
+	"github.com/dosanma1/forge/go/kit/filter"
+	"github.com/dosanma1/forge/go/kit/search/query"
+)
+
+func TestRepoLockApply(t *testing.T) {
+	repo := dryRunRepo(t)
+
+	tests := []struct {
+		name    string
+		lock    repository.Lock
+		wantSQL string
+	}{
+		{
+			name:    "exclusive",
+			lock:    repository.NewLock(repository.LockLevelRow, []repository.LockMode{repository.LockModeExclusive}),
+			wantSQL: `SELECT * FROM "test_entities" WHERE test_entities.id = $1 FOR UPDATE OF "test_entities"`,
+		},
+		{
+			name: "no key update skipping locked rows",
+			lock: repository.NewLock(
+				repository.LockLevelRow, []repository.LockMode{repository.LockModeNoKeyUpdate}, repository.SkipLocked(),
+			),
+			wantSQL: `SELECT * FROM "test_entities" WHERE test_entities.id = $1 FOR NO KEY UPDATE OF "test_entities" SKIP LOCKED`,
+		},
+		{
+			name: "key share without waiting",
+			lock: repository.NewLock(
+				repository.LockLevelRow, []repository.LockMode{repository.LockModeKeyShare}, repository.NoWait(),
+			),
+			wantSQL: `SELECT * FROM "test_entities" WHERE test_entities.id = $1 FOR KEY SHARE OF "test_entities" NOWAIT`,
+		},
+		{
+			name: "table locks add no clause",
+			lock: repository.NewLock(
+				repository.LockLevelTable, []repository.LockMode{repository.LockModeShareRowExclusive},
+			),
+			wantSQL: `SELECT * FROM "test_entities" WHERE test_entities.id = $1`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := repository.WithLock(context.Background(), tt.lock)
+			tx := repo.QueryApplyWithTableName(ctx, query.New(query.FilterBy(filter.OpEq, "id", "a")), "test_entities").
+				Find(&[]TestEntity{})
+			require.NoError(t, tx.Error)
+			assert.Equal(t, tt.wantSQL, tx.Statement.SQL.String())
+		})
+	}
+}
+
+// policyless is a lock implementing the Lock interface only, e.g. one of
+// another package.
+type policyless struct {
+	repository.Lock
+}
+
+func TestLockStatements(t *testing.T) {
+	db := dryRunRepo(t).DB.Table("jobs")
+	db.Statement.Table = "jobs"
+
+	tests := []struct {
+		name    string
+		lock    repository.Lock
+		want    []lockStatement
+		wantErr apierrors.Code
+	}{
+		{
+			name: "row lock timeout",
+			lock: repository.NewLock(
+				repository.LockLevelRow, []repository.LockMode{repository.LockModeExclusive},
+				repository.WithLockTimeout(1500*time.Millisecond),
+			),
+			want: []lockStatement{{sql: "SET LOCAL lock_timeout = '1500ms'"}},
+		},
+		{
+			name: "table lock",
+			lock: repository.NewLock(
+				repository.LockLevelTable, []repository.LockMode{repository.LockModeAccessExclusive}, repository.NoWait(),
+			),
+			want: []lockStatement{{sql: `LOCK TABLE "jobs" IN ACCESS EXCLUSIVE MODE NOWAIT`}},
+		},
+		{
+			name:    "table lock without mode",
+			lock:    repository.NewLock(repository.LockLevelTable, nil),
+			wantErr: apierrors.CodeInvalidArgument,
+		},
+		{
+			name:    "table lock in a row lock mode",
+			lock:    repository.NewLock(repository.LockLevelTable, []repository.LockMode{repository.LockModeNoKeyUpdate}),
+			wantErr: apierrors.CodeInvalidArgument,
+		},
+		{
+			name:    "table lock mode injection",
+			lock:    repository.NewLock(repository.LockLevelTable, []repository.LockMode{"SHARE MODE; DROP TABLE jobs; --"}),
+			wantErr: apierrors.CodeInvalidArgument,
+		},
+		{
+			name: "lock without policy",
+			lock: policyless{repository.NewLock(
+				repository.LockLevelTable, []repository.LockMode{repository.LockModeShare}, repository.NoWait(),
+			)},
+			want: []lockStatement{{sql: `LOCK TABLE "jobs" IN SHARE MODE`}},
+		},
+		{
+			name: "table lock skipping locked rows",
+			lock: repository.NewLock(
+				repository.LockLevelTable, []repository.LockMode{repository.LockModeShare}, repository.SkipLocked(),
+			),
+			wantErr: apierrors.CodeInvalidArgument,
+		},
+		{
+			name: "advisory lock",
+			lock: repository.NewLock(
+				repository.LockLevelAdvisory, []repository.LockMode{repository.LockModeExclusive}, repository.WithLockKey(42),
+			),
+			want: []lockStatement{{sql: "SELECT pg_advisory_xact_lock(42)"}},
+		},
+		{
+			name: "shared advisory lock without waiting",
+			lock: repository.NewLock(
+				repository.LockLevelAdvisory, []repository.LockMode{repository.LockModeShare},
+				repository.WithLockKey(7), repository.NoWait(),
+			),
+			want: []lockStatement{{sql: "SELECT pg_try_advisory_xact_lock_shared(7)", try: true}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := lockStatements(db, tt.lock)
+			if tt.wantErr != "" {
+				var apiErr apierrors.Error
+				require.ErrorAs(t, err, &apiErr)
+				assert.Equal(t, tt.wantErr, apiErr.Code())
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestLockTimeoutRestore(t *testing.T) {
+	repo, mock := mockRepo(t)
+	lock := repository.NewLock(
+		repository.LockLevelRow, []repository.LockMode{repository.LockModeExclusive},
+		repository.WithLockTimeout(time.Second),
+	)
+	ctx := repository.WithLock(context.Background(), lock)
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(regexp.QuoteMeta("SHOW lock_timeout")).
+		WillReturnRows(sqlmock.NewRows([]string{"lock_timeout"}).AddRow("5s"))
+	mock.ExpectExec(regexp.QuoteMeta("SET LOCAL lock_timeout = '1000ms'")).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "test_entities" WHERE test_entities.id = $1 FOR UPDATE`)).
+		WithArgs("a").
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow("a"))
+	mock.ExpectExec(regexp.QuoteMeta("SELECT set_config('lock_timeout', $1, true)")).
+		WithArgs("5s").
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectCommit()
+
+	err := repo.DB.Transaction(func(tx *gorm.DB) error {
+		q := query.New(query.FilterBy(filter.OpEq, "id", "a"))
+		return repo.QueryApplyWithTableName(ctx, q, "test_entities", withDB(tx)).Find(&[]TestEntity{}).Error
+	})
+	require.NoError(t, err)
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+type lockNotAvailableErr struct{}
+
+func (lockNotAvailableErr) Error() string    { return "could not obtain lock on row" }
+func (lockNotAvailableErr) SQLState() string { return "55P03" }
+
+func TestLockError(t *testing.T) {
+	locked := func(err error) *gorm.DB {
+		db := dryRunRepo(t).DB.Set(lockKey, repository.NewLock(repository.LockLevelRow, nil, repository.NoWait()))
+		db.Error = err
+		return db
+	}
+
+	db := locked(lockNotAvailableErr{})
+	lockError(db)
+	var apiErr apierrors.Error
+	require.ErrorAs(t, db.Error, &apiErr)
+	assert.Equal(t, apierrors.CodeConflict, apiErr.Code())
+
+	other := errors.New("boom")
+	db = locked(other)
+	lockError(db)
+	assert.Equal(t, other, db.Error)
+}
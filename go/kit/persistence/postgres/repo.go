@@ -5,7 +5,6 @@ import (
 	"errors"
 	"fmt"
 	"reflect"
-	"sort"
 	"strings"
 
 	"golang.org/x/exp/maps"
@@ -16,16 +15,35 @@ import (
 	apierrors "github.com/dosanma1/forge/go/kit/errors"
 	"github.com/dosanma1/forge/go/kit/filter"
 	"github.com/dosanma1/forge/go/kit/persistence/gormdb"
+	"github.com/dosanma1/forge/go/kit/resource"
 	"github.com/dosanma1/forge/go/kit/search/query"
 	"github.com/dosanma1/forge/go/kit/slicesx"
 )
 
 type Repo struct {
-	DB      *gormdb.DBClient
-	fMapper map[string]string
+	DB          *gormdb.DBClient
+	fMapper     map[string]string
+	cursorCodec *query.CursorCodec
+
+	searchLanguage string
+	searchFields   map[string]searchField
+	snippetOptions string
+
+	relations map[string]relation
 }
 
-func NewRepo(db *gormdb.DBClient, fMapper map[string]string) (*Repo, error) {
+type RepoOption func(r *Repo)
+
+// WithCursorCodec sets the codec used to sign and verify keyset pagination
+// cursors. Defaults to query.DefaultCursorCodec, as configured when the
+// cursors are used.
+func WithCursorCodec(codec *query.CursorCodec) RepoOption {
+	return func(r *Repo) {
+		r.cursorCodec = codec
+	}
+}
+
+func NewRepo(db *gormdb.DBClient, fMapper map[string]string, opts ...RepoOption) (*Repo, error) {
 	if db == nil {
 		return nil, errors.New("missing db client")
 	}
@@ -34,10 +52,31 @@ func NewRepo(db *gormdb.DBClient, fMapper map[string]string) (*Repo, error) {
 	}
 	fieldMapper := maps.Clone(fMapper)
 
-	return &Repo{
+	r := &Repo{
 		DB:      db,
 		fMapper: fieldMapper,
-	}, nil
+
+		searchLanguage: defaultSearchLanguage,
+		searchFields:   make(map[string]searchField),
+		snippetOptions: defaultSnippetOptions,
+
+		relations: make(map[string]relation),
+	}
+	for _, opt := range opts {
+		opt(r)
+	}
+	if len(r.relations) > 0 && db.DB != nil {
+		if err := registerRelationLoaders(db.DB); err != nil {
+			return nil, err
+		}
+	}
+	if db.DB != nil {
+		if err := registerLocking(db.DB); err != nil {
+			return nil, err
+		}
+	}
+
+	return r, nil
 }
 
 func (r *Repo) FMapper() map[string]string {
@@ -68,6 +107,41 @@ func (r *Repo) CountApplyWithTableName(ctx context.Context, model any, q query.Q
 	return r.countApply(ctx, model, q, tableName)
 }
 
+// List fetches the page of models of q, mapped to resources by toResource,
+// with the count of the rows matching its filters. The list of a keyset
+// paginated query carries the cursors of its adjacent pages, see KeysetPage
+// and resource.CursorListResponse.
+func List[M, R any](ctx context.Context, r *Repo, q query.Query, toResource func(M) R) (resource.ListResponse[R], error) {
+	var rows []M
+	if err := r.QueryApply(ctx, q).Find(&rows).Error; err != nil {
+		return nil, listError(err)
+	}
+	rows, next, prev, err := KeysetPage(ctx, r, q, rows)
+	if err != nil {
+		return nil, err
+	}
+	var total int64
+	if err := r.CountApply(ctx, new(M), q).Count(&total).Error; err != nil {
+		return nil, listError(err)
+	}
+
+	items := make([]R, len(rows))
+	for i, row := range rows {
+		items[i] = toResource(row)
+	}
+
+	return resource.NewListResponse(items, int(total), resource.WithCursors(next, prev)), nil
+}
+
+// listError keeps the kit errors raised while applying the query, e.g. an
+// invalid cursor, and hides the database ones.
+func listError(err error) error {
+	if _, ok := apierrors.As(err); ok {
+		return err
+	}
+	return NewErrUnknown(err)
+}
+
 func (r *Repo) PatchApply(ctx context.Context, q query.Query, model any, toPatch map[string]any) (tx *gorm.DB) {
 	mapped := make(map[string]any, len(toPatch))
 	for k, v := range toPatch {
@@ -93,17 +167,28 @@ func (r *Repo) queryApply(ctx context.Context, q query.Query, tableName string,
 	}
 
 	tx = r.DB.WithContext(ctx)
+	if s.db != nil {
+		tx = s.db.WithContext(ctx)
+	}
 	if q == nil {
 		return
 	}
-	tx = r.filterApply(tx, q.Filters(), tableName)
-	tx = r.sortingApply(tx, q.Sorting())
-	if q.Pagination() != nil {
-		tx = r.paginationApply(tx, q.Pagination())
+	tx = r.filterApply(tx, q, tableName)
+	tx = r.includeApply(tx, q)
+	if q.CursorPagination() != nil {
+		tx = r.keysetApply(tx, q, tableName)
+	} else {
+		tx = r.sortingApply(tx, q, tableName)
+		if q.Pagination() != nil {
+			tx = r.paginationApply(tx, q.Pagination())
+		}
 	}
 	if s.lock != nil {
 		tx = tx.Clauses(s.lock)
 	}
+	if s.prelude != nil {
+		tx = tx.Set(lockKey, s.prelude)
+	}
 
 	return
 }
@@ -116,87 +201,123 @@ func (r *Repo) countApply(ctx context.Context, model any, q query.Query, tableNa
 	if tableName != "" {
 		tx = tx.Table(tableName)
 	}
-	tx = r.filterApply(tx, q.Filters(), tableName)
+	tx = r.filterApply(tx, q, tableName)
 
 	return
 }
 
-func (r *Repo) filterApply(tx *gorm.DB, filters query.Filters[any], tableName string) *gorm.DB {
-	if len(filters) < 1 {
+func (r *Repo) filterApply(tx *gorm.DB, q query.Query, tableName string) *gorm.DB {
+	tree := query.FilterTree(q)
+	if tree == nil {
+		return tx
+	}
+	if err := tree.Err(); err != nil {
+		_ = tx.AddError(err)
+		return tx
+	}
+	if err := r.checkOperators(tree); err != nil {
+		_ = tx.AddError(err)
 		return tx
 	}
 
-	sqlQuery := strings.Builder{}
-	args := []any{}
+	sqlQuery, args := r.filterExpr(tree, tableName)
+	if tree.Op == query.BoolOr {
+		sqlQuery = "(" + sqlQuery + ")"
+	}
 
-	keys := maps.Keys(filters)
-	sort.Strings(keys)
-	for i, key := range keys {
-		filt := filters[key]
-		colName := r.fMapper[key]
-		if colName == "" {
-			colName = key
-		}
+	return tx.Where(sqlQuery, args...)
+}
 
-		if tableName != "" && !strings.Contains(colName, ".") {
-			colName = tableName + "." + colName
+// filterExpr renders a filter expression, parenthesizing every nested group
+// so that the tree structure survives SQL operator precedence.
+func (r *Repo) filterExpr(node *query.FilterNode, tableName string) (string, []any) {
+	if node.IsLeaf() {
+		return r.filterCond(node.Filter, tableName)
+	}
+	if node.Op == query.BoolNot {
+		sqlQuery, args := r.filterExpr(query.And(node.Children...), tableName)
+		return fmt.Sprintf("NOT (%s)", sqlQuery), args
+	}
+
+	parts := make([]string, 0, len(node.Children))
+	args := []any{}
+	for _, child := range node.Children {
+		sqlQuery, childArgs := r.filterExpr(child, tableName)
+		if !child.IsLeaf() && child.Op != query.BoolNot {
+			sqlQuery = "(" + sqlQuery + ")"
 		}
+		parts = append(parts, sqlQuery)
+		args = append(args, childArgs...)
+	}
 
-		switch filt.Operator() {
-		case filter.OpIs, filter.OpIsNot:
-			if filt.Value() == nil {
-				sqlQuery.WriteString(fmt.Sprintf("%s %s NULL", colName, filt.Operator().String()))
-			} else {
-				sqlQuery.WriteString(simpleArg(colName, filt.Operator()))
-				args = append(args, filt.Value())
-			}
-		case filter.OpLike:
+	if node.Op == query.BoolOr {
+		return strings.Join(parts, " OR "), args
+	}
+
+	return strings.Join(parts, " AND "), args
+}
+
+func (r *Repo) filterCond(filt filter.FieldFilter[any], tableName string) (string, []any) {
+	sqlQuery := strings.Builder{}
+	args := []any{}
+
+	colName := r.column(filt.Name(), tableName)
+
+	switch filt.Operator() {
+	case filter.OpIs, filter.OpIsNot:
+		if filt.Value() == nil {
+			sqlQuery.WriteString(fmt.Sprintf("%s %s NULL", colName, filt.Operator().String()))
+		} else {
 			sqlQuery.WriteString(simpleArg(colName, filt.Operator()))
-			args = append(args, fmt.Sprintf("%%%v%%", filt.Value()))
-		case filter.OpIn, filter.OpNotIn, filter.OpContainsLike:
-			vals, ok := filt.Value().([]string) // Safe casting as of now since it will always be of type []string
-			if !ok {
-				// Cast slice to slice string
-				inputVal := reflect.ValueOf(filt.Value())
-				if inputVal.Kind() == reflect.Slice {
-					output := make([]string, inputVal.Len())
-					for i := 0; i < inputVal.Len(); i++ {
-						output[i] = fmt.Sprintf("%v", inputVal.Index(i).Interface())
-					}
-					vals = output
+			args = append(args, filt.Value())
+		}
+	case filter.OpLike:
+		sqlQuery.WriteString(simpleArg(colName, filt.Operator()))
+		args = append(args, fmt.Sprintf("%%%v%%", filt.Value()))
+	case filter.OpIn, filter.OpNotIn, filter.OpContainsLike:
+		vals, ok := filt.Value().([]string) // Safe casting as of now since it will always be of type []string
+		if !ok {
+			// Cast slice to slice string
+			inputVal := reflect.ValueOf(filt.Value())
+			if inputVal.Kind() == reflect.Slice {
+				output := make([]string, inputVal.Len())
+				for i := 0; i < inputVal.Len(); i++ {
+					output[i] = fmt.Sprintf("%v", inputVal.Index(i).Interface())
 				}
-			}
-			sqlQuery.WriteString(sliceArg(filt.Operator(), colName, vals))
-			args = append(args, slicesx.Map(vals, func(s string) any { return s })...)
-		case filter.OpContains:
-			sqlQuery.WriteString(simpleArg(colName, filt.Operator()))
-			if kind := reflect.ValueOf(filt.Value()).Kind(); kind == reflect.Slice || kind == reflect.Array {
-				args = append(args, pq.Array(filt.Value()))
-			} else {
-				args = append(args, pq.Array([]any{filt.Value()}))
-			}
-		case filter.OpBetween:
-			vals := btwArgs(filt.Value())
-			sqlQuery.WriteString(fmt.Sprintf("%s %s ? AND ?", colName, filt.Operator()))
-			args = append(args, vals...)
-		default:
-			sqlQuery.WriteString(simpleArg(colName, filt.Operator()))
-			if kind := reflect.ValueOf(filt.Value()).Kind(); kind == reflect.Slice || kind == reflect.Array {
-				args = append(args, pq.Array(filt.Value()))
-			} else {
-				args = append(args, filt.Value())
+				vals = output
 			}
 		}
-
-		if i < len(filters)-1 {
-			sqlQuery.WriteString(" AND ")
+		sqlQuery.WriteString(sliceArg(filt.Operator(), colName, vals))
+		args = append(args, slicesx.Map(vals, func(s string) any { return s })...)
+	case filter.OpContains:
+		sqlQuery.WriteString(simpleArg(colName, filt.Operator()))
+		if kind := reflect.ValueOf(filt.Value()).Kind(); kind == reflect.Slice || kind == reflect.Array {
+			args = append(args, pq.Array(filt.Value()))
+		} else {
+			args = append(args, pq.Array([]any{filt.Value()}))
+		}
+	case filter.OpSearch, filter.OpFuzzy:
+		return r.searchCond(filt, tableName)
+	case filter.OpJSONContains, filter.OpHasKey, filter.OpHasAnyKeys, filter.OpHasAllKeys, filter.OpJSONPath:
+		return r.jsonCond(filt, tableName)
+	case filter.OpBetween:
+		vals := btwArgs(filt.Value())
+		sqlQuery.WriteString(fmt.Sprintf("%s %s ? AND ?", colName, filt.Operator()))
+		args = append(args, vals...)
+	default:
+		sqlQuery.WriteString(simpleArg(colName, filt.Operator()))
+		if kind := reflect.ValueOf(filt.Value()).Kind(); kind == reflect.Slice || kind == reflect.Array {
+			args = append(args, pq.Array(filt.Value()))
+		} else {
+			args = append(args, filt.Value())
 		}
 	}
 
-	return tx.Where(sqlQuery.String(), args...)
+	return sqlQuery.String(), args
 }
 
-func (r *Repo) sortingApply(tx *gorm.DB, sorting *query.SortingParams) *gorm.DB {
+func (r *Repo) sortingApply(tx *gorm.DB, q query.Query, tableName string) *gorm.DB {
+	sorting := q.Sorting()
 	if sorting == nil {
 		return tx
 	}
@@ -205,16 +326,32 @@ func (r *Repo) sortingApply(tx *gorm.DB, sorting *query.SortingParams) *gorm.DB
 		return tx
 	}
 	allParams := make([]string, len(keys))
+	var args []any
 	idx := 0
 	for _, key := range keys {
 		dir := sorting.Get(key)
-		col := r.fMapper[key]
-		if col == "" {
-			col = key
+		if key == query.SortRelevance {
+			expr, exprArgs, err := r.relevanceExpr(q, tableName)
+			if err != nil {
+				_ = tx.AddError(err)
+				return tx
+			}
+			allParams[idx] = fmt.Sprintf("%s %s", expr, dir)
+			args = append(args, exprArgs...)
+			idx++
+			continue
+		}
+		col, err := r.sortColumn(tx, sorting, key, tableName)
+		if err != nil {
+			_ = tx.AddError(err)
+			return tx
 		}
 		allParams[idx] = fmt.Sprintf("%s %s", col, dir)
 		idx++
 	}
+	if len(args) > 0 {
+		return tx.Order(relevanceOrder(allParams, args))
+	}
 	return tx.Order(strings.Join(allParams, ","))
 }
 
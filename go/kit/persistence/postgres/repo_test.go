@@ -2,11 +2,18 @@ package postgres
 
 import (
 	"context"
+	"net/url"
 	"testing"
 	"time"
 
+	apierrors "github.com/dosanma1/forge/go/kit/errors"
 	"github.com/dosanma1/forge/go/kit/filter"
+	"github.com/dosanma1/forge/go/kit/monitoring"
+	"github.com/dosanma1/forge/go/kit/monitoring/logger/loggertest"
 	"github.com/dosanma1/forge/go/kit/persistence/gormdb/gormdbtest"
+	"github.com/dosanma1/forge/go/kit/persistence/gormdb/gormsqlite"
+	"github.com/dosanma1/forge/go/kit/persistence/sqldb"
+	"github.com/dosanma1/forge/go/kit/resource"
 	"github.com/dosanma1/forge/go/kit/search/query"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -51,6 +58,7 @@ func TestRepoFilterApplyIntegration(t *testing.T) {
 			"age":        "age",
 			"created_at": "created_at",
 		},
+		WithCursorCodec(query.NewCursorCodec([]byte("secret"))),
 	)
 	require.NoError(t, err)
 
@@ -103,6 +111,52 @@ func TestRepoFilterApplyIntegration(t *testing.T) {
 		assert.Equal(t, "Bob", results[1].EName)
 	})
 
+	t.Run("FilterGroups", func(t *testing.T) {
+		// (name = Alice OR age < 22) AND NOT (id = 1)
+		q := query.New(query.Where(
+			query.Or(
+				query.Cond(filter.OpEq, "name", "Alice"),
+				query.Cond(filter.OpLT, "age", 22),
+			),
+			query.Not(query.Cond(filter.OpEq, "id", "1")),
+		))
+
+		var results []TestEntity
+		err := repo.QueryApply(ctx, q).Find(&results).Error
+		require.NoError(t, err)
+		require.Len(t, results, 1)
+		assert.Equal(t, "Bob", results[0].EName)
+	})
+
+	t.Run("FilterGroups on the same field", func(t *testing.T) {
+		q := query.New(query.Where(
+			query.Cond(filter.OpGTEq, "age", 20),
+			query.Cond(filter.OpLT, "age", 30),
+			query.Cond(filter.OpNEq, "age", 25),
+		))
+
+		var results []TestEntity
+		err := repo.QueryApply(ctx, q).Find(&results).Error
+		require.NoError(t, err)
+		require.Len(t, results, 1)
+		assert.Equal(t, "Bob", results[0].EName)
+	})
+
+	t.Run("Aggregate", func(t *testing.T) {
+		q := query.New(
+			query.FilterBy(filter.OpGT, "age", 21),
+			query.Aggregate(query.Count(), query.Sum("age"), query.Max("created_at")),
+			query.GroupByTrunc("created_at", query.TruncYear),
+		)
+
+		buckets, err := repo.Aggregate(ctx, &TestEntity{}, q)
+		require.NoError(t, err)
+		require.Len(t, buckets, 1)
+		assert.Equal(t, int64(2), buckets[0].Count())
+		assert.Equal(t, float64(55), buckets[0].Float("sum_age"))
+		assert.Equal(t, now.Year(), buckets[0].KeyTime("created_at_year").Year())
+	})
+
 	t.Run("Sorting", func(t *testing.T) {
 		q := query.New()
 		q.Sorting().Set("age", query.SortDesc)
@@ -132,6 +186,46 @@ func TestRepoFilterApplyIntegration(t *testing.T) {
 		assert.Len(t, results, 1)
 		assert.Equal(t, "Charlie", results[0].EName)
 	})
+
+	t.Run("CursorPagination", func(t *testing.T) {
+		page := func(cursor string, dir query.CursorDirection) ([]TestEntity, string, string) {
+			q := query.New(query.CursorPage(1, cursor, dir), query.SortBy("age", query.SortAsc))
+
+			list, err := List(ctx, repo, q, func(e TestEntity) TestEntity { return e })
+			require.NoError(t, err)
+			page := list.(resource.CursorListResponse)
+			return list.Results(), page.NextCursor(), page.PrevCursor()
+		}
+
+		first, next, prev := page("", query.CursorAfter)
+		require.Len(t, first, 1)
+		assert.Equal(t, "Bob", first[0].EName)
+		assert.Empty(t, prev)
+		require.NotEmpty(t, next)
+
+		second, next, prev := page(next, query.CursorAfter)
+		require.Len(t, second, 1)
+		assert.Equal(t, "Charlie", second[0].EName)
+		require.NotEmpty(t, prev)
+		require.NotEmpty(t, next)
+
+		back, _, _ := page(prev, query.CursorBefore)
+		require.Len(t, back, 1)
+		assert.Equal(t, "Bob", back[0].EName)
+
+		last, next, _ := page(next, query.CursorAfter)
+		require.Len(t, last, 1)
+		assert.Equal(t, "Alice", last[0].EName)
+		assert.Empty(t, next)
+	})
+
+	t.Run("CursorPagination rejects tampered cursor", func(t *testing.T) {
+		q := query.New(query.CursorPage(1, "eyJrIjpbImlkIl19.forged", query.CursorAfter))
+
+		var results []TestEntity
+		err := repo.QueryApply(ctx, q).Find(&results).Error
+		assert.ErrorIs(t, err, apierrors.InvalidArgument(""))
+	})
 }
 
 func TestRepoPatchApplyIntegration(t *testing.T) {
@@ -212,3 +306,190 @@ func TestRepoCountApplyIntegration(t *testing.T) {
 	require.NoError(t, err)
 	assert.Equal(t, int64(2), count)
 }
+
+func TestRepoFilterExpr(t *testing.T) {
+	repo := &Repo{fMapper: map[string]string{"owner": "owner_id"}}
+
+	tests := []struct {
+		name     string
+		node     *query.FilterNode
+		wantSQL  string
+		wantArgs []any
+	}{
+		{
+			name:     "leaf",
+			node:     query.Cond(filter.OpEq, "status", "open"),
+			wantSQL:  "t.status = ?",
+			wantArgs: []any{"open"},
+		},
+		{
+			name: "or inside and is parenthesized",
+			node: query.And(
+				query.Cond(filter.OpEq, "tenant", "a"),
+				query.Or(
+					query.Cond(filter.OpEq, "status", "open"),
+					query.Cond(filter.OpEq, "owner", "me"),
+				),
+			),
+			wantSQL:  "t.tenant = ? AND (t.status = ? OR t.owner_id = ?)",
+			wantArgs: []any{"a", "open", "me"},
+		},
+		{
+			name: "nested not",
+			node: query.Or(
+				query.Not(
+					query.Cond(filter.OpGT, "age", 1),
+					query.Cond(filter.OpLT, "age", 5),
+				),
+				query.Cond(filter.OpIs, "age", nil),
+			),
+			wantSQL:  "NOT (t.age > ? AND t.age < ?) OR t.age IS NULL",
+			wantArgs: []any{1, 5},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sql, args := repo.filterExpr(tt.node, "t")
+			assert.Equal(t, tt.wantSQL, sql)
+			assert.Equal(t, tt.wantArgs, args)
+		})
+	}
+}
+
+func TestRepoSortingApply(t *testing.T) {
+	repo := dryRunRepo(t, WithCursorCodec(query.NewCursorCodec([]byte("secret"))))
+	repo.fMapper["metadata"] = "attrs"
+	ctx := context.Background()
+
+	tests := []struct {
+		name    string
+		q       query.Query
+		wantSQL string
+		wantErr apierrors.Code
+	}{
+		{
+			name:    "mapped field is quoted",
+			q:       query.New(query.SortBy("created_at", query.SortDesc)),
+			wantSQL: `SELECT * FROM "test_entities" ORDER BY "created_on" DESC`,
+		},
+		{
+			name:    "json path",
+			q:       query.New(query.SortBy("metadata.size", query.SortAsc)),
+			wantSQL: `SELECT * FROM "test_entities" ORDER BY "attrs"->>'size' ASC`,
+		},
+		{
+			name:    "keyset pagination",
+			q:       query.New(query.CursorPage(10, "", query.CursorAfter), query.SortBy("created_at", query.SortAsc)),
+			wantSQL: `SELECT * FROM "test_entities" ORDER BY "created_on" ASC NULLS LAST,"id" ASC LIMIT $1`,
+		},
+		{
+			name:    "service keys fall back to the raw key",
+			q:       query.New(query.SortBy("status", query.SortAsc)),
+			wantSQL: `SELECT * FROM "test_entities" ORDER BY status ASC`,
+		},
+		{
+			name:    "unknown field from the url",
+			q:       urlQuery(t, "sort=status"),
+			wantErr: apierrors.CodeInvalidArgument,
+		},
+		{
+			name:    "expression injection from the url",
+			q:       urlQuery(t, "sort=-(SELECT+pg_sleep(5))"),
+			wantErr: apierrors.CodeInvalidArgument,
+		},
+		{
+			name:    "expression injection from the url with keyset pagination",
+			q:       urlQuery(t, "page[after]=&page[limit]=10&sort=-(SELECT+pg_sleep(5))"),
+			wantErr: apierrors.CodeInvalidArgument,
+		},
+		{
+			name:    "mapped field from the url",
+			q:       urlQuery(t, "sort=-created_at"),
+			wantSQL: `SELECT * FROM "test_entities" ORDER BY "created_on" DESC LIMIT $1`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tx := repo.QueryApply(ctx, tt.q).Find(&[]TestEntity{})
+			if tt.wantErr != "" {
+				assert.True(t, apierrors.Is(tx.Error, tt.wantErr), tx.Error)
+				return
+			}
+			require.NoError(t, tx.Error)
+			assert.Equal(t, tt.wantSQL, tx.Statement.SQL.String())
+		})
+	}
+}
+
+func TestRepoSortingApplyWithTableName(t *testing.T) {
+	repo := dryRunRepo(t)
+
+	tx := repo.QueryApplyWithTableName(context.Background(), urlQuery(t, "sort=-created_at"), "t").
+		Table("test_entities AS t").Find(&[]TestEntity{})
+	require.NoError(t, tx.Error)
+	assert.Equal(t, `SELECT * FROM test_entities AS t ORDER BY "t"."created_on" DESC LIMIT $1`, tx.Statement.SQL.String())
+}
+
+// urlQuery parses the query of a request URL.
+func urlQuery(t *testing.T, rawQuery string) query.Query {
+	t.Helper()
+	opts, err := query.ParseURLQueryOpts(&url.URL{RawQuery: rawQuery})
+	require.NoError(t, err)
+	return query.New(opts...)
+}
+
+func TestRepoSQLite(t *testing.T) {
+	t.Setenv("DB_LOG_LEVEL", "error")
+	cli, err := gormsqlite.NewClient(
+		sqldb.MustGenerateDSN(sqldb.DriverTypeSQLite, sqldb.WithConnDBName(":memory:")),
+		monitoring.New(loggertest.NewStubLogger(t)),
+	)
+	require.NoError(t, err)
+	defer cli.Close()
+
+	require.NoError(t, cli.AutoMigrate(&TestEntity{}))
+	require.NoError(t, cli.Create(&[]TestEntity{
+		{EID: "1", EName: "Alice", EAge: 30},
+		{EID: "2", EName: "Bob", EAge: 20},
+	}).Error)
+
+	repo, err := NewRepo(cli, map[string]string{"name": "name", "age": "age"})
+	require.NoError(t, err)
+	ctx := context.Background()
+
+	t.Run("portable operators", func(t *testing.T) {
+		q := query.New(
+			query.FilterBy(filter.OpGT, "age", 25),
+			query.FilterBy(filter.OpIn, "name", []string{"Alice", "Bob"}),
+		)
+
+		var results []TestEntity
+		require.NoError(t, repo.QueryApply(ctx, q).Find(&results).Error)
+		require.Len(t, results, 1)
+		assert.Equal(t, "Alice", results[0].EName)
+	})
+
+	t.Run("postgres operators are rejected", func(t *testing.T) {
+		q := query.New(query.FilterBy(filter.OpContains, "name", []string{"Alice"}))
+
+		var results []TestEntity
+		err := repo.QueryApply(ctx, q).Find(&results).Error
+		assert.True(t, apierrors.Is(err, apierrors.CodeInvalidArgument), err)
+		assert.ErrorContains(t, err, "operator any is not supported by sqlite")
+	})
+
+	t.Run("portable aggregations", func(t *testing.T) {
+		buckets, err := repo.Aggregate(ctx, &TestEntity{}, query.New(query.Aggregate(query.Sum("age")), query.GroupBy("name")))
+		require.NoError(t, err)
+		require.Len(t, buckets, 2)
+		assert.Equal(t, float64(30), buckets[0].Float("sum_age"))
+	})
+
+	t.Run("date truncation is rejected", func(t *testing.T) {
+		_, err := repo.Aggregate(ctx, &TestEntity{}, query.New(query.Aggregate(), query.GroupByTrunc("age", query.TruncDay)))
+		assert.True(t, apierrors.Is(err, apierrors.CodeInvalidArgument), err)
+		assert.ErrorContains(t, err, "grouping by age_day is not supported by sqlite")
+	})
+}
@@ -0,0 +1,230 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+
+	"gorm.io/gorm/clause"
+
+	apierrors "github.com/dosanma1/forge/go/kit/errors"
+	"github.com/dosanma1/forge/go/kit/filter"
+	"github.com/dosanma1/forge/go/kit/search/query"
+)
+
+const (
+	defaultSearchLanguage = "simple"
+	defaultSnippetOptions = "StartSel=<mark>, StopSel=</mark>, MaxFragments=2, MaxWords=30, MinWords=10"
+)
+
+// searchField is the text search setup of a filter field: the text columns
+// it covers and, optionally, a precomputed tsvector column.
+type searchField struct {
+	columns []string
+	vector  string
+}
+
+// WithSearchLanguage sets the text search configuration, e.g. english, used
+// to parse documents and queries. Defaults to simple.
+func WithSearchLanguage(lang string) RepoOption {
+	return func(r *Repo) {
+		r.searchLanguage = lang
+	}
+}
+
+// WithSearchColumns makes the search and fuzzy filters on field match the
+// given text columns. Search filters build their tsvector on the fly unless
+// WithSearchVector is also set. Fields without columns search the column
+// they are mapped to.
+func WithSearchColumns(field string, columns ...string) RepoOption {
+	return func(r *Repo) {
+		sf := r.searchFields[field]
+		sf.columns = append(sf.columns, columns...)
+		r.searchFields[field] = sf
+	}
+}
+
+// WithSearchVector makes the search filters on field match a generated,
+// ideally GIN indexed, tsvector column, e.g.
+//
+//	search tsvector GENERATED ALWAYS AS (to_tsvector('english', title || ' ' || body)) STORED
+func WithSearchVector(field, column string) RepoOption {
+	return func(r *Repo) {
+		sf := r.searchFields[field]
+		sf.vector = column
+		r.searchFields[field] = sf
+	}
+}
+
+// WithSnippetOptions sets the ts_headline options of SearchSnippets.
+func WithSnippetOptions(opts string) RepoOption {
+	return func(r *Repo) {
+		r.snippetOptions = opts
+	}
+}
+
+func (r *Repo) searchColumns(field, tableName string) []string {
+	sf := r.searchFields[field]
+	if len(sf.columns) == 0 {
+		return []string{r.column(field, tableName)}
+	}
+	cols := make([]string, len(sf.columns))
+	for i, c := range sf.columns {
+		cols[i] = qualify(c, tableName)
+	}
+
+	return cols
+}
+
+func (r *Repo) searchVector(field, tableName string) (string, []any) {
+	if vector := r.searchFields[field].vector; vector != "" {
+		return qualify(vector, tableName), nil
+	}
+
+	return fmt.Sprintf("to_tsvector(?::regconfig, concat_ws(' ', %s))", strings.Join(r.searchColumns(field, tableName), ", ")),
+		[]any{r.searchLanguage}
+}
+
+func (r *Repo) searchQuery(text any) (string, []any) {
+	return "websearch_to_tsquery(?::regconfig, ?)", []any{r.searchLanguage, fmt.Sprint(text)}
+}
+
+// searchCond renders a search filter as a tsvector match and a fuzzy filter
+// as a pg_trgm similarity match on any of the search columns.
+func (r *Repo) searchCond(filt filter.FieldFilter[any], tableName string) (string, []any) {
+	if filt.Operator() == filter.OpSearch {
+		vector, args := r.searchVector(filt.Name(), tableName)
+		tsQuery, qArgs := r.searchQuery(filt.Value())
+		return fmt.Sprintf("%s @@ %s", vector, tsQuery), append(args, qArgs...)
+	}
+
+	cols := r.searchColumns(filt.Name(), tableName)
+	conds := make([]string, len(cols))
+	args := make([]any, len(cols))
+	for i, c := range cols {
+		conds[i] = fmt.Sprintf("%s %% ?", c)
+		args[i] = fmt.Sprint(filt.Value())
+	}
+	if len(conds) == 1 {
+		return conds[0], args
+	}
+
+	return "(" + strings.Join(conds, " OR ") + ")", args
+}
+
+// relevanceExpr ranks the rows by the search filter of q: ts_rank for full
+// text searches, the best trigram similarity for fuzzy ones.
+func (r *Repo) relevanceExpr(q query.Query, tableName string) (string, []any, error) {
+	filt := query.SearchFilter(q)
+	if filt == nil {
+		return "", nil, apierrors.InvalidArgument("sorting by relevance requires a search filter")
+	}
+
+	if filt.Operator() == filter.OpSearch {
+		vector, args := r.searchVector(filt.Name(), tableName)
+		tsQuery, qArgs := r.searchQuery(filt.Value())
+		return fmt.Sprintf("ts_rank(%s, %s)", vector, tsQuery), append(args, qArgs...), nil
+	}
+
+	cols := r.searchColumns(filt.Name(), tableName)
+	sims := make([]string, len(cols))
+	args := make([]any, len(cols))
+	for i, c := range cols {
+		sims[i] = fmt.Sprintf("similarity(%s, ?)", c)
+		args[i] = fmt.Sprint(filt.Value())
+	}
+	if len(sims) == 1 {
+		return sims[0], args, nil
+	}
+
+	return fmt.Sprintf("greatest(%s)", strings.Join(sims, ", ")), args, nil
+}
+
+// relevanceOrder builds the ORDER BY clause of a sorting including the
+// relevance pseudo field. gorm drops the variables of plain string orders,
+// hence the single expression.
+func relevanceOrder(order []string, args []any) clause.OrderBy {
+	return clause.OrderBy{Expression: clause.Expr{
+		SQL:                strings.Join(order, ","),
+		Vars:               args,
+		WithoutParentheses: true,
+	}}
+}
+
+// SearchSnippets highlights the matches of the search filter of q in the
+// search columns of the rows with the given ids. Snippets are keyed by row
+// id and column, ready to be returned as list meta, e.g.
+//
+//	resource.NewListResponse(items, total, resource.WithMeta(query.MetaSnippets, snippets))
+//
+// It returns nil when q has no full text search filter.
+func (r *Repo) SearchSnippets(ctx context.Context, model any, q query.Query, ids ...string) (map[string]map[string]string, error) {
+	filt := query.SearchFilter(q)
+	if filt == nil || filt.Operator() != filter.OpSearch || len(ids) == 0 {
+		return nil, nil
+	}
+
+	sf := r.searchFields[filt.Name()]
+	names := sf.columns
+	if len(names) == 0 {
+		names = []string{r.column(filt.Name(), "")}
+	}
+	tsQuery, qArgs := r.searchQuery(filt.Value())
+	selects := []string{fmt.Sprintf("%s AS %q", keysetTieBreaker, keysetTieBreaker)}
+	args := []any{}
+	for _, name := range names {
+		selects = append(selects, fmt.Sprintf(
+			"ts_headline(?::regconfig, coalesce(%s, ''), %s, ?) AS %q", name, tsQuery, snippetKey(name),
+		))
+		args = append(args, r.searchLanguage)
+		args = append(args, qArgs...)
+		args = append(args, r.snippetOptions)
+	}
+
+	rows, err := r.DB.WithContext(ctx).Model(model).
+		Select(strings.Join(selects, ", "), args...).
+		Where(fmt.Sprintf("%s IN ?", keysetTieBreaker), ids).
+		Rows()
+	if err != nil {
+		return nil, NewErrUnknown(err)
+	}
+	defer rows.Close()
+
+	snippets := make(map[string]map[string]string, len(ids))
+	for rows.Next() {
+		id := ""
+		values := make([]sql.NullString, len(names))
+		ptrs := []any{&id}
+		for i := range values {
+			ptrs = append(ptrs, &values[i])
+		}
+		if err := rows.Scan(ptrs...); err != nil {
+			return nil, NewErrUnknown(err)
+		}
+		byColumn := make(map[string]string, len(names))
+		for i, name := range names {
+			byColumn[snippetKey(name)] = values[i].String
+		}
+		snippets[id] = byColumn
+	}
+	if err := rows.Err(); err != nil {
+		return nil, NewErrUnknown(err)
+	}
+
+	return snippets, nil
+}
+
+func snippetKey(column string) string {
+	if i := strings.LastIndexByte(column, '.'); i >= 0 {
+		return column[i+1:]
+	}
+	return column
+}
+
+func qualify(col, tableName string) string {
+	if tableName != "" && !strings.Contains(col, ".") {
+		return tableName + "." + col
+	}
+	return col
+}
@@ -0,0 +1,92 @@
+package postgres
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	apierrors "github.com/dosanma1/forge/go/kit/errors"
+	"github.com/dosanma1/forge/go/kit/filter"
+	"github.com/dosanma1/forge/go/kit/search/query"
+)
+
+func TestRepoSearchApply(t *testing.T) {
+	repo := dryRunRepo(t,
+		WithSearchLanguage("english"),
+		WithSearchColumns("text", "title", "body"),
+		WithSearchVector("doc", "search_vector"),
+	)
+	ctx := context.Background()
+
+	tests := []struct {
+		name     string
+		q        query.Query
+		wantSQL  string
+		wantVars []any
+		wantErr  apierrors.Code
+	}{
+		{
+			name: "search over columns",
+			q:    query.New(query.FilterBy(filter.OpSearch, "text", `"red fox" -dog`)),
+			wantSQL: `SELECT * FROM "test_entities" WHERE to_tsvector($1::regconfig, concat_ws(' ', title, body)) @@ ` +
+				`websearch_to_tsquery($2::regconfig, $3)`,
+			wantVars: []any{"english", "english", `"red fox" -dog`},
+		},
+		{
+			name: "search over a generated column sorted by relevance",
+			q: query.New(
+				query.FilterBy(filter.OpSearch, "doc", "fox"),
+				query.SortBy(query.SortRelevance, query.SortDesc),
+				query.SortBy("created_at", query.SortAsc),
+			),
+			wantSQL: `SELECT * FROM "test_entities" WHERE search_vector @@ websearch_to_tsquery($1::regconfig, $2) ` +
+				`ORDER BY ts_rank(search_vector, websearch_to_tsquery($3::regconfig, $4)) DESC,"created_on" ASC`,
+			wantVars: []any{"english", "fox", "english", "fox"},
+		},
+		{
+			name: "fuzzy over columns sorted by relevance",
+			q: query.New(
+				query.FilterBy(filter.OpFuzzy, "text", "fxo"),
+				query.SortBy(query.SortRelevance, query.SortDesc),
+			),
+			wantSQL: `SELECT * FROM "test_entities" WHERE (title % $1 OR body % $2) ` +
+				`ORDER BY greatest(similarity(title, $3), similarity(body, $4)) DESC`,
+			wantVars: []any{"fxo", "fxo", "fxo", "fxo"},
+		},
+		{
+			name:     "fuzzy over the mapped column",
+			q:        query.New(query.FilterBy(filter.OpFuzzy, "name", "jon")),
+			wantSQL:  `SELECT * FROM "test_entities" WHERE name % $1`,
+			wantVars: []any{"jon"},
+		},
+		{
+			name:    "relevance without search",
+			q:       query.New(query.SortBy(query.SortRelevance, query.SortDesc)),
+			wantErr: apierrors.CodeInvalidArgument,
+		},
+		{
+			name: "relevance with cursor pagination",
+			q: query.New(
+				query.FilterBy(filter.OpSearch, "doc", "fox"),
+				query.SortBy(query.SortRelevance, query.SortDesc),
+				query.CursorPage(10, "", query.CursorAfter),
+			),
+			wantErr: apierrors.CodeInvalidArgument,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tx := repo.QueryApply(ctx, tt.q).Find(&[]TestEntity{})
+			if tt.wantErr != "" {
+				assert.True(t, apierrors.Is(tx.Error, tt.wantErr), tx.Error)
+				return
+			}
+			require.NoError(t, tx.Error)
+			assert.Equal(t, tt.wantSQL, tx.Statement.SQL.String())
+			assert.Equal(t, tt.wantVars, tx.Statement.Vars)
+		})
+	}
+}
@@ -0,0 +1,73 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"iter"
+
+	apierrors "github.com/dosanma1/forge/go/kit/errors"
+	"github.com/dosanma1/forge/go/kit/search/query"
+)
+
+// Stream yields the M rows matching q mapped to resources, reading them one
+// at a time from the open result set instead of loading the whole result.
+// The rows are read inside a read-only, repeatable read transaction, so a
+// long export sees a consistent snapshot. Pagination of q still applies;
+// includes are not loaded.
+//
+//	func (r *repo) Stream(ctx context.Context, opts ...search.Option) iter.Seq2[Article, error] {
+//		return postgres.Stream(ctx, r.Repo, search.New(opts...).Query(), toArticle)
+//	}
+func Stream[M, R any](ctx context.Context, r *Repo, q query.Query, toResource func(*M) R) iter.Seq2[R, error] {
+	return stream(ctx, r, q, "", toResource)
+}
+
+func StreamWithTableName[M, R any](
+	ctx context.Context, r *Repo, q query.Query, tableName string, toResource func(*M) R,
+) iter.Seq2[R, error] {
+	return stream(ctx, r, q, tableName, toResource)
+}
+
+func stream[M, R any](
+	ctx context.Context, r *Repo, q query.Query, tableName string, toResource func(*M) R,
+) iter.Seq2[R, error] {
+	return func(yield func(R, error) bool) {
+		var zero R
+
+		tx := r.DB.WithContext(ctx).Begin(&sql.TxOptions{Isolation: sql.LevelRepeatableRead, ReadOnly: true})
+		if tx.Error != nil {
+			yield(zero, NewErrUnknown(tx.Error))
+			return
+		}
+		// nothing to commit, the transaction only pins the snapshot
+		defer tx.Rollback()
+
+		rows, err := r.queryApply(ctx, q, tableName, withDB(tx)).Model(new(M)).Rows()
+		if err != nil {
+			yield(zero, streamErr(err))
+			return
+		}
+		defer rows.Close()
+
+		for rows.Next() {
+			m := new(M)
+			if err := tx.ScanRows(rows, m); err != nil {
+				yield(zero, NewErrUnknown(err))
+				return
+			}
+			if !yield(toResource(m), nil) {
+				return
+			}
+		}
+		if err := rows.Err(); err != nil {
+			yield(zero, NewErrUnknown(err))
+		}
+	}
+}
+
+func streamErr(err error) error {
+	if _, ok := apierrors.As(err); ok {
+		return err
+	}
+	return NewErrUnknown(err)
+}
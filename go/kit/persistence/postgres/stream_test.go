@@ -0,0 +1,72 @@
+package postgres
+
+import (
+	"context"
+	"regexp"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	apierrors "github.com/dosanma1/forge/go/kit/errors"
+	"github.com/dosanma1/forge/go/kit/filter"
+	"github.com/dosanma1/forge/go/kit/search/query"
+)
+
+func TestStream(t *testing.T) {
+	ctx := context.Background()
+	articleID := func(a *testArticle) string { return a.ID }
+	collect := func(seq func(func(string, error) bool), limit int) ([]string, error) {
+		var ids []string
+		for id, err := range seq {
+			if err != nil {
+				return ids, err
+			}
+			ids = append(ids, id)
+			if len(ids) == limit {
+				break
+			}
+		}
+		return ids, nil
+	}
+
+	t.Run("yields every row inside a read-only transaction", func(t *testing.T) {
+		repo, mock := mockRepo(t)
+		mock.ExpectBegin()
+		mock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "articles" WHERE author_id = $1 ORDER BY "id" ASC`)).
+			WithArgs("u1").
+			WillReturnRows(sqlmock.NewRows([]string{"id", "author_id"}).AddRow("a1", "u1").AddRow("a2", "u1"))
+		mock.ExpectRollback()
+
+		q := query.New(query.FilterBy(filter.OpEq, "author_id", "u1"), query.SortBy("id", query.SortAsc))
+		ids, err := collect(Stream(ctx, repo, q, articleID), 0)
+		require.NoError(t, err)
+		assert.Equal(t, []string{"a1", "a2"}, ids)
+		require.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("stops reading when the caller breaks", func(t *testing.T) {
+		repo, mock := mockRepo(t)
+		mock.ExpectBegin()
+		mock.ExpectQuery(regexp.QuoteMeta(`SELECT * FROM "articles"`)).
+			WillReturnRows(sqlmock.NewRows([]string{"id", "author_id"}).AddRow("a1", "u1").AddRow("a2", "u1"))
+		mock.ExpectRollback()
+
+		ids, err := collect(Stream(ctx, repo, query.New(), articleID), 1)
+		require.NoError(t, err)
+		assert.Equal(t, []string{"a1"}, ids)
+		require.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("yields query errors", func(t *testing.T) {
+		repo, mock := mockRepo(t)
+		mock.ExpectBegin()
+		mock.ExpectRollback()
+
+		q := query.New(query.SortBy(query.SortRelevance, query.SortDesc))
+		_, err := collect(Stream(ctx, repo, q, articleID), 0)
+		assert.True(t, apierrors.Is(err, apierrors.CodeInvalidArgument), err)
+		require.NoError(t, mock.ExpectationsWereMet())
+	})
+}
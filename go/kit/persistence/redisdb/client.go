@@ -20,6 +20,12 @@
 //
 //	// Use client
 //	err = client.Set(ctx, "key", "value", 0).Err()
+//
+// Watch receives the keyspace notifications of keys, e.g. to clean up after
+// expiring presence keys, and an EventDispatcher routes them to handlers.
+//
+// NewResourceRepo stores typed resources, e.g. sessions or presence data, as
+// JSON or protobuf values with optional TTLs and a creation time index.
 package redisdb
 
 import (
@@ -38,7 +44,9 @@ const (
 )
 
 type config struct {
-	rConfig *redis.UniversalOptions
+	rConfig        *redis.UniversalOptions
+	keyspaceEvents []EventType
+	notify         bool
 }
 
 func newConfig(options ...Option) *config {
@@ -108,6 +116,17 @@ func WithDB(db int) Option {
 	}
 }
 
+// WithKeyspaceEvents makes the server notify the keyspace events of the
+// types, or all of them when none are given, adding their classes to the
+// notify-keyspace-events it has, see Watch. The setting is lost when the
+// server restarts unless it is in its configuration file too.
+func WithKeyspaceEvents(events ...EventType) Option {
+	return func(c *config) {
+		c.keyspaceEvents = events
+		c.notify = true
+	}
+}
+
 // WithAddressFromEnv allows to set the host:port address
 // by reading the REDIS_ADDRESS envvar (this is a default option).
 func WithAddressFromEnv() Option {
@@ -145,14 +164,14 @@ type Client struct {
 
 // New creates a new Redis client with the given monitoring and options.
 // It establishes a connection to Redis, verifies connectivity via PING,
-// and configures keyspace notifications for pub/sub functionality.
+// and configures keyspace notifications when asked to, see WithKeyspaceEvents.
 //
 // The client uses connection pooling with default limits of 100 max open
 // connections and 10 max idle connections. These can be customized using
 // WithMaxOpenLimit and WithMaxIdleConns options.
 //
 // Returns an error if connection fails, PING doesn't return PONG, or if
-// keyspacenotifications configuration fails.
+// keyspace notifications configuration fails.
 func New(m monitoring.Monitor, options ...Option) (*Client, error) {
 	config := newConfig(options...)
 
@@ -167,12 +186,10 @@ func New(m monitoring.Monitor, options ...Option) (*Client, error) {
 		return nil, newPingErr()
 	}
 
-	cmd, err = cli.ConfigSet(context.Background(), "notify-keyspace-events", "KEA").Result()
-	if err != nil {
-		return nil, err
-	}
-	if cmd != "OK" {
-		return nil, newNotifyKeySpaceEventsErr()
+	if config.notify {
+		if err := enableKeyspaceEvents(context.Background(), cli, config.keyspaceEvents...); err != nil {
+			return nil, err
+		}
 	}
 
 	return &Client{cli}, nil
@@ -0,0 +1,67 @@
+package redisdb
+
+import (
+	"encoding/json"
+
+	"google.golang.org/protobuf/proto"
+
+	"github.com/dosanma1/forge/go/kit/resource"
+)
+
+// Codec converts the resources R to the values stored by a ResourceRepo and
+// back.
+type Codec[R resource.Resource] interface {
+	Marshal(res R) ([]byte, error)
+	Unmarshal(data []byte) (R, error)
+}
+
+type jsonCodec[M any, R resource.Resource] struct {
+	toModel    func(R) M
+	toResource func(*M) R
+}
+
+// JSONCodec stores the resources as the JSON of their models M, converting
+// them with toModel and back with toResource.
+func JSONCodec[M any, R resource.Resource](toModel func(R) M, toResource func(*M) R) Codec[R] {
+	return &jsonCodec[M, R]{toModel: toModel, toResource: toResource}
+}
+
+func (c *jsonCodec[M, R]) Marshal(res R) ([]byte, error) {
+	return json.Marshal(c.toModel(res))
+}
+
+func (c *jsonCodec[M, R]) Unmarshal(data []byte) (R, error) {
+	var m M
+	if err := json.Unmarshal(data, &m); err != nil {
+		var zero R
+		return zero, err
+	}
+
+	return c.toResource(&m), nil
+}
+
+type protoCodec[M proto.Message, R resource.Resource] struct {
+	toProto   func(R) M
+	fromProto func(M) R
+}
+
+// ProtoCodec stores the resources as the wire encoding of their protobuf
+// messages M, converting them with toProto and back with fromProto.
+func ProtoCodec[M proto.Message, R resource.Resource](toProto func(R) M, fromProto func(M) R) Codec[R] {
+	return &protoCodec[M, R]{toProto: toProto, fromProto: fromProto}
+}
+
+func (c *protoCodec[M, R]) Marshal(res R) ([]byte, error) {
+	return proto.Marshal(c.toProto(res))
+}
+
+func (c *protoCodec[M, R]) Unmarshal(data []byte) (R, error) {
+	var zero M
+	m, _ := zero.ProtoReflect().Type().New().Interface().(M)
+	if err := proto.Unmarshal(data, m); err != nil {
+		var zero R
+		return zero, err
+	}
+
+	return c.fromProto(m), nil
+}
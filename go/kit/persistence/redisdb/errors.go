@@ -1,8 +1,10 @@
 package redisdb
 
 import (
-	"errors"
+	stderrors "errors"
 	"fmt"
+
+	"github.com/dosanma1/forge/go/kit/errors"
 )
 
 // ConnectionErr defines a database connection error.
@@ -27,9 +29,21 @@ func newErrConn(wrappedErr error) error {
 }
 
 func newPingErr() error {
-	return newErrConn(errors.New("no PONG received"))
+	return newErrConn(stderrors.New("no PONG received"))
 }
 
 func newNotifyKeySpaceEventsErr() error {
-	return newErrConn(errors.New("notify-keyspace-events not configured correctly"))
+	return newErrConn(stderrors.New("notify-keyspace-events not configured correctly"))
+}
+
+func newErrCommand(err error) error {
+	return errors.Wrap(err, errors.CodeDatabaseError, errors.WithMessage("redis command failed"))
+}
+
+func newErrCodec(err error) error {
+	return errors.Wrap(err, errors.CodeDataCorruption, errors.WithMessage("redis value cannot be encoded"))
+}
+
+func newErrConcurrentWrite(resourceName, id string) error {
+	return errors.Conflict(fmt.Sprintf("%s %s was written concurrently", resourceName, id))
 }
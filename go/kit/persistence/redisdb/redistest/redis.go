@@ -1,7 +1,6 @@
 package redistest
 
 import (
-	"context"
 	"sync"
 	"testing"
 
@@ -52,12 +51,9 @@ func helperCreateRedisContainer(t *testing.T, m monitoring.Monitor) *db {
 	assert.NoError(t, err)
 
 	addr := container.DefaultAddress()
-	client, err := redisdb.New(m, redisdb.WithAddress(addr))
+	client, err := redisdb.New(m, redisdb.WithAddress(addr), redisdb.WithKeyspaceEvents())
 	assert.NoError(t, err)
 	assert.NotNil(t, client)
-	cmd, err := client.ConfigSet(context.TODO(), "notify-keyspace-events", "KEA").Result()
-	assert.NoError(t, err)
-	assert.Equal(t, "OK", cmd)
 
 	return &db{
 		Client:   client,
@@ -0,0 +1,509 @@
+package redisdb
+
+import (
+	"context"
+	stderrors "errors"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+
+	"github.com/dosanma1/forge/go/kit/application/repository"
+	"github.com/dosanma1/forge/go/kit/errors"
+	"github.com/dosanma1/forge/go/kit/filter"
+	"github.com/dosanma1/forge/go/kit/resource"
+	"github.com/dosanma1/forge/go/kit/search"
+	"github.com/dosanma1/forge/go/kit/search/query"
+)
+
+const (
+	fieldID        = "id"
+	fieldCreatedAt = "createdAt"
+
+	defaultModifyAttempts = 5
+)
+
+type (
+	ResourceRepoOption func(c *resourceRepoConfig)
+	resourceRepoConfig struct {
+		ttl            func(resource.Resource) time.Duration
+		index          bool
+		modifyAttempts int
+		resourceName   string
+	}
+)
+
+// WithTTL expires the keys of the resources ttl after they are written.
+// Defaults to no expiration.
+func WithTTL(ttl time.Duration) ResourceRepoOption {
+	return WithTTLFunc(func(resource.Resource) time.Duration { return ttl })
+}
+
+// WithTTLFunc expires the key of every resource the duration fn returns for
+// it after it is written, a zero duration keeping the current expiration.
+func WithTTLFunc(fn func(res resource.Resource) time.Duration) ResourceRepoOption {
+	return func(c *resourceRepoConfig) {
+		c.ttl = fn
+	}
+}
+
+// WithoutCreatedAtIndex does not index the resources by creation time, for
+// the repositories listing them by id only.
+func WithoutCreatedAtIndex() ResourceRepoOption {
+	return func(c *resourceRepoConfig) {
+		c.index = false
+	}
+}
+
+// WithModifyAttempts sets how many times Modify runs when the resource is
+// written concurrently. Defaults to 5.
+func WithModifyAttempts(attempts int) ResourceRepoOption {
+	return func(c *resourceRepoConfig) {
+		if attempts > 0 {
+			c.modifyAttempts = attempts
+		}
+	}
+}
+
+// WithResourceRepoName sets the resource name of the not found errors.
+// Defaults to the key prefix.
+func WithResourceRepoName(name string) ResourceRepoOption {
+	return func(c *resourceRepoConfig) {
+		c.resourceName = name
+	}
+}
+
+// ResourceRepo stores the resources R as values encoded by a Codec, under the
+// keys {prefix}:r:<id>. Unless disabled, a sorted set, {prefix}:idx:createdAt,
+// indexes them by creation time to list them, see List. The keys of a repository share the hash tag
+// of its prefix, so they live in the same slot of a cluster.
+//
+// Writes run in WATCH transactions: a resource written concurrently fails
+// with a conflict error rather than being overwritten, see Modify to retry.
+type ResourceRepo[R resource.Resource] struct {
+	cli    redis.UniversalClient
+	codec  Codec[R]
+	prefix string
+	resourceRepoConfig
+}
+
+var (
+	_ repository.Creator[resource.Resource] = (*ResourceRepo[resource.Resource])(nil)
+	_ repository.Getter[resource.Resource]  = (*ResourceRepo[resource.Resource])(nil)
+	_ repository.Lister[resource.Resource]  = (*ResourceRepo[resource.Resource])(nil)
+	_ repository.Updater[resource.Resource] = (*ResourceRepo[resource.Resource])(nil)
+	_ repository.Deleter                    = (*ResourceRepo[resource.Resource])(nil)
+)
+
+// NewResourceRepo returns a repository of the resources stored under prefix.
+func NewResourceRepo[R resource.Resource](
+	cli redis.UniversalClient, prefix string, codec Codec[R], opts ...ResourceRepoOption,
+) (*ResourceRepo[R], error) {
+	if cli == nil {
+		return nil, ErrRedisMissingRedisConn
+	}
+	if prefix == "" {
+		return nil, errors.InvalidArgument("redis repository key prefix is required")
+	}
+	if codec == nil {
+		return nil, errors.InvalidArgument("redis repository codec is required")
+	}
+
+	cfg := resourceRepoConfig{
+		index:          true,
+		modifyAttempts: defaultModifyAttempts,
+		resourceName:   prefix,
+	}
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+
+	return &ResourceRepo[R]{
+		cli:                cli,
+		codec:              codec,
+		prefix:             prefix,
+		resourceRepoConfig: cfg,
+	}, nil
+}
+
+// Create stores the resource, which must have an id, failing when one with
+// the same id exists.
+func (r *ResourceRepo[R]) Create(ctx context.Context, res R) (R, error) {
+	var zero R
+	if res.ID() == "" {
+		return zero, errors.MissingField(fieldID)
+	}
+	data, err := r.codec.Marshal(res)
+	if err != nil {
+		return zero, newErrCodec(err)
+	}
+
+	key := r.key(res.ID())
+	err = r.cli.Watch(ctx, func(tx *redis.Tx) error {
+		n, err := tx.Exists(ctx, key).Result()
+		if err != nil {
+			return err
+		}
+		if n > 0 {
+			return errors.AlreadyExists(r.resourceName, res.ID())
+		}
+
+		return r.write(ctx, tx, res, data, 0)
+	}, key)
+	if err != nil {
+		return zero, r.mapErr(err, res.ID())
+	}
+
+	return res, nil
+}
+
+// Get returns the resource of the id filter of the search options.
+func (r *ResourceRepo[R]) Get(ctx context.Context, opts ...search.Option) (R, error) {
+	var zero R
+	ids, err := filteredIDs(search.New(opts...).Query())
+	if err != nil {
+		return zero, err
+	}
+	if len(ids) != 1 {
+		return zero, errors.InvalidArgument("redis repository gets a single id")
+	}
+
+	data, err := r.cli.Get(ctx, r.key(ids[0])).Bytes()
+	if stderrors.Is(err, redis.Nil) {
+		return zero, errors.NotFound(r.resourceName, ids[0])
+	}
+	if err != nil {
+		return zero, newErrCommand(err)
+	}
+
+	return r.decode(data)
+}
+
+// List returns the resources of the id filter of the search options, or all
+// of them through the creation time index when there is none. They can only
+// be sorted by createdAt, and are in the order of the ids or of creation
+// otherwise.
+//
+// The entries of the index whose keys expired are removed as they are found
+// listing, so the total can count a few of them before.
+func (r *ResourceRepo[R]) List(ctx context.Context, opts ...search.Option) (resource.ListResponse[R], error) {
+	q := search.New(opts...).Query()
+	ids, err := filteredIDs(q)
+	if err != nil {
+		return nil, err
+	}
+	dir := query.SortDirUndefined
+	for _, key := range q.Sorting().Keys() {
+		if key != fieldCreatedAt {
+			return nil, errors.InvalidArgument(fmt.Sprintf("redis repository cannot sort by %s", key))
+		}
+		dir = q.Sorting().Get(key)
+	}
+
+	if ids != nil {
+		return r.listByIDs(ctx, ids, dir, q.Pagination())
+	}
+	if !r.index {
+		return nil, errors.InvalidArgument("redis repository without index lists by id only")
+	}
+
+	return r.listByCreatedAt(ctx, dir, q.Pagination())
+}
+
+func (r *ResourceRepo[R]) listByIDs(
+	ctx context.Context, ids []string, dir query.SortingDir, page *query.PaginationParams,
+) (resource.ListResponse[R], error) {
+	items, _, err := r.getAll(ctx, ids)
+	if err != nil {
+		return nil, err
+	}
+	if dir != query.SortDirUndefined {
+		slices.SortStableFunc(items, func(a, b R) int {
+			if dir == query.SortDesc {
+				return b.CreatedAt().Compare(a.CreatedAt())
+			}
+			return a.CreatedAt().Compare(b.CreatedAt())
+		})
+	}
+
+	total := len(items)
+	if page != nil {
+		items = items[min(page.Offset, total):]
+		if page.Limit > 0 {
+			items = items[:min(page.Limit, len(items))]
+		}
+	}
+
+	return resource.NewListResponse(items, total), nil
+}
+
+func (r *ResourceRepo[R]) listByCreatedAt(
+	ctx context.Context, dir query.SortingDir, page *query.PaginationParams,
+) (resource.ListResponse[R], error) {
+	start, stop := int64(0), int64(-1)
+	if page != nil {
+		start = int64(page.Offset)
+		if page.Limit > 0 {
+			stop = start + int64(page.Limit) - 1
+		}
+	}
+
+	rangeCmd := r.cli.ZRange
+	if dir == query.SortDesc {
+		rangeCmd = r.cli.ZRevRange
+	}
+	ids, err := rangeCmd(ctx, r.indexKey(), start, stop).Result()
+	if err != nil {
+		return nil, newErrCommand(err)
+	}
+	total, err := r.cli.ZCard(ctx, r.indexKey()).Result()
+	if err != nil {
+		return nil, newErrCommand(err)
+	}
+
+	items, expired, err := r.getAll(ctx, ids)
+	if err != nil {
+		return nil, err
+	}
+	if len(expired) > 0 {
+		members := make([]any, len(expired))
+		for i, id := range expired {
+			members[i] = id
+		}
+		if err := r.cli.ZRem(ctx, r.indexKey(), members...).Err(); err != nil {
+			return nil, newErrCommand(err)
+		}
+		total -= int64(len(expired))
+	}
+
+	return resource.NewListResponse(items, int(total)), nil
+}
+
+// getAll returns the resources of the ids in order, and the ids of those
+// missing.
+func (r *ResourceRepo[R]) getAll(ctx context.Context, ids []string) ([]R, []string, error) {
+	if len(ids) == 0 {
+		return []R{}, nil, nil
+	}
+
+	keys := make([]string, len(ids))
+	for i, id := range ids {
+		keys[i] = r.key(id)
+	}
+	values, err := r.cli.MGet(ctx, keys...).Result()
+	if err != nil {
+		return nil, nil, newErrCommand(err)
+	}
+
+	items := make([]R, 0, len(values))
+	var missing []string
+	for i, v := range values {
+		data, ok := v.(string)
+		if !ok {
+			missing = append(missing, ids[i])
+			continue
+		}
+		res, err := r.decode([]byte(data))
+		if err != nil {
+			return nil, nil, err
+		}
+		items = append(items, res)
+	}
+
+	return items, missing, nil
+}
+
+// Update overwrites the stored resource, failing when there is none.
+func (r *ResourceRepo[R]) Update(ctx context.Context, res R) (R, error) {
+	var zero R
+	data, err := r.codec.Marshal(res)
+	if err != nil {
+		return zero, newErrCodec(err)
+	}
+
+	key := r.key(res.ID())
+	err = r.cli.Watch(ctx, func(tx *redis.Tx) error {
+		n, err := tx.Exists(ctx, key).Result()
+		if err != nil {
+			return err
+		}
+		if n == 0 {
+			return errors.NotFound(r.resourceName, res.ID())
+		}
+
+		return r.write(ctx, tx, res, data, redis.KeepTTL)
+	}, key)
+	if err != nil {
+		return zero, r.mapErr(err, res.ID())
+	}
+
+	return res, nil
+}
+
+// Modify stores the resource fn returns from the stored one of the id. fn
+// runs again on the new stored resource when it is written concurrently, up
+// to the modify attempts of the repository, see WithModifyAttempts.
+func (r *ResourceRepo[R]) Modify(ctx context.Context, id string, fn func(R) (R, error)) (R, error) {
+	var (
+		zero     R
+		modified R
+		fnErr    error
+	)
+	key := r.key(id)
+	for range r.modifyAttempts {
+		err := r.cli.Watch(ctx, func(tx *redis.Tx) error {
+			data, err := tx.Get(ctx, key).Bytes()
+			if stderrors.Is(err, redis.Nil) {
+				return errors.NotFound(r.resourceName, id)
+			}
+			if err != nil {
+				return err
+			}
+			current, err := r.decode(data)
+			if err != nil {
+				return err
+			}
+
+			if modified, fnErr = fn(current); fnErr != nil {
+				return fnErr
+			}
+			if modified.ID() != id {
+				return errors.InvalidArgument("redis repository cannot modify the id of a resource")
+			}
+			if data, err = r.codec.Marshal(modified); err != nil {
+				return newErrCodec(err)
+			}
+
+			return r.write(ctx, tx, modified, data, redis.KeepTTL)
+		}, key)
+		if stderrors.Is(err, redis.TxFailedErr) {
+			continue
+		}
+		if fnErr != nil {
+			return zero, fnErr
+		}
+		if err != nil {
+			return zero, r.mapErr(err, id)
+		}
+
+		return modified, nil
+	}
+
+	return zero, newErrConcurrentWrite(r.resourceName, id)
+}
+
+// Delete hard deletes the resources of the id filter of the search options.
+func (r *ResourceRepo[R]) Delete(ctx context.Context, delType repository.DeleteType, opts ...search.Option) error {
+	switch delType {
+	case repository.DeleteTypeHard:
+	case repository.DeleteTypeSoft:
+		return errors.InvalidArgument(fmt.Sprintf("%s cannot be soft deleted", r.resourceName))
+	default:
+		return errors.InvalidArgument(fmt.Sprintf("unknown delete type: %d", delType))
+	}
+
+	ids, err := filteredIDs(search.New(opts...).Query())
+	if err != nil {
+		return err
+	}
+	if len(ids) == 0 {
+		return errors.InvalidArgument("delete requires at least one id")
+	}
+
+	keys := make([]string, len(ids))
+	members := make([]any, len(ids))
+	for i, id := range ids {
+		keys[i], members[i] = r.key(id), id
+	}
+	_, err = r.cli.TxPipelined(ctx, func(p redis.Pipeliner) error {
+		p.Del(ctx, keys...)
+		if r.index {
+			p.ZRem(ctx, r.indexKey(), members...)
+		}
+		return nil
+	})
+	if err != nil {
+		return newErrCommand(err)
+	}
+
+	return nil
+}
+
+// write stores the encoded resource in the transaction of tx, expiring its
+// key after the TTL of the repository or else keepTTL.
+func (r *ResourceRepo[R]) write(ctx context.Context, tx *redis.Tx, res R, data []byte, keepTTL time.Duration) error {
+	ttl := keepTTL
+	if r.ttl != nil {
+		if d := r.ttl(res); d > 0 {
+			ttl = d
+		}
+	}
+
+	_, err := tx.TxPipelined(ctx, func(p redis.Pipeliner) error {
+		p.Set(ctx, r.key(res.ID()), data, ttl)
+		if r.index {
+			p.ZAdd(ctx, r.indexKey(), redis.Z{Score: float64(res.CreatedAt().UnixMilli()), Member: res.ID()})
+		}
+		return nil
+	})
+
+	return err
+}
+
+func (r *ResourceRepo[R]) decode(data []byte) (R, error) {
+	res, err := r.codec.Unmarshal(data)
+	if err != nil {
+		return res, newErrCodec(err)
+	}
+
+	return res, nil
+}
+
+// key returns the key of the resource of the id. The resources and the
+// index live in separate namespaces so that no id collides with the index.
+func (r *ResourceRepo[R]) key(id string) string {
+	return fmt.Sprintf("{%s}:r:%s", r.prefix, id)
+}
+
+func (r *ResourceRepo[R]) indexKey() string {
+	return fmt.Sprintf("{%s}:idx:%s", r.prefix, fieldCreatedAt)
+}
+
+func (r *ResourceRepo[R]) mapErr(err error, id string) error {
+	if _, ok := errors.As(err); ok {
+		return err
+	}
+	if stderrors.Is(err, redis.TxFailedErr) {
+		return newErrConcurrentWrite(r.resourceName, id)
+	}
+
+	return newErrCommand(err)
+}
+
+// filteredIDs returns the ids of the id filter of q, nil when there is none.
+// The repository filters by id only.
+func filteredIDs(q query.Query) ([]string, error) {
+	if len(q.FilterGroups()) > 0 {
+		return nil, errors.InvalidArgument("redis repository filters by id only")
+	}
+	for name := range q.Filters() {
+		if name != fieldID {
+			return nil, errors.InvalidArgument(fmt.Sprintf("redis repository cannot filter by %s", name))
+		}
+	}
+
+	f := q.Filters().Get(fieldID)
+	if f == nil {
+		return nil, nil
+	}
+	if f.Operator() != filter.OpEq && f.Operator() != filter.OpIn {
+		return nil, errors.InvalidArgument("redis repository filters ids by equality only")
+	}
+	ids := query.GetFilterSingleOrArrayVal[string](fieldID, q.Filters())
+	if len(ids) == 0 {
+		return nil, errors.InvalidArgument("redis repository ids must be strings")
+	}
+
+	return ids, nil
+}
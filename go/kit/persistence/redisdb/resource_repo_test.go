@@ -0,0 +1,260 @@
+package redisdb_test
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+
+	"github.com/dosanma1/forge/go/kit/application/repository"
+	apierrors "github.com/dosanma1/forge/go/kit/errors"
+	"github.com/dosanma1/forge/go/kit/filter"
+	"github.com/dosanma1/forge/go/kit/persistence/redisdb"
+	"github.com/dosanma1/forge/go/kit/resource"
+	"github.com/dosanma1/forge/go/kit/search"
+	"github.com/dosanma1/forge/go/kit/search/query"
+)
+
+type session struct {
+	resource.Resource
+	userID string
+}
+
+type sessionModel struct {
+	ID        string    `json:"id"`
+	UserID    string    `json:"userId"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+func sessionToModel(s *session) sessionModel {
+	return sessionModel{ID: s.ID(), UserID: s.userID, CreatedAt: s.CreatedAt()}
+}
+
+func sessionFromModel(m *sessionModel) *session {
+	return newSession(m.ID, m.UserID, m.CreatedAt)
+}
+
+func newSession(id, userID string, createdAt time.Time) *session {
+	return &session{
+		Resource: resource.New(resource.WithID(id), resource.WithCreatedAt(createdAt.UTC())),
+		userID:   userID,
+	}
+}
+
+func newSessionRepo(t *testing.T, opts ...redisdb.ResourceRepoOption) (*redisdb.ResourceRepo[*session], *miniredis.Miniredis) {
+	t.Helper()
+
+	mr := miniredis.RunT(t)
+	cli := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	t.Cleanup(func() { cli.Close() })
+
+	repo, err := redisdb.NewResourceRepo(cli, "sessions", redisdb.JSONCodec(sessionToModel, sessionFromModel), opts...)
+	require.NoError(t, err)
+
+	return repo, mr
+}
+
+func byID(ids ...string) search.Option {
+	if len(ids) == 1 {
+		return search.WithQueryOpts(query.FilterBy(filter.OpEq, "id", ids[0]))
+	}
+	return search.WithQueryOpts(query.FilterBy(filter.OpIn, "id", ids))
+}
+
+func listIDs(t *testing.T, res resource.ListResponse[*session]) []string {
+	t.Helper()
+
+	ids := make([]string, 0, len(res.Results()))
+	for _, s := range res.Results() {
+		ids = append(ids, s.ID())
+	}
+	return ids
+}
+
+func TestResourceRepoCRUD(t *testing.T) {
+	ctx := context.Background()
+	now := time.Now().Truncate(time.Millisecond)
+
+	t.Run("creates, gets, updates and deletes a resource", func(t *testing.T) {
+		repo, _ := newSessionRepo(t)
+
+		_, err := repo.Create(ctx, newSession("s1", "u1", now))
+		require.NoError(t, err)
+		_, err = repo.Create(ctx, newSession("s1", "u2", now))
+		assert.True(t, apierrors.Is(err, apierrors.CodeAlreadyExists))
+
+		got, err := repo.Get(ctx, byID("s1"))
+		require.NoError(t, err)
+		assert.Equal(t, "u1", got.userID)
+		assert.True(t, now.Equal(got.CreatedAt()))
+
+		_, err = repo.Update(ctx, newSession("s1", "u2", now))
+		require.NoError(t, err)
+		got, err = repo.Get(ctx, byID("s1"))
+		require.NoError(t, err)
+		assert.Equal(t, "u2", got.userID)
+
+		require.NoError(t, repo.Delete(ctx, repository.DeleteTypeHard, byID("s1")))
+		_, err = repo.Get(ctx, byID("s1"))
+		assert.True(t, apierrors.Is(err, apierrors.CodeNotFound))
+		_, err = repo.Update(ctx, newSession("s1", "u2", now))
+		assert.True(t, apierrors.Is(err, apierrors.CodeNotFound))
+	})
+
+	t.Run("rejects what it cannot do", func(t *testing.T) {
+		repo, _ := newSessionRepo(t)
+
+		_, err := repo.Create(ctx, newSession("", "u1", now))
+		assert.True(t, apierrors.Is(err, apierrors.CodeMissingField))
+		_, err = repo.Get(ctx, search.WithQueryOpts(query.FilterBy(filter.OpEq, "userId", "u1")))
+		assert.True(t, apierrors.Is(err, apierrors.CodeInvalidArgument))
+		_, err = repo.List(ctx, search.WithQueryOpts(query.SortBy("userId", query.SortAsc)))
+		assert.True(t, apierrors.Is(err, apierrors.CodeInvalidArgument))
+		err = repo.Delete(ctx, repository.DeleteTypeSoft, byID("s1"))
+		assert.True(t, apierrors.Is(err, apierrors.CodeInvalidArgument))
+		err = repo.Delete(ctx, repository.DeleteTypeHard)
+		assert.True(t, apierrors.Is(err, apierrors.CodeInvalidArgument))
+	})
+
+	t.Run("expires the keys after their ttl", func(t *testing.T) {
+		repo, mr := newSessionRepo(t, redisdb.WithTTLFunc(func(res resource.Resource) time.Duration {
+			if res.(*session).userID == "admin" {
+				return time.Minute
+			}
+			return time.Hour
+		}))
+
+		_, err := repo.Create(ctx, newSession("s1", "admin", now))
+		require.NoError(t, err)
+		_, err = repo.Create(ctx, newSession("s2", "u1", now))
+		require.NoError(t, err)
+		assert.Equal(t, time.Minute, mr.TTL("{sessions}:r:s1"))
+		assert.Equal(t, time.Hour, mr.TTL("{sessions}:r:s2"))
+
+		mr.FastForward(2 * time.Minute)
+		_, err = repo.Get(ctx, byID("s1"))
+		assert.True(t, apierrors.Is(err, apierrors.CodeNotFound))
+
+		list, err := repo.List(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, []string{"s2"}, listIDs(t, list))
+		assert.Equal(t, 1, list.TotalCount())
+	})
+
+	t.Run("keeps the ids apart from the index", func(t *testing.T) {
+		repo, _ := newSessionRepo(t)
+
+		for _, id := range []string{"s1", "index:createdAt", "idx:createdAt"} {
+			_, err := repo.Create(ctx, newSession(id, "u1", now))
+			require.NoError(t, err)
+		}
+		got, err := repo.Get(ctx, byID("idx:createdAt"))
+		require.NoError(t, err)
+		assert.Equal(t, "idx:createdAt", got.ID())
+
+		list, err := repo.List(ctx)
+		require.NoError(t, err)
+		assert.ElementsMatch(t, []string{"s1", "index:createdAt", "idx:createdAt"}, listIDs(t, list))
+		assert.Equal(t, 3, list.TotalCount())
+	})
+}
+
+func TestResourceRepoList(t *testing.T) {
+	ctx := context.Background()
+	now := time.Now().Truncate(time.Millisecond)
+	repo, _ := newSessionRepo(t)
+	for i, id := range []string{"s2", "s3", "s1"} {
+		_, err := repo.Create(ctx, newSession(id, "u1", now.Add(time.Duration([]int{2, 3, 1}[i])*time.Second)))
+		require.NoError(t, err)
+	}
+
+	tests := []struct {
+		name      string
+		opts      []search.Option
+		wantIDs   []string
+		wantTotal int
+	}{
+		{
+			name:      "all by creation time",
+			wantIDs:   []string{"s1", "s2", "s3"},
+			wantTotal: 3,
+		},
+		{
+			name: "a page by descending creation time",
+			opts: []search.Option{search.WithQueryOpts(
+				query.SortBy("createdAt", query.SortDesc), query.Pagination(2, 1),
+			)},
+			wantIDs:   []string{"s2", "s1"},
+			wantTotal: 3,
+		},
+		{
+			name:      "by ids in their order, leaving out the missing ones",
+			opts:      []search.Option{byID("s3", "s9", "s1")},
+			wantIDs:   []string{"s3", "s1"},
+			wantTotal: 2,
+		},
+		{
+			name: "by ids sorted by creation time",
+			opts: []search.Option{
+				byID("s3", "s1", "s2"),
+				search.WithQueryOpts(query.SortBy("createdAt", query.SortAsc), query.Pagination(2, 0)),
+			},
+			wantIDs:   []string{"s1", "s2"},
+			wantTotal: 3,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			list, err := repo.List(ctx, tt.opts...)
+			require.NoError(t, err)
+			assert.Equal(t, tt.wantIDs, listIDs(t, list))
+			assert.Equal(t, tt.wantTotal, list.TotalCount())
+		})
+	}
+}
+
+func TestResourceRepoModify(t *testing.T) {
+	ctx := context.Background()
+	repo, _ := newSessionRepo(t)
+	_, err := repo.Create(ctx, newSession("s1", "", time.Now()))
+	require.NoError(t, err)
+
+	var wg sync.WaitGroup
+	for range 5 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := repo.Modify(ctx, "s1", func(s *session) (*session, error) {
+				return newSession(s.ID(), s.userID+"x", s.CreatedAt()), nil
+			})
+			assert.NoError(t, err)
+		}()
+	}
+	wg.Wait()
+
+	got, err := repo.Get(ctx, byID("s1"))
+	require.NoError(t, err)
+	assert.Equal(t, "xxxxx", got.userID)
+
+	_, err = repo.Modify(ctx, "s9", func(s *session) (*session, error) { return s, nil })
+	assert.True(t, apierrors.Is(err, apierrors.CodeNotFound))
+}
+
+func TestProtoCodec(t *testing.T) {
+	codec := redisdb.ProtoCodec(
+		func(res resource.Resource) *wrapperspb.StringValue { return wrapperspb.String(res.ID()) },
+		func(m *wrapperspb.StringValue) resource.Resource { return resource.New(resource.WithID(m.GetValue())) },
+	)
+
+	data, err := codec.Marshal(resource.New(resource.WithID("s1")))
+	require.NoError(t, err)
+	got, err := codec.Unmarshal(data)
+	require.NoError(t, err)
+	assert.Equal(t, "s1", got.ID())
+}
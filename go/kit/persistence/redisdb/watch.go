@@ -0,0 +1,273 @@
+package redisdb
+
+import (
+	"context"
+	stderrors "errors"
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+
+	"github.com/dosanma1/forge/go/kit/errors"
+	"github.com/dosanma1/forge/go/kit/retry"
+)
+
+const (
+	notifyKeyspaceEvents = "notify-keyspace-events"
+
+	watchBufferSize   = 100
+	watchPingInterval = 30 * time.Second
+)
+
+// EventType is the name of the command or condition notifying a key event.
+type EventType string
+
+const (
+	EventSet     EventType = "set"
+	EventDel     EventType = "del"
+	EventExpired EventType = "expired"
+	EventEvicted EventType = "evicted"
+)
+
+// class returns the notify-keyspace-events class of the event.
+func (t EventType) class() string {
+	switch t {
+	case EventSet:
+		return "$"
+	case EventDel:
+		return "g"
+	case EventExpired:
+		return "x"
+	case EventEvicted:
+		return "e"
+	default:
+		return "A"
+	}
+}
+
+// Event is a keyspace notification of a key.
+type Event struct {
+	Type EventType
+	Key  string
+	DB   int
+}
+
+// Watcher receives the keyspace notifications of the keys matching a pattern,
+// see Watch.
+type Watcher struct {
+	ps     *redis.PubSub
+	events chan Event
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+// Watch receives the events of the keys matching the glob-style pattern, or
+// all their events when none are given, until ctx is done or the watcher is
+// closed. It subscribes again on its own when the connection is lost, the
+// events notified meanwhile being missed.
+//
+// The server must notify the events, see WithKeyspaceEvents. On a cluster,
+// the events of the node holding the pattern's slot are received only.
+func Watch(ctx context.Context, cli redis.UniversalClient, pattern string, events ...EventType) (*Watcher, error) {
+	if pattern == "" {
+		return nil, errors.InvalidArgument("redis watch pattern is required")
+	}
+
+	db := clientDB(cli)
+	prefix := fmt.Sprintf("__keyspace@%d__:", db)
+	ps := cli.PSubscribe(ctx, prefix+pattern)
+	if _, err := ps.Receive(ctx); err != nil {
+		_ = ps.Close()
+		return nil, newErrCommand(err)
+	}
+
+	wanted := make(map[EventType]bool, len(events))
+	for _, e := range events {
+		wanted[e] = true
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	w := &Watcher{
+		ps:     ps,
+		events: make(chan Event, watchBufferSize),
+		cancel: cancel,
+		done:   make(chan struct{}),
+	}
+	// Receive does not return when ctx is done, closing ps makes it.
+	stop := context.AfterFunc(ctx, func() { _ = ps.Close() })
+	go func() {
+		defer stop()
+		w.run(ctx, prefix, db, wanted)
+	}()
+
+	return w, nil
+}
+
+// Events returns the events received, closed once the watcher is done.
+func (w *Watcher) Events() <-chan Event {
+	return w.events
+}
+
+// Close stops watching.
+func (w *Watcher) Close() error {
+	w.cancel()
+	<-w.done
+
+	return nil
+}
+
+func (w *Watcher) run(ctx context.Context, prefix string, db int, wanted map[EventType]bool) {
+	defer close(w.done)
+	defer close(w.events)
+
+	failures := 0
+	for {
+		msg, err := w.ps.ReceiveTimeout(ctx, watchPingInterval)
+		if ctx.Err() != nil {
+			return
+		}
+		if isTimeout(err) {
+			// a ping failing on a lost connection makes the next receive
+			// reconnect and subscribe again
+			err = w.ps.Ping(ctx)
+		}
+		if err != nil {
+			failures++
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(retry.Delay(failures)):
+			}
+			continue
+		}
+		failures = 0
+
+		m, ok := msg.(*redis.Message)
+		if !ok {
+			continue
+		}
+		e := Event{Type: EventType(m.Payload), Key: strings.TrimPrefix(m.Channel, prefix), DB: db}
+		if len(wanted) > 0 && !wanted[e.Type] {
+			continue
+		}
+		select {
+		case w.events <- e:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// EventHandler handles the events of a watcher, see EventDispatcher.
+type EventHandler func(ctx context.Context, e Event)
+
+// EventDispatcher runs the handlers of the events of a watcher.
+type EventDispatcher struct {
+	mu       sync.RWMutex
+	handlers map[EventType][]EventHandler
+	all      []EventHandler
+}
+
+func NewEventDispatcher() *EventDispatcher {
+	return &EventDispatcher{handlers: map[EventType][]EventHandler{}}
+}
+
+// Handle runs h on the events of the types, or on all of them when none are
+// given.
+func (d *EventDispatcher) Handle(h EventHandler, events ...EventType) *EventDispatcher {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if len(events) == 0 {
+		d.all = append(d.all, h)
+	}
+	for _, e := range events {
+		d.handlers[e] = append(d.handlers[e], h)
+	}
+
+	return d
+}
+
+// Run runs the handlers on the events of w, one at a time and in order, until
+// w is done or ctx is.
+func (d *EventDispatcher) Run(ctx context.Context, w *Watcher) error {
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case e, ok := <-w.Events():
+			if !ok {
+				return nil
+			}
+			d.dispatch(ctx, e)
+		}
+	}
+}
+
+func (d *EventDispatcher) dispatch(ctx context.Context, e Event) {
+	d.mu.RLock()
+	handlers := append(append([]EventHandler{}, d.handlers[e.Type]...), d.all...)
+	d.mu.RUnlock()
+
+	for _, h := range handlers {
+		h(ctx, e)
+	}
+}
+
+// enableKeyspaceEvents adds the classes of the events, or all of them when
+// none are given, to those the server notifies.
+func enableKeyspaceEvents(ctx context.Context, cli redis.UniversalClient, events ...EventType) error {
+	classes := "KA"
+	if len(events) > 0 {
+		classes = "K"
+		for _, e := range events {
+			classes += e.class()
+		}
+	}
+
+	current, err := cli.ConfigGet(ctx, notifyKeyspaceEvents).Result()
+	if err != nil {
+		return err
+	}
+	flags := current[notifyKeyspaceEvents]
+	merged := flags
+	for _, c := range classes {
+		if !strings.ContainsRune(merged, c) {
+			merged += string(c)
+		}
+	}
+	if merged == flags {
+		return nil
+	}
+
+	res, err := cli.ConfigSet(ctx, notifyKeyspaceEvents, merged).Result()
+	if err != nil {
+		return err
+	}
+	if res != "OK" {
+		return newNotifyKeySpaceEventsErr()
+	}
+
+	return nil
+}
+
+// clientDB returns the database cli selects, the keyspace notifications
+// being published per database.
+func clientDB(cli redis.UniversalClient) int {
+	if c, ok := cli.(*Client); ok {
+		cli = c.UniversalClient
+	}
+	if c, ok := cli.(*redis.Client); ok {
+		return c.Options().DB
+	}
+
+	return 0
+}
+
+func isTimeout(err error) bool {
+	var netErr net.Error
+	return stderrors.As(err, &netErr) && netErr.Timeout()
+}
@@ -0,0 +1,106 @@
+package redisdb_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/dosanma1/forge/go/kit/persistence/redisdb"
+)
+
+// miniredis does not notify keyspace events, the tests publish them.
+func newWatchedServer(t *testing.T, db int) (*miniredis.Miniredis, redis.UniversalClient) {
+	t.Helper()
+
+	mr := miniredis.RunT(t)
+	cli := redis.NewClient(&redis.Options{Addr: mr.Addr(), DB: db})
+	t.Cleanup(func() { cli.Close() })
+
+	return mr, cli
+}
+
+func receive(t *testing.T, w *redisdb.Watcher) redisdb.Event {
+	t.Helper()
+
+	select {
+	case e := <-w.Events():
+		return e
+	case <-time.After(time.Second):
+		require.FailNow(t, "no event received")
+		return redisdb.Event{}
+	}
+}
+
+func TestWatch(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("receives the events of the types watched", func(t *testing.T) {
+		mr, cli := newWatchedServer(t, 2)
+		w, err := redisdb.Watch(ctx, cli, "presence:*", redisdb.EventExpired, redisdb.EventDel)
+		require.NoError(t, err)
+		t.Cleanup(func() { w.Close() })
+
+		mr.Publish("__keyspace@2__:presence:u1", "set")
+		mr.Publish("__keyspace@2__:presence:u1", "expired")
+		mr.Publish("__keyspace@2__:sessions:s1", "del")
+		mr.Publish("__keyspace@2__:presence:u2", "del")
+
+		assert.Equal(t, redisdb.Event{Type: redisdb.EventExpired, Key: "presence:u1", DB: 2}, receive(t, w))
+		assert.Equal(t, redisdb.Event{Type: redisdb.EventDel, Key: "presence:u2", DB: 2}, receive(t, w))
+	})
+
+	t.Run("subscribes again when the connection is lost", func(t *testing.T) {
+		mr, cli := newWatchedServer(t, 0)
+		w, err := redisdb.Watch(ctx, cli, "presence:*")
+		require.NoError(t, err)
+		t.Cleanup(func() { w.Close() })
+
+		mr.Close()
+		require.NoError(t, mr.Restart())
+
+		assert.Eventually(t, func() bool {
+			return mr.Publish("__keyspace@0__:presence:u1", "evicted") > 0
+		}, 5*time.Second, 10*time.Millisecond)
+		assert.Equal(t, redisdb.Event{Type: redisdb.EventEvicted, Key: "presence:u1"}, receive(t, w))
+	})
+
+	t.Run("closes the events once closed", func(t *testing.T) {
+		_, cli := newWatchedServer(t, 0)
+		w, err := redisdb.Watch(ctx, cli, "presence:*")
+		require.NoError(t, err)
+
+		require.NoError(t, w.Close())
+		_, ok := <-w.Events()
+		assert.False(t, ok)
+	})
+}
+
+func TestEventDispatcher(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	mr, cli := newWatchedServer(t, 0)
+	w, err := redisdb.Watch(ctx, cli, "presence:*")
+	require.NoError(t, err)
+
+	expired, all := make(chan string, 10), make(chan string, 10)
+	d := redisdb.NewEventDispatcher().
+		Handle(func(_ context.Context, e redisdb.Event) { expired <- e.Key }, redisdb.EventExpired, redisdb.EventEvicted).
+		Handle(func(_ context.Context, e redisdb.Event) { all <- string(e.Type) })
+	done := make(chan error)
+	go func() { done <- d.Run(ctx, w) }()
+
+	mr.Publish("__keyspace@0__:presence:u1", "set")
+	mr.Publish("__keyspace@0__:presence:u1", "expired")
+	assert.Equal(t, "set", <-all)
+	assert.Equal(t, "presence:u1", <-expired)
+	assert.Equal(t, "expired", <-all)
+
+	require.NoError(t, w.Close())
+	assert.NoError(t, <-done)
+}
@@ -0,0 +1,344 @@
+package sqldb
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/lib/pq"
+
+	"github.com/dosanma1/forge/go/kit/application/repository"
+	"github.com/dosanma1/forge/go/kit/errors"
+	"github.com/dosanma1/forge/go/kit/filter"
+	"github.com/dosanma1/forge/go/kit/search/query"
+)
+
+// Builder translates queries into SQL statements of a dialect, to be run on
+// a Querier:
+//
+//	b := sqldb.NewBuilder(sqldb.DialectPostgres, map[string]string{"createdAt": "created_at"})
+//	stmt, args, err := b.Select(ctx, "articles", []string{"id", "title"}, q)
+//	rows, err := sqldb.GetTx(ctx, db).QueryContext(ctx, stmt, args...)
+//
+// Fields found in the field mapper are rendered as their mapped column, as
+// is; any other field is quoted as an identifier.
+type Builder struct {
+	dialect Dialect
+	fMapper map[string]string
+}
+
+// NewBuilder returns a builder rendering dialect SQL. fMapper maps the query
+// field names to their columns and may be nil.
+func NewBuilder(dialect Dialect, fMapper map[string]string) *Builder {
+	if dialect == nil {
+		dialect = DialectPostgres
+	}
+
+	return &Builder{dialect: dialect, fMapper: maps.Clone(fMapper)}
+}
+
+// Dialect returns the dialect of the builder.
+func (b *Builder) Dialect() Dialect {
+	return b.dialect
+}
+
+// Column returns the SQL column of a query field.
+func (b *Builder) Column(field string) string {
+	if col, ok := b.fMapper[field]; ok {
+		return col
+	}
+
+	return b.dialect.Quote(field)
+}
+
+// Select renders a SELECT of columns from table honoring the filters,
+// sorting and offset pagination of q, and locking the rows when the context
+// carries a row lock, see repository.WithLockingCtx. Cursor pagination is
+// not supported.
+func (b *Builder) Select(ctx context.Context, table string, columns []string, q query.Query) (string, []any, error) {
+	return b.selectSQL(ctx, table, columns, q)
+}
+
+// Count renders a SELECT COUNT(*) of the rows of table matching the filters
+// of q.
+func (b *Builder) Count(table string, q query.Query) (string, []any, error) {
+	return b.countSQL(table, q)
+}
+
+func (b *Builder) countSQL(table string, q query.Query, conds ...string) (string, []any, error) {
+	w := b.writer(0)
+	w.WriteString("SELECT COUNT(*) FROM ")
+	w.WriteString(b.dialect.Quote(table))
+	if err := w.where(q, conds...); err != nil {
+		return "", nil, err
+	}
+
+	return w.String(), w.args, nil
+}
+
+// Where renders the filters of q as a condition, without the WHERE keyword,
+// numbering its placeholders after argOffset arguments. It returns an empty
+// condition when q has no filters.
+func (b *Builder) Where(q query.Query, argOffset int) (string, []any, error) {
+	w := b.writer(argOffset)
+	tree := query.FilterTree(q)
+	if tree == nil {
+		return "", nil, nil
+	}
+	if err := w.expr(tree); err != nil {
+		return "", nil, err
+	}
+
+	return w.String(), w.args, nil
+}
+
+func (b *Builder) selectSQL(ctx context.Context, table string, columns []string, q query.Query, conds ...string) (string, []any, error) {
+	w := b.writer(0)
+	w.WriteString("SELECT ")
+	if len(columns) == 0 {
+		w.WriteString("*")
+	}
+	for i, c := range columns {
+		if i > 0 {
+			w.WriteString(", ")
+		}
+		w.WriteString(b.dialect.Quote(c))
+	}
+	w.WriteString(" FROM ")
+	w.WriteString(b.dialect.Quote(table))
+	if err := w.where(q, conds...); err != nil {
+		return "", nil, err
+	}
+	if q != nil {
+		if q.CursorPagination() != nil {
+			return "", nil, errors.InvalidArgument("cursor pagination is not supported")
+		}
+		if err := w.orderBy(q.Sorting()); err != nil {
+			return "", nil, err
+		}
+		w.paginate(q.Pagination())
+	}
+	if lock := b.dialect.Lock(repository.LockFromCtx(ctx)); lock != "" {
+		w.WriteString(" ")
+		w.WriteString(lock)
+	}
+
+	return w.String(), w.args, nil
+}
+
+func (b *Builder) writer(argOffset int) *sqlWriter {
+	return &sqlWriter{b: b, offset: argOffset}
+}
+
+// sqlWriter accumulates a statement and its arguments, numbering the
+// placeholders as they are written.
+type sqlWriter struct {
+	strings.Builder
+	b      *Builder
+	args   []any
+	offset int
+}
+
+func (w *sqlWriter) arg(v any) string {
+	w.args = append(w.args, v)
+	return w.b.dialect.Placeholder(w.offset + len(w.args))
+}
+
+// where writes the WHERE clause of the filters of q and of the extra raw
+// conditions.
+func (w *sqlWriter) where(q query.Query, conds ...string) error {
+	var tree *query.FilterNode
+	if q != nil {
+		tree = query.FilterTree(q)
+	}
+	if err := tree.Err(); err != nil {
+		return err
+	}
+	if tree == nil && len(conds) == 0 {
+		return nil
+	}
+
+	w.WriteString(" WHERE ")
+	for i, c := range conds {
+		if i > 0 {
+			w.WriteString(" AND ")
+		}
+		w.WriteString(c)
+	}
+	if tree == nil {
+		return nil
+	}
+	if len(conds) > 0 {
+		w.WriteString(" AND ")
+		if !tree.IsLeaf() && tree.Op != query.BoolNot {
+			w.WriteString("(")
+			defer w.WriteString(")")
+		}
+	}
+
+	return w.expr(tree)
+}
+
+// expr writes a filter expression, parenthesizing every nested group so that
+// the tree structure survives SQL operator precedence.
+func (w *sqlWriter) expr(node *query.FilterNode) error {
+	if node.IsLeaf() {
+		return w.cond(node.Filter)
+	}
+	if node.Op == query.BoolNot {
+		w.WriteString("NOT (")
+		if err := w.expr(query.And(node.Children...)); err != nil {
+			return err
+		}
+		w.WriteString(")")
+		return nil
+	}
+
+	sep := " AND "
+	if node.Op == query.BoolOr {
+		sep = " OR "
+	}
+	for i, child := range node.Children {
+		if i > 0 {
+			w.WriteString(sep)
+		}
+		nested := !child.IsLeaf() && child.Op != query.BoolNot
+		if nested {
+			w.WriteString("(")
+		}
+		if err := w.expr(child); err != nil {
+			return err
+		}
+		if nested {
+			w.WriteString(")")
+		}
+	}
+
+	return nil
+}
+
+func (w *sqlWriter) cond(f filter.FieldFilter[any]) error {
+	op := f.Operator()
+	if !w.b.dialect.Supports(op) {
+		return errors.InvalidArgument(fmt.Sprintf("operator %s is not supported", operatorName(op)))
+	}
+	col := w.b.Column(f.Name())
+
+	switch op {
+	case filter.OpIs, filter.OpIsNot:
+		if f.Value() == nil {
+			fmt.Fprintf(w, "%s %s NULL", col, op)
+			return nil
+		}
+		fmt.Fprintf(w, "%s %s %s", col, op, w.arg(f.Value()))
+	case filter.OpLike:
+		fmt.Fprintf(w, "%s LIKE %s", col, w.arg(fmt.Sprintf("%%%v%%", f.Value())))
+	case filter.OpIn, filter.OpNotIn:
+		vals := sliceValues(f.Value())
+		if vals == nil && f.Value() != nil {
+			vals = []any{f.Value()}
+		}
+		if len(vals) == 0 {
+			// an empty IN list is a syntax error
+			if op == filter.OpIn {
+				w.WriteString("1 = 0")
+			} else {
+				w.WriteString("1 = 1")
+			}
+			return nil
+		}
+		fmt.Fprintf(w, "%s %s (", col, op)
+		for i, v := range vals {
+			if i > 0 {
+				w.WriteString(", ")
+			}
+			w.WriteString(w.arg(v))
+		}
+		w.WriteString(")")
+	case filter.OpBetween:
+		vals := sliceValues(f.Value())
+		if len(vals) != 2 {
+			return errors.InvalidArgument(fmt.Sprintf("between filter on %s requires two values", f.Name()))
+		}
+		fmt.Fprintf(w, "%s BETWEEN %s AND %s", col, w.arg(vals[0]), w.arg(vals[1]))
+	case filter.OpContains:
+		vals := sliceValues(f.Value())
+		if vals == nil {
+			vals = []any{f.Value()}
+		}
+		fmt.Fprintf(w, "%s @> %s", col, w.arg(pq.Array(vals)))
+	default:
+		fmt.Fprintf(w, "%s %s %s", col, comparison(op), w.arg(f.Value()))
+	}
+
+	return nil
+}
+
+func (w *sqlWriter) orderBy(sorting *query.SortingParams) error {
+	if sorting == nil || len(sorting.Keys()) == 0 {
+		return nil
+	}
+
+	w.WriteString(" ORDER BY ")
+	for i, key := range sorting.Keys() {
+		if key == query.SortRelevance {
+			return errors.InvalidArgument("sorting by relevance is not supported")
+		}
+		if i > 0 {
+			w.WriteString(", ")
+		}
+		fmt.Fprintf(w, "%s %s", w.b.Column(key), sorting.Get(key))
+	}
+
+	return nil
+}
+
+func (w *sqlWriter) paginate(p *query.PaginationParams) {
+	if p == nil {
+		return
+	}
+	if p.Limit > 0 {
+		w.WriteString(" LIMIT ")
+		w.WriteString(strconv.Itoa(p.Limit))
+	}
+	if p.Offset > 0 {
+		w.WriteString(" OFFSET ")
+		w.WriteString(strconv.Itoa(p.Offset))
+	}
+}
+
+func comparison(op filter.Operator) string {
+	switch op {
+	case filter.OpNEq:
+		return "<>"
+	case filter.OpEq:
+		return "="
+	default:
+		return op.String()
+	}
+}
+
+func operatorName(op filter.Operator) string {
+	if name := query.MarshalOperator(op); name != "" {
+		return name
+	}
+	return op.String()
+}
+
+// sliceValues returns the elements of a slice or array value, nil for any
+// other value.
+func sliceValues(v any) []any {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Slice && rv.Kind() != reflect.Array {
+		return nil
+	}
+	vals := make([]any, rv.Len())
+	for i := range vals {
+		vals[i] = rv.Index(i).Interface()
+	}
+
+	return vals
+}
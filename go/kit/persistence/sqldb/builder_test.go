@@ -0,0 +1,154 @@
+package sqldb_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/dosanma1/forge/go/kit/application/repository"
+	"github.com/dosanma1/forge/go/kit/filter"
+	"github.com/dosanma1/forge/go/kit/persistence/sqldb"
+	"github.com/dosanma1/forge/go/kit/search/query"
+)
+
+func TestBuilderSelect(t *testing.T) {
+	fMapper := map[string]string{"createdAt": "created_at"}
+	lockCtx := repository.WithLockingCtx(context.Background(), repository.LockLevelRow, repository.LockModeExclusive)
+
+	tests := []struct {
+		name     string
+		ctx      context.Context
+		dialect  sqldb.Dialect
+		q        query.Query
+		wantSQL  string
+		wantArgs []any
+		errMsg   string
+	}{
+		{
+			name:    "no query",
+			dialect: sqldb.DialectPostgres,
+			wantSQL: `SELECT "id", "title" FROM "articles"`,
+		},
+		{
+			name:    "filters sorting and pagination",
+			dialect: sqldb.DialectPostgres,
+			q: query.New(
+				query.FilterBy(filter.OpEq, "title", "go"),
+				query.SortBy("createdAt", query.SortDesc),
+				query.Pagination(10, 20),
+			),
+			wantSQL:  `SELECT "id", "title" FROM "articles" WHERE "title" = $1 ORDER BY created_at DESC LIMIT 10 OFFSET 20`,
+			wantArgs: []any{"go"},
+		},
+		{
+			name:    "filter groups",
+			dialect: sqldb.DialectMySQL,
+			q: query.New(query.Where(query.Or(
+				query.Cond(filter.OpIn, "id", []string{"a", "b"}),
+				query.And(
+					query.Cond(filter.OpLike, "title", "go"),
+					query.Cond(filter.OpIs, "createdAt", nil),
+				),
+			))),
+			wantSQL:  "SELECT `id`, `title` FROM `articles` WHERE `id` IN (?, ?) OR (`title` LIKE ? AND created_at IS NULL)",
+			wantArgs: []any{"a", "b", "%go%"},
+		},
+		{
+			name:    "between and not",
+			dialect: sqldb.DialectSQLite,
+			q: query.New(query.Where(
+				query.Cond(filter.OpBetween, "createdAt", []string{"2024-01-01", "2024-12-31"}),
+				query.Not(query.Cond(filter.OpEq, "title", "draft")),
+			)),
+			wantSQL:  `SELECT "id", "title" FROM "articles" WHERE created_at BETWEEN ? AND ? AND NOT ("title" = ?)`,
+			wantArgs: []any{"2024-01-01", "2024-12-31", "draft"},
+		},
+		{
+			name:     "row lock",
+			ctx:      lockCtx,
+			dialect:  sqldb.DialectPostgres,
+			q:        query.New(query.FilterBy(filter.OpEq, "id", "a")),
+			wantSQL:  `SELECT "id", "title" FROM "articles" WHERE "id" = $1 FOR UPDATE`,
+			wantArgs: []any{"a"},
+		},
+		{
+			name: "row lock skipping locked rows",
+			ctx: repository.WithLock(context.Background(), repository.NewLock(
+				repository.LockLevelRow, []repository.LockMode{repository.LockModeNoKeyUpdate}, repository.SkipLocked(),
+			)),
+			dialect: sqldb.DialectPostgres,
+			wantSQL: `SELECT "id", "title" FROM "articles" FOR NO KEY UPDATE SKIP LOCKED`,
+		},
+		{
+			name: "mysql key share without waiting",
+			ctx: repository.WithLock(context.Background(), repository.NewLock(
+				repository.LockLevelRow, []repository.LockMode{repository.LockModeKeyShare}, repository.NoWait(),
+			)),
+			dialect: sqldb.DialectMySQL,
+			wantSQL: "SELECT `id`, `title` FROM `articles` FOR SHARE NOWAIT",
+		},
+		{
+			name:    "sqlite ignores row locks",
+			ctx:     lockCtx,
+			dialect: sqldb.DialectSQLite,
+			wantSQL: `SELECT "id", "title" FROM "articles"`,
+		},
+		{
+			name:     "quotes unmapped fields",
+			dialect:  sqldb.DialectPostgres,
+			q:        query.New(query.FilterBy(filter.OpEq, `x" OR 1=1 --`, "a")),
+			wantSQL:  `SELECT "id", "title" FROM "articles" WHERE "x"" OR 1=1 --" = $1`,
+			wantArgs: []any{"a"},
+		},
+		{
+			name:    "unsupported operator",
+			dialect: sqldb.DialectMySQL,
+			q:       query.New(query.FilterBy(filter.OpContains, "tags", []string{"go"})),
+			errMsg:  "operator any is not supported",
+		},
+		{
+			name:    "cursor pagination",
+			dialect: sqldb.DialectPostgres,
+			q:       query.New(query.CursorPage(10, "", query.CursorAfter)),
+			errMsg:  "cursor pagination is not supported",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := tt.ctx
+			if ctx == nil {
+				ctx = context.Background()
+			}
+			stmt, args, err := sqldb.NewBuilder(tt.dialect, fMapper).Select(ctx, "articles", []string{"id", "title"}, tt.q)
+			if tt.errMsg != "" {
+				require.Error(t, err)
+				assert.Contains(t, err.Error(), tt.errMsg)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.wantSQL, stmt)
+			assert.Equal(t, tt.wantArgs, args)
+		})
+	}
+}
+
+func TestBuilderWhere(t *testing.T) {
+	b := sqldb.NewBuilder(sqldb.DialectPostgres, nil)
+
+	cond, args, err := b.Where(query.New(query.FilterBy(filter.OpGT, "views", 10)), 2)
+	require.NoError(t, err)
+	assert.Equal(t, `"views" > $3`, cond)
+	assert.Equal(t, []any{10}, args)
+
+	cond, args, err = b.Where(query.New(), 0)
+	require.NoError(t, err)
+	assert.Empty(t, cond)
+	assert.Empty(t, args)
+
+	stmt, args, err := b.Count("articles", query.New(query.FilterBy(filter.OpNEq, "title", "x")))
+	require.NoError(t, err)
+	assert.Equal(t, `SELECT COUNT(*) FROM "articles" WHERE "title" <> $1`, stmt)
+	assert.Equal(t, []any{"x"}, args)
+}
@@ -33,7 +33,8 @@ func WithMaxIdleConns(limit int) ConnectionOption {
 	}
 }
 
-// WithDBSchema sets the database schema search path for PostgreSQL.
+// WithDBSchema sets the database schema search path for PostgreSQL, other
+// databases reject it.
 // The schema is added to the search path along with 'public'.
 // If schema is empty or whitespace, this option does nothing.
 func WithDBSchema(schema string) ConnectionOption {
@@ -62,7 +63,11 @@ func Connect(connURL *url.URL) (*sql.DB, error) {
 		return nil, newErrConnInvalidDriver(driverType)
 	}
 
-	db, err := sql.Open(string(driverType), connURL.String())
+	driverDSN, err := DriverDSN(connURL)
+	if err != nil {
+		return nil, err
+	}
+	db, err := sql.Open(string(driverType), driverDSN)
 	if err != nil {
 		fmt.Println("[DEBUG] sql.Open error:", err)
 		fmt.Println("[DEBUG] DSN:", connURL.String())
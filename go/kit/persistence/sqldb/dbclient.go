@@ -7,12 +7,29 @@ import (
 
 // DBClient wraps a *sql.DB and provides additional functionality
 type DBClient struct {
-	db *sql.DB
+	db       *sql.DB
+	replicas *ReplicaSet
+}
+
+// DBClientOption configures a DBClient.
+type DBClientOption func(c *DBClient)
+
+// WithReplicaSet routes the queries of the client to the read replicas of
+// rs, see ReplicaSet. The client closes rs on Close.
+func WithReplicaSet(rs *ReplicaSet) DBClientOption {
+	return func(c *DBClient) {
+		c.replicas = rs
+	}
 }
 
 // NewDBClient creates a new DBClient from a *sql.DB
-func NewDBClient(db *sql.DB) *DBClient {
-	return &DBClient{db: db}
+func NewDBClient(db *sql.DB, opts ...DBClientOption) *DBClient {
+	c := &DBClient{db: db}
+	for _, opt := range opts {
+		opt(c)
+	}
+
+	return c
 }
 
 // DB returns the underlying *sql.DB
@@ -20,19 +37,43 @@ func (c *DBClient) DB() *sql.DB {
 	return c.db
 }
 
+// Replicas returns the replica set of the client, nil when it has none.
+func (c *DBClient) Replicas() *ReplicaSet {
+	return c.replicas
+}
+
 // Exec executes a query without returning rows
 func (c *DBClient) Exec(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
-	return c.db.ExecContext(ctx, query, args...)
+	if c.replicas == nil {
+		return c.db.ExecContext(ctx, query, args...)
+	}
+
+	res, err := c.replicas.Writer(ctx).ExecContext(ctx, query, args...)
+	if err == nil {
+		MarkWritten(ctx)
+	}
+
+	return res, err
 }
 
-// Query executes a query that returns rows
+// Query executes a query that returns rows, on a read replica when the client
+// has any.
 func (c *DBClient) Query(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error) {
-	return c.db.QueryContext(ctx, query, args...)
+	if c.replicas == nil {
+		return c.db.QueryContext(ctx, query, args...)
+	}
+
+	return c.replicas.Reader(ctx).QueryContext(ctx, query, args...)
 }
 
-// QueryRow executes a query that returns at most one row
+// QueryRow executes a query that returns at most one row, on a read replica
+// when the client has any.
 func (c *DBClient) QueryRow(ctx context.Context, query string, args ...interface{}) *sql.Row {
-	return c.db.QueryRowContext(ctx, query, args...)
+	if c.replicas == nil {
+		return c.db.QueryRowContext(ctx, query, args...)
+	}
+
+	return c.replicas.Reader(ctx).QueryRowContext(ctx, query, args...)
 }
 
 // Begin starts a new transaction with default options.
@@ -45,8 +86,14 @@ func (c *DBClient) BeginTx(ctx context.Context, opts *sql.TxOptions) (*sql.Tx, e
 	return c.db.BeginTx(ctx, opts)
 }
 
-// Close closes the database connection.
+// Close closes the database connection and the replicas, if any.
 func (c *DBClient) Close() error {
+	if c.replicas != nil {
+		if err := c.replicas.Close(); err != nil {
+			return err
+		}
+	}
+
 	return c.db.Close()
 }
 
@@ -0,0 +1,165 @@
+package sqldb
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/dosanma1/forge/go/kit/application/repository"
+	"github.com/dosanma1/forge/go/kit/filter"
+)
+
+// Dialect renders the parts of a SQL statement that differ between
+// databases.
+type Dialect interface {
+	// Placeholder returns the placeholder of the n-th argument, starting at 1.
+	Placeholder(n int) string
+	// Quote quotes an identifier.
+	Quote(ident string) string
+	// Lock returns the row locking clause of a SELECT, including its wait
+	// policy, empty when the lock is not supported.
+	Lock(l repository.Lock) string
+	// Supports reports whether the filter operator can be rendered.
+	Supports(op filter.Operator) bool
+	// Returning reports whether INSERT and UPDATE support RETURNING.
+	Returning() bool
+}
+
+var (
+	// DialectPostgres renders $n placeholders and "quoted" identifiers.
+	DialectPostgres Dialect = postgresDialect{}
+	// DialectMySQL renders ? placeholders and `quoted` identifiers.
+	DialectMySQL Dialect = mysqlDialect{}
+	// DialectSQLite renders ? placeholders and "quoted" identifiers.
+	DialectSQLite Dialect = sqliteDialect{}
+)
+
+type postgresDialect struct{}
+
+func (postgresDialect) Placeholder(n int) string {
+	return "$" + strconv.Itoa(n)
+}
+
+func (postgresDialect) Quote(ident string) string {
+	return quoteIdent(ident, `"`)
+}
+
+func (postgresDialect) Lock(l repository.Lock) string {
+	if l == nil || l.Level() != repository.LockLevelRow {
+		return ""
+	}
+
+	strength := ""
+	switch {
+	case l.Contains(repository.LockModeExclusive):
+		strength = "UPDATE"
+	case l.Contains(repository.LockModeNoKeyUpdate):
+		strength = "NO KEY UPDATE"
+	case l.Contains(repository.LockModeShare):
+		strength = "SHARE"
+	case l.Contains(repository.LockModeKeyShare):
+		strength = "KEY SHARE"
+	}
+
+	return rowLock(strength, repository.LockWaitOf(l))
+}
+
+func (postgresDialect) Supports(op filter.Operator) bool {
+	return basicOperator(op) || op == filter.OpContains
+}
+
+func (postgresDialect) Returning() bool {
+	return true
+}
+
+type mysqlDialect struct{}
+
+func (mysqlDialect) Placeholder(int) string {
+	return "?"
+}
+
+func (mysqlDialect) Quote(ident string) string {
+	return quoteIdent(ident, "`")
+}
+
+// Lock returns the FOR UPDATE or FOR SHARE clause of the lock, MySQL has no
+// key locks so that NO KEY UPDATE and KEY SHARE fall back to them.
+func (mysqlDialect) Lock(l repository.Lock) string {
+	if l == nil || l.Level() != repository.LockLevelRow {
+		return ""
+	}
+
+	strength := ""
+	switch {
+	case l.Contains(repository.LockModeExclusive), l.Contains(repository.LockModeNoKeyUpdate):
+		strength = "UPDATE"
+	case l.Contains(repository.LockModeShare), l.Contains(repository.LockModeKeyShare):
+		strength = "SHARE"
+	}
+
+	return rowLock(strength, repository.LockWaitOf(l))
+}
+
+func (mysqlDialect) Supports(op filter.Operator) bool {
+	return basicOperator(op)
+}
+
+func (mysqlDialect) Returning() bool {
+	return false
+}
+
+type sqliteDialect struct{}
+
+func (sqliteDialect) Placeholder(int) string {
+	return "?"
+}
+
+func (sqliteDialect) Quote(ident string) string {
+	return quoteIdent(ident, `"`)
+}
+
+// Lock returns no clause: SQLite locks the whole database on write.
+func (sqliteDialect) Lock(repository.Lock) string {
+	return ""
+}
+
+func (sqliteDialect) Supports(op filter.Operator) bool {
+	return basicOperator(op)
+}
+
+func (sqliteDialect) Returning() bool {
+	return true
+}
+
+func basicOperator(op filter.Operator) bool {
+	switch op {
+	case filter.OpEq, filter.OpNEq, filter.OpGT, filter.OpGTEq, filter.OpLT, filter.OpLTEq,
+		filter.OpIn, filter.OpNotIn, filter.OpLike, filter.OpBetween, filter.OpIs, filter.OpIsNot:
+		return true
+	default:
+		return false
+	}
+}
+
+// rowLock returns the FOR clause of the lock strength and wait policy, empty
+// without strength. Table and advisory locks are not rendered.
+func rowLock(strength string, wait repository.LockWait) string {
+	if strength == "" {
+		return ""
+	}
+	if wait == repository.LockWaitBlock {
+		return "FOR " + strength
+	}
+
+	return "FOR " + strength + " " + string(wait)
+}
+
+// quoteIdent quotes every part of a possibly qualified identifier, e.g.
+// users.id, doubling the quotes they contain.
+func quoteIdent(ident, quote string) string {
+	parts := strings.Split(ident, ".")
+	for i, p := range parts {
+		parts[i] = quote + strings.ReplaceAll(p, quote, quote+quote) + quote
+	}
+
+	return strings.Join(parts, ".")
+}
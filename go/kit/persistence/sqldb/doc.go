@@ -2,9 +2,13 @@
 //
 // It includes functionality for:
 //   - Connection management (pooling, health checks) via Configurable DSN parameters
+//   - PostgreSQL, MySQL and SQLite (pure Go, no cgo) drivers
 //   - Transaction management (Begin, Commit, Rollback, context propagation)
+//   - Read replica routing with health checks and read-your-writes (ReplicaSet)
 //   - Prepared statement registry for performance optimization
 //   - High-performance connection pool configuration presets
+//   - Dialect aware translation of query.Query into SQL statements (Builder)
+//   - Scanning of rows into structs by their db tags and a generic CRUD repository
 //
 // The package is designed to work with the "database/sql" standard library and provides
 // a "DBClient" wrapper to extend functionality with project-specific patterns.
@@ -17,6 +17,16 @@ func TestDriverTypeValid(t *testing.T) {
 			driver: DriverTypePostgres,
 			want:   true,
 		},
+		{
+			name:   "mysql is valid",
+			driver: DriverTypeMySQL,
+			want:   true,
+		},
+		{
+			name:   "sqlite is valid",
+			driver: DriverTypeSQLite,
+			want:   true,
+		},
 		{
 			name:   "empty is invalid",
 			driver: "",
@@ -24,7 +34,7 @@ func TestDriverTypeValid(t *testing.T) {
 		},
 		{
 			name:   "unknown is invalid",
-			driver: "mysql",
+			driver: "oracle",
 			want:   false,
 		},
 	}
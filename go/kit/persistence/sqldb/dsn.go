@@ -48,20 +48,54 @@ func (d dsn) add(f connField, val string) error {
 	return nil
 }
 
-func (d dsn) buildQueryParams() url.Values {
+func (d dsn) buildQueryParams(driverType DriverType) url.Values {
 	vals := url.Values{}
-	vals.Add(connFieldSSLMode.String(), d[connFieldSSLMode])
+	switch driverType {
+	case DriverTypeMySQL:
+		if tls := mysqlTLS(d[connFieldSSLMode]); tls != "" {
+			vals.Add("tls", tls)
+		}
+	case DriverTypeSQLite:
+	default:
+		vals.Add(connFieldSSLMode.String(), d[connFieldSSLMode])
 
-	// lib/pq uses 'options' to pass session-level parameters like search_path
-	if searchPath := d[connFieldSearchPath]; searchPath != "" {
-		vals.Add("options", fmt.Sprintf("-c %s=%s", connFieldSearchPath.String(), searchPath))
+		// lib/pq uses 'options' to pass session-level parameters like search_path
+		if searchPath := d[connFieldSearchPath]; searchPath != "" {
+			vals.Add("options", fmt.Sprintf("-c %s=%s", connFieldSearchPath.String(), searchPath))
+		}
 	}
 
 	return vals
 }
 
-func (d dsn) validateConnFields() error {
-	for _, f := range orderedConnFields {
+// mysqlTLS maps the postgres like ssl modes to the tls parameter of the
+// mysql driver.
+func mysqlTLS(sslMode string) string {
+	switch sslMode {
+	case "require":
+		return "skip-verify"
+	case "verify-ca", "verify-full":
+		return "true"
+	default:
+		return ""
+	}
+}
+
+// requiredConnFields returns the fields the DSN of a driver cannot do
+// without: sqlite only needs the database file.
+func requiredConnFields(driverType DriverType) []connField {
+	switch driverType {
+	case DriverTypeSQLite:
+		return []connField{connFieldDBName}
+	case DriverTypeMySQL:
+		return []connField{connFieldHost, connFieldPort, connFieldDBName, connFieldUser, connFieldPwd}
+	default:
+		return orderedConnFields
+	}
+}
+
+func (d dsn) validateConnFields(driverType DriverType) error {
+	for _, f := range requiredConnFields(driverType) {
 		if d[f] == "" {
 			return newErrEmptyDSNField(f)
 		}
@@ -70,16 +104,23 @@ func (d dsn) validateConnFields() error {
 }
 
 func (d dsn) genConnectionURL(driverType DriverType) (*url.URL, error) {
-	if err := d.validateConnFields(); err != nil {
+	if err := d.validateConnFields(driverType); err != nil {
 		return nil, err
 	}
 
+	if driverType == DriverTypeSQLite {
+		return &url.URL{
+			Scheme: string(driverType),
+			Opaque: d[connFieldDBName],
+		}, nil
+	}
+
 	return &url.URL{
 		Scheme:   string(driverType),
 		User:     url.UserPassword(d[connFieldUser], d[connFieldPwd]),
 		Path:     d[connFieldDBName],
 		Host:     net.JoinHostPort(d[connFieldHost], d[connFieldPort]),
-		RawQuery: d.buildQueryParams().Encode(),
+		RawQuery: d.buildQueryParams(driverType).Encode(),
 	}, nil
 }
 
@@ -112,7 +153,8 @@ func WithConnPwd(pwd string) ConnectionDSNOption {
 	return withConnStringVal(connFieldPwd, pwd)
 }
 
-// WithConnDBName sets the database name in the DSN.
+// WithConnDBName sets the database name in the DSN, or the database file path
+// for sqlite.
 func WithConnDBName(dbName string) ConnectionDSNOption {
 	return withConnStringVal(connFieldDBName, dbName)
 }
@@ -1,11 +1,27 @@
 package sqldb
 
 import (
+	stderrors "errors"
 	"fmt"
 
+	"github.com/go-sql-driver/mysql"
+
 	"github.com/dosanma1/forge/go/kit/errors"
 )
 
+const (
+	sqlStateSerializationFailure = "40001"
+	sqlStateDeadlockDetected     = "40P01"
+	sqlStateLockNotAvailable     = "55P03"
+	sqlStateUniqueViolation      = "23505"
+	sqliteConstraintUnique       = 2067
+	sqliteConstraintPrimaryKey   = 1555
+	mysqlErrDuplicateEntry       = 1062
+	mysqlErrDeadlock             = 1213
+	mysqlErrLockWaitTimeout      = 1205
+	mysqlErrLockNowait           = 3572
+)
+
 func newErrConnEmptyDSN() error {
 	return errors.New(errors.CodeConfigurationError, errors.WithMessage("connection DSN cannot be empty"))
 }
@@ -25,3 +41,68 @@ func newErrEmptyDSNField(field connField) error {
 func NewErrEmptyDBConnection() error {
 	return errors.New(errors.CodeConfigurationError, errors.WithMessage("empty sql.DB connection handle"))
 }
+
+func newErrQuery(err error) error {
+	if IsLockNotAvailable(err) {
+		return NewErrLockNotAvailable(err)
+	}
+	return errors.Wrap(err, errors.CodeDatabaseError, errors.WithMessage("query failed"))
+}
+
+// NewErrLockNotAvailable returns the conflict error of a query which could
+// not get its lock, see IsLockNotAvailable.
+func NewErrLockNotAvailable(err error) error {
+	return errors.Wrap(err, errors.CodeConflict, errors.WithMessage("lock not available"))
+}
+
+// IsSerializationFailure reports whether err is a serialization failure or a
+// deadlock, SQLSTATE 40001 or 40P01, after which the transaction can be run
+// again, see persistence.WithRetry.
+func IsSerializationFailure(err error) bool {
+	var stateErr interface{ SQLState() string }
+	if stderrors.As(err, &stateErr) {
+		state := stateErr.SQLState()
+		return state == sqlStateSerializationFailure || state == sqlStateDeadlockDetected
+	}
+	var mysqlErr *mysql.MySQLError
+	if stderrors.As(err, &mysqlErr) {
+		return mysqlErr.Number == mysqlErrDeadlock || string(mysqlErr.SQLState[:]) == sqlStateSerializationFailure
+	}
+
+	return false
+}
+
+// IsLockNotAvailable reports whether err is a lock request failing without
+// waiting, e.g. NOWAIT, or timing out, SQLSTATE 55P03.
+func IsLockNotAvailable(err error) bool {
+	var stateErr interface{ SQLState() string }
+	if stderrors.As(err, &stateErr) {
+		return stateErr.SQLState() == sqlStateLockNotAvailable
+	}
+	var mysqlErr *mysql.MySQLError
+	if stderrors.As(err, &mysqlErr) {
+		return mysqlErr.Number == mysqlErrLockNowait || mysqlErr.Number == mysqlErrLockWaitTimeout
+	}
+
+	return false
+}
+
+// IsUniqueViolation reports whether err is a statement violating a unique
+// constraint, SQLSTATE 23505.
+func IsUniqueViolation(err error) bool {
+	var stateErr interface{ SQLState() string }
+	if stderrors.As(err, &stateErr) {
+		return stateErr.SQLState() == sqlStateUniqueViolation
+	}
+	var mysqlErr *mysql.MySQLError
+	if stderrors.As(err, &mysqlErr) {
+		return mysqlErr.Number == mysqlErrDuplicateEntry
+	}
+	var sqliteErr interface{ Code() int }
+	if stderrors.As(err, &sqliteErr) {
+		code := sqliteErr.Code()
+		return code == sqliteConstraintUnique || code == sqliteConstraintPrimaryKey
+	}
+
+	return false
+}
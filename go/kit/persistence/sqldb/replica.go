@@ -0,0 +1,312 @@
+package sqldb
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"math/rand/v2"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	defaultHealthCheckInterval = 5 * time.Second
+	defaultHealthCheckTimeout  = 2 * time.Second
+)
+
+// PostgresReplicaLagQuery returns the replication lag of a PostgreSQL
+// standby in seconds, 0 on a primary.
+const PostgresReplicaLagQuery = `SELECT COALESCE(EXTRACT(EPOCH FROM now() - pg_last_xact_replay_timestamp()), 0)`
+
+// Replica is a read replica of the primary database. Replicas are picked at
+// random proportionally to their weight, a weight <= 0 counts as 1.
+type Replica struct {
+	DB     *sql.DB
+	Weight int
+}
+
+type (
+	ReplicaOption func(c *replicaConfig)
+	replicaConfig struct {
+		interval time.Duration
+		timeout  time.Duration
+		maxLag   time.Duration
+		lagQuery string
+	}
+)
+
+// WithHealthCheckInterval sets how often the replicas are health checked in
+// the background, a non positive interval disables the background checks.
+// Defaults to 5s.
+func WithHealthCheckInterval(d time.Duration) ReplicaOption {
+	return func(c *replicaConfig) {
+		c.interval = d
+	}
+}
+
+// WithHealthCheckTimeout bounds every replica health check. Defaults to 2s.
+func WithHealthCheckTimeout(d time.Duration) ReplicaOption {
+	return func(c *replicaConfig) {
+		c.timeout = d
+	}
+}
+
+// WithMaxReplicaLag takes the replicas lagging more than max behind the
+// primary out of rotation until they catch up. The lag is read with the lag
+// query, PostgresReplicaLagQuery unless set by WithReplicaLagQuery.
+func WithMaxReplicaLag(max time.Duration) ReplicaOption {
+	return func(c *replicaConfig) {
+		c.maxLag = max
+	}
+}
+
+// WithReplicaLagQuery sets the query returning the replication lag of a
+// replica in seconds.
+func WithReplicaLagQuery(q string) ReplicaOption {
+	return func(c *replicaConfig) {
+		c.lagQuery = q
+	}
+}
+
+type replica struct {
+	db      *sql.DB
+	weight  int
+	healthy atomic.Bool
+}
+
+// ReplicaSet routes the reads to the read replicas of a primary database and
+// the writes to the primary. Reads go to the primary when:
+//   - they run in a transaction, see InjectTx
+//   - the context asks for it, see Primary
+//   - the context wrote within a read-your-writes scope, see WithReadYourWrites
+//   - no replica is healthy, either unreachable or lagging, see WithMaxReplicaLag
+type ReplicaSet struct {
+	primary  *sql.DB
+	replicas []*replica
+	cfg      replicaConfig
+
+	stop      chan struct{}
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+// NewReplicaSet returns the replica set of primary, checking the health of
+// the replicas right away and then periodically until it is closed.
+func NewReplicaSet(primary *sql.DB, replicas []Replica, opts ...ReplicaOption) (*ReplicaSet, error) {
+	if primary == nil {
+		return nil, NewErrEmptyDBConnection()
+	}
+
+	cfg := replicaConfig{
+		interval: defaultHealthCheckInterval,
+		timeout:  defaultHealthCheckTimeout,
+		lagQuery: PostgresReplicaLagQuery,
+	}
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+
+	s := &ReplicaSet{
+		primary: primary,
+		cfg:     cfg,
+		stop:    make(chan struct{}),
+		done:    make(chan struct{}),
+	}
+	for _, r := range replicas {
+		if r.DB == nil {
+			return nil, errors.New("missing replica db connection")
+		}
+		s.replicas = append(s.replicas, &replica{db: r.DB, weight: max(r.Weight, 1)})
+	}
+
+	s.Check(context.Background())
+	if cfg.interval > 0 && len(s.replicas) > 0 {
+		go s.checkLoop()
+	} else {
+		close(s.done)
+	}
+
+	return s, nil
+}
+
+// Primary returns the primary database.
+func (s *ReplicaSet) Primary() *sql.DB {
+	return s.primary
+}
+
+// Reader returns the querier the reads of ctx run on: the transaction of
+// ctx if any, else the database picked by ReadDB.
+func (s *ReplicaSet) Reader(ctx context.Context) Querier {
+	if tx := extractTx(ctx); tx != nil {
+		return tx
+	}
+
+	return s.ReadDB(ctx)
+}
+
+// Writer returns the querier the writes of ctx run on: the transaction of
+// ctx if any, else the primary. Callers mark the context as written after a
+// successful write, see MarkWritten.
+func (s *ReplicaSet) Writer(ctx context.Context) Querier {
+	return GetTx(ctx, s.primary)
+}
+
+// ReadDB returns the database the reads of ctx outside of a transaction go
+// to: the primary when ctx asks for it or wrote within a read-your-writes
+// scope, a healthy replica otherwise, falling back to the primary when there
+// is none.
+func (s *ReplicaSet) ReadDB(ctx context.Context) *sql.DB {
+	if readsFromPrimary(ctx) {
+		return s.primary
+	}
+	if r := s.pick(); r != nil {
+		return r.db
+	}
+
+	return s.primary
+}
+
+// Check health checks every replica once, pinging it and reading its lag
+// when a max lag is set.
+func (s *ReplicaSet) Check(ctx context.Context) {
+	var wg sync.WaitGroup
+	for _, r := range s.replicas {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			r.healthy.Store(s.check(ctx, r.db))
+		}()
+	}
+	wg.Wait()
+}
+
+// Healthy returns the number of replicas currently in rotation.
+func (s *ReplicaSet) Healthy() int {
+	n := 0
+	for _, r := range s.replicas {
+		if r.healthy.Load() {
+			n++
+		}
+	}
+
+	return n
+}
+
+// Close stops the health checks and closes the replicas, leaving the
+// primary open.
+func (s *ReplicaSet) Close() error {
+	var errs []error
+	s.closeOnce.Do(func() {
+		close(s.stop)
+		<-s.done
+		for _, r := range s.replicas {
+			errs = append(errs, r.db.Close())
+		}
+	})
+
+	return errors.Join(errs...)
+}
+
+func (s *ReplicaSet) check(ctx context.Context, db *sql.DB) bool {
+	if s.cfg.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, s.cfg.timeout)
+		defer cancel()
+	}
+	if err := db.PingContext(ctx); err != nil {
+		return false
+	}
+	if s.cfg.maxLag <= 0 || s.cfg.lagQuery == "" {
+		return true
+	}
+
+	var lag float64
+	if err := db.QueryRowContext(ctx, s.cfg.lagQuery).Scan(&lag); err != nil {
+		return false
+	}
+
+	return time.Duration(lag*float64(time.Second)) <= s.cfg.maxLag
+}
+
+func (s *ReplicaSet) checkLoop() {
+	defer close(s.done)
+
+	ticker := time.NewTicker(s.cfg.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-s.stop:
+			return
+		case <-ticker.C:
+			s.Check(context.Background())
+		}
+	}
+}
+
+// pick returns a healthy replica at random proportionally to the weights,
+// nil when there is none.
+func (s *ReplicaSet) pick() *replica {
+	total := 0
+	for _, r := range s.replicas {
+		if r.healthy.Load() {
+			total += r.weight
+		}
+	}
+	if total == 0 {
+		return nil
+	}
+
+	n := rand.IntN(total) //nolint:gosec // load balancing needs no crypto randomness
+	for _, r := range s.replicas {
+		if !r.healthy.Load() {
+			continue
+		}
+		if n < r.weight {
+			return r
+		}
+		n -= r.weight
+	}
+
+	return nil
+}
+
+type (
+	primaryKey struct{}
+	rywKey     struct{}
+)
+
+// Primary returns a context whose reads go to the primary database, e.g. to
+// read a row right before updating it.
+func Primary(ctx context.Context) context.Context {
+	return context.WithValue(ctx, primaryKey{}, true)
+}
+
+// WithReadYourWrites starts a read-your-writes scope, typically one per
+// request: once a write succeeds within ctx, see MarkWritten, the following
+// reads of ctx go to the primary so that they observe it despite the
+// replication lag.
+func WithReadYourWrites(ctx context.Context) context.Context {
+	if _, ok := ctx.Value(rywKey{}).(*atomic.Bool); ok {
+		return ctx
+	}
+
+	return context.WithValue(ctx, rywKey{}, new(atomic.Bool))
+}
+
+// MarkWritten records a successful write within the read-your-writes scope
+// of ctx, if any.
+func MarkWritten(ctx context.Context) {
+	if written, ok := ctx.Value(rywKey{}).(*atomic.Bool); ok {
+		written.Store(true)
+	}
+}
+
+func readsFromPrimary(ctx context.Context) bool {
+	if primary, _ := ctx.Value(primaryKey{}).(bool); primary {
+		return true
+	}
+	written, ok := ctx.Value(rywKey{}).(*atomic.Bool)
+
+	return ok && written.Load()
+}
@@ -0,0 +1,130 @@
+package sqldb
+
+import (
+	"context"
+	"database/sql"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// sqliteDB opens a sqlite database holding a single row naming it.
+func sqliteDB(t *testing.T, name string) *sql.DB {
+	t.Helper()
+
+	db, err := Connect(MustGenerateDSN(DriverTypeSQLite, WithConnDBName(filepath.Join(t.TempDir(), name+".db"))))
+	require.NoError(t, err)
+	t.Cleanup(func() { db.Close() })
+
+	_, err = db.Exec("CREATE TABLE source (name TEXT)")
+	require.NoError(t, err)
+	_, err = db.Exec("INSERT INTO source (name) VALUES (?)", name)
+	require.NoError(t, err)
+
+	return db
+}
+
+func readSource(ctx context.Context, t *testing.T, cli *DBClient) string {
+	t.Helper()
+
+	var name string
+	require.NoError(t, cli.QueryRow(ctx, "SELECT name FROM source").Scan(&name))
+	return name
+}
+
+func TestReplicaSet(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("reads go to the replicas", func(t *testing.T) {
+		primary := sqliteDB(t, "primary")
+		rs, err := NewReplicaSet(primary, []Replica{{DB: sqliteDB(t, "replica")}}, WithHealthCheckInterval(0))
+		require.NoError(t, err)
+		cli := NewDBClient(primary, WithReplicaSet(rs))
+
+		assert.Equal(t, 1, rs.Healthy())
+		assert.Equal(t, "replica", readSource(ctx, t, cli))
+		assert.Equal(t, "primary", readSource(Primary(ctx), t, cli))
+	})
+
+	t.Run("reads follow the writes of a read-your-writes scope", func(t *testing.T) {
+		primary := sqliteDB(t, "primary")
+		rs, err := NewReplicaSet(primary, []Replica{{DB: sqliteDB(t, "replica")}}, WithHealthCheckInterval(0))
+		require.NoError(t, err)
+		cli := NewDBClient(primary, WithReplicaSet(rs))
+
+		scoped := WithReadYourWrites(ctx)
+		assert.Equal(t, "replica", readSource(scoped, t, cli))
+
+		_, err = cli.Exec(scoped, "UPDATE source SET name = ?", "written")
+		require.NoError(t, err)
+		assert.Equal(t, "written", readSource(scoped, t, cli))
+		// outside of the scope reads still go to the replicas
+		assert.Equal(t, "replica", readSource(ctx, t, cli))
+	})
+
+	t.Run("reads in a transaction go to the primary", func(t *testing.T) {
+		primary := sqliteDB(t, "primary")
+		rs, err := NewReplicaSet(primary, []Replica{{DB: sqliteDB(t, "replica")}}, WithHealthCheckInterval(0))
+		require.NoError(t, err)
+		cli := NewDBClient(primary, WithReplicaSet(rs))
+
+		err = NewTransactioner(primary).Exec(ctx, func(ctx context.Context) error {
+			assert.Equal(t, "primary", readSource(ctx, t, cli))
+			return nil
+		})
+		require.NoError(t, err)
+	})
+
+	t.Run("unhealthy replicas fail over to the primary", func(t *testing.T) {
+		primary := sqliteDB(t, "primary")
+		replica := sqliteDB(t, "replica")
+		rs, err := NewReplicaSet(primary, []Replica{{DB: replica}}, WithHealthCheckInterval(0))
+		require.NoError(t, err)
+		cli := NewDBClient(primary, WithReplicaSet(rs))
+
+		require.NoError(t, replica.Close())
+		rs.Check(ctx)
+		assert.Equal(t, 0, rs.Healthy())
+		assert.Equal(t, "primary", readSource(ctx, t, cli))
+	})
+
+	t.Run("lagging replicas are out of rotation", func(t *testing.T) {
+		primary := sqliteDB(t, "primary")
+		rs, err := NewReplicaSet(primary,
+			[]Replica{
+				{DB: sqliteDB(t, "lagging"), Weight: 10},
+				{DB: sqliteDB(t, "replica")},
+			},
+			WithHealthCheckInterval(0),
+			WithMaxReplicaLag(time.Second),
+			WithReplicaLagQuery("SELECT CASE WHEN name = 'lagging' THEN 5 ELSE 0.5 END FROM source"),
+		)
+		require.NoError(t, err)
+		cli := NewDBClient(primary, WithReplicaSet(rs))
+
+		assert.Equal(t, 1, rs.Healthy())
+		for range 10 {
+			assert.Equal(t, "replica", readSource(ctx, t, cli))
+		}
+	})
+
+	t.Run("background health checks", func(t *testing.T) {
+		primary := sqliteDB(t, "primary")
+		replica := sqliteDB(t, "replica")
+		rs, err := NewReplicaSet(primary, []Replica{{DB: replica}}, WithHealthCheckInterval(10*time.Millisecond))
+		require.NoError(t, err)
+		defer rs.Close()
+
+		assert.Equal(t, 1, rs.Healthy())
+		require.NoError(t, replica.Close())
+		assert.Eventually(t, func() bool { return rs.Healthy() == 0 }, time.Second, 10*time.Millisecond)
+	})
+
+	t.Run("missing primary", func(t *testing.T) {
+		_, err := NewReplicaSet(nil, nil)
+		assert.Error(t, err)
+	})
+}
@@ -0,0 +1,393 @@
+package sqldb
+
+import (
+	"context"
+	"database/sql"
+	stderrors "errors"
+	"fmt"
+	"reflect"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/dosanma1/forge/go/kit/application/repository"
+	"github.com/dosanma1/forge/go/kit/errors"
+	"github.com/dosanma1/forge/go/kit/resource"
+	"github.com/dosanma1/forge/go/kit/search"
+	"github.com/dosanma1/forge/go/kit/search/query"
+)
+
+const (
+	defaultPrimaryKey       = "id"
+	defaultSoftDeleteColumn = "deleted_at"
+)
+
+type (
+	RepoOption func(c *repoConfig)
+	repoConfig struct {
+		dialect      Dialect
+		replicas     *ReplicaSet
+		fMapper      map[string]string
+		primaryKey   string
+		softDelete   *string
+		resourceName string
+	}
+)
+
+// WithDialect sets the SQL dialect of the repository. Defaults to
+// DialectPostgres.
+func WithDialect(d Dialect) RepoOption {
+	return func(c *repoConfig) {
+		c.dialect = d
+	}
+}
+
+// WithReadReplicas runs the Get and List queries on the read replicas of rs,
+// see ReplicaSet. Successful writes mark the context as written, see
+// WithReadYourWrites.
+func WithReadReplicas(rs *ReplicaSet) RepoOption {
+	return func(c *repoConfig) {
+		c.replicas = rs
+	}
+}
+
+// WithFieldMapper maps the query field names to their columns.
+func WithFieldMapper(fMapper map[string]string) RepoOption {
+	return func(c *repoConfig) {
+		c.fMapper = fMapper
+	}
+}
+
+// WithPrimaryKey sets the primary key column. Defaults to id.
+func WithPrimaryKey(column string) RepoOption {
+	return func(c *repoConfig) {
+		c.primaryKey = column
+	}
+}
+
+// WithSoftDelete sets the timestamp column marking soft deleted rows, an
+// empty column disables soft deletes. Defaults to deleted_at when the model
+// has such a column.
+func WithSoftDelete(column string) RepoOption {
+	return func(c *repoConfig) {
+		c.softDelete = &column
+	}
+}
+
+// WithResourceName sets the resource name of the not found errors. Defaults
+// to the table name.
+func WithResourceName(name string) RepoOption {
+	return func(c *repoConfig) {
+		c.resourceName = name
+	}
+}
+
+// CRUDRepo stores the resources R in a table as models M, structs mapped to
+// the columns by db tags, see ScanRow. Zero valued fields tagged omitempty
+// are left out of inserts and updates so that the database defaults apply,
+// e.g. `db:"id,omitempty"` for generated ids.
+//
+// Statements run in the transaction of the context when there is one, see
+// NewTransactioner.
+type CRUDRepo[M any, R resource.Resource] struct {
+	db         *sql.DB
+	builder    *Builder
+	table      string
+	columns    []string
+	toModel    func(R) M
+	toResource func(*M) R
+	repoConfig
+}
+
+// NewCRUDRepo returns a repository of the table converting resources to
+// models with toModel and back with toResource.
+func NewCRUDRepo[M any, R resource.Resource](
+	db *sql.DB, table string, toModel func(R) M, toResource func(*M) R, opts ...RepoOption,
+) (*CRUDRepo[M, R], error) {
+	if db == nil {
+		return nil, NewErrEmptyDBConnection()
+	}
+	if table == "" {
+		return nil, fmt.Errorf("missing table name")
+	}
+	if reflect.TypeOf((*M)(nil)).Elem().Kind() != reflect.Struct {
+		return nil, fmt.Errorf("model must be a struct")
+	}
+
+	columns := Columns[M]()
+	cfg := repoConfig{
+		dialect:      DialectPostgres,
+		primaryKey:   defaultPrimaryKey,
+		resourceName: table,
+	}
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+	if cfg.softDelete == nil {
+		softDelete := ""
+		if slices.Contains(columns, defaultSoftDeleteColumn) {
+			softDelete = defaultSoftDeleteColumn
+		}
+		cfg.softDelete = &softDelete
+	}
+	if !slices.Contains(columns, cfg.primaryKey) {
+		return nil, fmt.Errorf("model has no %s column", cfg.primaryKey)
+	}
+
+	return &CRUDRepo[M, R]{
+		db:         db,
+		builder:    NewBuilder(cfg.dialect, cfg.fMapper),
+		table:      table,
+		columns:    columns,
+		toModel:    toModel,
+		toResource: toResource,
+		repoConfig: cfg,
+	}, nil
+}
+
+// Builder returns the statement builder of the repository, to write the
+// queries it does not cover.
+func (r *CRUDRepo[M, R]) Builder() *Builder {
+	return r.builder
+}
+
+func (r *CRUDRepo[M, R]) Create(ctx context.Context, res R) (R, error) {
+	var zero R
+	m := r.toModel(res)
+	cols, vals := columnValues(reflect.ValueOf(m))
+
+	w := r.builder.writer(0)
+	fmt.Fprintf(w, "INSERT INTO %s (%s) VALUES (", r.quote(r.table), r.quoteAll(cols))
+	for i, v := range vals {
+		if i > 0 {
+			w.WriteString(", ")
+		}
+		w.WriteString(w.arg(v))
+	}
+	w.WriteString(")")
+
+	if r.dialect.Returning() {
+		fmt.Fprintf(w, " RETURNING %s", r.quoteAll(r.columns))
+		return r.queryWritten(ctx, w.String(), w.args...)
+	}
+
+	result, err := r.writer(ctx).ExecContext(ctx, w.String(), w.args...)
+	if err != nil {
+		return zero, newErrQuery(err)
+	}
+	MarkWritten(ctx)
+	id := r.primaryKeyValue(m)
+	if isZero(id) {
+		lastID, err := result.LastInsertId()
+		if err != nil {
+			return zero, newErrQuery(err)
+		}
+		id = lastID
+	}
+
+	return r.getByPrimaryKey(ctx, id)
+}
+
+func (r *CRUDRepo[M, R]) Get(ctx context.Context, opts ...search.Option) (R, error) {
+	q := search.New(append(opts, search.WithQueryOpts(query.Pagination(1, 0)))...).Query()
+	stmt, args, err := r.builder.selectSQL(ctx, r.table, r.columns, q, r.notDeleted()...)
+	if err != nil {
+		var zero R
+		return zero, err
+	}
+
+	return r.queryOne(ctx, r.reader(ctx), stmt, args...)
+}
+
+func (r *CRUDRepo[M, R]) List(ctx context.Context, opts ...search.Option) (resource.ListResponse[R], error) {
+	q := search.New(opts...).Query()
+	stmt, args, err := r.builder.selectSQL(ctx, r.table, r.columns, q, r.notDeleted()...)
+	if err != nil {
+		return nil, err
+	}
+	db := r.reader(ctx)
+	rows, err := db.QueryContext(ctx, stmt, args...)
+	if err != nil {
+		return nil, newErrQuery(err)
+	}
+	models, err := ScanRows[M](rows)
+	if err != nil {
+		return nil, newErrQuery(err)
+	}
+
+	countStmt, countArgs, err := r.builder.countSQL(r.table, q, r.notDeleted()...)
+	if err != nil {
+		return nil, err
+	}
+	total := 0
+	if err := db.QueryRowContext(ctx, countStmt, countArgs...).Scan(&total); err != nil {
+		return nil, newErrQuery(err)
+	}
+
+	items := make([]R, len(models))
+	for i := range models {
+		items[i] = r.toResource(&models[i])
+	}
+
+	return resource.NewListResponse(items, total), nil
+}
+
+// Update overwrites the row of the resource with its model, leaving out the
+// primary key and the zero valued omitempty fields.
+func (r *CRUDRepo[M, R]) Update(ctx context.Context, res R) (R, error) {
+	var zero R
+	m := r.toModel(res)
+	id := r.primaryKeyValue(m)
+	cols, vals := columnValues(reflect.ValueOf(m), r.primaryKey)
+	if len(cols) == 0 {
+		return zero, errors.InvalidArgument("nothing to update")
+	}
+
+	w := r.builder.writer(0)
+	fmt.Fprintf(w, "UPDATE %s SET ", r.quote(r.table))
+	for i, col := range cols {
+		if i > 0 {
+			w.WriteString(", ")
+		}
+		fmt.Fprintf(w, "%s = %s", r.quote(col), w.arg(vals[i]))
+	}
+	fmt.Fprintf(w, " WHERE %s = %s", r.quote(r.primaryKey), w.arg(id))
+	for _, cond := range r.notDeleted() {
+		fmt.Fprintf(w, " AND %s", cond)
+	}
+
+	if r.dialect.Returning() {
+		fmt.Fprintf(w, " RETURNING %s", r.quoteAll(r.columns))
+		return r.queryWritten(ctx, w.String(), w.args...)
+	}
+
+	if _, err := r.writer(ctx).ExecContext(ctx, w.String(), w.args...); err != nil {
+		return zero, newErrQuery(err)
+	}
+	MarkWritten(ctx)
+
+	return r.getByPrimaryKey(ctx, id)
+}
+
+// Delete deletes the rows matching the search options, which must filter
+// them. Soft deletes set the soft delete column of the rows instead and fail
+// when the repository has none.
+func (r *CRUDRepo[M, R]) Delete(ctx context.Context, delType repository.DeleteType, opts ...search.Option) error {
+	q := search.New(opts...).Query()
+	if query.FilterTree(q) == nil {
+		return errors.InvalidArgument("delete requires at least one filter")
+	}
+
+	w := r.builder.writer(0)
+	switch delType {
+	case repository.DeleteTypeHard:
+		fmt.Fprintf(w, "DELETE FROM %s", r.quote(r.table))
+		if err := w.where(q); err != nil {
+			return err
+		}
+	case repository.DeleteTypeSoft:
+		if *r.softDelete == "" {
+			return errors.InvalidArgument(fmt.Sprintf("%s cannot be soft deleted", r.resourceName))
+		}
+		fmt.Fprintf(w, "UPDATE %s SET %s = %s", r.quote(r.table), r.quote(*r.softDelete), w.arg(time.Now().UTC()))
+		if err := w.where(q, r.notDeleted()...); err != nil {
+			return err
+		}
+	default:
+		return errors.InvalidArgument(fmt.Sprintf("unknown delete type: %d", delType))
+	}
+
+	if _, err := r.writer(ctx).ExecContext(ctx, w.String(), w.args...); err != nil {
+		return newErrQuery(err)
+	}
+	MarkWritten(ctx)
+
+	return nil
+}
+
+func (r *CRUDRepo[M, R]) getByPrimaryKey(ctx context.Context, id any) (R, error) {
+	w := r.builder.writer(0)
+	fmt.Fprintf(w, "SELECT %s FROM %s WHERE %s = %s",
+		r.quoteAll(r.columns), r.quote(r.table), r.quote(r.primaryKey), w.arg(id))
+
+	return r.queryOne(ctx, r.writer(ctx), w.String(), w.args...)
+}
+
+func (r *CRUDRepo[M, R]) queryOne(ctx context.Context, db Querier, stmt string, args ...any) (R, error) {
+	var zero R
+	rows, err := db.QueryContext(ctx, stmt, args...)
+	if err != nil {
+		return zero, newErrQuery(err)
+	}
+	m, err := ScanOne[M](rows)
+	if stderrors.Is(err, sql.ErrNoRows) {
+		return zero, errors.NotFound(r.resourceName, nil)
+	}
+	if err != nil {
+		return zero, newErrQuery(err)
+	}
+
+	return r.toResource(&m), nil
+}
+
+// reader returns the querier of the reads of ctx, a read replica when the
+// repository has any.
+func (r *CRUDRepo[M, R]) reader(ctx context.Context) Querier {
+	if r.replicas == nil {
+		return GetTx(ctx, r.db)
+	}
+
+	return r.replicas.Reader(ctx)
+}
+
+func (r *CRUDRepo[M, R]) writer(ctx context.Context) Querier {
+	return GetTx(ctx, r.db)
+}
+
+// queryWritten runs a write returning the written row, marking ctx as
+// written when it succeeds.
+func (r *CRUDRepo[M, R]) queryWritten(ctx context.Context, stmt string, args ...any) (R, error) {
+	res, err := r.queryOne(ctx, r.writer(ctx), stmt, args...)
+	if err == nil {
+		MarkWritten(ctx)
+	}
+
+	return res, err
+}
+
+// notDeleted returns the condition excluding soft deleted rows, if any.
+func (r *CRUDRepo[M, R]) notDeleted() []string {
+	if *r.softDelete == "" {
+		return nil
+	}
+
+	return []string{r.quote(*r.softDelete) + " IS NULL"}
+}
+
+func (r *CRUDRepo[M, R]) primaryKeyValue(m M) any {
+	v := reflect.ValueOf(m)
+	for _, f := range dbFields(v.Type()) {
+		if f.column == r.primaryKey {
+			return v.FieldByIndex(f.index).Interface()
+		}
+	}
+
+	return nil
+}
+
+func (r *CRUDRepo[M, R]) quote(ident string) string {
+	return r.dialect.Quote(ident)
+}
+
+func (r *CRUDRepo[M, R]) quoteAll(idents []string) string {
+	quoted := make([]string, len(idents))
+	for i, ident := range idents {
+		quoted[i] = r.quote(ident)
+	}
+
+	return strings.Join(quoted, ", ")
+}
+
+func isZero(v any) bool {
+	return v == nil || reflect.ValueOf(v).IsZero()
+}
@@ -0,0 +1,200 @@
+package sqldb_test
+
+import (
+	"context"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/dosanma1/forge/go/kit/application/repository"
+	apierrors "github.com/dosanma1/forge/go/kit/errors"
+	"github.com/dosanma1/forge/go/kit/filter"
+	"github.com/dosanma1/forge/go/kit/persistence/sqldb"
+	"github.com/dosanma1/forge/go/kit/resource"
+	"github.com/dosanma1/forge/go/kit/search"
+	"github.com/dosanma1/forge/go/kit/search/query"
+)
+
+type testNote struct {
+	resource.Resource
+	text string
+}
+
+type testNoteTimestamps struct {
+	CreatedAt time.Time  `db:"created_at,omitempty"`
+	DeletedAt *time.Time `db:"deleted_at,omitempty"`
+}
+
+type testNoteRow struct {
+	ID   string `db:"id,omitempty"`
+	Text string `db:"text"`
+	testNoteTimestamps
+	Ignored string
+}
+
+func testNoteToRow(n *testNote) testNoteRow {
+	return testNoteRow{ID: n.ID(), Text: n.text}
+}
+
+func testNoteFromRow(r *testNoteRow) *testNote {
+	return &testNote{
+		Resource: resource.New(resource.WithID(r.ID), resource.WithCreatedAt(r.CreatedAt)),
+		text:     r.Text,
+	}
+}
+
+var testNoteColumns = []string{"id", "text", "created_at", "deleted_at"}
+
+func testNoteRepo(t *testing.T, opts ...sqldb.RepoOption) (*sqldb.CRUDRepo[testNoteRow, *testNote], sqlmock.Sqlmock) {
+	t.Helper()
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		assert.NoError(t, mock.ExpectationsWereMet())
+		db.Close()
+	})
+
+	repo, err := sqldb.NewCRUDRepo(db, "notes", testNoteToRow, testNoteFromRow, opts...)
+	require.NoError(t, err)
+
+	return repo, mock
+}
+
+func TestColumns(t *testing.T) {
+	assert.Equal(t, testNoteColumns, sqldb.Columns[testNoteRow]())
+}
+
+func TestCRUDRepo(t *testing.T) {
+	ctx := context.Background()
+	createdAt := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+
+	t.Run("create returns the stored row", func(t *testing.T) {
+		repo, mock := testNoteRepo(t)
+		mock.ExpectQuery(regexp.QuoteMeta(
+			`INSERT INTO "notes" ("text") VALUES ($1) RETURNING "id", "text", "created_at", "deleted_at"`,
+		)).WithArgs("hello").WillReturnRows(
+			sqlmock.NewRows(testNoteColumns).AddRow("n1", "hello", createdAt, nil),
+		)
+
+		created, err := repo.Create(ctx, &testNote{Resource: resource.New(), text: "hello"})
+		require.NoError(t, err)
+		assert.Equal(t, "n1", created.ID())
+		assert.Equal(t, "hello", created.text)
+		assert.Equal(t, createdAt, created.CreatedAt())
+	})
+
+	t.Run("create without returning reads the row back", func(t *testing.T) {
+		repo, mock := testNoteRepo(t, sqldb.WithDialect(sqldb.DialectMySQL))
+		mock.ExpectExec(regexp.QuoteMeta("INSERT INTO `notes` (`id`, `text`) VALUES (?, ?)")).
+			WithArgs("n1", "hello").WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectQuery(regexp.QuoteMeta(
+			"SELECT `id`, `text`, `created_at`, `deleted_at` FROM `notes` WHERE `id` = ?",
+		)).WithArgs("n1").WillReturnRows(
+			sqlmock.NewRows(testNoteColumns).AddRow("n1", "hello", createdAt, nil),
+		)
+
+		created, err := repo.Create(ctx, &testNote{Resource: resource.New(resource.WithID("n1")), text: "hello"})
+		require.NoError(t, err)
+		assert.Equal(t, "n1", created.ID())
+	})
+
+	t.Run("get excludes soft deleted rows", func(t *testing.T) {
+		repo, mock := testNoteRepo(t)
+		mock.ExpectQuery(regexp.QuoteMeta(
+			`SELECT "id", "text", "created_at", "deleted_at" FROM "notes" WHERE "deleted_at" IS NULL AND "id" = $1 LIMIT 1`,
+		)).WithArgs("n1").WillReturnRows(sqlmock.NewRows(testNoteColumns))
+
+		_, err := repo.Get(ctx, search.WithQueryOpts(query.FilterBy(filter.OpEq, "id", "n1")))
+		require.Error(t, err)
+		apiErr, ok := apierrors.As(err)
+		require.True(t, ok)
+		assert.Equal(t, apierrors.CodeNotFound, apiErr.Code())
+	})
+
+	t.Run("list counts the matching rows", func(t *testing.T) {
+		repo, mock := testNoteRepo(t, sqldb.WithFieldMapper(map[string]string{"createdAt": "created_at"}))
+		mock.ExpectQuery(regexp.QuoteMeta(
+			`SELECT "id", "text", "created_at", "deleted_at" FROM "notes" WHERE "deleted_at" IS NULL AND ("text" LIKE $1 OR "id" = $2) ORDER BY created_at DESC LIMIT 1`,
+		)).WithArgs("%go%", "n2").WillReturnRows(
+			sqlmock.NewRows(testNoteColumns).AddRow("n1", "go", createdAt, nil),
+		)
+		mock.ExpectQuery(regexp.QuoteMeta(
+			`SELECT COUNT(*) FROM "notes" WHERE "deleted_at" IS NULL AND ("text" LIKE $1 OR "id" = $2)`,
+		)).WithArgs("%go%", "n2").WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(2))
+
+		list, err := repo.List(ctx, search.WithQueryOpts(
+			query.Where(query.Or(
+				query.Cond(filter.OpLike, "text", "go"),
+				query.Cond(filter.OpEq, "id", "n2"),
+			)),
+			query.SortBy("createdAt", query.SortDesc),
+			query.Pagination(1, 0),
+		))
+		require.NoError(t, err)
+		assert.Equal(t, 2, list.TotalCount())
+		require.Len(t, list.Results(), 1)
+		assert.Equal(t, "go", list.Results()[0].text)
+	})
+
+	t.Run("update leaves the primary key out", func(t *testing.T) {
+		repo, mock := testNoteRepo(t)
+		mock.ExpectQuery(regexp.QuoteMeta(
+			`UPDATE "notes" SET "text" = $1 WHERE "id" = $2 AND "deleted_at" IS NULL RETURNING "id", "text", "created_at", "deleted_at"`,
+		)).WithArgs("bye", "n1").WillReturnRows(
+			sqlmock.NewRows(testNoteColumns).AddRow("n1", "bye", createdAt, nil),
+		)
+
+		updated, err := repo.Update(ctx, &testNote{Resource: resource.New(resource.WithID("n1")), text: "bye"})
+		require.NoError(t, err)
+		assert.Equal(t, "bye", updated.text)
+	})
+
+	t.Run("soft delete", func(t *testing.T) {
+		repo, mock := testNoteRepo(t)
+		mock.ExpectExec(regexp.QuoteMeta(
+			`UPDATE "notes" SET "deleted_at" = $1 WHERE "deleted_at" IS NULL AND "id" = $2`,
+		)).WithArgs(sqlmock.AnyArg(), "n1").WillReturnResult(sqlmock.NewResult(0, 1))
+
+		require.NoError(t, repo.Delete(ctx, repository.DeleteTypeSoft,
+			search.WithQueryOpts(query.FilterBy(filter.OpEq, "id", "n1"))))
+	})
+
+	t.Run("hard delete", func(t *testing.T) {
+		repo, mock := testNoteRepo(t)
+		mock.ExpectExec(regexp.QuoteMeta(`DELETE FROM "notes" WHERE "id" IN ($1, $2)`)).
+			WithArgs("n1", "n2").WillReturnResult(sqlmock.NewResult(0, 2))
+
+		require.NoError(t, repo.Delete(ctx, repository.DeleteTypeHard,
+			search.WithQueryOpts(query.FilterBy(filter.OpIn, "id", []string{"n1", "n2"}))))
+	})
+
+	t.Run("delete requires a filter", func(t *testing.T) {
+		repo, _ := testNoteRepo(t)
+		err := repo.Delete(ctx, repository.DeleteTypeHard)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "delete requires at least one filter")
+	})
+
+	t.Run("runs in the context transaction", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		require.NoError(t, err)
+		defer db.Close()
+		repo, err := sqldb.NewCRUDRepo(db, "notes", testNoteToRow, testNoteFromRow)
+		require.NoError(t, err)
+		mock.ExpectBegin()
+		mock.ExpectExec(regexp.QuoteMeta(`DELETE FROM "notes" WHERE "id" = $1`)).
+			WithArgs("n1").WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectCommit()
+
+		err = sqldb.NewTransactioner(db).Exec(ctx, func(ctx context.Context) error {
+			return repo.Delete(ctx, repository.DeleteTypeHard,
+				search.WithQueryOpts(query.FilterBy(filter.OpEq, "id", "n1")))
+		})
+		require.NoError(t, err)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+}
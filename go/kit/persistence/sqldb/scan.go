@@ -0,0 +1,178 @@
+package sqldb
+
+import (
+	"database/sql"
+	"fmt"
+	"reflect"
+	"slices"
+	"strings"
+	"sync"
+)
+
+const (
+	tagDB = "db"
+
+	dbOptOmitEmpty = "omitempty"
+)
+
+// dbField is a struct field mapped to a column by its db tag.
+type dbField struct {
+	column    string
+	index     []int
+	omitEmpty bool
+}
+
+//nolint:gochecknoglobals // reflection results are cached per type for every scan
+var dbFieldsCache sync.Map // reflect.Type -> []dbField
+
+// dbFields returns the fields of struct type t tagged with a column name,
+// e.g. `db:"created_at"` or `db:"id,omitempty"`, flattening anonymous
+// embedded structs. Fields tagged `db:"-"` or untagged are ignored.
+func dbFields(t reflect.Type) []dbField {
+	if cached, ok := dbFieldsCache.Load(t); ok {
+		return cached.([]dbField)
+	}
+
+	fields := collectDBFields(t, nil)
+	dbFieldsCache.Store(t, fields)
+
+	return fields
+}
+
+func collectDBFields(t reflect.Type, parent []int) []dbField {
+	fields := []dbField{}
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		index := append(append([]int{}, parent...), i)
+		tag, tagged := sf.Tag.Lookup(tagDB)
+		if sf.Anonymous && !tagged && sf.Type.Kind() == reflect.Struct {
+			fields = append(fields, collectDBFields(sf.Type, index)...)
+			continue
+		}
+		if !tagged || !sf.IsExported() {
+			continue
+		}
+		name, opts, _ := strings.Cut(tag, ",")
+		if name == "" || name == "-" {
+			continue
+		}
+		fields = append(fields, dbField{
+			column:    name,
+			index:     index,
+			omitEmpty: opts == dbOptOmitEmpty,
+		})
+	}
+
+	return fields
+}
+
+// Columns returns the columns T, a struct, is mapped to by its db tags.
+func Columns[T any]() []string {
+	fields := dbFields(structType[T]())
+	cols := make([]string, len(fields))
+	for i, f := range fields {
+		cols[i] = f.column
+	}
+
+	return cols
+}
+
+// ScanRow scans the current row of rows into a T by the db tags of its
+// fields. Result columns without a matching field are discarded.
+func ScanRow[T any](rows *sql.Rows) (T, error) {
+	var dst T
+	cols, err := rows.Columns()
+	if err != nil {
+		return dst, err
+	}
+
+	return dst, rows.Scan(scanTargets(reflect.ValueOf(&dst).Elem(), cols)...)
+}
+
+// ScanRows scans every remaining row of rows into a T, see ScanRow, and
+// closes rows.
+func ScanRows[T any](rows *sql.Rows) ([]T, error) {
+	defer rows.Close()
+
+	cols, err := rows.Columns()
+	if err != nil {
+		return nil, err
+	}
+	items := []T{}
+	for rows.Next() {
+		var dst T
+		if err := rows.Scan(scanTargets(reflect.ValueOf(&dst).Elem(), cols)...); err != nil {
+			return nil, err
+		}
+		items = append(items, dst)
+	}
+
+	return items, rows.Err()
+}
+
+// ScanOne scans the first row of rows into a T, see ScanRow, and closes
+// rows. It returns sql.ErrNoRows when there are no rows.
+func ScanOne[T any](rows *sql.Rows) (T, error) {
+	defer rows.Close()
+
+	var dst T
+	if !rows.Next() {
+		if err := rows.Err(); err != nil {
+			return dst, err
+		}
+		return dst, sql.ErrNoRows
+	}
+	dst, err := ScanRow[T](rows)
+	if err != nil {
+		return dst, err
+	}
+
+	return dst, rows.Close()
+}
+
+func scanTargets(dst reflect.Value, cols []string) []any {
+	byColumn := make(map[string][]int)
+	for _, f := range dbFields(dst.Type()) {
+		byColumn[f.column] = f.index
+	}
+	targets := make([]any, len(cols))
+	for i, col := range cols {
+		index, ok := byColumn[col]
+		if !ok {
+			targets[i] = new(any)
+			continue
+		}
+		targets[i] = dst.FieldByIndex(index).Addr().Interface()
+	}
+
+	return targets
+}
+
+// columnValues returns the columns and values of the db tagged fields of v,
+// skipping the omitempty fields holding a zero value and the excluded
+// columns.
+func columnValues(v reflect.Value, exclude ...string) ([]string, []any) {
+	cols, vals := []string{}, []any{}
+	for _, f := range dbFields(v.Type()) {
+		if slices.Contains(exclude, f.column) {
+			continue
+		}
+		fv := v.FieldByIndex(f.index)
+		if f.omitEmpty && fv.IsZero() {
+			continue
+		}
+		cols = append(cols, f.column)
+		vals = append(vals, fv.Interface())
+	}
+
+	return cols, vals
+}
+
+func structType[T any]() reflect.Type {
+	t := reflect.TypeOf((*T)(nil)).Elem()
+	if t.Kind() != reflect.Struct {
+		panic(fmt.Sprintf("sqldb: expected a struct, got %s", t))
+	}
+
+	return t
+}
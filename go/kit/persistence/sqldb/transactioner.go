@@ -3,8 +3,12 @@ package sqldb
 import (
 	"context"
 	"database/sql"
+	"errors"
+	"fmt"
+	"sync/atomic"
 
 	"github.com/dosanma1/forge/go/kit/persistence"
+	"github.com/dosanma1/forge/go/kit/retry"
 )
 
 // Querier interface that both *sql.DB and *sql.Tx implement
@@ -65,26 +69,100 @@ func NewTransactioner(db *sql.DB) persistence.Transactioner {
 // Automatically commits on success, rolls back on error
 // Supports nested transactions by reusing existing transaction in context
 func (t *transactioner) Exec(ctx context.Context, fn persistence.TxFunc) error {
+	return t.ExecWith(ctx, fn)
+}
+
+// ExecWith runs the given function within a transaction configured by the
+// options, see persistence.TxOption. The hooks registered by the function
+// run once the transaction ends, see persistence.AfterCommit.
+func (t *transactioner) ExecWith(ctx context.Context, fn persistence.TxFunc, opts ...persistence.TxOption) error {
+	cfg := persistence.NewTxConfig(opts...)
+
 	// If already in transaction, reuse it (nested transaction support)
-	if sqlTxExists(ctx) {
+	if tx := extractTx(ctx); tx != nil {
+		if cfg.Savepoint {
+			return execSavepoint(ctx, tx, fn)
+		}
 		return fn(ctx)
 	}
+	if !cfg.Retry {
+		return t.exec(ctx, cfg, fn)
+	}
 
+	return retry.RetryWithContext(ctx, func() error {
+		err := t.exec(ctx, cfg, fn)
+		if err != nil && !IsSerializationFailure(err) {
+			return retry.Permanent(err)
+		}
+		return err
+	}, cfg.RetryOpts...)
+}
+
+func (t *transactioner) exec(ctx context.Context, cfg persistence.TxConfig, fn persistence.TxFunc) error {
 	// Begin new transaction
-	tx, err := t.db.BeginTx(ctx, nil)
+	tx, err := t.db.BeginTx(ctx, cfg.TxOptions())
 	if err != nil {
 		return err
 	}
+	if cfg.Deferrable {
+		if _, err := tx.ExecContext(ctx, "SET TRANSACTION DEFERRABLE"); err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
 
 	// Execute function with transaction context
-	err = fn(InjectTx(ctx, tx))
+	txCtx, hooks := persistence.NewTxHooks(ctx)
+	defer func() {
+		// a panicking fn must not leave the transaction open
+		if r := recover(); r != nil {
+			tx.Rollback()
+			hooks.RolledBack(ctx)
+			panic(r)
+		}
+	}()
+	err = fn(InjectTx(txCtx, tx))
 
 	if err != nil {
 		// Rollback on error
 		tx.Rollback()
+		hooks.RolledBack(ctx)
 		return err
 	}
 
 	// Commit on success
-	return tx.Commit()
+	if err := tx.Commit(); err != nil {
+		hooks.RolledBack(ctx)
+		return err
+	}
+	hooks.Committed(ctx)
+
+	return nil
+}
+
+//nolint:gochecknoglobals // savepoint names only need to be unique within a transaction
+var savepointSeq atomic.Uint64
+
+// execSavepoint runs fn in a savepoint of tx, rolling back to it when fn
+// fails.
+func execSavepoint(ctx context.Context, tx *sql.Tx, fn persistence.TxFunc) error {
+	name := fmt.Sprintf("sp_%d", savepointSeq.Add(1))
+	if _, err := tx.ExecContext(ctx, "SAVEPOINT "+name); err != nil {
+		return err
+	}
+
+	spCtx, hooks := persistence.NewTxHooks(ctx)
+	if err := fn(spCtx); err != nil {
+		if _, rbErr := tx.ExecContext(ctx, "ROLLBACK TO SAVEPOINT "+name); rbErr != nil {
+			return errors.Join(err, rbErr)
+		}
+		hooks.RolledBack(ctx)
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, "RELEASE SAVEPOINT "+name); err != nil {
+		return err
+	}
+	hooks.Committed(ctx)
+
+	return nil
 }
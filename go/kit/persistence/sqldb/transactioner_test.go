@@ -3,10 +3,14 @@ package sqldb_test
 import (
 	"context"
 	"errors"
+	"path/filepath"
 	"testing"
+	"time"
 
+	"github.com/dosanma1/forge/go/kit/persistence"
 	"github.com/dosanma1/forge/go/kit/persistence/sqldb"
 	"github.com/dosanma1/forge/go/kit/persistence/sqldb/sqldbtest"
+	"github.com/dosanma1/forge/go/kit/retry"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
@@ -82,3 +86,146 @@ func TestTransactionerIntegration(t *testing.T) {
 		assert.Equal(t, 2, count)
 	})
 }
+
+// serializationErr is a driver error carrying a SQLSTATE.
+type serializationErr struct{ state string }
+
+func (e serializationErr) Error() string    { return "could not serialize access" }
+func (e serializationErr) SQLState() string { return e.state }
+
+func TestTransactionerExecWith(t *testing.T) {
+	db, err := sqldb.Connect(sqldb.MustGenerateDSN(
+		sqldb.DriverTypeSQLite, sqldb.WithConnDBName(filepath.Join(t.TempDir(), "tx.db")),
+	))
+	require.NoError(t, err)
+	defer db.Close()
+	_, err = db.Exec("CREATE TABLE tx_test (id INT PRIMARY KEY)")
+	require.NoError(t, err)
+
+	trx := sqldb.NewTransactioner(db)
+	ctx := context.Background()
+	insert := func(ctx context.Context, id int) error {
+		_, err := sqldb.GetTx(ctx, db).ExecContext(ctx, "INSERT INTO tx_test (id) VALUES (?)", id)
+		return err
+	}
+	exists := func(id int) bool {
+		var count int
+		require.NoError(t, db.QueryRow("SELECT COUNT(*) FROM tx_test WHERE id = ?", id).Scan(&count))
+		return count > 0
+	}
+
+	t.Run("savepoint rolls back the nested call only", func(t *testing.T) {
+		err := trx.ExecWith(ctx, func(ctx context.Context) error {
+			require.NoError(t, insert(ctx, 1))
+			err := trx.ExecWith(ctx, func(ctx context.Context) error {
+				require.NoError(t, insert(ctx, 2))
+				return errors.New("abort")
+			}, persistence.WithSavepoint())
+			require.Error(t, err)
+
+			return trx.ExecWith(ctx, func(ctx context.Context) error {
+				return insert(ctx, 3)
+			}, persistence.WithSavepoint())
+		})
+		require.NoError(t, err)
+
+		assert.True(t, exists(1))
+		assert.False(t, exists(2))
+		assert.True(t, exists(3))
+	})
+
+	t.Run("panics roll back", func(t *testing.T) {
+		rolledBack := false
+		assert.PanicsWithValue(t, "boom", func() {
+			_ = trx.ExecWith(ctx, func(ctx context.Context) error {
+				require.NoError(t, insert(ctx, 4))
+				persistence.AfterRollback(ctx, func(context.Context) { rolledBack = true })
+				panic("boom")
+			})
+		})
+
+		assert.False(t, exists(4))
+		assert.True(t, rolledBack)
+	})
+
+	t.Run("serialization failures are retried", func(t *testing.T) {
+		attempts := 0
+		err := trx.ExecWith(ctx, func(ctx context.Context) error {
+			attempts++
+			if err := insert(ctx, 10); err != nil {
+				return err
+			}
+			if attempts < 3 {
+				return serializationErr{state: "40001"}
+			}
+			return nil
+		}, persistence.WithRetry(retry.WithConstantPolicy(time.Millisecond)))
+		require.NoError(t, err)
+
+		assert.Equal(t, 3, attempts)
+		assert.True(t, exists(10))
+	})
+
+	t.Run("other failures are not retried", func(t *testing.T) {
+		attempts := 0
+		err := trx.ExecWith(ctx, func(ctx context.Context) error {
+			attempts++
+			return errors.New("abort")
+		}, persistence.WithRetry(retry.WithConstantPolicy(time.Millisecond)))
+		require.Error(t, err)
+
+		assert.Equal(t, 1, attempts)
+	})
+
+	t.Run("retries give up", func(t *testing.T) {
+		attempts := 0
+		err := trx.ExecWith(ctx, func(ctx context.Context) error {
+			attempts++
+			return serializationErr{state: "40P01"}
+		}, persistence.WithRetry(retry.WithConstantPolicy(time.Millisecond), retry.WithMaxRetries(2)))
+		require.Error(t, err)
+
+		assert.True(t, sqldb.IsSerializationFailure(err))
+		assert.Equal(t, 2, attempts)
+	})
+
+	t.Run("hooks", func(t *testing.T) {
+		var events []string
+		hook := func(event string) func(context.Context) {
+			return func(context.Context) { events = append(events, event) }
+		}
+
+		err := trx.ExecWith(ctx, func(ctx context.Context) error {
+			persistence.AfterCommit(ctx, hook("commit"))
+			persistence.AfterRollback(ctx, hook("rollback"))
+
+			_ = trx.ExecWith(ctx, func(ctx context.Context) error {
+				persistence.AfterCommit(ctx, hook("savepoint commit"))
+				persistence.AfterRollback(ctx, hook("savepoint rollback"))
+				return errors.New("abort")
+			}, persistence.WithSavepoint())
+			assert.Equal(t, []string{"savepoint rollback"}, events)
+
+			return trx.ExecWith(ctx, func(ctx context.Context) error {
+				persistence.AfterCommit(ctx, hook("released commit"))
+				return nil
+			}, persistence.WithSavepoint())
+		})
+		require.NoError(t, err)
+		assert.Equal(t, []string{"savepoint rollback", "commit", "released commit"}, events)
+
+		events = nil
+		err = trx.Exec(ctx, func(ctx context.Context) error {
+			persistence.AfterCommit(ctx, hook("commit"))
+			persistence.AfterRollback(ctx, hook("rollback"))
+			return errors.New("abort")
+		})
+		require.Error(t, err)
+		assert.Equal(t, []string{"rollback"}, events)
+
+		events = nil
+		persistence.AfterCommit(ctx, hook("no transaction"))
+		persistence.AfterRollback(ctx, hook("never"))
+		assert.Equal(t, []string{"no transaction"}, events)
+	})
+}
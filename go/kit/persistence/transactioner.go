@@ -1,6 +1,17 @@
 package persistence
 
-import "context"
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/dosanma1/forge/go/kit/retry"
+)
+
+const (
+	defaultTxRetries       = 5
+	defaultTxRetryInterval = 10 * time.Millisecond
+)
 
 // TxFunc is a function that runs within a transaction
 type TxFunc func(ctx context.Context) error
@@ -12,4 +23,86 @@ type Transactioner interface {
 	// Otherwise, the transaction is committed
 	// Supports nested transactions by reusing existing transaction in context
 	Exec(ctx context.Context, fn TxFunc) error
+	// ExecWith executes a function within a transaction configured by the
+	// options, see TxOption. Without WithSavepoint, nested calls reuse the
+	// existing transaction of the context and ignore the options.
+	ExecWith(ctx context.Context, fn TxFunc, opts ...TxOption) error
+}
+
+// TxOption configures a transaction run by Transactioner.ExecWith.
+type TxOption func(c *TxConfig)
+
+// TxConfig is the configuration of a transaction, see NewTxConfig.
+type TxConfig struct {
+	Isolation  sql.IsolationLevel
+	ReadOnly   bool
+	Deferrable bool
+	Savepoint  bool
+	Retry      bool
+	RetryOpts  []retry.Option
+}
+
+// NewTxConfig returns the configuration set by the options.
+func NewTxConfig(opts ...TxOption) TxConfig {
+	c := TxConfig{}
+	for _, opt := range opts {
+		opt(&c)
+	}
+
+	return c
+}
+
+// TxOptions returns the database/sql options of the transaction.
+func (c TxConfig) TxOptions() *sql.TxOptions {
+	return &sql.TxOptions{Isolation: c.Isolation, ReadOnly: c.ReadOnly}
+}
+
+// WithIsolation sets the isolation level of the transaction. Defaults to the
+// database default level.
+func WithIsolation(level sql.IsolationLevel) TxOption {
+	return func(c *TxConfig) {
+		c.Isolation = level
+	}
+}
+
+// WithReadOnly makes the transaction read only.
+func WithReadOnly() TxOption {
+	return func(c *TxConfig) {
+		c.ReadOnly = true
+	}
+}
+
+// WithDeferrable makes the transaction deferrable, which only PostgreSQL
+// supports: a serializable read only deferrable transaction waits for a
+// snapshot free of serialization anomalies and then runs without failing
+// nor blocking the writers.
+func WithDeferrable() TxOption {
+	return func(c *TxConfig) {
+		c.Deferrable = true
+	}
+}
+
+// WithSavepoint runs a nested call in a savepoint of the existing
+// transaction of the context, so that its failure rolls back its own
+// changes only and leaves the outer transaction usable. The call starts a
+// new transaction when there is none.
+func WithSavepoint() TxOption {
+	return func(c *TxConfig) {
+		c.Savepoint = true
+	}
+}
+
+// WithRetry runs the whole transaction again when it fails on a
+// serialization failure or a deadlock, e.g. SQLSTATE 40001 and 40P01, as
+// configured by the retry options. Defaults to 5 tries backing off
+// exponentially from 10ms. Nested calls are never retried on their own,
+// the outermost transaction is.
+func WithRetry(opts ...retry.Option) TxOption {
+	return func(c *TxConfig) {
+		c.Retry = true
+		c.RetryOpts = append([]retry.Option{
+			retry.WithMaxRetries(defaultTxRetries),
+			retry.WithInitialInterval(defaultTxRetryInterval),
+		}, opts...)
+	}
 }
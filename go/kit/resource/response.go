@@ -5,9 +5,49 @@ type ListResponse[T any] interface {
 	TotalCount() int
 }
 
+// CursorListResponse is optionally implemented by the list responses of keyset
+// paginated lists. NextCursor and PrevCursor are the cursors of the adjacent
+// pages, empty when there is no such page.
+type CursorListResponse interface {
+	NextCursor() string
+	PrevCursor() string
+}
+
+// MetaListResponse is optionally implemented by the list responses carrying
+// non-resource information about the list, such as search snippets, rendered
+// as the top-level meta of the response.
+type MetaListResponse interface {
+	Meta() map[string]any
+}
+
 type listRes[T any] struct {
 	items []T
 	count int
+	listExtras
+}
+
+type listExtras struct {
+	next string
+	prev string
+	meta map[string]any
+}
+
+type ListResponseOption func(c *listExtras)
+
+func WithCursors(next, prev string) ListResponseOption {
+	return func(c *listExtras) {
+		c.next = next
+		c.prev = prev
+	}
+}
+
+func WithMeta(key string, value any) ListResponseOption {
+	return func(c *listExtras) {
+		if c.meta == nil {
+			c.meta = make(map[string]any)
+		}
+		c.meta[key] = value
+	}
 }
 
 func (lr *listRes[T]) Results() []T {
@@ -18,6 +58,18 @@ func (lr *listRes[T]) TotalCount() int {
 	return lr.count
 }
 
+func (lr *listRes[T]) NextCursor() string {
+	return lr.next
+}
+
+func (lr *listRes[T]) PrevCursor() string {
+	return lr.prev
+}
+
+func (lr *listRes[T]) Meta() map[string]any {
+	return lr.meta
+}
+
 func NewEmptyListResponse[T any]() *listRes[T] {
 	return &listRes[T]{
 		items: []T{},
@@ -25,21 +77,29 @@ func NewEmptyListResponse[T any]() *listRes[T] {
 	}
 }
 
-func NewListResponse[T any](items []T, count int) *listRes[T] {
-	return &listRes[T]{
+func NewListResponse[T any](items []T, count int, opts ...ListResponseOption) *listRes[T] {
+	lr := &listRes[T]{
 		items: items,
 		count: count,
 	}
+	for _, opt := range opts {
+		opt(&lr.listExtras)
+	}
+
+	return lr
 }
 
 type (
 	ListResponseDTO[DTO any] struct {
 		RResults   []DTO          `json:"results"`
 		Pagination *PaginationDTO `json:"pagination"`
+		Meta       map[string]any `json:"meta,omitempty"`
 	}
 
 	PaginationDTO struct {
-		TotalCount int `json:"totalCount"`
+		TotalCount int    `json:"totalCount"`
+		NextCursor string `json:"nextCursor,omitempty"`
+		PrevCursor string `json:"prevCursor,omitempty"`
 	}
 )
 
@@ -48,10 +108,18 @@ func ListResponseToDTO[DTO any, R any](
 ) func(res ListResponse[R]) *ListResponseDTO[DTO] {
 	return func(coll ListResponse[R]) *ListResponseDTO[DTO] {
 		var items []R
-		var count int
+		var meta map[string]any
+		pagination := new(PaginationDTO)
 		if coll != nil {
 			items = coll.Results()
-			count = coll.TotalCount()
+			pagination.TotalCount = coll.TotalCount()
+		}
+		if c, ok := coll.(CursorListResponse); ok {
+			pagination.NextCursor = c.NextCursor()
+			pagination.PrevCursor = c.PrevCursor()
+		}
+		if m, ok := coll.(MetaListResponse); ok {
+			meta = m.Meta()
 		}
 
 		dtos := make([]DTO, len(items))
@@ -60,10 +128,9 @@ func ListResponseToDTO[DTO any, R any](
 		}
 
 		return &ListResponseDTO[DTO]{
-			RResults: dtos,
-			Pagination: &PaginationDTO{
-				TotalCount: count,
-			},
+			RResults:   dtos,
+			Pagination: pagination,
+			Meta:       meta,
 		}
 	}
 }
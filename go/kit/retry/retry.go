@@ -2,6 +2,7 @@ package retry
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"time"
 
@@ -203,3 +204,37 @@ func RetryWithDataAndContext[T any](ctx context.Context, operation func() (T, er
 
 	return backoff.Retry(ctx, operation, retryOpts...)
 }
+
+// Permanent wraps err so that the retries stop right away, returning err.
+func Permanent(err error) error {
+	if err == nil {
+		return nil
+	}
+
+	return backoff.Permanent(err)
+}
+
+// IsPermanent reports whether err was wrapped by Permanent.
+func IsPermanent(err error) bool {
+	var permanent *backoff.PermanentError
+
+	return errors.As(err, &permanent)
+}
+
+// Delay returns the back off delay before the given retry, starting at 1,
+// e.g. to schedule the retries of work persisted between attempts rather
+// than retried in a loop.
+func Delay(attempt int, opts ...Option) time.Duration {
+	config := config{}
+	for _, opt := range append(defaultOptions(), opts...) {
+		opt(&config)
+	}
+
+	b := config.BackOff()
+	delay := time.Duration(0)
+	for range max(attempt, 1) {
+		delay = b.NextBackOff()
+	}
+
+	return delay
+}
@@ -3,6 +3,7 @@ package retry_test
 import (
 	"context"
 	"errors"
+	"fmt"
 	"testing"
 	"time"
 
@@ -320,3 +321,39 @@ func TestPolicyOptions(t *testing.T) {
 		})
 	}
 }
+
+func TestPermanent(t *testing.T) {
+	permanentErr := errors.New("permanent error")
+	callCount := 0
+	err := retry.Retry(func() error {
+		callCount++
+		return retry.Permanent(permanentErr)
+	}, retry.WithConstantPolicy(time.Millisecond), retry.WithMaxRetries(5))
+
+	assert.ErrorIs(t, err, permanentErr)
+	assert.Equal(t, 1, callCount)
+	assert.NoError(t, retry.Permanent(nil))
+}
+
+func TestIsPermanent(t *testing.T) {
+	err := errors.New("failed")
+
+	assert.False(t, retry.IsPermanent(err))
+	assert.True(t, retry.IsPermanent(retry.Permanent(err)))
+	assert.True(t, retry.IsPermanent(fmt.Errorf("wrapped: %w", retry.Permanent(err))))
+}
+
+func TestDelay(t *testing.T) {
+	exponential := []retry.Option{
+		retry.WithInitialInterval(time.Second),
+		retry.WithRandomizationFactor(0),
+		retry.WithMultiplier(2),
+		retry.WithMaxInterval(5 * time.Second),
+	}
+
+	assert.Equal(t, time.Second, retry.Delay(0, exponential...))
+	assert.Equal(t, time.Second, retry.Delay(1, exponential...))
+	assert.Equal(t, 4*time.Second, retry.Delay(3, exponential...))
+	assert.Equal(t, 5*time.Second, retry.Delay(10, exponential...))
+	assert.Equal(t, time.Minute, retry.Delay(7, retry.WithConstantPolicy(time.Minute)))
+}
@@ -0,0 +1,21 @@
+package scheduler
+
+import "time"
+
+// Clock tells the time to the scheduler, see schedulertest.Clock to control
+// it from tests.
+type Clock interface {
+	Now() time.Time
+	// After sends the current time on the returned channel once d elapsed.
+	After(d time.Duration) <-chan time.Time
+}
+
+type realClock struct{}
+
+func (realClock) Now() time.Time {
+	return time.Now()
+}
+
+func (realClock) After(d time.Duration) <-chan time.Time {
+	return time.After(d)
+}
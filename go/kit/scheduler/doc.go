@@ -0,0 +1,24 @@
+// Package scheduler runs periodic jobs on cron schedules, once per schedule
+// across the replicas of a service.
+//
+// It includes functionality for:
+//   - Cron expressions, with optional seconds, descriptors such as @daily and
+//     @every intervals, see Parse
+//   - Time zones, either per job, see WithLocation, or in the expression,
+//     e.g. CRON_TZ=Europe/Madrid 0 9 * * *
+//   - Jitter spreading the runs of the jobs sharing a schedule
+//   - A missed run policy for the runs missed while a previous run lasted,
+//     or while the process was suspended
+//   - Single runs across replicas, guarded by a Postgres advisory lock or a
+//     Redis lock, see Locker
+//   - Registration through fx, see NewFxJob, and a fake clock for tests, see
+//     schedulertest.Clock
+//
+// Example usage:
+//
+//	s := scheduler.New(monitor, scheduler.WithLocker(scheduler.NewPostgresLocker(db)))
+//	err := s.Add("purge-sessions", "@every 10m", func(ctx context.Context) error {
+//		return sessions.Purge(ctx)
+//	}, scheduler.WithJitter(time.Minute))
+//	err = s.Start(ctx)
+package scheduler
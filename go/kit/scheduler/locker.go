@@ -0,0 +1,100 @@
+package scheduler
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"hash/fnv"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+const redisLockPrefix = "scheduler:lock:"
+
+// Locker claims the runs of the jobs so that a single replica runs each of
+// them. The keys identify a run, i.e. a job and the time it is scheduled
+// at.
+type Locker interface {
+	// TryLock claims the run of key for at least ttl, returning false when
+	// another replica claimed it first. release gives the claim up, not
+	// before ttl elapsed.
+	TryLock(ctx context.Context, key string, ttl time.Duration) (release func(), acquired bool, err error)
+}
+
+type postgresLocker struct {
+	db *sql.DB
+}
+
+// NewPostgresLocker returns a Locker taking the session level PostgreSQL
+// advisory lock of the run, on a connection of db held until the lock is
+// released.
+func NewPostgresLocker(db *sql.DB) Locker {
+	return &postgresLocker{db: db}
+}
+
+func (l *postgresLocker) TryLock(ctx context.Context, key string, ttl time.Duration) (func(), bool, error) {
+	conn, err := l.db.Conn(ctx)
+	if err != nil {
+		return nil, false, err
+	}
+
+	id := advisoryLockID(key)
+	acquired := false
+	if err := conn.QueryRowContext(ctx, "SELECT pg_try_advisory_lock($1)", id).Scan(&acquired); err != nil {
+		conn.Close()
+		return nil, false, err
+	}
+	if !acquired {
+		conn.Close()
+		return nil, false, nil
+	}
+
+	acquiredAt := time.Now()
+	unlock := func() {
+		if _, err := conn.ExecContext(context.Background(), "SELECT pg_advisory_unlock($1)", id); err != nil {
+			// drop the connection rather than returning it to the pool
+			// still holding the lock
+			_ = conn.Raw(func(any) error { return driver.ErrBadConn })
+		}
+		conn.Close()
+	}
+	release := func() {
+		if remaining := ttl - time.Since(acquiredAt); remaining > 0 {
+			time.AfterFunc(remaining, unlock)
+			return
+		}
+		unlock()
+	}
+
+	return release, true, nil
+}
+
+// advisoryLockID returns the advisory lock of key.
+func advisoryLockID(key string) int64 {
+	h := fnv.New64a()
+	h.Write([]byte(key))
+
+	return int64(h.Sum64()) //nolint:gosec // the lock ids wrap around on purpose
+}
+
+type redisLocker struct {
+	cli redis.UniversalClient
+}
+
+// NewRedisLocker returns a Locker setting a key of the run expiring after
+// the ttl, e.g. on a *redisdb.Client.
+func NewRedisLocker(cli redis.UniversalClient) Locker {
+	return &redisLocker{cli: cli}
+}
+
+func (l *redisLocker) TryLock(ctx context.Context, key string, ttl time.Duration) (func(), bool, error) {
+	acquired, err := l.cli.SetNX(ctx, redisLockPrefix+key, time.Now().UTC().Format(time.RFC3339Nano), ttl).Result()
+	if err != nil {
+		return nil, false, err
+	}
+
+	// the key identifies a single run, it is left to expire so that the
+	// replicas late to the run do not claim it again
+	return func() {}, acquired, nil
+}
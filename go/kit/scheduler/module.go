@@ -0,0 +1,58 @@
+package scheduler
+
+import (
+	"context"
+	"fmt"
+
+	"go.uber.org/fx"
+
+	"github.com/dosanma1/forge/go/kit/monitoring"
+)
+
+type schedulerParams struct {
+	fx.In
+
+	Monitor monitoring.Monitor
+	Locker  Locker `optional:"true"`
+}
+
+// FxModule provides a Scheduler started and stopped along with the
+// application. Its runs are guarded by the Locker of the container if any,
+// e.g.:
+//
+//	fx.Provide(scheduler.NewPostgresLocker)
+func FxModule(opts ...Option) fx.Option {
+	return fx.Module("scheduler",
+		fx.Provide(func(p schedulerParams) *Scheduler {
+			if p.Locker == nil {
+				return New(p.Monitor, opts...)
+			}
+			return New(p.Monitor, append([]Option{WithLocker(p.Locker)}, opts...)...)
+		}),
+		fx.Invoke(func(lc fx.Lifecycle, s *Scheduler) {
+			lc.Append(fx.Hook{
+				OnStart: func(ctx context.Context) error {
+					return s.Start(ctx)
+				},
+				OnStop: func(ctx context.Context) error {
+					return s.Stop(ctx)
+				},
+			})
+		}),
+	)
+}
+
+// NewFxJob adds a job to the Scheduler of FxModule, running the Func
+// returned by constructor on the schedule spec, see Scheduler.Add:
+//
+//	scheduler.NewFxJob("purge-sessions", "@every 10m", func(repo SessionRepo) scheduler.Func {
+//		return repo.Purge
+//	}, scheduler.WithJitter(time.Minute))
+func NewFxJob(name, spec string, constructor any, opts ...JobOption) fx.Option {
+	return fx.Module(fmt.Sprintf("scheduler:job:%s", name),
+		fx.Provide(fx.Private, constructor),
+		fx.Invoke(func(s *Scheduler, fn Func) error {
+			return s.Add(name, spec, fn, opts...)
+		}),
+	)
+}
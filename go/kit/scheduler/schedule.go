@@ -0,0 +1,93 @@
+package scheduler
+
+import (
+	"fmt"
+	"hash/fnv"
+	"time"
+
+	"github.com/robfig/cron/v3"
+
+	"github.com/dosanma1/forge/go/kit/errors"
+)
+
+//nolint:gochecknoglobals // the parser is stateless and shared by every job
+var parser = cron.NewParser(
+	cron.SecondOptional | cron.Minute | cron.Hour | cron.Dom | cron.Month | cron.Dow | cron.Descriptor,
+)
+
+// Schedule returns the next run of a job.
+type Schedule interface {
+	// Next returns the first run after t, in the location of t unless the
+	// schedule has its own time zone.
+	Next(t time.Time) time.Time
+}
+
+// Parse parses a schedule, either:
+//   - a cron expression of 5 fields, or 6 starting with the seconds, e.g.
+//     */15 9-17 * * MON-FRI
+//   - a descriptor: @yearly, @monthly, @weekly, @daily or @hourly
+//   - an interval: @every 1h30m, whose runs are aligned to the Unix epoch
+//     so that they fall at the same times on every replica
+//
+// Cron expressions and descriptors may start with the time zone they are
+// expressed in, e.g. CRON_TZ=America/New_York 0 9 * * *.
+func Parse(spec string) (Schedule, error) {
+	s, err := parser.Parse(spec)
+	if err != nil {
+		return nil, errors.InvalidArgument(fmt.Sprintf("invalid schedule %q: %v", spec, err))
+	}
+	if every, ok := s.(cron.ConstantDelaySchedule); ok {
+		return intervalSchedule{interval: every.Delay}, nil
+	}
+
+	return s, nil
+}
+
+// intervalSchedule runs every interval since the Unix epoch. Unlike
+// cron.ConstantDelaySchedule, its runs do not depend on when the replica
+// started, so they identify the same run across replicas.
+type intervalSchedule struct {
+	interval time.Duration
+}
+
+func (s intervalSchedule) Next(t time.Time) time.Time {
+	runs := t.UnixNano() / int64(s.interval)
+
+	return time.Unix(0, (runs+1)*int64(s.interval)).In(t.Location())
+}
+
+// MissedRuns is what a job does about the runs it missed, because its
+// previous run lasted longer than the interval between runs or because the
+// process was suspended.
+type MissedRuns int
+
+const (
+	// MissedRunsSkip skips the missed runs and waits for the next one.
+	MissedRunsSkip MissedRuns = iota
+	// MissedRunsRunOnce runs once right away for all the missed runs.
+	MissedRunsRunOnce
+)
+
+func (m MissedRuns) String() string {
+	switch m {
+	case MissedRunsSkip:
+		return "skip"
+	case MissedRunsRunOnce:
+		return "run once"
+	default:
+		return fmt.Sprintf("MissedRuns(%d)", int(m))
+	}
+}
+
+// jitter returns the delay of the run of the job at t, up to max. It only
+// depends on them so that every replica delays the run the same.
+func jitter(name string, t time.Time, maxJitter time.Duration) time.Duration {
+	if maxJitter <= 0 {
+		return 0
+	}
+
+	h := fnv.New64a()
+	fmt.Fprintf(h, "%s@%d", name, t.UnixNano())
+
+	return time.Duration(h.Sum64() % uint64(maxJitter))
+}
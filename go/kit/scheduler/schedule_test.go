@@ -0,0 +1,26 @@
+package scheduler
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestJitter(t *testing.T) {
+	tick := time.Date(2024, 1, 15, 10, 0, 0, 0, time.UTC)
+
+	assert.Zero(t, jitter("report", tick, 0))
+
+	delays := map[time.Duration]struct{}{}
+	for i := range 10 {
+		run := tick.Add(time.Duration(i) * time.Hour)
+		d := jitter("report", run, 10*time.Minute)
+		assert.Equal(t, d, jitter("report", run, 10*time.Minute), "replicas delay a run alike")
+		assert.GreaterOrEqual(t, d, time.Duration(0))
+		assert.Less(t, d, 10*time.Minute)
+		delays[d] = struct{}{}
+	}
+	assert.Greater(t, len(delays), 1, "runs are delayed differently")
+	assert.NotEqual(t, jitter("report", tick, time.Hour), jitter("backup", tick, time.Hour))
+}
@@ -0,0 +1,294 @@
+package scheduler
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/dosanma1/forge/go/kit/errors"
+	"github.com/dosanma1/forge/go/kit/monitoring"
+	"github.com/dosanma1/forge/go/kit/monitoring/logger"
+)
+
+const defaultLockTTL = time.Minute
+
+// Func is the function run by a job.
+type Func func(ctx context.Context) error
+
+type (
+	// Option configures a Scheduler.
+	Option func(c *config)
+	config struct {
+		locker Locker
+		clock  Clock
+	}
+)
+
+// WithLocker guards the runs of the jobs with l so that a single replica
+// runs each of them. Every replica runs them otherwise.
+func WithLocker(l Locker) Option {
+	return func(c *config) {
+		c.locker = l
+	}
+}
+
+// WithClock sets the clock of the scheduler, see schedulertest.Clock.
+// Defaults to the system clock.
+func WithClock(clock Clock) Option {
+	return func(c *config) {
+		c.clock = clock
+	}
+}
+
+type (
+	// JobOption configures a job.
+	JobOption func(c *jobConfig)
+	jobConfig struct {
+		location *time.Location
+		jitter   time.Duration
+		missed   MissedRuns
+		timeout  time.Duration
+		lockTTL  time.Duration
+	}
+)
+
+// WithLocation sets the time zone of the schedule of the job, unless it has
+// its own, see Parse. Defaults to UTC.
+func WithLocation(loc *time.Location) JobOption {
+	return func(c *jobConfig) {
+		c.location = loc
+	}
+}
+
+// WithJitter delays every run of the job up to d, e.g. to spread the jobs
+// sharing a schedule. The delay of a run is the same on every replica.
+func WithJitter(d time.Duration) JobOption {
+	return func(c *jobConfig) {
+		c.jitter = d
+	}
+}
+
+// WithMissedRuns sets what the job does about its missed runs. Defaults to
+// MissedRunsSkip.
+func WithMissedRuns(m MissedRuns) JobOption {
+	return func(c *jobConfig) {
+		c.missed = m
+	}
+}
+
+// WithTimeout cancels the context of the runs of the job lasting longer
+// than d.
+func WithTimeout(d time.Duration) JobOption {
+	return func(c *jobConfig) {
+		c.timeout = d
+	}
+}
+
+// WithLockTTL sets how long a run stays claimed, see Locker, once its
+// jitter elapsed, which must cover the clock skew between the replicas.
+// It is capped to the interval between runs. Defaults to 1m.
+func WithLockTTL(d time.Duration) JobOption {
+	return func(c *jobConfig) {
+		c.lockTTL = d
+	}
+}
+
+type job struct {
+	name     string
+	schedule Schedule
+	fn       Func
+	jobConfig
+}
+
+// next returns the first run of the job after t.
+func (j *job) next(t time.Time) time.Time {
+	return j.schedule.Next(t.In(j.location))
+}
+
+// Scheduler runs jobs on their schedule.
+type Scheduler struct {
+	log logger.Logger
+	config
+
+	mu      sync.Mutex
+	jobs    []*job
+	started bool
+	stop    chan struct{}
+	loops   sync.WaitGroup
+	runCtx  context.Context
+	cancel  context.CancelFunc
+}
+
+// New returns a scheduler logging the runs of its jobs with the logger of m.
+func New(m monitoring.Monitor, opts ...Option) *Scheduler {
+	cfg := config{clock: realClock{}}
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+
+	return &Scheduler{log: m.Logger(), config: cfg}
+}
+
+// Add adds a job running fn on the schedule spec, see Parse. The names of
+// the jobs must be unique, since they identify their runs across replicas.
+func (s *Scheduler) Add(name, spec string, fn Func, opts ...JobOption) error {
+	schedule, err := Parse(spec)
+	if err != nil {
+		return err
+	}
+	cfg := jobConfig{location: time.UTC, lockTTL: defaultLockTTL}
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, j := range s.jobs {
+		if j.name == name {
+			return errors.AlreadyExists("scheduler job", name)
+		}
+	}
+
+	j := &job{name: name, schedule: schedule, fn: fn, jobConfig: cfg}
+	s.jobs = append(s.jobs, j)
+	if s.started {
+		s.loops.Add(1)
+		go s.loop(j)
+	}
+
+	return nil
+}
+
+// Start starts running the jobs on their schedule until Stop is called.
+func (s *Scheduler) Start(ctx context.Context) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.started {
+		return errors.Conflict("scheduler already started")
+	}
+
+	s.started = true
+	s.stop = make(chan struct{})
+	s.runCtx, s.cancel = context.WithCancel(context.WithoutCancel(ctx))
+	for _, j := range s.jobs {
+		s.loops.Add(1)
+		go s.loop(j)
+	}
+
+	s.log.InfoContext(ctx, "scheduler -> started", "jobs", len(s.jobs))
+	return nil
+}
+
+// Stop stops scheduling runs and waits for the running ones, cancelling
+// their context when ctx is done first.
+func (s *Scheduler) Stop(ctx context.Context) error {
+	s.mu.Lock()
+	if !s.started {
+		s.mu.Unlock()
+		return nil
+	}
+	s.started = false
+	close(s.stop)
+	s.mu.Unlock()
+
+	finished := make(chan struct{})
+	go func() {
+		s.loops.Wait()
+		close(finished)
+	}()
+	defer s.cancel()
+
+	select {
+	case <-finished:
+		return nil
+	case <-ctx.Done():
+		s.cancel()
+		<-finished
+		return ctx.Err()
+	}
+}
+
+// loop runs j on its schedule until the scheduler stops. The runs of a job
+// never overlap on a replica.
+func (s *Scheduler) loop(j *job) {
+	defer s.loops.Done()
+
+	tick := j.next(s.clock.Now())
+	catchingUp := false
+	for {
+		if tick.IsZero() {
+			s.log.WarnContext(s.runCtx, "scheduler:job -> schedule has no further runs", "job", j.name)
+			return
+		}
+		runAt := tick.Add(jitter(j.name, tick, j.jitter))
+		select {
+		case <-s.stop:
+			return
+		case <-s.clock.After(runAt.Sub(s.clock.Now())):
+		}
+
+		s.run(j, tick)
+
+		next, now := j.next(tick), s.clock.Now()
+		switch {
+		case next.After(now):
+			catchingUp = false
+		case j.missed == MissedRunsRunOnce && !catchingUp:
+			catchingUp = true
+			s.log.WarnContext(s.runCtx, "scheduler:job -> running missed runs once", "job", j.name, "missed", next)
+		default:
+			catchingUp = false
+			s.log.WarnContext(s.runCtx, "scheduler:job -> skipping missed runs", "job", j.name, "missed", next)
+			next = j.next(now)
+		}
+		tick = next
+	}
+}
+
+// run runs j for its run at tick, once the locker claimed the run if any.
+func (s *Scheduler) run(j *job, tick time.Time) {
+	ctx := s.runCtx
+	if j.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, j.timeout)
+		defer cancel()
+	}
+
+	if s.locker != nil {
+		ttl := j.jitter + j.lockTTL
+		if interval := j.next(tick).Sub(tick); interval > 0 && interval < ttl {
+			ttl = interval
+		}
+		release, acquired, err := s.locker.TryLock(ctx, fmt.Sprintf("%s:%d", j.name, tick.Unix()), ttl)
+		if err != nil {
+			s.log.ErrorContext(ctx, "scheduler:job -> error claiming run", "job", j.name, "run", tick, "err", err)
+			return
+		}
+		if !acquired {
+			s.log.DebugContext(ctx, "scheduler:job -> run claimed by another replica", "job", j.name, "run", tick)
+			return
+		}
+		defer release()
+	}
+
+	start := s.clock.Now()
+	err := safeRun(ctx, j.fn)
+	elapsed := s.clock.Now().Sub(start)
+	if err != nil {
+		s.log.ErrorContext(ctx, "scheduler:job -> run failed", "job", j.name, "run", tick, "elapsed", elapsed, "err", err)
+		return
+	}
+	s.log.InfoContext(ctx, "scheduler:job -> run completed", "job", j.name, "run", tick, "elapsed", elapsed)
+}
+
+// safeRun runs fn, turning its panics into errors.
+func safeRun(ctx context.Context, fn Func) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = errors.InternalError(fmt.Sprintf("job panicked: %v", r))
+		}
+	}()
+
+	return fn(ctx)
+}
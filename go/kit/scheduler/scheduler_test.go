@@ -0,0 +1,251 @@
+package scheduler_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/fx"
+	"go.uber.org/fx/fxtest"
+
+	apierrors "github.com/dosanma1/forge/go/kit/errors"
+	"github.com/dosanma1/forge/go/kit/monitoring"
+	"github.com/dosanma1/forge/go/kit/monitoring/logger/loggertest"
+	"github.com/dosanma1/forge/go/kit/scheduler"
+	"github.com/dosanma1/forge/go/kit/scheduler/schedulertest"
+)
+
+//nolint:gochecknoglobals // shared start time of the fake clocks
+var start = time.Date(2024, 1, 15, 10, 0, 0, 0, time.UTC)
+
+func newScheduler(t *testing.T, clock *schedulertest.Clock, opts ...scheduler.Option) *scheduler.Scheduler {
+	t.Helper()
+
+	s := scheduler.New(monitoring.New(loggertest.NewStubLogger(t)), append(opts, scheduler.WithClock(clock))...)
+	t.Cleanup(func() {
+		require.NoError(t, s.Stop(context.Background()))
+	})
+
+	return s
+}
+
+// runs returns a job function sending the time of its runs.
+func runs(clock *schedulertest.Clock) (scheduler.Func, chan time.Time) {
+	ch := make(chan time.Time, 10)
+	return func(context.Context) error {
+		ch <- clock.Now()
+		return nil
+	}, ch
+}
+
+func TestParse(t *testing.T) {
+	madrid, err := time.LoadLocation("Europe/Madrid")
+	require.NoError(t, err)
+
+	tests := []struct {
+		spec string
+		from time.Time
+		want time.Time
+	}{
+		{spec: "*/15 9-17 * * MON-FRI", from: start, want: start.Add(15 * time.Minute)},
+		{spec: "30 * * * * *", from: start, want: start.Add(30 * time.Second)},
+		{spec: "@daily", from: start, want: time.Date(2024, 1, 16, 0, 0, 0, 0, time.UTC)},
+		{spec: "@every 1h30m", from: start, want: start.Add(30 * time.Minute)},
+		{spec: "@every 1m", from: start.Add(17 * time.Second), want: start.Add(time.Minute)},
+		{spec: "0 9 * * *", from: start.In(madrid), want: time.Date(2024, 1, 16, 9, 0, 0, 0, madrid)},
+		{spec: "CRON_TZ=Europe/Madrid 0 12 * * *", from: start, want: time.Date(2024, 1, 15, 12, 0, 0, 0, madrid)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.spec, func(t *testing.T) {
+			s, err := scheduler.Parse(tt.spec)
+			require.NoError(t, err)
+			assert.True(t, tt.want.Equal(s.Next(tt.from)), "got %s", s.Next(tt.from))
+		})
+	}
+
+	_, err = scheduler.Parse("61 * * * *")
+	var apiErr apierrors.Error
+	require.ErrorAs(t, err, &apiErr)
+	assert.Equal(t, apierrors.CodeInvalidArgument, apiErr.Code())
+}
+
+func TestScheduler(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("runs the jobs on their schedule", func(t *testing.T) {
+		clock := schedulertest.NewClock(start)
+		s := newScheduler(t, clock)
+		fn, ran := runs(clock)
+		require.NoError(t, s.Add("every-minute", "@every 1m", fn))
+		require.NoError(t, s.Start(ctx))
+
+		for i := 1; i <= 3; i++ {
+			clock.BlockUntil(1)
+			clock.Advance(time.Minute)
+			assert.Equal(t, start.Add(time.Duration(i)*time.Minute), <-ran)
+		}
+	})
+
+	t.Run("in the time zone of the job", func(t *testing.T) {
+		madrid, err := time.LoadLocation("Europe/Madrid")
+		require.NoError(t, err)
+		clock := schedulertest.NewClock(time.Date(2024, 1, 15, 7, 59, 0, 0, time.UTC))
+		s := newScheduler(t, clock)
+		fn, ran := runs(clock)
+		require.NoError(t, s.Add("morning", "0 9 * * *", fn, scheduler.WithLocation(madrid)))
+		require.NoError(t, s.Start(ctx))
+
+		clock.BlockUntil(1)
+		clock.Advance(time.Minute)
+		assert.Equal(t, time.Date(2024, 1, 15, 8, 0, 0, 0, time.UTC), (<-ran).UTC())
+	})
+
+	t.Run("missed runs", func(t *testing.T) {
+		for name, tt := range map[string]struct {
+			policy scheduler.MissedRuns
+			want   []time.Duration
+		}{
+			"skipped":  {policy: scheduler.MissedRunsSkip, want: []time.Duration{time.Minute, 5 * time.Minute}},
+			"run once": {policy: scheduler.MissedRunsRunOnce, want: []time.Duration{time.Minute, 4*time.Minute + 30*time.Second, 5 * time.Minute}},
+		} {
+			t.Run(name, func(t *testing.T) {
+				clock := schedulertest.NewClock(start)
+				s := newScheduler(t, clock)
+				ran := make(chan time.Time, 10)
+				first := true
+				require.NoError(t, s.Add("slow", "* * * * *", func(context.Context) error {
+					ran <- clock.Now()
+					if first {
+						first = false
+						clock.Advance(3*time.Minute + 30*time.Second)
+					}
+					return nil
+				}, scheduler.WithMissedRuns(tt.policy)))
+				require.NoError(t, s.Start(ctx))
+
+				clock.BlockUntil(1)
+				clock.Advance(time.Minute)
+				for _, want := range tt.want {
+					if len(ran) == 0 {
+						clock.BlockUntil(1)
+						clock.Advance(start.Add(want).Sub(clock.Now()))
+					}
+					assert.Equal(t, start.Add(want), <-ran)
+				}
+			})
+		}
+	})
+
+	t.Run("keeps running the jobs after they fail or panic", func(t *testing.T) {
+		clock := schedulertest.NewClock(start)
+		s := newScheduler(t, clock)
+		ran := make(chan struct{}, 10)
+		require.NoError(t, s.Add("panicking", "@every 1m", func(context.Context) error {
+			ran <- struct{}{}
+			panic("boom")
+		}))
+		require.NoError(t, s.Start(ctx))
+
+		for range 2 {
+			clock.BlockUntil(1)
+			clock.Advance(time.Minute)
+			<-ran
+		}
+	})
+
+	t.Run("job names are unique", func(t *testing.T) {
+		s := newScheduler(t, schedulertest.NewClock(start))
+		require.NoError(t, s.Add("job", "@hourly", func(context.Context) error { return nil }))
+
+		err := s.Add("job", "@daily", func(context.Context) error { return nil })
+		var apiErr apierrors.Error
+		require.ErrorAs(t, err, &apiErr)
+		assert.Equal(t, apierrors.CodeAlreadyExists, apiErr.Code())
+	})
+}
+
+func TestSchedulerReplicas(t *testing.T) {
+	ctx := context.Background()
+	mr := miniredis.RunT(t)
+	locker := scheduler.NewRedisLocker(redis.NewClient(&redis.Options{Addr: mr.Addr()}))
+
+	clock := schedulertest.NewClock(start)
+	ran := make(chan string, 10)
+	for i, replica := range []string{"a", "b", "c"} {
+		s := newScheduler(t, clock, scheduler.WithLocker(locker))
+		require.NoError(t, s.Add("report", "@every 1m", func(context.Context) error {
+			ran <- replica
+			return nil
+		}))
+		require.NoError(t, s.Start(ctx))
+		// the replicas start at different times, yet share their runs
+		clock.BlockUntil(i + 1)
+		clock.Advance(15 * time.Second)
+	}
+
+	for i := 1; i <= 3; i++ {
+		clock.BlockUntil(3)
+		clock.Advance(start.Add(time.Duration(i) * time.Minute).Sub(clock.Now()))
+		<-ran
+		clock.BlockUntil(3)
+		assert.Empty(t, ran, "a single replica runs each run")
+	}
+}
+
+func TestRedisLocker(t *testing.T) {
+	ctx := context.Background()
+	mr := miniredis.RunT(t)
+	locker := scheduler.NewRedisLocker(redis.NewClient(&redis.Options{Addr: mr.Addr()}))
+
+	release, acquired, err := locker.TryLock(ctx, "report:1700000000", time.Minute)
+	require.NoError(t, err)
+	assert.True(t, acquired)
+	release()
+
+	_, acquired, err = locker.TryLock(ctx, "report:1700000000", time.Minute)
+	require.NoError(t, err)
+	assert.False(t, acquired, "released runs stay claimed until their ttl elapses")
+
+	_, acquired, err = locker.TryLock(ctx, "report:1700000060", time.Minute)
+	require.NoError(t, err)
+	assert.True(t, acquired)
+
+	mr.FastForward(time.Minute)
+	_, acquired, err = locker.TryLock(ctx, "report:1700000000", time.Minute)
+	require.NoError(t, err)
+	assert.True(t, acquired)
+}
+
+func TestNewFxJob(t *testing.T) {
+	clock := schedulertest.NewClock(start)
+	ran := make(chan string, 10)
+	newJob := func(name string) func() scheduler.Func {
+		return func() scheduler.Func {
+			return func(context.Context) error {
+				ran <- name
+				return nil
+			}
+		}
+	}
+
+	app := fxtest.New(t,
+		fx.Provide(func() monitoring.Monitor {
+			return monitoring.New(loggertest.NewStubLogger(t))
+		}),
+		scheduler.FxModule(scheduler.WithClock(clock)),
+		scheduler.NewFxJob("first", "@every 1m", newJob("first")),
+		scheduler.NewFxJob("second", "@every 2m", newJob("second")),
+	)
+	app.RequireStart()
+	defer app.RequireStop()
+
+	clock.BlockUntil(2)
+	clock.Advance(2 * time.Minute)
+	got := []string{<-ran, <-ran}
+	assert.ElementsMatch(t, []string{"first", "second"}, got)
+}
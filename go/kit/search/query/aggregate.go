@@ -0,0 +1,230 @@
+package query
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+type AggregateFunc uint
+
+const (
+	AggUndefined AggregateFunc = iota
+	AggCount
+	AggSum
+	AggAvg
+	AggMin
+	AggMax
+)
+
+func (f AggregateFunc) Valid() bool {
+	return f != AggUndefined && f <= AggMax
+}
+
+func (f AggregateFunc) String() string {
+	switch f {
+	case AggCount:
+		return "count"
+	case AggSum:
+		return "sum"
+	case AggAvg:
+		return "avg"
+	case AggMin:
+		return "min"
+	case AggMax:
+		return "max"
+	case AggUndefined:
+		return ""
+	default:
+		return ""
+	}
+}
+
+// TruncUnit buckets a time field, as postgres date_trunc does.
+type TruncUnit string
+
+const (
+	TruncNone    TruncUnit = ""
+	TruncMinute  TruncUnit = "minute"
+	TruncHour    TruncUnit = "hour"
+	TruncDay     TruncUnit = "day"
+	TruncWeek    TruncUnit = "week"
+	TruncMonth   TruncUnit = "month"
+	TruncQuarter TruncUnit = "quarter"
+	TruncYear    TruncUnit = "year"
+)
+
+func (u TruncUnit) Valid() bool {
+	switch u {
+	case TruncMinute, TruncHour, TruncDay, TruncWeek, TruncMonth, TruncQuarter, TruncYear:
+		return true
+	}
+	return false
+}
+
+// Metric is an aggregate computed for every bucket. Field is empty for
+// count(*).
+type Metric struct {
+	Func  AggregateFunc
+	Field string
+}
+
+// Name is the key of the metric in a Bucket, e.g. count or sum_amount.
+func (m Metric) Name() string {
+	if m.Field == "" {
+		return m.Func.String()
+	}
+	return m.Func.String() + "_" + aggregateName(m.Field)
+}
+
+func Count() Metric {
+	return Metric{Func: AggCount}
+}
+
+func CountOf(field string) Metric {
+	return Metric{Func: AggCount, Field: field}
+}
+
+func Sum(field string) Metric {
+	return Metric{Func: AggSum, Field: field}
+}
+
+func Avg(field string) Metric {
+	return Metric{Func: AggAvg, Field: field}
+}
+
+func Min(field string) Metric {
+	return Metric{Func: AggMin, Field: field}
+}
+
+func Max(field string) Metric {
+	return Metric{Func: AggMax, Field: field}
+}
+
+// GroupKey is a field the rows are grouped by, optionally truncated to a
+// time unit.
+type GroupKey struct {
+	Field string
+	Trunc TruncUnit
+}
+
+// Name is the key of the group value in a Bucket, e.g. status or
+// created_at_day.
+func (g GroupKey) Name() string {
+	if g.Trunc == TruncNone {
+		return aggregateName(g.Field)
+	}
+	return aggregateName(g.Field) + "_" + string(g.Trunc)
+}
+
+func aggregateName(field string) string {
+	return strings.ReplaceAll(field, ".", "_")
+}
+
+// Aggregation turns a query into a group-by query: the filters still apply
+// but the result is a list of Buckets instead of resources.
+type Aggregation struct {
+	Metrics []Metric
+	GroupBy []GroupKey
+}
+
+// Aggregate requests the given metrics. A query with an aggregation but
+// without metrics counts the rows of every bucket.
+func Aggregate(metrics ...Metric) Option {
+	return func(q *query) {
+		agg := q.aggregation()
+		for _, m := range metrics {
+			if m.Func.Valid() {
+				agg.Metrics = append(agg.Metrics, m)
+			}
+		}
+	}
+}
+
+func GroupBy(fields ...string) Option {
+	return func(q *query) {
+		agg := q.aggregation()
+		for _, f := range fields {
+			if f != "" {
+				agg.GroupBy = append(agg.GroupBy, GroupKey{Field: f})
+			}
+		}
+	}
+}
+
+// GroupByTrunc groups by a time field truncated to unit, e.g. sums per day.
+func GroupByTrunc(field string, unit TruncUnit) Option {
+	return func(q *query) {
+		if field == "" || !unit.Valid() {
+			return
+		}
+		agg := q.aggregation()
+		agg.GroupBy = append(agg.GroupBy, GroupKey{Field: field, Trunc: unit})
+	}
+}
+
+func (q *query) aggregation() *Aggregation {
+	if q.aggregate == nil {
+		q.aggregate = new(Aggregation)
+	}
+	return q.aggregate
+}
+
+// Bucket is a row of an aggregation: the group values, keyed by
+// GroupKey.Name, and the metrics, keyed by Metric.Name. Counts are int64,
+// sums and averages float64; min, max and group values keep the type of
+// their column.
+type Bucket struct {
+	Keys    map[string]any `json:"keys"`
+	Metrics map[string]any `json:"metrics"`
+}
+
+func (b Bucket) Key(name string) any {
+	return b.Keys[name]
+}
+
+func (b Bucket) KeyString(name string) string {
+	switch v := b.Keys[name].(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case []byte:
+		return string(v)
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+func (b Bucket) KeyTime(name string) time.Time {
+	t, _ := b.Keys[name].(time.Time)
+	return t
+}
+
+func (b Bucket) Count() int64 {
+	return b.Int(Count().Name())
+}
+
+func (b Bucket) Int(name string) int64 {
+	switch v := b.Metrics[name].(type) {
+	case int64:
+		return v
+	case float64:
+		return int64(v)
+	}
+	return 0
+}
+
+func (b Bucket) Float(name string) float64 {
+	switch v := b.Metrics[name].(type) {
+	case float64:
+		return v
+	case int64:
+		return float64(v)
+	}
+	return 0
+}
+
+func (b Bucket) Metric(name string) any {
+	return b.Metrics[name]
+}
@@ -2,10 +2,8 @@ package query
 
 import (
 	"context"
-	"encoding/base64"
 	"fmt"
-	"regexp"
-	"strings"
+	"net/url"
 	"time"
 
 	"github.com/dosanma1/forge/go/kit/resource"
@@ -49,70 +47,96 @@ func (c *cursor) Limit() int {
 	return c.limit
 }
 
-var valuesKeyRegexp = regexp.MustCompile(`^(id|created_at)\[(.*)\](.*)$`)
+// The sort keys of the lists paged by FormatNextURL.
+const (
+	fieldCreatedAt = "createdAt"
+	fieldID        = "id"
+)
 
+// EncodeCursor returns the signed keyset cursor, see CursorCodec, of the page
+// following the row created at createdAt with the given id, for lists sorted
+// by createdAt and id. It returns an empty cursor when the default codec has
+// no secret.
+//
+// Deprecated: params are no longer part of the cursor, the filters of a list
+// stay in the query of its page links, see FormatNextURL and PageURL.
 func EncodeCursor(createdAt time.Time, id string, params map[string]string) string {
-	keyChain := fmt.Sprintf("created_at[lte]=%s&id[lt]=%s", createdAt.Format(time.RFC3339Nano), id)
-	for k, v := range params {
-		if k == "cursor" {
-			cursorValue := strings.TrimPrefix(v, "cursor=")
-			if len(cursorValue) > 1 {
-				byt, err := base64.StdEncoding.DecodeString(cursorValue)
-				if err != nil {
-					// return errors.Internal(fmt.Sprintf("error decoding cursor"), err).Err()
-					return ""
-				}
-
-				cursorParams := strings.Split(string(byt), "&")
-				for _, param := range cursorParams {
-					if !valuesKeyRegexp.Match([]byte(param)) {
-						keyChain = fmt.Sprintf("%s&%s", keyChain, param)
-					}
-				}
-
-			}
-			continue
-		}
-		keyChain = fmt.Sprintf("%s&%s", keyChain, v)
+	cursor, err := DefaultCursorCodec().Encode(PageCursor{
+		Keys:   []string{fieldCreatedAt, fieldID},
+		Values: []any{createdAt, id},
+	})
+	if err != nil {
+		return ""
 	}
 
-	return base64.StdEncoding.EncodeToString([]byte(keyChain))
+	return cursor
 }
 
+// FormatNextURL returns the link of the page following r, a list sorted by
+// createdAt and id, on the host and path forwarded by the gateway. The fields
+// of req become the query of the link. It returns an empty link when r is
+// empty or req looks a resource up by id.
 func FormatNextURL[R resource.Resource](ctx context.Context, r []R, req protoreflect.ProtoMessage) (nextUrl string) {
-	if len(r) > 0 {
-		searchById := false
-		params := make(map[string]string, 0)
-		rft := req.ProtoReflect()
-		rft.Range(func(fd protoreflect.FieldDescriptor, v protoreflect.Value) bool {
-			if fd.TextName() == "id" {
-				searchById = true
-				return false
-			}
-
-			// Field that support operators
-			if fd.IsMap() {
-				v.Map().Range(func(mk protoreflect.MapKey, v protoreflect.Value) bool {
-					params[fd.TextName()] = fmt.Sprintf("%s[%s]=%s", fd.TextName(), mk.String(), v.String())
-					return true
-				})
-				return true
-			}
+	if len(r) == 0 {
+		return ""
+	}
 
-			params[fd.TextName()] = fmt.Sprintf("%s=%s", fd.TextName(), v.String())
+	searchById := false
+	values := url.Values{}
+	rft := req.ProtoReflect()
+	rft.Range(func(fd protoreflect.FieldDescriptor, v protoreflect.Value) bool {
+		if fd.TextName() == "id" {
+			searchById = true
+			return false
+		}
 
+		// Field that support operators
+		if fd.IsMap() {
+			v.Map().Range(func(mk protoreflect.MapKey, v protoreflect.Value) bool {
+				values.Set(fmt.Sprintf("%s[%s]", fd.TextName(), mk.String()), v.String())
+				return true
+			})
 			return true
-		})
-		if !searchById {
-			lastEl := r[len(r)-1]
-			cursor := EncodeCursor(lastEl.CreatedAt(), lastEl.ID(), params)
-
-			md, _ := metadata.FromIncomingContext(ctx)
-			if md != nil {
-				// TODO: Add schema from metadata context
-				nextUrl = fmt.Sprintf("http://%s%s?cursor=%s", md["x-forwarded-host"][0], md["x-forwarded-path"][0], cursor)
-			}
 		}
+
+		values.Set(fd.TextName(), v.String())
+
+		return true
+	})
+	if searchById {
+		return ""
 	}
-	return nextUrl
+
+	md, _ := metadata.FromIncomingContext(ctx)
+	host, path := md.Get("x-forwarded-host"), md.Get("x-forwarded-path")
+	if len(host) == 0 || len(path) == 0 {
+		return ""
+	}
+	lastEl := r[len(r)-1]
+	cursor := EncodeCursor(lastEl.CreatedAt(), lastEl.ID(), nil)
+	if cursor == "" {
+		return ""
+	}
+
+	// TODO: Add schema from metadata context
+	return PageURL(&url.URL{Scheme: "http", Host: host[0], Path: path[0], RawQuery: values.Encode()}, CursorAfter, cursor)
+}
+
+// PageURL returns u pointing at the page on the dir side of cursor: any page
+// cursor or offset of u is replaced by page[after] or page[before], the rest
+// of the query, e.g. filters, sorting and the page limit, is kept.
+func PageURL(u *url.URL, dir CursorDirection, cursor string) string {
+	values := u.Query()
+	for _, p := range []string{paramPageAfter, paramPageBefore, paramPageCursor, paramPageOffset} {
+		values.Del(p)
+	}
+	param := paramPageAfter
+	if dir == CursorBefore {
+		param = paramPageBefore
+	}
+	values.Set(param, cursor)
+
+	page := *u
+	page.RawQuery = values.Encode()
+	return page.String()
 }
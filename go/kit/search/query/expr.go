@@ -0,0 +1,535 @@
+package query
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	kiterrors "github.com/dosanma1/forge/go/kit/errors"
+	"github.com/dosanma1/forge/go/kit/filter"
+)
+
+// Filter expressions are an RSQL/FIQL flavoured text syntax for FilterNode
+// trees, accepted in the filter= URL parameter:
+//
+//	status==active;(age>18,vip==true)
+//	name=like='bob';created_at=btw=(2024-01-01,2024-02-01)
+//	!(role=in=(admin,owner)) or deleted_at==null
+//
+// ';' or "and" is a conjunction, ',' or "or" a disjunction and '!' a
+// negation; AND binds tighter than OR and parentheses group. Comparisons are
+// written selector operator argument, where the operator is one of ==, !=,
+// >, >=, <, <= or =name= with any name of Operators (=in=, =not-in=, =btw=,
+// ...; =out=, =ge= and =le= are accepted as aliases). Arguments are either
+// unquoted, single or double quoted values, or a parenthesized list. The
+// unquoted values null, true and false are parsed as nil and booleans, ==null
+// and !=null meaning IS NULL and IS NOT NULL; in lists, they are kept as text.
+
+const (
+	maxFilterExprDepth = 32
+
+	filterExprFormat = "filter expression"
+)
+
+//nolint:gochecknoglobals // operator tables used by the filter expression parser
+var (
+	filterExprSymbols = []struct {
+		sym string
+		op  filter.Operator
+	}{
+		// two character symbols first so that >= is not read as >
+		{"==", filter.OpEq},
+		{"!=", filter.OpNEq},
+		{">=", filter.OpGTEq},
+		{"<=", filter.OpLTEq},
+		{">", filter.OpGT},
+		{"<", filter.OpLT},
+	}
+
+	filterExprOperatorAliases = map[string]filter.Operator{
+		"out": filter.OpNotIn,
+		"ge":  filter.OpGTEq,
+		"le":  filter.OpLTEq,
+	}
+)
+
+// ParseFilterExpr parses a filter expression into a FilterNode. Syntax
+// errors are reported as errors.InvalidFormat whose message carries the
+// 1-based position of the offending character.
+func ParseFilterExpr(expr string) (*FilterNode, error) {
+	p := &exprParser{src: expr}
+	p.skipSpaces()
+	if p.eof() {
+		return nil, p.errorf("empty expression")
+	}
+	node, err := p.parseOr(0)
+	if err != nil {
+		return nil, err
+	}
+	p.skipSpaces()
+	if !p.eof() {
+		return nil, p.errorf("unexpected %q", p.peekRune())
+	}
+
+	return node, nil
+}
+
+type exprParser struct {
+	src string
+	pos int
+}
+
+func (p *exprParser) errorf(format string, args ...any) error {
+	return p.errorAt(p.pos, format, args...)
+}
+
+func (p *exprParser) errorAt(pos int, format string, args ...any) error {
+	msg := fmt.Sprintf("invalid filter expression at position %d: %s", pos+1, fmt.Sprintf(format, args...))
+	return kiterrors.InvalidFormat(filterParam, p.src, filterExprFormat, kiterrors.WithMessage(msg))
+}
+
+func (p *exprParser) eof() bool {
+	return p.pos >= len(p.src)
+}
+
+func (p *exprParser) peekRune() rune {
+	r, _ := utf8.DecodeRuneInString(p.src[p.pos:])
+	return r
+}
+
+func (p *exprParser) skipSpaces() {
+	for !p.eof() {
+		r, size := utf8.DecodeRuneInString(p.src[p.pos:])
+		if !unicode.IsSpace(r) {
+			return
+		}
+		p.pos += size
+	}
+}
+
+// consumeKeyword consumes a case insensitive "and"/"or" keyword, which must
+// be followed by a space, '(' or '!' so that it does not eat a selector.
+func (p *exprParser) consumeKeyword(kw string) bool {
+	end := p.pos + len(kw)
+	if end >= len(p.src) || !strings.EqualFold(p.src[p.pos:end], kw) {
+		return false
+	}
+	if next := p.src[end]; next != '(' && next != '!' && !unicode.IsSpace(rune(next)) {
+		return false
+	}
+	p.pos = end
+
+	return true
+}
+
+func (p *exprParser) consumeSeparator(sep byte, kw string) bool {
+	p.skipSpaces()
+	if !p.eof() && p.src[p.pos] == sep {
+		p.pos++
+		return true
+	}
+	return p.consumeKeyword(kw)
+}
+
+func (p *exprParser) parseOr(depth int) (*FilterNode, error) {
+	first, err := p.parseAnd(depth)
+	if err != nil {
+		return nil, err
+	}
+	nodes := []*FilterNode{first}
+	for p.consumeSeparator(',', filterGroupOr) {
+		n, err := p.parseAnd(depth)
+		if err != nil {
+			return nil, err
+		}
+		nodes = append(nodes, n)
+	}
+
+	return Or(nodes...), nil
+}
+
+func (p *exprParser) parseAnd(depth int) (*FilterNode, error) {
+	first, err := p.parseUnary(depth)
+	if err != nil {
+		return nil, err
+	}
+	nodes := []*FilterNode{first}
+	for p.consumeSeparator(';', filterGroupAnd) {
+		n, err := p.parseUnary(depth)
+		if err != nil {
+			return nil, err
+		}
+		nodes = append(nodes, n)
+	}
+
+	return And(nodes...), nil
+}
+
+func (p *exprParser) parseUnary(depth int) (*FilterNode, error) {
+	if depth > maxFilterExprDepth {
+		return nil, p.errorf("expression nested too deeply")
+	}
+	p.skipSpaces()
+	if p.eof() {
+		return nil, p.errorf("unexpected end of expression")
+	}
+
+	switch p.src[p.pos] {
+	case '!':
+		p.pos++
+		n, err := p.parseUnary(depth + 1)
+		if err != nil {
+			return nil, err
+		}
+		return Not(n), nil
+	case '(':
+		open := p.pos
+		p.pos++
+		n, err := p.parseOr(depth + 1)
+		if err != nil {
+			return nil, err
+		}
+		p.skipSpaces()
+		if p.eof() || p.src[p.pos] != ')' {
+			return nil, p.errorf("missing ')' for '(' at position %d", open+1)
+		}
+		p.pos++
+		return n, nil
+	}
+
+	return p.parseComparison()
+}
+
+func (p *exprParser) parseComparison() (*FilterNode, error) {
+	start := p.pos
+	selector := p.scanUnreserved()
+	if selector == "" {
+		return nil, p.errorf("expected a selector, got %q", p.peekRune())
+	}
+	if !isSelector(selector) {
+		return nil, p.errorAt(start, "invalid selector %q", selector)
+	}
+
+	p.skipSpaces()
+	opPos := p.pos
+	op, err := p.parseOperator()
+	if err != nil {
+		return nil, err
+	}
+
+	p.skipSpaces()
+	argPos := p.pos
+	val, isList, err := p.parseArgument()
+	if err != nil {
+		return nil, err
+	}
+
+	switch op {
+	case filter.OpIn, filter.OpNotIn, filter.OpContainsLike, filter.OpBetween, filter.OpHasAnyKeys, filter.OpHasAllKeys:
+		if val == nil {
+			return nil, p.errorAt(argPos, "operator %s does not take null", MarshalOperator(op))
+		}
+		if !isList {
+			val = []string{fmt.Sprint(val)}
+		}
+		if op == filter.OpBetween && len(val.([]string)) != 2 {
+			return nil, p.errorAt(argPos, "=btw= takes a list of two values")
+		}
+	case filter.OpContains:
+	default:
+		if isList {
+			return nil, p.errorAt(opPos, "operator %s does not take a list", MarshalOperator(op))
+		}
+	}
+	if val == nil {
+		switch op {
+		case filter.OpEq, filter.OpIs:
+			op = filter.OpIs
+		case filter.OpNEq, filter.OpIsNot:
+			op = filter.OpIsNot
+		default:
+			return nil, p.errorAt(argPos, "null can only be compared with == or !=")
+		}
+	}
+
+	return Cond(op, selector, val), nil
+}
+
+func (p *exprParser) parseOperator() (filter.Operator, error) {
+	rest := p.src[p.pos:]
+	for _, s := range filterExprSymbols {
+		if strings.HasPrefix(rest, s.sym) {
+			p.pos += len(s.sym)
+			return s.op, nil
+		}
+	}
+	if strings.HasPrefix(rest, "=") {
+		end := strings.IndexByte(rest[1:], '=')
+		if end > 0 {
+			name := rest[1 : end+1]
+			op, ok := Operators[name]
+			if !ok {
+				op, ok = filterExprOperatorAliases[name]
+			}
+			if ok {
+				p.pos += end + 2
+				return op, nil
+			}
+			return filter.OpUndefined, p.errorf("unknown operator =%s=", name)
+		}
+	}
+	if p.eof() {
+		return filter.OpUndefined, p.errorf("expected an operator, got end of expression")
+	}
+
+	return filter.OpUndefined, p.errorf("expected an operator, got %q", p.peekRune())
+}
+
+// parseArgument returns a single value or, for parenthesized lists, the
+// values as []string.
+func (p *exprParser) parseArgument() (any, bool, error) {
+	if p.eof() {
+		return nil, false, p.errorf("expected a value, got end of expression")
+	}
+	if p.src[p.pos] != '(' {
+		v, err := p.parseValue()
+		return v, false, err
+	}
+
+	open := p.pos
+	p.pos++
+	vals := []string{}
+	for {
+		p.skipSpaces()
+		v, err := p.parseListValue()
+		if err != nil {
+			return nil, false, err
+		}
+		vals = append(vals, v)
+
+		p.skipSpaces()
+		if p.eof() {
+			return nil, false, p.errorf("missing ')' for '(' at position %d", open+1)
+		}
+		switch p.src[p.pos] {
+		case ',':
+			p.pos++
+		case ')':
+			p.pos++
+			return vals, true, nil
+		default:
+			return nil, false, p.errorf("expected ',' or ')', got %q", p.peekRune())
+		}
+	}
+}
+
+func (p *exprParser) parseValue() (any, error) {
+	quoted := !p.eof() && (p.src[p.pos] == '\'' || p.src[p.pos] == '"')
+	raw, err := p.parseListValue()
+	if err != nil {
+		return nil, err
+	}
+	if quoted {
+		return raw, nil
+	}
+	switch strings.ToLower(raw) {
+	case "null":
+		return nil, nil
+	case "true":
+		return true, nil
+	case "false":
+		return false, nil
+	}
+
+	return raw, nil
+}
+
+// parseListValue parses a value of a list as text: null, true and false are
+// plain values there.
+func (p *exprParser) parseListValue() (string, error) {
+	if p.eof() {
+		return "", p.errorf("expected a value, got end of expression")
+	}
+	if q := p.src[p.pos]; q == '\'' || q == '"' {
+		return p.parseQuoted(q)
+	}
+	raw := p.scanUnreserved()
+	if raw == "" {
+		return "", p.errorf("expected a value, got %q", p.peekRune())
+	}
+
+	return raw, nil
+}
+
+func (p *exprParser) parseQuoted(quote byte) (string, error) {
+	open := p.pos
+	p.pos++
+	var sb strings.Builder
+	for !p.eof() {
+		c := p.src[p.pos]
+		switch {
+		case c == '\\' && p.pos+1 < len(p.src):
+			sb.WriteByte(p.src[p.pos+1])
+			p.pos += 2
+		case c == quote:
+			p.pos++
+			return sb.String(), nil
+		default:
+			sb.WriteByte(c)
+			p.pos++
+		}
+	}
+
+	return "", p.errorAt(open, "unterminated string")
+}
+
+func (p *exprParser) scanUnreserved() string {
+	start := p.pos
+	for !p.eof() {
+		r, size := utf8.DecodeRuneInString(p.src[p.pos:])
+		if isReservedExprRune(r) {
+			break
+		}
+		p.pos += size
+	}
+
+	return p.src[start:p.pos]
+}
+
+func isReservedExprRune(r rune) bool {
+	return unicode.IsSpace(r) || strings.ContainsRune(`"'(),;=!<>\`, r)
+}
+
+func isSelector(s string) bool {
+	for i, r := range s {
+		if r == '_' || unicode.IsLetter(r) || (i > 0 && (unicode.IsDigit(r) || r == '.' || r == '-')) {
+			continue
+		}
+		return false
+	}
+
+	return s != ""
+}
+
+// FormatFilterExpr renders a FilterNode in the filter expression syntax so
+// that ParseFilterExpr(FormatFilterExpr(n)) yields an equivalent tree.
+func FormatFilterExpr(n *FilterNode) string {
+	if n == nil {
+		return ""
+	}
+	var sb strings.Builder
+	writeFilterExpr(&sb, n, BoolUndefined)
+	return sb.String()
+}
+
+func (n *FilterNode) String() string {
+	return FormatFilterExpr(n)
+}
+
+func writeFilterExpr(sb *strings.Builder, n *FilterNode, parent BoolOp) {
+	if n.IsLeaf() {
+		writeFilterCond(sb, n.Filter)
+		return
+	}
+
+	switch n.Op {
+	case BoolNot:
+		sb.WriteString("!(")
+		writeFilterExpr(sb, And(n.Children...), BoolUndefined)
+		sb.WriteByte(')')
+	case BoolAnd, BoolOr:
+		sep := ";"
+		if n.Op == BoolOr {
+			sep = ","
+		}
+		// AND binds tighter than OR, so only ORs nested in ANDs need parentheses
+		wrap := n.Op == BoolOr && parent == BoolAnd
+		if wrap {
+			sb.WriteByte('(')
+		}
+		for i, c := range n.Children {
+			if i > 0 {
+				sb.WriteString(sep)
+			}
+			writeFilterExpr(sb, c, n.Op)
+		}
+		if wrap {
+			sb.WriteByte(')')
+		}
+	}
+}
+
+func writeFilterCond(sb *strings.Builder, f filter.FieldFilter[any]) {
+	sb.WriteString(f.Name())
+
+	op, val := f.Operator(), f.Value()
+	sym := "=" + MarshalOperator(op) + "="
+	if val == nil && op == filter.OpIs {
+		op = filter.OpEq
+	} else if val == nil && op == filter.OpIsNot {
+		op = filter.OpNEq
+	}
+	for _, s := range filterExprSymbols {
+		if s.op == op {
+			sym = s.sym
+			break
+		}
+	}
+	sb.WriteString(sym)
+
+	if items, ok := filterValueStrings(val); ok {
+		sb.WriteByte('(')
+		for i, item := range items {
+			if i > 0 {
+				sb.WriteByte(',')
+			}
+			sb.WriteString(quoteFilterValue(item))
+		}
+		sb.WriteByte(')')
+		return
+	}
+	switch v := val.(type) {
+	case nil:
+		sb.WriteString("null")
+	case bool:
+		fmt.Fprintf(sb, "%t", v)
+	default:
+		sb.WriteString(quoteFilterValue(fmt.Sprintf("%v", v)))
+	}
+}
+
+// quoteFilterValue quotes values that would not parse back verbatim.
+func quoteFilterValue(s string) string {
+	needsQuotes := s == ""
+	switch strings.ToLower(s) {
+	case "null", "true", "false":
+		needsQuotes = true
+	}
+	if strings.IndexFunc(s, isReservedExprRune) >= 0 {
+		needsQuotes = true
+	}
+	if !needsQuotes {
+		return s
+	}
+
+	return "'" + strings.NewReplacer(`\`, `\\`, `'`, `\'`).Replace(s) + "'"
+}
+
+// filterValueStrings returns the items of slice values.
+func filterValueStrings(val any) ([]string, bool) {
+	if val == nil {
+		return nil, false
+	}
+	rv := reflect.ValueOf(val)
+	if rv.Kind() != reflect.Slice && rv.Kind() != reflect.Array {
+		return nil, false
+	}
+	items := make([]string, rv.Len())
+	for i := range items {
+		items[i] = fmt.Sprintf("%v", rv.Index(i).Interface())
+	}
+
+	return items, true
+}
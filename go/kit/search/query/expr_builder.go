@@ -0,0 +1,119 @@
+package query
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/dosanma1/forge/go/kit/errors"
+	"github.com/dosanma1/forge/go/kit/filter"
+)
+
+// FieldRef builds the conditions of a filter expression on a field, e.g.
+//
+//	query.Or(
+//		query.Field("status").Eq("active"),
+//		query.And(query.Field("age").Gt(18), query.Field("vip").Eq(true)),
+//	)
+//
+// renders as status==active,(age>18;vip==true) with FormatFilterExpr.
+type FieldRef string
+
+func Field(name string) FieldRef {
+	return FieldRef(name)
+}
+
+// Eq compares with ==; a nil value tests for NULL.
+func (f FieldRef) Eq(val any) *FilterNode {
+	if val == nil {
+		return f.IsNull()
+	}
+	return Cond(filter.OpEq, string(f), val)
+}
+
+// Ne compares with !=; a nil value tests for NOT NULL.
+func (f FieldRef) Ne(val any) *FilterNode {
+	if val == nil {
+		return f.IsNotNull()
+	}
+	return Cond(filter.OpNEq, string(f), val)
+}
+
+func (f FieldRef) Gt(val any) *FilterNode {
+	return Cond(filter.OpGT, string(f), val)
+}
+
+func (f FieldRef) Gte(val any) *FilterNode {
+	return Cond(filter.OpGTEq, string(f), val)
+}
+
+func (f FieldRef) Lt(val any) *FilterNode {
+	return Cond(filter.OpLT, string(f), val)
+}
+
+func (f FieldRef) Lte(val any) *FilterNode {
+	return Cond(filter.OpLTEq, string(f), val)
+}
+
+func (f FieldRef) Like(val string) *FilterNode {
+	return Cond(filter.OpLike, string(f), val)
+}
+
+func (f FieldRef) In(vals ...any) *FilterNode {
+	return Cond(filter.OpIn, string(f), vals)
+}
+
+func (f FieldRef) NotIn(vals ...any) *FilterNode {
+	return Cond(filter.OpNotIn, string(f), vals)
+}
+
+func (f FieldRef) Between(from, to any) *FilterNode {
+	return Cond(filter.OpBetween, string(f), []any{from, to})
+}
+
+func (f FieldRef) Contains(vals ...any) *FilterNode {
+	return Cond(filter.OpContains, string(f), vals)
+}
+
+func (f FieldRef) ContainsLike(vals ...string) *FilterNode {
+	return Cond(filter.OpContainsLike, string(f), vals)
+}
+
+func (f FieldRef) IsNull() *FilterNode {
+	return Cond(filter.OpIs, string(f), nil)
+}
+
+func (f FieldRef) IsNotNull() *FilterNode {
+	return Cond(filter.OpIsNot, string(f), nil)
+}
+
+// JSONContains matches JSON documents containing val, given either as a JSON
+// string or as a value marshalled to JSON. A value failing to marshal yields
+// an invalid node, see FilterNode.Err.
+func (f FieldRef) JSONContains(val any) *FilterNode {
+	if _, ok := val.(string); !ok {
+		b, err := json.Marshal(val)
+		if err != nil {
+			return invalidNode(errors.InvalidArgument(fmt.Sprintf("invalid JSON value for field %s: %v", f, err)))
+		}
+		val = string(b)
+	}
+	return Cond(filter.OpJSONContains, string(f), val)
+}
+
+func (f FieldRef) HasKey(key string) *FilterNode {
+	return Cond(filter.OpHasKey, string(f), key)
+}
+
+func (f FieldRef) HasAnyKeys(keys ...string) *FilterNode {
+	return Cond(filter.OpHasAnyKeys, string(f), keys)
+}
+
+func (f FieldRef) HasAllKeys(keys ...string) *FilterNode {
+	return Cond(filter.OpHasAllKeys, string(f), keys)
+}
+
+// JSONPath matches JSON documents for which the SQL/JSON path returns any
+// item, e.g. $.items[*] ? (@.qty > 2).
+func (f FieldRef) JSONPath(path string) *FilterNode {
+	return Cond(filter.OpJSONPath, string(f), path)
+}
@@ -0,0 +1,273 @@
+package query_test
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	apierrors "github.com/dosanma1/forge/go/kit/errors"
+	"github.com/dosanma1/forge/go/kit/filter"
+	"github.com/dosanma1/forge/go/kit/search/query"
+)
+
+func TestParseFilterExpr(t *testing.T) {
+	tests := []struct {
+		name string
+		expr string
+		want *query.FilterNode
+	}{
+		{
+			name: "comparison",
+			expr: "status==active",
+			want: query.Cond(filter.OpEq, "status", "active"),
+		},
+		{
+			name: "symbol operators",
+			expr: "a!=1;b>2;c>=3;d<4;e<=5",
+			want: query.And(
+				query.Cond(filter.OpNEq, "a", "1"),
+				query.Cond(filter.OpGT, "b", "2"),
+				query.Cond(filter.OpGTEq, "c", "3"),
+				query.Cond(filter.OpLT, "d", "4"),
+				query.Cond(filter.OpLTEq, "e", "5"),
+			),
+		},
+		{
+			name: "named operators and aliases",
+			expr: "a=like=bob*;b=ge=1;c=le=2;d=out=(x)",
+			want: query.And(
+				query.Cond(filter.OpLike, "a", "bob*"),
+				query.Cond(filter.OpGTEq, "b", "1"),
+				query.Cond(filter.OpLTEq, "c", "2"),
+				query.Cond(filter.OpNotIn, "d", []string{"x"}),
+			),
+		},
+		{
+			name: "and binds tighter than or",
+			expr: "a==1,b==2;c==3",
+			want: query.Or(
+				query.Cond(filter.OpEq, "a", "1"),
+				query.And(query.Cond(filter.OpEq, "b", "2"), query.Cond(filter.OpEq, "c", "3")),
+			),
+		},
+		{
+			name: "parentheses",
+			expr: "status==active;(age>18,vip==true)",
+			want: query.And(
+				query.Cond(filter.OpEq, "status", "active"),
+				query.Or(query.Cond(filter.OpGT, "age", "18"), query.Cond(filter.OpEq, "vip", true)),
+			),
+		},
+		{
+			name: "keywords and negation",
+			expr: "!(role=in=(admin,owner)) OR deleted_at==null and x!=null",
+			want: query.Or(
+				query.Not(query.Cond(filter.OpIn, "role", []string{"admin", "owner"})),
+				query.And(query.Cond(filter.OpIs, "deleted_at", nil), query.Cond(filter.OpIsNot, "x", nil)),
+			),
+		},
+		{
+			name: "quoted values",
+			expr: `name=like='bob smith';note=="say \"hi\"";kind=='null';flag=='true';empty==''`,
+			want: query.And(
+				query.Cond(filter.OpLike, "name", "bob smith"),
+				query.Cond(filter.OpEq, "note", `say "hi"`),
+				query.Cond(filter.OpEq, "kind", "null"),
+				query.Cond(filter.OpEq, "flag", "true"),
+				query.Cond(filter.OpEq, "empty", ""),
+			),
+		},
+		{
+			name: "between",
+			expr: "created_at=btw=(2024-01-01, 2024-02-01)",
+			want: query.Cond(filter.OpBetween, "created_at", []string{"2024-01-01", "2024-02-01"}),
+		},
+		{
+			name: "list with booleans and null",
+			expr: "a=in=(true,x);b=not-in=(FALSE,null,'y')",
+			want: query.And(
+				query.Cond(filter.OpIn, "a", []string{"true", "x"}),
+				query.Cond(filter.OpNotIn, "b", []string{"FALSE", "null", "y"}),
+			),
+		},
+		{
+			name: "single value for a list operator",
+			expr: "a=in=x;b=in=true",
+			want: query.And(
+				query.Cond(filter.OpIn, "a", []string{"x"}),
+				query.Cond(filter.OpIn, "b", []string{"true"}),
+			),
+		},
+		{
+			name: "contains takes a value or a list",
+			expr: "tags=any=x;labels=any=(x,y)",
+			want: query.And(
+				query.Cond(filter.OpContains, "tags", "x"),
+				query.Cond(filter.OpContains, "labels", []string{"x", "y"}),
+			),
+		},
+		{
+			name: "json path selector",
+			expr: "attrs.size>=10",
+			want: query.Cond(filter.OpGTEq, "attrs.size", "10"),
+		},
+		{
+			name: "spaces",
+			expr: "  ( a == 1 ; b == 2 )  ",
+			want: query.And(query.Cond(filter.OpEq, "a", "1"), query.Cond(filter.OpEq, "b", "2")),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := query.ParseFilterExpr(tt.expr)
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestParseFilterExprErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		expr    string
+		wantPos int
+		wantMsg string
+	}{
+		{name: "empty", expr: "  ", wantPos: 3, wantMsg: "empty expression"},
+		{name: "trailing separator", expr: "a==1;", wantPos: 6, wantMsg: "unexpected end of expression"},
+		{name: "unexpected character", expr: "a==1)", wantPos: 5, wantMsg: `unexpected ')'`},
+		{name: "missing parenthesis", expr: "(a==1", wantPos: 6, wantMsg: "missing ')' for '(' at position 1"},
+		{name: "missing selector", expr: "==1", wantPos: 1, wantMsg: "expected a selector"},
+		{name: "invalid selector", expr: "a==1;1a==1", wantPos: 6, wantMsg: `invalid selector "1a"`},
+		{name: "missing operator", expr: "a", wantPos: 2, wantMsg: "expected an operator, got end of expression"},
+		{name: "unknown operator", expr: "a=foo=1", wantPos: 2, wantMsg: "unknown operator =foo="},
+		{name: "missing value", expr: "a==", wantPos: 4, wantMsg: "expected a value, got end of expression"},
+		{name: "list for a single value operator", expr: "a==(1,2)", wantPos: 2, wantMsg: "operator eq does not take a list"},
+		{name: "null for a list operator", expr: "a=in=null", wantPos: 6, wantMsg: "operator in does not take null"},
+		{name: "null compared", expr: "a>null", wantPos: 3, wantMsg: "null can only be compared with == or !="},
+		{name: "between of one value", expr: "a=btw=(1)", wantPos: 7, wantMsg: "=btw= takes a list of two values"},
+		{name: "list separator", expr: "a=in=(x;y)", wantPos: 8, wantMsg: `expected ',' or ')', got ';'`},
+		{name: "unterminated list", expr: "a=in=(x,y", wantPos: 10, wantMsg: "missing ')' for '(' at position 6"},
+		{name: "unterminated string", expr: "a=='x", wantPos: 4, wantMsg: "unterminated string"},
+		{
+			name:    "too deep",
+			expr:    strings.Repeat("(", 40) + "a==1" + strings.Repeat(")", 40),
+			wantPos: 34, wantMsg: "expression nested too deeply",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := query.ParseFilterExpr(tt.expr)
+			var apiErr apierrors.Error
+			require.ErrorAs(t, err, &apiErr)
+			assert.Equal(t, apierrors.CodeInvalidFormat, apiErr.Code())
+			assert.Contains(t, apiErr.Message(), fmt.Sprintf("at position %d: %s", tt.wantPos, tt.wantMsg))
+		})
+	}
+}
+
+func TestFormatFilterExpr(t *testing.T) {
+	tests := []struct {
+		name string
+		node *query.FilterNode
+		want string
+	}{
+		{
+			name: "builder",
+			node: query.Or(
+				query.Field("status").Eq("active"),
+				query.And(query.Field("age").Gt(18), query.Field("vip").Eq(true)),
+			),
+			want: "status==active,age>18;vip==true",
+		},
+		{
+			name: "or nested in and",
+			node: query.And(
+				query.Field("status").Eq("active"),
+				query.Or(query.Field("age").Gte(18), query.Field("vip").Ne(false)),
+			),
+			want: "status==active;(age>=18,vip!=false)",
+		},
+		{
+			name: "null and negation",
+			node: query.Or(query.Not(query.Field("role").In("admin", "owner")), query.Field("deleted_at").Eq(nil)),
+			want: "!(role=in=(admin,owner)),deleted_at==null",
+		},
+		{
+			name: "quoting",
+			node: query.And(
+				query.Field("name").Like("bob smith"),
+				query.Field("kind").Eq("null"),
+				query.Field("note").Ne(`it's`),
+				query.Field("tags").In("true", "a,b"),
+			),
+			want: `name=like='bob smith';kind=='null';note!='it\'s';tags=in=('true','a,b')`,
+		},
+		{
+			name: "named operators",
+			node: query.And(
+				query.Field("created_at").Between("2024-01-01", "2024-02-01"),
+				query.Field("labels").ContainsLike("a", "b"),
+				query.Field("attrs").HasAllKeys("x", "y"),
+				query.Field("score").Lte(3),
+				query.Field("id").IsNotNull(),
+			),
+			want: "created_at=btw=(2024-01-01,2024-02-01);labels=any-like=(a,b);attrs=has-all-keys=(x,y);score<=3;id!=null",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			formatted := query.FormatFilterExpr(tt.node)
+			assert.Equal(t, tt.want, formatted)
+			assert.Equal(t, formatted, tt.node.String())
+
+			// values parse back as text, so the round trip compares the
+			// formatted expressions
+			parsed, err := query.ParseFilterExpr(formatted)
+			require.NoError(t, err)
+			assert.Equal(t, formatted, query.FormatFilterExpr(parsed))
+		})
+	}
+}
+
+func TestFieldRefJSONContainsInvalidValue(t *testing.T) {
+	node := query.Or(
+		query.Field("status").Eq("active"),
+		query.Field("attrs").JSONContains(make(chan int)),
+	)
+
+	require.NotNil(t, node)
+	assert.Len(t, node.Children, 2, "the invalid condition must not widen the expression")
+	assert.True(t, apierrors.Is(node.Err(), apierrors.CodeInvalidArgument), node.Err())
+	assert.NoError(t, query.Field("attrs").JSONContains(map[string]any{"a": 1}).Err())
+}
+
+func TestFilterExprRoundTrip(t *testing.T) {
+	exprs := []string{
+		"status==active;(age>18,vip==true)",
+		"!(role=in=(admin,owner)),deleted_at==null",
+		`name=like='bob smith';note=='say "hi"';kind=='null'`,
+		"created_at=btw=(2024-01-01,2024-02-01);a=in=(true,x)",
+		"!(a==1;b==2);!(c==3,d!=null)",
+		"tags=any=x;attrs.size>=10",
+	}
+
+	for _, expr := range exprs {
+		t.Run(expr, func(t *testing.T) {
+			node, err := query.ParseFilterExpr(expr)
+			require.NoError(t, err)
+
+			again, err := query.ParseFilterExpr(query.FormatFilterExpr(node))
+			require.NoError(t, err)
+			assert.Equal(t, node, again)
+		})
+	}
+
+	assert.Empty(t, query.FormatFilterExpr(nil))
+}
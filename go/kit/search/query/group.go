@@ -0,0 +1,202 @@
+package query
+
+import (
+	"sort"
+
+	"github.com/dosanma1/forge/go/kit/filter"
+)
+
+// BoolOp is the logical operator combining the children of a FilterNode.
+type BoolOp uint
+
+const (
+	BoolUndefined BoolOp = iota
+	BoolAnd
+	BoolOr
+	BoolNot
+)
+
+func (op BoolOp) Valid() bool {
+	return op != BoolUndefined && op <= BoolNot
+}
+
+func (op BoolOp) String() string {
+	switch op {
+	case BoolAnd:
+		return "AND"
+	case BoolOr:
+		return "OR"
+	case BoolNot:
+		return "NOT"
+	case BoolUndefined:
+		return ""
+	default:
+		return ""
+	}
+}
+
+// FilterNode is a node of a boolean filter expression. Leaves carry a single
+// field filter; inner nodes combine their children with Op. Unlike the flat
+// Filters map, a tree may hold several filters on the same field.
+type FilterNode struct {
+	Op       BoolOp
+	Filter   filter.FieldFilter[any]
+	Children []*FilterNode
+
+	// err is the reason a builder could not create the condition of the
+	// node, see Err.
+	err error
+}
+
+// invalidNode stands for a condition a builder failed to create. It is kept in
+// the tree, instead of a nil node, so that And and Or do not silently drop it
+// and widen the expression; repositories fail with Err instead.
+func invalidNode(err error) *FilterNode {
+	return &FilterNode{err: err}
+}
+
+// Err returns the error of the first condition of the expression a builder
+// failed to create, nil when the whole expression is valid.
+func (n *FilterNode) Err() error {
+	if n == nil {
+		return nil
+	}
+	if n.err != nil {
+		return n.err
+	}
+	for _, c := range n.Children {
+		if err := c.Err(); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (n *FilterNode) IsLeaf() bool {
+	return n != nil && n.Filter != nil
+}
+
+// Leaves returns every field filter of the expression, depth first.
+func (n *FilterNode) Leaves() []filter.FieldFilter[any] {
+	if n == nil {
+		return nil
+	}
+	if n.IsLeaf() {
+		return []filter.FieldFilter[any]{n.Filter}
+	}
+	var leaves []filter.FieldFilter[any]
+	for _, c := range n.Children {
+		leaves = append(leaves, c.Leaves()...)
+	}
+
+	return leaves
+}
+
+// Conjuncts returns the field filters that every matching row must satisfy,
+// i.e. the leaves reachable from n through AND nodes only.
+func (n *FilterNode) Conjuncts() []filter.FieldFilter[any] {
+	if n == nil {
+		return nil
+	}
+	if n.IsLeaf() {
+		return []filter.FieldFilter[any]{n.Filter}
+	}
+	if n.Op != BoolAnd {
+		return nil
+	}
+	var leaves []filter.FieldFilter[any]
+	for _, c := range n.Children {
+		leaves = append(leaves, c.Conjuncts()...)
+	}
+
+	return leaves
+}
+
+func Leaf(f filter.FieldFilter[any]) *FilterNode {
+	if f == nil {
+		return nil
+	}
+	return &FilterNode{Filter: f}
+}
+
+func Cond(op filter.Operator, fieldName string, val any) *FilterNode {
+	return Leaf(filter.NewFieldFilter(op, fieldName, val))
+}
+
+func And(children ...*FilterNode) *FilterNode {
+	return group(BoolAnd, children)
+}
+
+func Or(children ...*FilterNode) *FilterNode {
+	return group(BoolOr, children)
+}
+
+// Not negates its children, which are AND-ed together when more than one.
+func Not(children ...*FilterNode) *FilterNode {
+	inner := And(children...)
+	if inner == nil {
+		return nil
+	}
+	return &FilterNode{Op: BoolNot, Children: []*FilterNode{inner}}
+}
+
+func group(op BoolOp, children []*FilterNode) *FilterNode {
+	nodes := make([]*FilterNode, 0, len(children))
+	for _, c := range children {
+		switch {
+		case c == nil:
+		case !c.IsLeaf() && c.Op == op:
+			// (a AND b) AND c is flattened into a AND b AND c
+			nodes = append(nodes, c.Children...)
+		default:
+			nodes = append(nodes, c)
+		}
+	}
+	switch len(nodes) {
+	case 0:
+		return nil
+	case 1:
+		return nodes[0]
+	}
+
+	return &FilterNode{Op: op, Children: nodes}
+}
+
+// Where adds filter expressions to the query. They are AND-ed with the
+// filters of the flat Filters map.
+func Where(nodes ...*FilterNode) Option {
+	return func(q *query) {
+		for _, n := range nodes {
+			if n != nil {
+				q.filterGroups = append(q.filterGroups, n)
+			}
+		}
+	}
+}
+
+// FilterTree returns the whole filter expression of a query: the flat
+// filters, sorted by name, AND-ed with the filter groups. It returns nil when
+// the query has no filters.
+func FilterTree(q Query) *FilterNode {
+	if q == nil {
+		return nil
+	}
+	return filterTree(q.Filters(), q.FilterGroups())
+}
+
+func filterTree(filters Filters[any], groups []*FilterNode) *FilterNode {
+	keys := make([]string, 0, len(filters))
+	for k := range filters {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	children := make([]*FilterNode, 0, len(keys)+len(groups))
+	for _, k := range keys {
+		children = append(children, Leaf(filters[k]))
+	}
+	children = append(children, groups...)
+
+	return And(children...)
+}
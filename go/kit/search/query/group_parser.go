@@ -0,0 +1,169 @@
+package query
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	kiterrors "github.com/dosanma1/forge/go/kit/errors"
+	"github.com/dosanma1/forge/go/kit/filter"
+)
+
+const (
+	filterGroupAnd = "and"
+	filterGroupOr  = "or"
+	filterGroupNot = "not"
+
+	filterParam = "filter"
+)
+
+// filterGroupBuilder collects the filter group parameters of a URL, e.g.
+//
+//	filter[or][0][status][eq]=open&filter[or][1][owner][eq]=me
+//	filter[and][0][price][gte]=10&filter[and][1][price][lte]=20
+//	filter[not][status][in]=archived,deleted
+//	filter[or][0][and][0][a][eq]=1&filter[or][0][and][1][b][eq]=2
+//
+// and/or take an index per child; parameters sharing an index, as well as
+// the parameters under not, are AND-ed together.
+type filterGroupBuilder struct {
+	leaves []filter.FieldFilter[any]
+	groups map[string]map[int]*filterGroupBuilder
+	not    *filterGroupBuilder
+}
+
+func newFilterGroupBuilder() *filterGroupBuilder {
+	return &filterGroupBuilder{groups: make(map[string]map[int]*filterGroupBuilder)}
+}
+
+func isFilterGroupKey(key string) bool {
+	segments, ok := filterKeySegments(key)
+	return ok && len(segments) > 0 && isFilterGroupKeyword(segments[0])
+}
+
+func isFilterGroupKeyword(s string) bool {
+	return s == filterGroupAnd || s == filterGroupOr || s == filterGroupNot
+}
+
+// filterKeySegments splits filter[a][b][c] into [a b c].
+func filterKeySegments(key string) ([]string, bool) {
+	rest, ok := strings.CutPrefix(key, filterParam)
+	if !ok || rest == "" {
+		return nil, false
+	}
+	var segments []string
+	for rest != "" {
+		if rest[0] != '[' {
+			return nil, false
+		}
+		end := strings.IndexByte(rest, ']')
+		if end < 0 {
+			return nil, false
+		}
+		segments = append(segments, rest[1:end])
+		rest = rest[end+1:]
+	}
+
+	return segments, true
+}
+
+func (b *filterGroupBuilder) add(key string, values []string) error {
+	segments, ok := filterKeySegments(key)
+	if !ok {
+		return kiterrors.InvalidArgument(fmt.Sprintf("invalid filter format: %s", key))
+	}
+	return b.addSegments(key, segments, values)
+}
+
+func (b *filterGroupBuilder) addSegments(key string, segments []string, values []string) error {
+	if len(segments) == 0 {
+		return kiterrors.InvalidArgument(fmt.Sprintf("invalid filter format: %s", key))
+	}
+
+	switch segments[0] {
+	case filterGroupAnd, filterGroupOr:
+		if len(segments) < 2 {
+			return kiterrors.InvalidArgument(fmt.Sprintf("invalid filter format: %s", key))
+		}
+		idx, err := strconv.Atoi(segments[1])
+		if err != nil || idx < 0 {
+			return kiterrors.InvalidArgument(fmt.Sprintf("invalid filter group index %q: %s", segments[1], key))
+		}
+		children := b.groups[segments[0]]
+		if children == nil {
+			children = make(map[int]*filterGroupBuilder)
+			b.groups[segments[0]] = children
+		}
+		child := children[idx]
+		if child == nil {
+			child = newFilterGroupBuilder()
+			children[idx] = child
+		}
+		return child.addSegments(key, segments[2:], values)
+	case filterGroupNot:
+		if b.not == nil {
+			b.not = newFilterGroupBuilder()
+		}
+		return b.not.addSegments(key, segments[1:], values)
+	}
+
+	if len(segments) != 2 {
+		return kiterrors.InvalidArgument(fmt.Sprintf("invalid filter format: %s", key))
+	}
+	op := ParseOperator(segments[1])
+	if op == filter.OpUndefined {
+		return kiterrors.InvalidArgument(fmt.Sprintf("invalid operator: %s", segments[1]))
+	}
+	if segments[0] == "" {
+		return kiterrors.InvalidArgument(fmt.Sprintf("invalid filter format: %s", key))
+	}
+	val := parseValue(op, values)
+	if val == nil && op != filter.OpIs && op != filter.OpIsNot {
+		return nil
+	}
+	b.leaves = append(b.leaves, filter.NewFieldFilter(op, segments[0], val))
+
+	return nil
+}
+
+// nodes returns the expressions collected by b in a deterministic order:
+// leaves by field and operator, then the and, or and not groups.
+func (b *filterGroupBuilder) nodes() []*FilterNode {
+	sort.Slice(b.leaves, func(i, j int) bool {
+		if b.leaves[i].Name() != b.leaves[j].Name() {
+			return b.leaves[i].Name() < b.leaves[j].Name()
+		}
+		return b.leaves[i].Operator() < b.leaves[j].Operator()
+	})
+
+	nodes := make([]*FilterNode, 0, len(b.leaves)+len(b.groups)+1)
+	for _, l := range b.leaves {
+		nodes = append(nodes, Leaf(l))
+	}
+	for _, kw := range []string{filterGroupAnd, filterGroupOr} {
+		children := b.groups[kw]
+		if len(children) == 0 {
+			continue
+		}
+		idxs := make([]int, 0, len(children))
+		for idx := range children {
+			idxs = append(idxs, idx)
+		}
+		sort.Ints(idxs)
+		groupNodes := make([]*FilterNode, 0, len(idxs))
+		for _, idx := range idxs {
+			groupNodes = append(groupNodes, And(children[idx].nodes()...))
+		}
+		if kw == filterGroupAnd {
+			nodes = append(nodes, And(groupNodes...))
+		} else {
+			nodes = append(nodes, Or(groupNodes...))
+		}
+	}
+	if b.not != nil {
+		nodes = append(nodes, Not(b.not.nodes()...))
+	}
+
+	return nodes
+}
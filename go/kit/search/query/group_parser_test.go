@@ -0,0 +1,212 @@
+package query_test
+
+import (
+	"net/url"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	apierrors "github.com/dosanma1/forge/go/kit/errors"
+	"github.com/dosanma1/forge/go/kit/filter"
+	"github.com/dosanma1/forge/go/kit/search/query"
+)
+
+func parseRawQuery(t *testing.T, rawQuery string) (query.Query, error) {
+	t.Helper()
+	opts, err := query.ParseURLQueryOpts(&url.URL{RawQuery: rawQuery}, query.SkipDefaultPagination())
+	if err != nil {
+		return nil, err
+	}
+	return query.New(opts...), nil
+}
+
+func TestParseFilterGroups(t *testing.T) {
+	tests := []struct {
+		name     string
+		rawQuery string
+		want     []*query.FilterNode
+	}{
+		{
+			name:     "or",
+			rawQuery: "filter[or][0][status][eq]=open&filter[or][1][owner][eq]=me",
+			want: []*query.FilterNode{query.Or(
+				query.Cond(filter.OpEq, "status", "open"),
+				query.Cond(filter.OpEq, "owner", "me"),
+			)},
+		},
+		{
+			name:     "and on the same field",
+			rawQuery: "filter[and][0][price][gte]=10&filter[and][1][price][lte]=20",
+			want: []*query.FilterNode{query.And(
+				query.Cond(filter.OpGTEq, "price", "10"),
+				query.Cond(filter.OpLTEq, "price", "20"),
+			)},
+		},
+		{
+			name:     "not",
+			rawQuery: "filter[not][status][in]=archived,deleted",
+			want: []*query.FilterNode{query.Not(
+				query.Cond(filter.OpIn, "status", []string{"archived", "deleted"}),
+			)},
+		},
+		{
+			name:     "parameters sharing an index are and-ed",
+			rawQuery: "filter[or][0][a][eq]=1&filter[or][0][b][eq]=2&filter[or][1][c][eq]=3",
+			want: []*query.FilterNode{query.Or(
+				query.And(query.Cond(filter.OpEq, "a", "1"), query.Cond(filter.OpEq, "b", "2")),
+				query.Cond(filter.OpEq, "c", "3"),
+			)},
+		},
+		{
+			name:     "nested groups",
+			rawQuery: "filter[or][0][and][0][a][eq]=1&filter[or][0][and][1][b][eq]=2&filter[or][1][not][c][eq]=3",
+			want: []*query.FilterNode{query.Or(
+				query.And(query.Cond(filter.OpEq, "a", "1"), query.Cond(filter.OpEq, "b", "2")),
+				query.Not(query.Cond(filter.OpEq, "c", "3")),
+			)},
+		},
+		{
+			name:     "children ordered by index",
+			rawQuery: "filter[or][10][a][eq]=1&filter[or][2][b][eq]=2",
+			want: []*query.FilterNode{query.Or(
+				query.Cond(filter.OpEq, "b", "2"),
+				query.Cond(filter.OpEq, "a", "1"),
+			)},
+		},
+		{
+			name:     "groups of several keywords",
+			rawQuery: "filter[not][a][eq]=1&filter[or][0][b][eq]=2&filter[or][1][c][eq]=3&filter[and][0][d][eq]=4",
+			want: []*query.FilterNode{
+				query.Cond(filter.OpEq, "d", "4"),
+				query.Or(query.Cond(filter.OpEq, "b", "2"), query.Cond(filter.OpEq, "c", "3")),
+				query.Not(query.Cond(filter.OpEq, "a", "1")),
+			},
+		},
+		{
+			name:     "null value",
+			rawQuery: "filter[or][0][deleted_at][is]=null&filter[or][1][active][eq]=true",
+			want: []*query.FilterNode{query.Or(
+				query.Cond(filter.OpIs, "deleted_at", nil),
+				query.Cond(filter.OpEq, "active", true),
+			)},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			q, err := parseRawQuery(t, tt.rawQuery)
+			require.NoError(t, err)
+			assert.Empty(t, q.Filters())
+			assert.Equal(t, tt.want, q.FilterGroups())
+		})
+	}
+}
+
+func TestParseFilterGroupsErrors(t *testing.T) {
+	tests := []struct {
+		name     string
+		rawQuery string
+	}{
+		{name: "missing index", rawQuery: "filter[or]=1"},
+		{name: "invalid index", rawQuery: "filter[or][x][a][eq]=1"},
+		{name: "negative index", rawQuery: "filter[and][-1][a][eq]=1"},
+		{name: "invalid operator", rawQuery: "filter[or][0][a][bogus]=1"},
+		{name: "missing operator", rawQuery: "filter[or][0][a]=1"},
+		{name: "missing field", rawQuery: "filter[not][][eq]=1"},
+		{name: "unclosed segment", rawQuery: "filter[or][0][a][eq=1"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := parseRawQuery(t, tt.rawQuery)
+			var apiErr apierrors.Error
+			require.ErrorAs(t, err, &apiErr)
+			assert.Equal(t, apierrors.CodeInvalidArgument, apiErr.Code())
+		})
+	}
+}
+
+func TestFilterGroupsURLRoundTrip(t *testing.T) {
+	tests := []struct {
+		name    string
+		filters query.Filters[any]
+		groups  []*query.FilterNode
+		want    []*query.FilterNode
+	}{
+		{
+			name: "or",
+			groups: []*query.FilterNode{query.Or(
+				query.Cond(filter.OpEq, "status", "open"),
+				query.Cond(filter.OpIn, "owner", []string{"me", "you"}),
+			)},
+		},
+		{
+			name: "not",
+			groups: []*query.FilterNode{query.Not(
+				query.Cond(filter.OpEq, "status", "archived"),
+				query.Cond(filter.OpIs, "deleted_at", nil),
+			)},
+		},
+		{
+			name: "nested groups",
+			groups: []*query.FilterNode{query.Or(
+				query.And(query.Cond(filter.OpGTEq, "price", "10"), query.Cond(filter.OpLTEq, "price", "20")),
+				query.Not(query.Cond(filter.OpEq, "vip", true)),
+			)},
+		},
+		{
+			name:    "flat filters kept apart from the groups",
+			filters: query.Filters[any]{"name": filter.NewFieldFilter[any](filter.OpEq, "name", "bob")},
+			groups: []*query.FilterNode{query.Or(
+				query.Cond(filter.OpEq, "a", "1"),
+				query.Cond(filter.OpEq, "b", "2"),
+			)},
+		},
+		{
+			// several groups are and-ed under a single key
+			name: "several groups",
+			groups: []*query.FilterNode{
+				query.Or(query.Cond(filter.OpEq, "a", "1"), query.Cond(filter.OpEq, "b", "2")),
+				query.Or(query.Cond(filter.OpEq, "c", "3"), query.Cond(filter.OpEq, "d", "4")),
+			},
+			want: []*query.FilterNode{query.And(
+				query.Or(query.Cond(filter.OpEq, "a", "1"), query.Cond(filter.OpEq, "b", "2")),
+				query.Or(query.Cond(filter.OpEq, "c", "3"), query.Cond(filter.OpEq, "d", "4")),
+			)},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			values := query.FiltersToURLValues(tt.filters, tt.groups...)
+
+			q, err := parseRawQuery(t, values.Encode())
+			require.NoError(t, err)
+
+			want := tt.want
+			if want == nil {
+				want = tt.groups
+			}
+			assert.Equal(t, want, q.FilterGroups())
+			if tt.filters == nil {
+				assert.Empty(t, q.Filters())
+			} else {
+				assert.Equal(t, tt.filters, q.Filters())
+			}
+		})
+	}
+}
+
+func TestFiltersToURLValuesGroups(t *testing.T) {
+	values := query.FiltersToURLValues(nil, query.Or(
+		query.And(query.Cond(filter.OpEq, "a", "1"), query.Cond(filter.OpEq, "b", "2")),
+		query.Not(query.Cond(filter.OpIn, "c", []string{"x", "y"})),
+	))
+
+	assert.Equal(t, url.Values{
+		"filter[or][0][and][0][a][eq]": {"1"},
+		"filter[or][0][and][1][b][eq]": {"2"},
+		"filter[or][1][not][c][in]":    {"x,y"},
+	}, values)
+}
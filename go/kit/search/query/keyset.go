@@ -0,0 +1,254 @@
+package query
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/dosanma1/forge/go/kit/errors"
+)
+
+// CursorDirection tells on which side of the cursor a page is requested.
+type CursorDirection uint
+
+const (
+	CursorDirUndefined CursorDirection = iota
+	CursorAfter
+	CursorBefore
+)
+
+func (d CursorDirection) Valid() bool {
+	return d == CursorAfter || d == CursorBefore
+}
+
+// CursorPagination is the keyset counterpart of PaginationParams. Cursor is
+// the opaque token handed out in a previous page; an empty cursor requests
+// the first (CursorAfter) or last (CursorBefore) page.
+type CursorPagination struct {
+	Limit     int
+	Cursor    string
+	Direction CursorDirection
+}
+
+// PageCursor is the decoded content of a cursor token: the sort keys of the
+// query it was issued for and the values of the boundary row for each key.
+type PageCursor struct {
+	Keys   []string
+	Values []any
+}
+
+// Matches reports whether the cursor was issued for the given sort keys.
+func (pc PageCursor) Matches(keys []string) bool {
+	if len(pc.Keys) != len(keys) || len(pc.Values) != len(keys) {
+		return false
+	}
+	for i := range keys {
+		if pc.Keys[i] != keys[i] {
+			return false
+		}
+	}
+
+	return true
+}
+
+// CursorCodec signs and verifies cursor tokens so clients cannot forge
+// arbitrary keyset boundaries. A codec without secret fails to encode and
+// decode cursors.
+type CursorCodec struct {
+	secret []byte
+}
+
+func NewCursorCodec(secret []byte) *CursorCodec {
+	return &CursorCodec{secret: secret}
+}
+
+var (
+	defaultCursorCodec   = NewCursorCodec(nil)
+	defaultCursorCodecMu sync.RWMutex
+)
+
+// SetCursorSecret configures the secret of the default codec. Every replica
+// serving the same API must share it, otherwise cursors issued by one
+// replica are rejected by the others.
+func SetCursorSecret(secret []byte) {
+	defaultCursorCodecMu.Lock()
+	defer defaultCursorCodecMu.Unlock()
+	defaultCursorCodec = NewCursorCodec(secret)
+}
+
+// DefaultCursorCodec returns the codec configured with SetCursorSecret. Until
+// a secret is configured, keyset pagination fails with a configuration error:
+// a secret of its own per process would break the cursors on restarts and
+// across replicas.
+func DefaultCursorCodec() *CursorCodec {
+	defaultCursorCodecMu.RLock()
+	defer defaultCursorCodecMu.RUnlock()
+
+	return defaultCursorCodec
+}
+
+// cursorValue keeps the Go type of a key value across the JSON round trip.
+type cursorValue struct {
+	T string `json:"t"`
+	V string `json:"v,omitempty"`
+}
+
+type cursorPayload struct {
+	K []string      `json:"k"`
+	V []cursorValue `json:"v"`
+}
+
+const (
+	cursorTypeNull   = "n"
+	cursorTypeString = "s"
+	cursorTypeInt    = "i"
+	cursorTypeUint   = "u"
+	cursorTypeFloat  = "f"
+	cursorTypeBool   = "b"
+	cursorTypeTime   = "t"
+
+	cursorSignatureSep = "."
+)
+
+func (c *CursorCodec) Encode(pc PageCursor) (string, error) {
+	if len(c.secret) == 0 {
+		return "", missingCursorSecret()
+	}
+	if len(pc.Keys) != len(pc.Values) {
+		return "", errors.InternalError("cursor keys and values mismatch")
+	}
+	payload := cursorPayload{K: pc.Keys, V: make([]cursorValue, len(pc.Values))}
+	for i, v := range pc.Values {
+		cv, err := encodeCursorValue(v)
+		if err != nil {
+			return "", err
+		}
+		payload.V[i] = cv
+	}
+
+	raw, err := json.Marshal(payload)
+	if err != nil {
+		return "", errors.WrapInternal(err, "encoding cursor")
+	}
+	body := base64.RawURLEncoding.EncodeToString(raw)
+
+	return body + cursorSignatureSep + c.sign(body), nil
+}
+
+func (c *CursorCodec) Decode(token string) (PageCursor, error) {
+	if len(c.secret) == 0 {
+		return PageCursor{}, missingCursorSecret()
+	}
+	body, sig, ok := strings.Cut(token, cursorSignatureSep)
+	if !ok || !hmac.Equal([]byte(sig), []byte(c.sign(body))) {
+		return PageCursor{}, invalidCursor()
+	}
+	raw, err := base64.RawURLEncoding.DecodeString(body)
+	if err != nil {
+		return PageCursor{}, invalidCursor()
+	}
+	var payload cursorPayload
+	if err := json.Unmarshal(raw, &payload); err != nil || len(payload.K) != len(payload.V) {
+		return PageCursor{}, invalidCursor()
+	}
+
+	pc := PageCursor{Keys: payload.K, Values: make([]any, len(payload.V))}
+	for i, cv := range payload.V {
+		v, err := decodeCursorValue(cv)
+		if err != nil {
+			return PageCursor{}, invalidCursor()
+		}
+		pc.Values[i] = v
+	}
+
+	return pc, nil
+}
+
+func (c *CursorCodec) sign(body string) string {
+	mac := hmac.New(sha256.New, c.secret)
+	mac.Write([]byte(body))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+func invalidCursor() error {
+	return errors.InvalidArgument("invalid page cursor")
+}
+
+func missingCursorSecret() error {
+	return errors.New(errors.CodeConfigurationError, errors.WithMessage(
+		"keyset pagination requires a cursor secret, see query.SetCursorSecret",
+	))
+}
+
+func encodeCursorValue(v any) (cursorValue, error) {
+	switch t := v.(type) {
+	case nil:
+		return cursorValue{T: cursorTypeNull}, nil
+	case time.Time:
+		return cursorValue{T: cursorTypeTime, V: t.Format(time.RFC3339Nano)}, nil
+	case *time.Time:
+		if t == nil {
+			return cursorValue{T: cursorTypeNull}, nil
+		}
+		return encodeCursorValue(*t)
+	case string:
+		return cursorValue{T: cursorTypeString, V: t}, nil
+	case fmt.Stringer:
+		return cursorValue{T: cursorTypeString, V: t.String()}, nil
+	case bool:
+		return cursorValue{T: cursorTypeBool, V: strconv.FormatBool(t)}, nil
+	case int:
+		return cursorValue{T: cursorTypeInt, V: strconv.FormatInt(int64(t), 10)}, nil
+	case int8:
+		return cursorValue{T: cursorTypeInt, V: strconv.FormatInt(int64(t), 10)}, nil
+	case int16:
+		return cursorValue{T: cursorTypeInt, V: strconv.FormatInt(int64(t), 10)}, nil
+	case int32:
+		return cursorValue{T: cursorTypeInt, V: strconv.FormatInt(int64(t), 10)}, nil
+	case int64:
+		return cursorValue{T: cursorTypeInt, V: strconv.FormatInt(t, 10)}, nil
+	case uint:
+		return cursorValue{T: cursorTypeUint, V: strconv.FormatUint(uint64(t), 10)}, nil
+	case uint8:
+		return cursorValue{T: cursorTypeUint, V: strconv.FormatUint(uint64(t), 10)}, nil
+	case uint16:
+		return cursorValue{T: cursorTypeUint, V: strconv.FormatUint(uint64(t), 10)}, nil
+	case uint32:
+		return cursorValue{T: cursorTypeUint, V: strconv.FormatUint(uint64(t), 10)}, nil
+	case uint64:
+		return cursorValue{T: cursorTypeUint, V: strconv.FormatUint(t, 10)}, nil
+	case float32:
+		return cursorValue{T: cursorTypeFloat, V: strconv.FormatFloat(float64(t), 'g', -1, 32)}, nil
+	case float64:
+		return cursorValue{T: cursorTypeFloat, V: strconv.FormatFloat(t, 'g', -1, 64)}, nil
+	}
+
+	return cursorValue{}, errors.InternalError(fmt.Sprintf("unsupported cursor value type %T", v))
+}
+
+func decodeCursorValue(cv cursorValue) (any, error) {
+	switch cv.T {
+	case cursorTypeNull:
+		return nil, nil
+	case cursorTypeString:
+		return cv.V, nil
+	case cursorTypeBool:
+		return strconv.ParseBool(cv.V)
+	case cursorTypeInt:
+		return strconv.ParseInt(cv.V, 10, 64)
+	case cursorTypeUint:
+		return strconv.ParseUint(cv.V, 10, 64)
+	case cursorTypeFloat:
+		return strconv.ParseFloat(cv.V, 64)
+	case cursorTypeTime:
+		return time.Parse(time.RFC3339Nano, cv.V)
+	}
+
+	return nil, fmt.Errorf("unknown cursor value type %q", cv.T)
+}
@@ -0,0 +1,74 @@
+package query_test
+
+import (
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	apierrors "github.com/dosanma1/forge/go/kit/errors"
+	"github.com/dosanma1/forge/go/kit/search/query"
+)
+
+func TestCursorCodec(t *testing.T) {
+	codec := query.NewCursorCodec([]byte("secret"))
+	at := time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC)
+	pc := query.PageCursor{Keys: []string{"createdAt", "id"}, Values: []any{at, "a1"}}
+
+	token, err := codec.Encode(pc)
+	require.NoError(t, err)
+
+	got, err := codec.Decode(token)
+	require.NoError(t, err)
+	assert.True(t, got.Matches(pc.Keys))
+	assert.Equal(t, pc.Values, got.Values)
+
+	_, err = query.NewCursorCodec([]byte("other")).Decode(token)
+	var apiErr apierrors.Error
+	require.ErrorAs(t, err, &apiErr)
+	assert.Equal(t, apierrors.CodeInvalidArgument, apiErr.Code())
+}
+
+func TestCursorCodecWithoutSecret(t *testing.T) {
+	codec := query.NewCursorCodec(nil)
+
+	_, err := codec.Encode(query.PageCursor{Keys: []string{"id"}, Values: []any{"a1"}})
+	var apiErr apierrors.Error
+	require.ErrorAs(t, err, &apiErr)
+	assert.Equal(t, apierrors.CodeConfigurationError, apiErr.Code())
+
+	_, err = codec.Decode("token")
+	require.ErrorAs(t, err, &apiErr)
+	assert.Equal(t, apierrors.CodeConfigurationError, apiErr.Code())
+}
+
+func TestPageURL(t *testing.T) {
+	u, err := url.Parse("https://api.test/v1/users?name[eq]=bob&page[limit]=10&page[after]=old&page[offset]=20")
+	require.NoError(t, err)
+
+	next, err := url.Parse(query.PageURL(u, query.CursorAfter, "next"))
+	require.NoError(t, err)
+	assert.Equal(t, "api.test", next.Host)
+	assert.Equal(t, "/v1/users", next.Path)
+	assert.Equal(t, url.Values{
+		"name[eq]":    {"bob"},
+		"page[limit]": {"10"},
+		"page[after]": {"next"},
+	}, next.Query())
+
+	prev, err := url.Parse(query.PageURL(u, query.CursorBefore, "prev"))
+	require.NoError(t, err)
+	assert.Equal(t, url.Values{
+		"name[eq]":     {"bob"},
+		"page[limit]":  {"10"},
+		"page[before]": {"prev"},
+	}, prev.Query())
+
+	opts, err := query.ParseURLQueryOpts(next)
+	require.NoError(t, err)
+	q := query.New(opts...)
+	require.NotNil(t, q.CursorPagination())
+	assert.Equal(t, "next", q.CursorPagination().Cursor)
+}
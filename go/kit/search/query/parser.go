@@ -18,6 +18,16 @@ const (
 	defaultPagOffset = 0
 
 	filterSplits = 3
+
+	paramPageLimit  = "page[limit]"
+	paramPageOffset = "page[offset]"
+	paramPageCursor = "page[cursor]"
+	paramPageAfter  = "page[after]"
+	paramPageBefore = "page[before]"
+
+	paramAggregate = "aggregate"
+	paramGroup     = "group"
+	paramSort      = "sort"
 )
 
 var (
@@ -40,6 +50,14 @@ var (
 		"btw":      filter.OpBetween,
 		"any":      filter.OpContains,
 		"any-like": filter.OpContainsLike,
+		"search":   filter.OpSearch,
+		"fuzzy":    filter.OpFuzzy,
+
+		"json-contains": filter.OpJSONContains,
+		"has-key":       filter.OpHasKey,
+		"has-any-keys":  filter.OpHasAnyKeys,
+		"has-all-keys":  filter.OpHasAllKeys,
+		"json-path":     filter.OpJSONPath,
 	}
 
 	//nolint:gochecknoglobals // map is used in every GET request with filters, it's more efficient to keep it global
@@ -58,6 +76,13 @@ var (
 		filter.OpBetween:      "btw",
 		filter.OpContains:     "any",
 		filter.OpContainsLike: "any-like",
+		filter.OpSearch:       "search",
+		filter.OpFuzzy:        "fuzzy",
+		filter.OpJSONContains: "json-contains",
+		filter.OpHasKey:       "has-key",
+		filter.OpHasAnyKeys:   "has-any-keys",
+		filter.OpHasAllKeys:   "has-all-keys",
+		filter.OpJSONPath:     "json-path",
 	}
 )
 
@@ -89,6 +114,20 @@ func parseFilter(filterKey string) (string, filter.Operator, error) {
 }
 
 func parseValue(op filter.Operator, val []string) any {
+	switch op {
+	case filter.OpSearch, filter.OpFuzzy:
+		// search text is free form: commas, "null" or "true" are plain words
+		return strings.Join(val, " ")
+	case filter.OpJSONContains, filter.OpHasKey, filter.OpJSONPath:
+		// JSON documents and paths hold commas of their own
+		return strings.Join(val, ",")
+	case filter.OpHasAnyKeys, filter.OpHasAllKeys:
+		keys := []string{}
+		for _, v := range val {
+			keys = append(keys, strings.Split(v, ",")...)
+		}
+		return keys
+	}
 	if len(val) == 1 {
 
 		if strings.ToLower(val[0]) == "null" {
@@ -116,8 +155,26 @@ func parseValue(op filter.Operator, val []string) any {
 
 func searchFromURL(uri *url.URL) ([]Option, error) {
 	opts := []Option{}
+	groups := newFilterGroupBuilder()
+	exprs := []*FilterNode{}
 	for key, values := range uri.Query() {
+		if key == filterParam {
+			for _, v := range values {
+				node, err := ParseFilterExpr(v)
+				if err != nil {
+					return nil, err
+				}
+				exprs = append(exprs, node)
+			}
+			continue
+		}
 		if strings.Contains(key, "filter") {
+			if isFilterGroupKey(key) {
+				if err := groups.add(key, values); err != nil {
+					return nil, err
+				}
+				continue
+			}
 			fName, op, err := parseFilter(key)
 			if err != nil {
 				return nil, err
@@ -125,14 +182,22 @@ func searchFromURL(uri *url.URL) ([]Option, error) {
 			opts = append(opts, FilterBy(op, fName, parseValue(op, values)))
 		}
 	}
+	if nodes := append(groups.nodes(), exprs...); len(nodes) > 0 {
+		opts = append(opts, Where(nodes...))
+	}
 
 	return opts, nil
 }
 
 func paginationFromURL(uri *url.URL, defaultIfEmpty bool) (opt Option, err error) {
+	opt, isCursor, err := cursorPaginationFromURL(uri)
+	if isCursor || err != nil {
+		return opt, err
+	}
+
 	limit, offset := defaultPagLimit, defaultPagOffset
-	l := uri.Query().Get("page[limit]")
-	o := uri.Query().Get("page[offset]")
+	l := uri.Query().Get(paramPageLimit)
+	o := uri.Query().Get(paramPageOffset)
 	if l == "" && o == "" && !defaultIfEmpty {
 		return nil, nil
 	}
@@ -151,6 +216,129 @@ func paginationFromURL(uri *url.URL, defaultIfEmpty bool) (opt Option, err error
 	return Pagination(limit, offset), nil
 }
 
+// cursorPaginationFromURL parses keyset pagination. page[cursor] is an alias
+// of page[after]; the presence of either key, even empty, selects cursor
+// pagination, an empty value meaning the first page.
+func cursorPaginationFromURL(uri *url.URL) (Option, bool, error) {
+	values := uri.Query()
+	after := values.Has(paramPageAfter) || values.Has(paramPageCursor)
+	before := values.Has(paramPageBefore)
+	if !after && !before {
+		return nil, false, nil
+	}
+	if after && before {
+		return nil, true, kiterrors.InvalidArgument("page[after] and page[before] cannot be combined")
+	}
+	if values.Has(paramPageOffset) {
+		return nil, true, kiterrors.InvalidArgument("page[offset] cannot be combined with cursor pagination")
+	}
+
+	limit := defaultPagLimit
+	if l := values.Get(paramPageLimit); l != "" {
+		var err error
+		limit, err = strconv.Atoi(l)
+		if err != nil || limit < 0 {
+			return nil, true, kiterrors.InvalidArgument(fmt.Sprintf("invalid limit: %s", l))
+		}
+	}
+
+	if before {
+		return CursorPage(limit, values.Get(paramPageBefore), CursorBefore), true, nil
+	}
+	cursor := values.Get(paramPageAfter)
+	if cursor == "" {
+		cursor = values.Get(paramPageCursor)
+	}
+
+	return CursorPage(limit, cursor, CursorAfter), true, nil
+}
+
+// aggregationFromURL parses aggregate=count,sum:amount and
+// group=status,created_at:day.
+func aggregationFromURL(uri *url.URL) ([]Option, error) {
+	values := uri.Query()
+	if !values.Has(paramAggregate) && !values.Has(paramGroup) {
+		return nil, nil
+	}
+
+	metrics := []Metric{}
+	for _, v := range values[paramAggregate] {
+		for _, item := range strings.Split(v, ",") {
+			fn, field, _ := strings.Cut(strings.TrimSpace(item), ":")
+			agg := ParseAggregateFunc(fn)
+			if !agg.Valid() {
+				return nil, kiterrors.InvalidArgument(fmt.Sprintf("invalid aggregate function: %s", fn))
+			}
+			if field == "" && agg != AggCount {
+				return nil, kiterrors.InvalidArgument(fmt.Sprintf("aggregate function %s requires a field", fn))
+			}
+			metrics = append(metrics, Metric{Func: agg, Field: field})
+		}
+	}
+	opts := []Option{Aggregate(metrics...)}
+
+	for _, v := range values[paramGroup] {
+		for _, item := range strings.Split(v, ",") {
+			field, unit, hasUnit := strings.Cut(strings.TrimSpace(item), ":")
+			if field == "" {
+				return nil, kiterrors.InvalidArgument(fmt.Sprintf("invalid group: %s", item))
+			}
+			if !hasUnit {
+				opts = append(opts, GroupBy(field))
+				continue
+			}
+			if !TruncUnit(unit).Valid() {
+				return nil, kiterrors.InvalidArgument(fmt.Sprintf("invalid group time unit: %s", unit))
+			}
+			opts = append(opts, GroupByTrunc(field, TruncUnit(unit)))
+		}
+	}
+
+	return opts, nil
+}
+
+// sortingFromURL parses JSON:API sorting, e.g. sort=-relevance,created_at,
+// where a leading minus sorts descending. The keys are marked as requested by
+// the client, see SortingParams.FromURL.
+func sortingFromURL(uri *url.URL) (Option, error) {
+	type sortKey struct {
+		key string
+		dir SortingDir
+	}
+	sortKeys := []sortKey{}
+	for _, v := range uri.Query()[paramSort] {
+		for _, item := range strings.Split(v, ",") {
+			key, desc := strings.CutPrefix(strings.TrimSpace(item), "-")
+			if key == "" {
+				return nil, kiterrors.InvalidArgument(fmt.Sprintf("invalid sort: %s", v))
+			}
+			dir := SortAsc
+			if desc {
+				dir = SortDesc
+			}
+			sortKeys = append(sortKeys, sortKey{key: key, dir: dir})
+		}
+	}
+	if len(sortKeys) == 0 {
+		return nil, nil
+	}
+
+	return func(q *query) {
+		for _, s := range sortKeys {
+			q.sortingParams.setFromURL(s.key, s.dir)
+		}
+	}, nil
+}
+
+func ParseAggregateFunc(val string) AggregateFunc {
+	for f := AggCount; f <= AggMax; f++ {
+		if f.String() == val {
+			return f
+		}
+	}
+	return AggUndefined
+}
+
 func includedResourceObjectsFromURL(uri *url.URL) []Option {
 	opts := []Option{}
 	if len(uri.Query()) < 1 {
@@ -211,6 +399,20 @@ func ParseURLQueryOpts(uri *url.URL, parseOpts ...ParseOpt) ([]Option, error) {
 		opts = append(opts, pag)
 	}
 
+	sorting, err := sortingFromURL(uri)
+	if err != nil {
+		return nil, err
+	}
+	if sorting != nil {
+		opts = append(opts, sorting)
+	}
+
+	aggregation, err := aggregationFromURL(uri)
+	if err != nil {
+		return nil, err
+	}
+	opts = append(opts, aggregation...)
+
 	includedResourceObjs := includedResourceObjectsFromURL(uri)
 	if len(includedResourceObjs) > 0 {
 		opts = append(opts, includedResourceObjs...)
@@ -43,6 +43,8 @@ func (sd SortingDir) String() string {
 type SortingParams struct {
 	m    map[string]SortingDir
 	keys []string
+	// fromURL holds the keys requested by the client, see FromURL.
+	fromURL map[string]bool
 }
 
 func newSortingParams() *SortingParams {
@@ -60,6 +62,22 @@ func (sp *SortingParams) Set(key string, v SortingDir) {
 	}
 }
 
+// setFromURL sets a key requested in the URL of the query.
+func (sp *SortingParams) setFromURL(key string, v SortingDir) {
+	sp.Set(key, v)
+	if sp.fromURL == nil {
+		sp.fromURL = make(map[string]bool)
+	}
+	sp.fromURL[key] = true
+}
+
+// FromURL reports whether key was requested by the client in the URL of the
+// query, see ParseURLQueryOpts, rather than set by the service. Repositories
+// only sort by the exposed fields for those keys.
+func (sp *SortingParams) FromURL(key string) bool {
+	return sp != nil && sp.fromURL[key]
+}
+
 func (sp *SortingParams) Keys() []string {
 	return sp.keys
 }
@@ -173,9 +191,12 @@ func UpdateFilter[T any](q Query, name string, updateFunc func(filter.Operator,
 
 type Query interface {
 	Filters() Filters[any]
+	FilterGroups() []*FilterNode
 	Sorting() *SortingParams
 	Merge(q Query)
 	Pagination() *PaginationParams
+	CursorPagination() *CursorPagination
+	Aggregation() *Aggregation
 	IncludedResourceObjects() []string
 	Equal(another Query) bool
 }
@@ -277,6 +298,21 @@ func Pagination(limit, offset int) Option {
 	}
 }
 
+// CursorPage requests keyset pagination. It takes precedence over offset
+// pagination when both are set.
+func CursorPage(limit int, cursor string, dir CursorDirection) Option {
+	return func(q *query) {
+		if !dir.Valid() {
+			return
+		}
+		q.cursorPagination = &CursorPagination{
+			Limit:     limit,
+			Cursor:    cursor,
+			Direction: dir,
+		}
+	}
+}
+
 func IncludedResourceObjects(relationshipNames ...string) Option {
 	return func(q *query) {
 		q.includedResourceObjects = append(q.includedResourceObjects, relationshipNames...)
@@ -285,8 +321,11 @@ func IncludedResourceObjects(relationshipNames ...string) Option {
 
 type query struct {
 	filters                 map[string]filter.FieldFilter[any]
+	filterGroups            []*FilterNode
 	sortingParams           *SortingParams
 	pagination              *PaginationParams
+	cursorPagination        *CursorPagination
+	aggregate               *Aggregation
 	includedResourceObjects []string
 }
 
@@ -294,6 +333,10 @@ func (q *query) Filters() Filters[any] {
 	return q.filters
 }
 
+func (q *query) FilterGroups() []*FilterNode {
+	return q.filterGroups
+}
+
 func (q *query) Sorting() *SortingParams {
 	return q.sortingParams
 }
@@ -301,10 +344,17 @@ func (q *query) Sorting() *SortingParams {
 func (q *query) Merge(m Query) {
 	if m != nil {
 		q.mergeFilters(m.Filters())
+		q.filterGroups = append(q.filterGroups, m.FilterGroups()...)
 		q.mergeSorting(m.Sorting())
 		if m.Pagination() != nil {
 			q.pagination = m.Pagination()
 		}
+		if m.CursorPagination() != nil {
+			q.cursorPagination = m.CursorPagination()
+		}
+		if m.Aggregation() != nil {
+			q.aggregate = m.Aggregation()
+		}
 		if m.IncludedResourceObjects() != nil {
 			q.includedResourceObjects = m.IncludedResourceObjects()
 		}
@@ -315,6 +365,14 @@ func (q *query) Pagination() *PaginationParams {
 	return q.pagination
 }
 
+func (q *query) CursorPagination() *CursorPagination {
+	return q.cursorPagination
+}
+
+func (q *query) Aggregation() *Aggregation {
+	return q.aggregate
+}
+
 func (q *query) IncludedResourceObjects() []string {
 	return q.includedResourceObjects
 }
@@ -337,6 +395,10 @@ func (q *query) mergeSorting(sortParams *SortingParams) {
 		if len(key) < 1 || !dir.Valid() {
 			continue
 		}
+		if sortParams.FromURL(key) {
+			q.sortingParams.setFromURL(key, dir)
+			continue
+		}
 		q.sortingParams.Set(key, dir)
 	}
 }
@@ -36,6 +36,98 @@ func (_m *Query) EXPECT() *Query_Expecter {
 	return &Query_Expecter{mock: &_m.Mock}
 }
 
+// Aggregation provides a mock function for the type Query
+func (_mock *Query) Aggregation() *query.Aggregation {
+	ret := _mock.Called()
+
+	if len(ret) == 0 {
+		panic("no return value specified for Aggregation")
+	}
+
+	var r0 *query.Aggregation
+	if returnFunc, ok := ret.Get(0).(func() *query.Aggregation); ok {
+		r0 = returnFunc()
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*query.Aggregation)
+		}
+	}
+	return r0
+}
+
+// Query_Aggregation_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'Aggregation'
+type Query_Aggregation_Call struct {
+	*mock.Call
+}
+
+// Aggregation is a helper method to define mock.On call
+func (_e *Query_Expecter) Aggregation() *Query_Aggregation_Call {
+	return &Query_Aggregation_Call{Call: _e.mock.On("Aggregation")}
+}
+
+func (_c *Query_Aggregation_Call) Run(run func()) *Query_Aggregation_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		run()
+	})
+	return _c
+}
+
+func (_c *Query_Aggregation_Call) Return(aggregation *query.Aggregation) *Query_Aggregation_Call {
+	_c.Call.Return(aggregation)
+	return _c
+}
+
+func (_c *Query_Aggregation_Call) RunAndReturn(run func() *query.Aggregation) *Query_Aggregation_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
+// CursorPagination provides a mock function for the type Query
+func (_mock *Query) CursorPagination() *query.CursorPagination {
+	ret := _mock.Called()
+
+	if len(ret) == 0 {
+		panic("no return value specified for CursorPagination")
+	}
+
+	var r0 *query.CursorPagination
+	if returnFunc, ok := ret.Get(0).(func() *query.CursorPagination); ok {
+		r0 = returnFunc()
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*query.CursorPagination)
+		}
+	}
+	return r0
+}
+
+// Query_CursorPagination_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'CursorPagination'
+type Query_CursorPagination_Call struct {
+	*mock.Call
+}
+
+// CursorPagination is a helper method to define mock.On call
+func (_e *Query_Expecter) CursorPagination() *Query_CursorPagination_Call {
+	return &Query_CursorPagination_Call{Call: _e.mock.On("CursorPagination")}
+}
+
+func (_c *Query_CursorPagination_Call) Run(run func()) *Query_CursorPagination_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		run()
+	})
+	return _c
+}
+
+func (_c *Query_CursorPagination_Call) Return(cursorPagination *query.CursorPagination) *Query_CursorPagination_Call {
+	_c.Call.Return(cursorPagination)
+	return _c
+}
+
+func (_c *Query_CursorPagination_Call) RunAndReturn(run func() *query.CursorPagination) *Query_CursorPagination_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
 // Equal provides a mock function for the type Query
 func (_mock *Query) Equal(another query.Query) bool {
 	ret := _mock.Called(another)
@@ -87,6 +179,52 @@ func (_c *Query_Equal_Call) RunAndReturn(run func(another query.Query) bool) *Qu
 	return _c
 }
 
+// FilterGroups provides a mock function for the type Query
+func (_mock *Query) FilterGroups() []*query.FilterNode {
+	ret := _mock.Called()
+
+	if len(ret) == 0 {
+		panic("no return value specified for FilterGroups")
+	}
+
+	var r0 []*query.FilterNode
+	if returnFunc, ok := ret.Get(0).(func() []*query.FilterNode); ok {
+		r0 = returnFunc()
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]*query.FilterNode)
+		}
+	}
+	return r0
+}
+
+// Query_FilterGroups_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'FilterGroups'
+type Query_FilterGroups_Call struct {
+	*mock.Call
+}
+
+// FilterGroups is a helper method to define mock.On call
+func (_e *Query_Expecter) FilterGroups() *Query_FilterGroups_Call {
+	return &Query_FilterGroups_Call{Call: _e.mock.On("FilterGroups")}
+}
+
+func (_c *Query_FilterGroups_Call) Run(run func()) *Query_FilterGroups_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		run()
+	})
+	return _c
+}
+
+func (_c *Query_FilterGroups_Call) Return(filterNodes []*query.FilterNode) *Query_FilterGroups_Call {
+	_c.Call.Return(filterNodes)
+	return _c
+}
+
+func (_c *Query_FilterGroups_Call) RunAndReturn(run func() []*query.FilterNode) *Query_FilterGroups_Call {
+	_c.Call.Return(run)
+	return _c
+}
+
 // Filters provides a mock function for the type Query
 func (_mock *Query) Filters() query.Filters[any] {
 	ret := _mock.Called()
@@ -0,0 +1,595 @@
+package query
+
+import (
+	"fmt"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/google/uuid"
+
+	"github.com/dosanma1/forge/go/kit/errors"
+	"github.com/dosanma1/forge/go/kit/filter"
+)
+
+const (
+	tagSearch  = "search"
+	tagJSONAPI = "jsonapi"
+	tagJSON    = "json"
+	tagGorm    = "gorm"
+
+	schemaRuleSeparator  = ";"
+	schemaParamSeparator = "="
+	schemaListSeparator  = ","
+
+	schemaRuleFilter   = "filter"
+	schemaRuleSort     = "sort"
+	schemaRuleRequired = "required"
+	schemaRuleColumn   = "column"
+	schemaRuleType     = "type"
+	schemaRuleName     = "name"
+)
+
+// FieldType is the type filter values of a field are coerced to.
+type FieldType string
+
+const (
+	FieldTypeString FieldType = "string"
+	FieldTypeUUID   FieldType = "uuid"
+	FieldTypeInt    FieldType = "int"
+	FieldTypeFloat  FieldType = "float"
+	FieldTypeBool   FieldType = "bool"
+	FieldTypeTime   FieldType = "time"
+)
+
+var (
+	schemaTimeType = reflect.TypeOf(time.Time{})
+	schemaUUIDType = reflect.TypeOf(uuid.UUID{})
+)
+
+// FieldRule holds the query rules of a field declared by a search tag.
+type FieldRule struct {
+	// Name is the name the field is filtered and sorted by.
+	Name string
+	// Column is the storage column the field maps to.
+	Column string
+	// Type is the type filter values are coerced to.
+	Type FieldType
+	// Operators are the filter operators allowed on the field, none when the
+	// field cannot be filtered.
+	Operators []filter.Operator
+	// Sortable reports whether the query can be sorted by the field.
+	Sortable bool
+	// Required reports whether every query must filter by the field.
+	Required bool
+}
+
+// Schema holds the query rules of a resource, declared once on its model or
+// DTO with search struct tags:
+//
+//	type Article struct {
+//		ID        string    `jsonapi:"primary,articles" search:"filter=eq,in;type=uuid"`
+//		Title     string    `jsonapi:"attr,title" search:"filter=eq,like,search;sort"`
+//		CreatedAt time.Time `jsonapi:"attr,createdAt" search:"filter=gte,lte;sort;column=created_at"`
+//	}
+//
+// The rules of a tag are separated by semicolons:
+//   - filter=ops allows filtering by the field with the given operators,
+//     a bare filter allows eq only
+//   - sort allows sorting by the field
+//   - required makes the filter mandatory
+//   - column=name sets the storage column, defaulting to the gorm column
+//     then to the snake case of the Go name
+//   - type=t sets the value type, one of string, uuid, int, float, bool or
+//     time, defaulting to the one of the Go type
+//   - name=n sets the query name, defaulting to the JSON:API then json name
+//
+// Anonymous embedded structs are flattened.
+type Schema struct {
+	fields []FieldRule
+}
+
+// SchemaOf reflects the search tags of T, a struct or a pointer to one.
+func SchemaOf[T any]() (*Schema, error) {
+	t := reflect.TypeOf((*T)(nil)).Elem()
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	if t.Kind() != reflect.Struct {
+		return nil, fmt.Errorf("query schema: expected a struct, got %s", t.Kind())
+	}
+
+	s := &Schema{}
+	if err := s.collect(t); err != nil {
+		return nil, err
+	}
+
+	return s, nil
+}
+
+// MustSchemaOf is like SchemaOf but panics on malformed tags, it is meant
+// for package level declarations.
+func MustSchemaOf[T any]() *Schema {
+	s, err := SchemaOf[T]()
+	if err != nil {
+		panic(err)
+	}
+
+	return s
+}
+
+func (s *Schema) collect(t reflect.Type) error {
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		tag, tagged := sf.Tag.Lookup(tagSearch)
+		if sf.Anonymous && !tagged {
+			ft := sf.Type
+			for ft.Kind() == reflect.Pointer {
+				ft = ft.Elem()
+			}
+			if ft.Kind() == reflect.Struct {
+				if err := s.collect(ft); err != nil {
+					return err
+				}
+			}
+			continue
+		}
+		if !tagged || tag == "-" || !sf.IsExported() {
+			continue
+		}
+
+		rule, err := parseSearchTag(sf, tag)
+		if err != nil {
+			return err
+		}
+		if _, exists := s.Field(rule.Name); exists {
+			return fmt.Errorf("query schema: duplicated field %s", rule.Name)
+		}
+		s.fields = append(s.fields, rule)
+	}
+
+	return nil
+}
+
+func parseSearchTag(sf reflect.StructField, tag string) (FieldRule, error) {
+	rule := FieldRule{}
+	for _, part := range strings.Split(tag, schemaRuleSeparator) {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		key, param, _ := strings.Cut(part, schemaParamSeparator)
+		switch key {
+		case schemaRuleFilter:
+			if param == "" {
+				rule.Operators = append(rule.Operators, filter.OpEq)
+				continue
+			}
+			for _, name := range strings.Split(param, schemaListSeparator) {
+				op := ParseOperator(strings.TrimSpace(name))
+				if op == filter.OpUndefined {
+					return FieldRule{}, fmt.Errorf("query schema: field %s: unknown operator %q", sf.Name, name)
+				}
+				if !slices.Contains(rule.Operators, op) {
+					rule.Operators = append(rule.Operators, op)
+				}
+			}
+		case schemaRuleSort:
+			rule.Sortable = true
+		case schemaRuleRequired:
+			rule.Required = true
+		case schemaRuleColumn:
+			rule.Column = param
+		case schemaRuleType:
+			rule.Type = FieldType(param)
+		case schemaRuleName:
+			rule.Name = param
+		default:
+			return FieldRule{}, fmt.Errorf("query schema: field %s: unknown rule %q", sf.Name, key)
+		}
+	}
+
+	if rule.Name == "" {
+		rule.Name = schemaFieldName(sf)
+	}
+	if rule.Column == "" {
+		rule.Column = schemaColumnName(sf)
+	}
+	if rule.Type == "" {
+		rule.Type = schemaFieldType(sf.Type)
+	}
+	switch rule.Type {
+	case FieldTypeString, FieldTypeUUID, FieldTypeInt, FieldTypeFloat, FieldTypeBool, FieldTypeTime:
+	default:
+		return FieldRule{}, fmt.Errorf("query schema: field %s: unknown type %q", sf.Name, rule.Type)
+	}
+	if rule.Required && len(rule.Operators) == 0 {
+		rule.Operators = []filter.Operator{filter.OpEq}
+	}
+
+	return rule, nil
+}
+
+// schemaFieldName resolves the name a field is queried by: the JSON:API
+// attribute name, then the json name, then the Go name.
+func schemaFieldName(sf reflect.StructField) string {
+	if tag, ok := sf.Tag.Lookup(tagJSONAPI); ok {
+		parts := strings.Split(tag, ",")
+		switch {
+		case len(parts) > 0 && parts[0] == "primary":
+			return "id"
+		case len(parts) > 1 && parts[1] != "":
+			return parts[1]
+		}
+	}
+	if tag, ok := sf.Tag.Lookup(tagJSON); ok {
+		name, _, _ := strings.Cut(tag, ",")
+		if name != "" && name != "-" {
+			return name
+		}
+	}
+
+	return sf.Name
+}
+
+func schemaColumnName(sf reflect.StructField) string {
+	for _, setting := range strings.Split(sf.Tag.Get(tagGorm), ";") {
+		key, val, _ := strings.Cut(setting, ":")
+		if strings.EqualFold(strings.TrimSpace(key), "column") && val != "" {
+			return strings.TrimSpace(val)
+		}
+	}
+
+	return snakeCase(sf.Name)
+}
+
+// snakeCase mimics the default gorm naming, e.g. UserID becomes user_id.
+func snakeCase(name string) string {
+	runes := []rune(name)
+	sb := strings.Builder{}
+	for i, r := range runes {
+		if unicode.IsUpper(r) && i > 0 {
+			prev := runes[i-1]
+			nextLower := i+1 < len(runes) && unicode.IsLower(runes[i+1])
+			if unicode.IsLower(prev) || unicode.IsDigit(prev) || (unicode.IsUpper(prev) && nextLower) {
+				sb.WriteByte('_')
+			}
+		}
+		sb.WriteRune(unicode.ToLower(r))
+	}
+
+	return sb.String()
+}
+
+func schemaFieldType(t reflect.Type) FieldType {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	switch {
+	case t == schemaTimeType:
+		return FieldTypeTime
+	case t == schemaUUIDType:
+		return FieldTypeUUID
+	}
+	switch t.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return FieldTypeInt
+	case reflect.Float32, reflect.Float64:
+		return FieldTypeFloat
+	case reflect.Bool:
+		return FieldTypeBool
+	default:
+		return FieldTypeString
+	}
+}
+
+// Fields returns the rules of every tagged field, in declaration order.
+func (s *Schema) Fields() []FieldRule {
+	return slices.Clone(s.fields)
+}
+
+// Field returns the rules of the named field.
+func (s *Schema) Field(name string) (FieldRule, bool) {
+	for _, f := range s.fields {
+		if f.Name == name {
+			return f, true
+		}
+	}
+
+	return FieldRule{}, false
+}
+
+// FieldMapper maps the field names to their columns, as expected by the
+// repositories, e.g. postgres.NewRepo.
+func (s *Schema) FieldMapper() map[string]string {
+	m := make(map[string]string, len(s.fields))
+	for _, f := range s.fields {
+		m[f.Name] = f.Column
+	}
+
+	return m
+}
+
+// ValidationOpts returns the validation options enforcing the schema: the
+// filterable, mandatory, searchable and sortable fields, the operators
+// allowed per field and the type of the filter values.
+func (s *Schema) ValidationOpts() []ValidationOpt {
+	var (
+		optional, mandatory, sortable, searchable []string
+		opts                                      []ValidationOpt
+	)
+	for _, f := range s.fields {
+		if f.Sortable {
+			sortable = append(sortable, f.Name)
+		}
+		if len(f.Operators) == 0 {
+			continue
+		}
+		if f.Required {
+			mandatory = append(mandatory, f.Name)
+		} else {
+			optional = append(optional, f.Name)
+		}
+		if slices.ContainsFunc(f.Operators, IsSearchOperator) {
+			searchable = append(searchable, f.Name)
+		}
+		opts = append(opts,
+			AllowedOperators(f.Name, f.Operators...),
+			ValidFilter(f.Name, validateFieldType(f.Type)),
+		)
+	}
+
+	return append([]ValidationOpt{
+		OptionalFilters(optional...),
+		MandatoryFilters(mandatory...),
+		SortFields(sortable...),
+		SearchFields(searchable...),
+	}, opts...)
+}
+
+// Validate coerces the filter values of q to the types of the schema, then
+// validates q against the schema and the extra options.
+func (s *Schema) Validate(q Query, opts ...ValidationOpt) error {
+	if q == nil {
+		return errors.InvalidArgument("query cannot be nil")
+	}
+	if err := s.Coerce(q); err != nil {
+		return err
+	}
+
+	return Validate(q, append(s.ValidationOpts(), opts...)...)
+}
+
+// Coerce converts in place the filter values of q parsed as strings, e.g.
+// from URL parameters, to the types of the schema fields: int64, float64,
+// bool or time.Time, either single or as slices. UUIDs stay strings but must
+// parse. Values of fields outside the schema and of text or JSON operators
+// are left untouched.
+func (s *Schema) Coerce(q Query) error {
+	filters := q.Filters()
+	for name, f := range filters {
+		coerced, err := s.coerceFilter(f)
+		if err != nil {
+			return err
+		}
+		filters[name] = coerced
+	}
+
+	var walk func(n *FilterNode) error
+	walk = func(n *FilterNode) error {
+		if n == nil {
+			return nil
+		}
+		if n.IsLeaf() {
+			coerced, err := s.coerceFilter(n.Filter)
+			if err != nil {
+				return err
+			}
+			n.Filter = coerced
+			return nil
+		}
+		for _, c := range n.Children {
+			if err := walk(c); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	for _, g := range q.FilterGroups() {
+		if err := walk(g); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (s *Schema) coerceFilter(f filter.FieldFilter[any]) (filter.FieldFilter[any], error) {
+	rule, ok := s.Field(f.Name())
+	if !ok || !coercible(f.Operator()) {
+		return f, nil
+	}
+	val, err := coerceValue(rule.Type, f.Name(), f.Value())
+	if err != nil {
+		return nil, err
+	}
+
+	return filter.NewFieldFilter(f.Operator(), f.Name(), val), nil
+}
+
+// coercible excludes the operators whose value is not a field value: text
+// patterns, search text and JSON operands.
+func coercible(op filter.Operator) bool {
+	switch op {
+	case filter.OpLike, filter.OpContainsLike, filter.OpSearch, filter.OpFuzzy,
+		filter.OpJSONContains, filter.OpHasKey, filter.OpHasAnyKeys, filter.OpHasAllKeys, filter.OpJSONPath:
+		return false
+	default:
+		return true
+	}
+}
+
+func validateFieldType(ft FieldType) ValidationFunc {
+	return func(f filter.FieldFilter[any]) error {
+		if !coercible(f.Operator()) {
+			return nil
+		}
+		_, err := coerceValue(ft, f.Name(), f.Value())
+		return err
+	}
+}
+
+func coerceValue(ft FieldType, name string, val any) (any, error) {
+	switch v := val.(type) {
+	case nil:
+		return nil, nil
+	case []string:
+		switch ft {
+		case FieldTypeInt:
+			return coerceSlice(v, ft, name, parseInt)
+		case FieldTypeFloat:
+			return coerceSlice(v, ft, name, parseFloat)
+		case FieldTypeBool:
+			return coerceSlice(v, ft, name, strconv.ParseBool)
+		case FieldTypeTime:
+			return coerceSlice(v, ft, name, parseTime)
+		case FieldTypeUUID:
+			return coerceSlice(v, ft, name, parseUUID)
+		default:
+			return v, nil
+		}
+	case string:
+		switch ft {
+		case FieldTypeInt:
+			return coerceOne(v, ft, name, parseInt)
+		case FieldTypeFloat:
+			return coerceOne(v, ft, name, parseFloat)
+		case FieldTypeBool:
+			return coerceOne(v, ft, name, strconv.ParseBool)
+		case FieldTypeTime:
+			return coerceOne(v, ft, name, parseTime)
+		case FieldTypeUUID:
+			return coerceOne(v, ft, name, parseUUID)
+		default:
+			return v, nil
+		}
+	case bool:
+		switch ft {
+		case FieldTypeBool:
+			return v, nil
+		case FieldTypeString:
+			// the URL parser reads true and false as booleans
+			return strconv.FormatBool(v), nil
+		}
+	default:
+		if typedAs(ft, val) {
+			return val, nil
+		}
+		if rv := reflect.ValueOf(val); rv.Kind() == reflect.Slice {
+			return coerceElems(ft, name, rv)
+		}
+	}
+
+	return nil, invalidFieldValue(name, ft)
+}
+
+// coerceElems coerces the elements of a slice one by one, e.g. the []any of
+// the builder and of the filter expression parser. The result is a slice of
+// the coerced type when all the elements share it.
+func coerceElems(ft FieldType, name string, rv reflect.Value) (any, error) {
+	vals := make([]any, rv.Len())
+	var elemType reflect.Type
+	sameType := true
+	for i := range vals {
+		v, err := coerceValue(ft, name, rv.Index(i).Interface())
+		if err != nil {
+			return nil, err
+		}
+		if v == nil {
+			return nil, invalidFieldValue(name, ft)
+		}
+		switch t := reflect.TypeOf(v); {
+		case elemType == nil:
+			elemType = t
+		case t != elemType:
+			sameType = false
+		}
+		vals[i] = v
+	}
+	if !sameType || elemType == nil {
+		return vals, nil
+	}
+
+	typed := reflect.MakeSlice(reflect.SliceOf(elemType), len(vals), len(vals))
+	for i, v := range vals {
+		typed.Index(i).Set(reflect.ValueOf(v))
+	}
+
+	return typed.Interface(), nil
+}
+
+func coerceOne[T any](s string, ft FieldType, name string, parse func(string) (T, error)) (any, error) {
+	v, err := parse(strings.TrimSpace(s))
+	if err != nil {
+		return nil, invalidFieldValue(name, ft)
+	}
+
+	return v, nil
+}
+
+func coerceSlice[T any](ss []string, ft FieldType, name string, parse func(string) (T, error)) (any, error) {
+	vals := make([]T, len(ss))
+	for i, s := range ss {
+		v, err := parse(strings.TrimSpace(s))
+		if err != nil {
+			return nil, invalidFieldValue(name, ft)
+		}
+		vals[i] = v
+	}
+
+	return vals, nil
+}
+
+// typedAs accepts values already holding the Go type of ft, e.g. set by
+// query options or coerced before.
+func typedAs(ft FieldType, val any) bool {
+	rv := reflect.ValueOf(val)
+	if rv.Kind() == reflect.Slice {
+		t := rv.Type().Elem()
+		return t.Kind() != reflect.Interface && schemaFieldType(t) == ft
+	}
+
+	return schemaFieldType(rv.Type()) == ft
+}
+
+func parseInt(s string) (int64, error) {
+	return strconv.ParseInt(s, 10, 64)
+}
+
+func parseFloat(s string) (float64, error) {
+	return strconv.ParseFloat(s, 64)
+}
+
+func parseTime(s string) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339Nano, s); err == nil {
+		return t, nil
+	}
+
+	return time.Parse(time.DateOnly, s)
+}
+
+func parseUUID(s string) (string, error) {
+	if _, err := uuid.Parse(s); err != nil {
+		return "", err
+	}
+
+	return s, nil
+}
+
+func invalidFieldValue(name string, ft FieldType) error {
+	return errors.InvalidArgument(fmt.Sprintf("invalid value for field %s, expected %s", name, ft))
+}
@@ -0,0 +1,202 @@
+package query_test
+
+import (
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	apierrors "github.com/dosanma1/forge/go/kit/errors"
+	"github.com/dosanma1/forge/go/kit/filter"
+	"github.com/dosanma1/forge/go/kit/search/query"
+)
+
+type schemaBase struct {
+	CreatedAt time.Time `jsonapi:"attr,createdAt" search:"filter=gte,lte;sort"`
+}
+
+type schemaArticle struct {
+	schemaBase
+	ID       string   `jsonapi:"primary,articles" search:"filter=eq,in;type=uuid"`
+	Title    string   `jsonapi:"attr,title" search:"filter=eq,like,search;sort"`
+	Views    int      `jsonapi:"attr,views" search:"filter=gte,lte;sort;column=view_count"`
+	Score    *float64 `json:"score" search:"filter=gt"`
+	Draft    bool     `jsonapi:"attr,draft" search:"filter"`
+	TenantID string   `gorm:"column:tenant" search:"required;name=tenant"`
+	Body     string   `jsonapi:"attr,body"`
+	Internal string   `search:"-"`
+}
+
+func TestSchemaOf(t *testing.T) {
+	schema, err := query.SchemaOf[*schemaArticle]()
+	require.NoError(t, err)
+
+	assert.Equal(t, []query.FieldRule{
+		{
+			Name: "createdAt", Column: "created_at", Type: query.FieldTypeTime,
+			Operators: []filter.Operator{filter.OpGTEq, filter.OpLTEq}, Sortable: true,
+		},
+		{Name: "id", Column: "id", Type: query.FieldTypeUUID, Operators: []filter.Operator{filter.OpEq, filter.OpIn}},
+		{
+			Name: "title", Column: "title", Type: query.FieldTypeString,
+			Operators: []filter.Operator{filter.OpEq, filter.OpLike, filter.OpSearch}, Sortable: true,
+		},
+		{
+			Name: "views", Column: "view_count", Type: query.FieldTypeInt,
+			Operators: []filter.Operator{filter.OpGTEq, filter.OpLTEq}, Sortable: true,
+		},
+		{Name: "score", Column: "score", Type: query.FieldTypeFloat, Operators: []filter.Operator{filter.OpGT}},
+		{Name: "draft", Column: "draft", Type: query.FieldTypeBool, Operators: []filter.Operator{filter.OpEq}},
+		{
+			Name: "tenant", Column: "tenant", Type: query.FieldTypeString,
+			Operators: []filter.Operator{filter.OpEq}, Required: true,
+		},
+	}, schema.Fields())
+
+	assert.Equal(t, map[string]string{
+		"createdAt": "created_at", "id": "id", "title": "title", "views": "view_count",
+		"score": "score", "draft": "draft", "tenant": "tenant",
+	}, schema.FieldMapper())
+
+	_, ok := schema.Field("body")
+	assert.False(t, ok)
+}
+
+func TestSchemaOfErrors(t *testing.T) {
+	type unknownOperator struct {
+		A string `search:"filter=eq,bogus"`
+	}
+	type unknownRule struct {
+		A string `search:"filter;index"`
+	}
+	type unknownType struct {
+		A string `search:"filter;type=decimal"`
+	}
+	type duplicated struct {
+		A string `json:"a" search:"filter"`
+		B string `search:"filter;name=a"`
+	}
+
+	tests := []struct {
+		name   string
+		schema func() (*query.Schema, error)
+		errMsg string
+	}{
+		{name: "not a struct", schema: query.SchemaOf[string], errMsg: "expected a struct"},
+		{name: "unknown operator", schema: query.SchemaOf[unknownOperator], errMsg: `unknown operator "bogus"`},
+		{name: "unknown rule", schema: query.SchemaOf[unknownRule], errMsg: `unknown rule "index"`},
+		{name: "unknown type", schema: query.SchemaOf[unknownType], errMsg: `unknown type "decimal"`},
+		{name: "duplicated field", schema: query.SchemaOf[duplicated], errMsg: "duplicated field a"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := tt.schema()
+			assert.ErrorContains(t, err, tt.errMsg)
+		})
+	}
+
+	assert.Panics(t, func() { query.MustSchemaOf[unknownRule]() })
+}
+
+func TestSchemaValidate(t *testing.T) {
+	schema := query.MustSchemaOf[schemaArticle]()
+
+	t.Run("coerces values", func(t *testing.T) {
+		q, err := parseRawQuery(t,
+			"filter[tenant][eq]=t1&filter[views][gte]=10&filter[createdAt][lte]=2024-01-02"+
+				"&filter[draft][eq]=true&filter[score][gt]=1.5&filter[title][like]=10&sort=-views",
+		)
+		require.NoError(t, err)
+		require.NoError(t, schema.Validate(q))
+
+		assert.Equal(t, int64(10), q.Filters().Get("views").Value())
+		assert.Equal(t, time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC), q.Filters().Get("createdAt").Value())
+		assert.Equal(t, true, q.Filters().Get("draft").Value())
+		assert.Equal(t, 1.5, q.Filters().Get("score").Value())
+		// like patterns are not field values
+		assert.Equal(t, "10", q.Filters().Get("title").Value())
+	})
+
+	t.Run("coerces lists and groups", func(t *testing.T) {
+		id1, id2 := "5f1c8a52-0c5e-4f0e-9d61-1a2b3c4d5e6f", "6a2d9b63-1d6f-4a1f-8e72-2b3c4d5e6f70"
+		q, err := parseRawQuery(t, "filter[tenant][eq]=t1&filter[id][in]="+id1+","+id2+
+			"&filter[or][0][views][gte]=10&filter[or][1][createdAt][gte]=2024-01-02T10:00:00Z")
+		require.NoError(t, err)
+		require.NoError(t, schema.Validate(q))
+
+		assert.Equal(t, []string{id1, id2}, q.Filters().Get("id").Value())
+		assert.Equal(t, []*query.FilterNode{query.Or(
+			query.Cond(filter.OpGTEq, "views", int64(10)),
+			query.Cond(filter.OpGTEq, "createdAt", time.Date(2024, 1, 2, 10, 0, 0, 0, time.UTC)),
+		)}, q.FilterGroups())
+	})
+
+	t.Run("coerces the lists of filter expressions and builders", func(t *testing.T) {
+		id1, id2 := "5f1c8a52-0c5e-4f0e-9d61-1a2b3c4d5e6f", "6a2d9b63-1d6f-4a1f-8e72-2b3c4d5e6f70"
+		q, err := parseRawQuery(t, "filter[tenant][eq]=t1&filter="+url.QueryEscape("id=in=("+id1+","+id2+")"))
+		require.NoError(t, err)
+		require.NoError(t, schema.Validate(q))
+		assert.Equal(t, []*query.FilterNode{query.Cond(filter.OpIn, "id", []string{id1, id2})}, q.FilterGroups())
+
+		q = query.New(
+			query.FilterBy(filter.OpEq, "tenant", "t1"),
+			query.Where(query.Field("id").In(id1, id2), query.Field("views").Gte("10")),
+		)
+		require.NoError(t, schema.Validate(q))
+		assert.Equal(t, []*query.FilterNode{
+			query.Cond(filter.OpIn, "id", []string{id1, id2}),
+			query.Cond(filter.OpGTEq, "views", int64(10)),
+		}, q.FilterGroups())
+
+		q = query.New(query.FilterBy(filter.OpEq, "tenant", "t1"), query.Where(query.Field("id").In(id1, "2")))
+		assert.ErrorContains(t, schema.Validate(q), "invalid value for field id, expected uuid")
+	})
+
+	t.Run("accepts typed values", func(t *testing.T) {
+		q := query.New(
+			query.FilterBy(filter.OpEq, "tenant", "t1"),
+			query.FilterBy(filter.OpGTEq, "views", 10),
+			query.FilterBy(filter.OpGT, "score", 1.5),
+		)
+		require.NoError(t, schema.Validate(q))
+	})
+
+	t.Run("applies extra options", func(t *testing.T) {
+		q := query.New(query.FilterBy(filter.OpEq, "tenant", "t1"))
+		err := schema.Validate(q, query.MandatoryFilters("draft"))
+		assert.ErrorContains(t, err, "missing mandatory filter: draft")
+	})
+
+	tests := []struct {
+		name     string
+		rawQuery string
+		errMsg   string
+	}{
+		{name: "operator not allowed", rawQuery: "filter[tenant][eq]=t1&filter[views][eq]=1", errMsg: "operator eq is not allowed on filter views"},
+		{name: "field not filterable", rawQuery: "filter[tenant][eq]=t1&filter[body][eq]=x", errMsg: "filter by body is not allowed"},
+		{name: "missing required field", rawQuery: "filter[views][gte]=1", errMsg: "missing mandatory filter: tenant"},
+		{name: "invalid int", rawQuery: "filter[tenant][eq]=t1&filter[views][gte]=many", errMsg: "invalid value for field views, expected int"},
+		{name: "invalid float", rawQuery: "filter[tenant][eq]=t1&filter[score][gt]=high", errMsg: "invalid value for field score, expected float"},
+		{name: "invalid bool", rawQuery: "filter[tenant][eq]=t1&filter[draft][eq]=maybe", errMsg: "invalid value for field draft, expected bool"},
+		{name: "invalid time", rawQuery: "filter[tenant][eq]=t1&filter[createdAt][gte]=yesterday", errMsg: "invalid value for field createdAt, expected time"},
+		{name: "invalid uuid", rawQuery: "filter[tenant][eq]=t1&filter[id][in]=1,2", errMsg: "invalid value for field id, expected uuid"},
+		{name: "invalid value in a group", rawQuery: "filter[tenant][eq]=t1&filter[not][views][gte]=many", errMsg: "invalid value for field views, expected int"},
+		{name: "field not sortable", rawQuery: "filter[tenant][eq]=t1&sort=draft", errMsg: "sorting by draft is not allowed"},
+		{name: "field not searchable", rawQuery: "filter[tenant][eq]=t1&filter[views][search]=x", errMsg: "operator search is not allowed on filter views"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			q, err := parseRawQuery(t, tt.rawQuery)
+			require.NoError(t, err)
+
+			err = schema.Validate(q)
+			var apiErr apierrors.Error
+			require.ErrorAs(t, err, &apiErr)
+			assert.Equal(t, apierrors.CodeInvalidArgument, apiErr.Code())
+			assert.Contains(t, err.Error(), tt.errMsg)
+		})
+	}
+}
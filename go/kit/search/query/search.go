@@ -0,0 +1,28 @@
+package query
+
+import (
+	"github.com/dosanma1/forge/go/kit/filter"
+)
+
+// SortRelevance is the pseudo field ordering the results of a search or
+// fuzzy filter by how well they match, e.g. sort=-relevance.
+const SortRelevance = "relevance"
+
+func IsSearchOperator(op filter.Operator) bool {
+	return op == filter.OpSearch || op == filter.OpFuzzy
+}
+
+// SearchFilter returns the search or fuzzy filter every result of q matches,
+// i.e. the first one not nested under an OR or NOT group, or nil.
+func SearchFilter(q Query) filter.FieldFilter[any] {
+	for _, f := range FilterTree(q).Conjuncts() {
+		if IsSearchOperator(f.Operator()) {
+			return f
+		}
+	}
+
+	return nil
+}
+
+// MetaSnippets is the list meta key of the highlighted search snippets.
+const MetaSnippets = "snippets"
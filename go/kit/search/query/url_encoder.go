@@ -11,57 +11,88 @@ import (
 
 // FiltersToURLValues converts query filters to url.Values with proper JSON:API format
 // The format follows: filter[field][operator]=value
-func FiltersToURLValues(filters Filters[any]) url.Values {
+// Filter groups, if any, are encoded as filter[or][0][field][operator]=value,
+// filter[and][0][...] and filter[not][...].
+func FiltersToURLValues(filters Filters[any], groups ...*FilterNode) url.Values {
 	queryParams := url.Values{}
 
 	for fieldName, fieldFilter := range filters {
 		if fieldFilter == nil {
 			continue
 		}
+		addFilterValue(queryParams, filterParam, fieldName, fieldFilter)
+	}
 
-		operator := MarshalOperator(fieldFilter.Operator())
-		value := fieldFilter.Value()
-
-		// Handle different value types
-		switch v := value.(type) {
-		case nil:
-			// For null values, use "null" string
-			queryParams.Add(fmt.Sprintf("filter[%s][%s]", fieldName, operator), "null")
-		case []string:
-			// For array values (like OpIn), join with comma
-			if len(v) > 0 {
-				queryParams.Add(fmt.Sprintf("filter[%s][%s]", fieldName, operator), strings.Join(v, ","))
-			}
-		case []interface{}:
-			// Convert interface slice to strings
+	// several groups are AND-ed under a single key so their indexes do not clash
+	addFilterNode(queryParams, filterParam, And(groups...))
+
+	return queryParams
+}
+
+func addFilterNode(queryParams url.Values, prefix string, node *FilterNode) {
+	if node == nil {
+		return
+	}
+	if node.IsLeaf() {
+		addFilterValue(queryParams, prefix, node.Filter.Name(), node.Filter)
+		return
+	}
+
+	switch node.Op {
+	case BoolAnd, BoolOr:
+		kw := filterGroupAnd
+		if node.Op == BoolOr {
+			kw = filterGroupOr
+		}
+		for i, c := range node.Children {
+			addFilterNode(queryParams, fmt.Sprintf("%s[%s][%d]", prefix, kw, i), c)
+		}
+	case BoolNot:
+		addFilterNode(queryParams, fmt.Sprintf("%s[%s]", prefix, filterGroupNot), And(node.Children...))
+	}
+}
+
+func addFilterValue(queryParams url.Values, prefix, fieldName string, fieldFilter filter.FieldFilter[any]) {
+	key := fmt.Sprintf("%s[%s][%s]", prefix, fieldName, MarshalOperator(fieldFilter.Operator()))
+	value := fieldFilter.Value()
+
+	// Handle different value types
+	switch v := value.(type) {
+	case nil:
+		// For null values, use "null" string
+		queryParams.Add(key, "null")
+	case []string:
+		// For array values (like OpIn), join with comma
+		if len(v) > 0 {
+			queryParams.Add(key, strings.Join(v, ","))
+		}
+	case []interface{}:
+		// Convert interface slice to strings
+		var strValues []string
+		for _, item := range v {
+			strValues = append(strValues, fmt.Sprintf("%v", item))
+		}
+		if len(strValues) > 0 {
+			queryParams.Add(key, strings.Join(strValues, ","))
+		}
+	default:
+		// Handle slice types dynamically using reflection
+		valueType := reflect.TypeOf(value)
+		if valueType != nil && valueType.Kind() == reflect.Slice {
 			var strValues []string
-			for _, item := range v {
+			sliceValue := reflect.ValueOf(value)
+			for i := 0; i < sliceValue.Len(); i++ {
+				item := sliceValue.Index(i).Interface()
 				strValues = append(strValues, fmt.Sprintf("%v", item))
 			}
 			if len(strValues) > 0 {
-				queryParams.Add(fmt.Sprintf("filter[%s][%s]", fieldName, operator), strings.Join(strValues, ","))
-			}
-		default:
-			// Handle slice types dynamically using reflection
-			valueType := reflect.TypeOf(value)
-			if valueType != nil && valueType.Kind() == reflect.Slice {
-				var strValues []string
-				sliceValue := reflect.ValueOf(value)
-				for i := 0; i < sliceValue.Len(); i++ {
-					item := sliceValue.Index(i).Interface()
-					strValues = append(strValues, fmt.Sprintf("%v", item))
-				}
-				if len(strValues) > 0 {
-					queryParams.Add(fmt.Sprintf("filter[%s][%s]", fieldName, operator), strings.Join(strValues, ","))
-				}
-			} else {
-				// Single value
-				queryParams.Add(fmt.Sprintf("filter[%s][%s]", fieldName, operator), fmt.Sprintf("%v", value))
+				queryParams.Add(key, strings.Join(strValues, ","))
 			}
+		} else {
+			// Single value
+			queryParams.Add(key, fmt.Sprintf("%v", value))
 		}
 	}
-
-	return queryParams
 }
 
 // AddFilterParam is a convenience function to add a single filter parameter
@@ -77,3 +108,62 @@ func AddFilterParam(queryParams url.Values, fieldName string, operator filter.Op
 		}
 	}
 }
+
+// AddFilterExprParam adds a filter expression as the filter= parameter, e.g.
+// filter=status==active;(age>18,vip==true)
+func AddFilterExprParam(queryParams url.Values, node *FilterNode) {
+	if node == nil {
+		return
+	}
+	queryParams.Add(filterParam, FormatFilterExpr(node))
+}
+
+// AggregationToURLValues encodes an aggregation as
+// aggregate=count,sum:amount&group=status,created_at:day
+func AggregationToURLValues(agg *Aggregation) url.Values {
+	queryParams := url.Values{}
+	if agg == nil {
+		return queryParams
+	}
+
+	metrics := make([]string, len(agg.Metrics))
+	for i, m := range agg.Metrics {
+		metrics[i] = m.Func.String()
+		if m.Field != "" {
+			metrics[i] += ":" + m.Field
+		}
+	}
+	queryParams.Set(paramAggregate, strings.Join(metrics, ","))
+
+	if len(agg.GroupBy) > 0 {
+		groups := make([]string, len(agg.GroupBy))
+		for i, g := range agg.GroupBy {
+			groups[i] = g.Field
+			if g.Trunc != TruncNone {
+				groups[i] += ":" + string(g.Trunc)
+			}
+		}
+		queryParams.Set(paramGroup, strings.Join(groups, ","))
+	}
+
+	return queryParams
+}
+
+// SortingToURLValues encodes a sorting as sort=-relevance,created_at.
+func SortingToURLValues(sorting *SortingParams) url.Values {
+	queryParams := url.Values{}
+	if sorting == nil || len(sorting.Keys()) == 0 {
+		return queryParams
+	}
+
+	keys := make([]string, len(sorting.Keys()))
+	for i, key := range sorting.Keys() {
+		if sorting.Get(key) == SortDesc {
+			key = "-" + key
+		}
+		keys[i] = key
+	}
+	queryParams.Set(paramSort, strings.Join(keys, ","))
+
+	return queryParams
+}
@@ -2,6 +2,7 @@ package query
 
 import (
 	"fmt"
+	"slices"
 
 	"github.com/dosanma1/forge/go/kit/errors"
 	"github.com/dosanma1/forge/go/kit/filter"
@@ -16,7 +17,10 @@ type validator struct {
 	optionalFields  []string
 	groupedFields   [][]string
 	sortFields      []string
+	aggFields       []string
+	searchFields    []string
 	filterValFuncs  map[string][]ValidationFunc
+	allowedOps      map[string][]filter.Operator
 	mustHaveFilters bool
 }
 
@@ -58,6 +62,39 @@ func SortFields(fs ...string) ValidationOpt {
 	}
 }
 
+// AggregateFields allows aggregating and grouping by the given fields.
+// count without a field is always allowed.
+func AggregateFields(fs ...string) ValidationOpt {
+	return func(c *validator) error {
+		c.aggFields = append(c.aggFields, fs...)
+
+		return nil
+	}
+}
+
+// SearchFields allows the search and fuzzy operators on the given fields, as
+// well as sorting by relevance when the query searches one of them.
+func SearchFields(fs ...string) ValidationOpt {
+	return func(c *validator) error {
+		c.searchFields = append(c.searchFields, fs...)
+
+		return nil
+	}
+}
+
+// AllowedOperators restricts the filters on field to the given operators.
+// Fields without allowed operators accept any of them.
+func AllowedOperators(field string, ops ...filter.Operator) ValidationOpt {
+	return func(c *validator) error {
+		if c.allowedOps == nil {
+			c.allowedOps = make(map[string][]filter.Operator)
+		}
+		c.allowedOps[field] = append(c.allowedOps[field], ops...)
+
+		return nil
+	}
+}
+
 func AtLeastOneFilter() ValidationOpt {
 	return func(c *validator) error {
 		c.mustHaveFilters = true
@@ -101,16 +138,28 @@ func (v *validator) validate(q Query) error {
 	if err != nil {
 		return err
 	}
+	err = v.validateSearch(q)
+	if err != nil {
+		return err
+	}
 	err = v.validateSortFieldsDenied(q)
 	if err != nil {
 		return err
 	}
+	err = v.validateAggregation(q)
+	if err != nil {
+		return err
+	}
 
 	return nil
 }
 
 func (v *validator) validateSortFieldsDenied(q Query) error {
 	for _, key := range q.Sorting().Keys() {
+		if key == SortRelevance {
+			// validated together with the search filters
+			continue
+		}
 		found := false
 		for _, allowed := range v.sortFields {
 			if allowed == key {
@@ -143,13 +192,52 @@ func (v *validator) validateSortFieldsAllowed(q Query) error {
 	return nil
 }
 
-func (v *validator) validateFieldFilters(q Query) error {
-	for fName, fs := range v.filterValFuncs {
-		found := q.Filters().Get(fName)
-		if found == nil {
+func (v *validator) validateSearch(q Query) error {
+	for _, f := range FilterTree(q).Leaves() {
+		if !IsSearchOperator(f.Operator()) {
 			continue
 		}
-		for _, f := range fs {
+		if !slices.Contains(v.searchFields, f.Name()) {
+			return errors.InvalidArgument(fmt.Sprintf("searching %s is not allowed", f.Name()))
+		}
+	}
+	if slices.Contains(q.Sorting().Keys(), SortRelevance) && SearchFilter(q) == nil {
+		return errors.InvalidArgument("sorting by relevance requires a search filter")
+	}
+
+	return nil
+}
+
+func (v *validator) validateAggregation(q Query) error {
+	agg := q.Aggregation()
+	if agg == nil {
+		return nil
+	}
+	for _, m := range agg.Metrics {
+		if m.Field != "" && !slices.Contains(v.aggFields, m.Field) {
+			return errors.InvalidArgument(fmt.Sprintf("aggregating %s is not allowed", m.Field))
+		}
+	}
+	for _, g := range agg.GroupBy {
+		if !slices.Contains(v.aggFields, g.Field) {
+			return errors.InvalidArgument(fmt.Sprintf("grouping by %s is not allowed", g.Field))
+		}
+	}
+
+	return nil
+}
+
+func (v *validator) validateFieldFilters(q Query) error {
+	for _, found := range FilterTree(q).Leaves() {
+		if err := filter.ValidateJSONOperand(found); err != nil {
+			return err
+		}
+		if allowed, ok := v.allowedOps[found.Name()]; ok && !slices.Contains(allowed, found.Operator()) {
+			return errors.InvalidArgument(fmt.Sprintf(
+				"operator %s is not allowed on filter %s", operatorName(found.Operator()), found.Name(),
+			))
+		}
+		for _, f := range v.filterValFuncs[found.Name()] {
 			err := f(found)
 			if err != nil {
 				return err
@@ -160,14 +248,29 @@ func (v *validator) validateFieldFilters(q Query) error {
 	return nil
 }
 
+// filterNames returns the names of every filter of the query and of those
+// that always apply, i.e. are not nested under an OR or NOT group.
+func filterNames(q Query) (all, required map[string]bool) {
+	tree := FilterTree(q)
+	all, required = make(map[string]bool), make(map[string]bool)
+	for _, f := range tree.Leaves() {
+		all[f.Name()] = true
+	}
+	for _, f := range tree.Conjuncts() {
+		required[f.Name()] = true
+	}
+
+	return all, required
+}
+
 func (v *validator) validateGroupedFields(q Query) error {
-	qf := q.Filters()
+	all, required := filterNames(q)
 	for _, group := range v.groupedFields {
 		anyExist := false
 		keysExists := make(map[string]bool)
 		for _, k := range group {
-			keysExists[k] = qf.Exists(k)
-			if qf.Exists(k) {
+			keysExists[k] = required[k]
+			if all[k] {
 				anyExist = true
 			}
 		}
@@ -185,8 +288,9 @@ func (v *validator) validateGroupedFields(q Query) error {
 }
 
 func (v *validator) validateMandatoryFieldsExist(q Query) error {
+	_, required := filterNames(q)
 	for _, field := range v.mandatoryFields {
-		if !q.Filters().Exists(field) {
+		if !required[field] {
 			return errors.InvalidArgument(fmt.Sprintf("missing mandatory filter: %s", field))
 		}
 	}
@@ -215,7 +319,7 @@ func (v *validator) validateInOptional(field string) error {
 }
 
 func (v *validator) validateFieldsAllowed(q Query) error {
-	for _, field := range q.Filters() {
+	for _, field := range FilterTree(q).Leaves() {
 		err := v.validateInMandatory(field.Name())
 		if err == nil {
 			continue
@@ -229,9 +333,16 @@ func (v *validator) validateFieldsAllowed(q Query) error {
 	return nil
 }
 
+func operatorName(op filter.Operator) string {
+	if name := MarshalOperator(op); name != "" {
+		return name
+	}
+	return op.String()
+}
+
 func (v *validator) validateMustHaveFilters(q Query) error {
 	if len(v.mandatoryFields) > 0 || v.mustHaveFilters {
-		if len(q.Filters()) < 1 {
+		if FilterTree(q) == nil {
 			return errors.InvalidArgument("at least one filter must be provided")
 		}
 	}
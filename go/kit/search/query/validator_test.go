@@ -0,0 +1,167 @@
+package query_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	apierrors "github.com/dosanma1/forge/go/kit/errors"
+	"github.com/dosanma1/forge/go/kit/filter"
+	"github.com/dosanma1/forge/go/kit/search/query"
+)
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		query   query.Query
+		opts    []query.ValidationOpt
+		wantErr bool
+	}{
+		{
+			name:  "optional filter",
+			query: query.New(query.FilterBy(filter.OpEq, "status", "open")),
+			opts:  []query.ValidationOpt{query.OptionalFilters("status")},
+		},
+		{
+			name:    "filter not allowed",
+			query:   query.New(query.FilterBy(filter.OpEq, "status", "open")),
+			opts:    []query.ValidationOpt{query.OptionalFilters("owner")},
+			wantErr: true,
+		},
+		{
+			name: "filter not allowed in a group",
+			query: query.New(query.Where(query.Or(
+				query.Cond(filter.OpEq, "status", "open"),
+				query.Cond(filter.OpEq, "owner", "me"),
+			))),
+			opts:    []query.ValidationOpt{query.OptionalFilters("status")},
+			wantErr: true,
+		},
+		{
+			name:    "at least one filter",
+			query:   query.New(),
+			opts:    []query.ValidationOpt{query.AtLeastOneFilter()},
+			wantErr: true,
+		},
+		{
+			name:  "at least one filter in a group",
+			query: query.New(query.Where(query.Not(query.Cond(filter.OpEq, "status", "open")))),
+			opts:  []query.ValidationOpt{query.OptionalFilters("status"), query.AtLeastOneFilter()},
+		},
+		{
+			name: "mandatory filter and-ed in a group",
+			query: query.New(query.Where(query.And(
+				query.Cond(filter.OpEq, "tenant", "t1"),
+				query.Or(query.Cond(filter.OpEq, "status", "open"), query.Cond(filter.OpEq, "status", "new")),
+			))),
+			opts: []query.ValidationOpt{query.MandatoryFilters("tenant"), query.OptionalFilters("status")},
+		},
+		{
+			name: "mandatory filter or-ed in a group",
+			query: query.New(query.Where(query.Or(
+				query.Cond(filter.OpEq, "tenant", "t1"),
+				query.Cond(filter.OpEq, "status", "open"),
+			))),
+			opts:    []query.ValidationOpt{query.MandatoryFilters("tenant"), query.OptionalFilters("status")},
+			wantErr: true,
+		},
+		{
+			name:    "mandatory filter negated",
+			query:   query.New(query.Where(query.Not(query.Cond(filter.OpEq, "tenant", "t1")))),
+			opts:    []query.ValidationOpt{query.MandatoryFilters("tenant")},
+			wantErr: true,
+		},
+		{
+			name: "grouped filters together",
+			query: query.New(
+				query.FilterBy(filter.OpGTEq, "from", "1"),
+				query.Where(query.And(query.Cond(filter.OpLTEq, "to", "2"))),
+			),
+			opts: []query.ValidationOpt{query.OptionalFilters("from", "to"), query.GroupedFilters("from", "to")},
+		},
+		{
+			name: "grouped filters split by or",
+			query: query.New(query.Where(query.Or(
+				query.Cond(filter.OpGTEq, "from", "1"),
+				query.Cond(filter.OpLTEq, "to", "2"),
+			))),
+			opts:    []query.ValidationOpt{query.OptionalFilters("from", "to"), query.GroupedFilters("from", "to")},
+			wantErr: true,
+		},
+		{
+			name:  "allowed operator",
+			query: query.New(query.Where(query.Or(query.Cond(filter.OpEq, "a", "1"), query.Cond(filter.OpIn, "a", []string{"2"})))),
+			opts: []query.ValidationOpt{
+				query.OptionalFilters("a"), query.AllowedOperators("a", filter.OpEq, filter.OpIn),
+			},
+		},
+		{
+			name:  "operator not allowed in a group",
+			query: query.New(query.Where(query.Or(query.Cond(filter.OpEq, "a", "1"), query.Cond(filter.OpLike, "a", "2")))),
+			opts: []query.ValidationOpt{
+				query.OptionalFilters("a"), query.AllowedOperators("a", filter.OpEq),
+			},
+			wantErr: true,
+		},
+		{
+			name:  "sort field allowed",
+			query: query.New(query.SortBy("name", query.SortAsc)),
+			opts:  []query.ValidationOpt{query.SortFields("name")},
+		},
+		{
+			name:    "sort field not allowed",
+			query:   query.New(query.SortBy("name", query.SortAsc)),
+			opts:    []query.ValidationOpt{query.SortFields("age")},
+			wantErr: true,
+		},
+		{
+			name:  "search field allowed",
+			query: query.New(query.FilterBy(filter.OpSearch, "title", "go"), query.SortBy(query.SortRelevance, query.SortDesc)),
+			opts:  []query.ValidationOpt{query.OptionalFilters("title"), query.SearchFields("title")},
+		},
+		{
+			name:    "search field not allowed",
+			query:   query.New(query.FilterBy(filter.OpSearch, "title", "go")),
+			opts:    []query.ValidationOpt{query.OptionalFilters("title")},
+			wantErr: true,
+		},
+		{
+			name:    "relevance sort without search",
+			query:   query.New(query.SortBy(query.SortRelevance, query.SortDesc)),
+			opts:    []query.ValidationOpt{query.SearchFields("title")},
+			wantErr: true,
+		},
+		{
+			name:  "validation func",
+			query: query.New(query.Where(query.Or(query.Cond(filter.OpEq, "a", "1"), query.Cond(filter.OpEq, "a", "bad")))),
+			opts: []query.ValidationOpt{
+				query.OptionalFilters("a"),
+				query.ValidFilter("a", func(f filter.FieldFilter[any]) error {
+					if f.Value() == "bad" {
+						return apierrors.InvalidArgument("bad a")
+					}
+					return nil
+				}),
+			},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := query.Validate(tt.query, tt.opts...)
+			if !tt.wantErr {
+				require.NoError(t, err)
+				return
+			}
+			var apiErr apierrors.Error
+			require.ErrorAs(t, err, &apiErr)
+			assert.Equal(t, apierrors.CodeInvalidArgument, apiErr.Code())
+		})
+	}
+}
+
+func TestValidateOptionErrors(t *testing.T) {
+	require.Error(t, query.Validate(query.New(), query.GroupedFilters("only")))
+}
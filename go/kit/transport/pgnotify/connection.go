@@ -0,0 +1,364 @@
+package pgnotify
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+
+	"github.com/dosanma1/forge/go/kit/errors"
+	"github.com/dosanma1/forge/go/kit/monitoring/logger"
+	"github.com/dosanma1/forge/go/kit/persistence/sqldb"
+	"github.com/dosanma1/forge/go/kit/retry"
+)
+
+const defaultConnectTimeout = 10 * time.Second
+
+// Notification is a notification received on a channel.
+type Notification = pgconn.Notification
+
+// Connection is a connection dedicated to listening to channels.
+type Connection interface {
+	// Listen runs fn on the notifications of channel, one at a time and in
+	// order, until unlisten is called. It returns once the connection
+	// listens to the channel.
+	Listen(ctx context.Context, channel string, fn func(*Notification)) (unlisten func(context.Context) error, err error)
+	Close(ctx context.Context) error
+}
+
+// listenConn is the part of *pgx.Conn used by the connection.
+type listenConn interface {
+	Exec(ctx context.Context, sql string, arguments ...any) (pgconn.CommandTag, error)
+	WaitForNotification(ctx context.Context) (*pgconn.Notification, error)
+	IsClosed() bool
+	Close(ctx context.Context) error
+}
+
+type dialer func(ctx context.Context, connURL string) (listenConn, error)
+
+func pgxDialer(ctx context.Context, connURL string) (listenConn, error) {
+	return pgx.Connect(ctx, connURL)
+}
+
+type config struct {
+	connURL        string
+	err            error
+	connectTimeout time.Duration
+	retryOpts      []retry.Option
+	dial           dialer
+}
+
+type connOption func(*config)
+
+// WithConnURLFromEnv connects to the database of the DB_* environment
+// variables, see sqldb.WithDSNConnFromEnv.
+func WithConnURLFromEnv() connOption {
+	return func(c *config) {
+		dsn, err := sqldb.NewDSN(sqldb.DriverTypePostgres)
+		if err != nil {
+			c.err = err
+			return
+		}
+		c.connURL, c.err = dsn.String(), nil
+	}
+}
+
+// WithConnURL connects to the database of the postgres:// url.
+func WithConnURL(url string) connOption {
+	return func(c *config) {
+		c.connURL, c.err = url, nil
+	}
+}
+
+// WithConnectTimeout bounds every attempt to connect. Defaults to 10s.
+func WithConnectTimeout(timeout time.Duration) connOption {
+	return func(c *config) {
+		c.connectTimeout = timeout
+	}
+}
+
+// WithReconnectOptions sets the delays between the attempts to reconnect,
+// see retry.Delay. Defaults to an exponential backoff from 1s up to 30s.
+func WithReconnectOptions(opts ...retry.Option) connOption {
+	return func(c *config) {
+		c.retryOpts = opts
+	}
+}
+
+func defaultOpts() []connOption {
+	return []connOption{
+		WithConnURLFromEnv(),
+		WithConnectTimeout(defaultConnectTimeout),
+		WithReconnectOptions(
+			retry.WithExponentialPolicy(),
+			retry.WithInitialInterval(time.Second),
+			retry.WithMaxInterval(30*time.Second),
+		),
+	}
+}
+
+type listener struct {
+	channel string
+	fn      func(*Notification)
+}
+
+type connection struct {
+	log    logger.Logger
+	config config
+
+	mu        sync.Mutex
+	listeners map[uint64]listener
+	nextID    uint64
+	// listening holds the channels the current connection listens to, and
+	// waiting the Listen calls waiting for the connection to listen to theirs
+	listening map[string]bool
+	waiting   map[string][]chan error
+
+	wake   chan struct{}
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+// NewConnection connects to the database, returning an error when it cannot.
+// The connection reconnects on its own afterwards, until it is closed.
+func NewConnection(log logger.Logger, opts ...connOption) (Connection, error) {
+	cfg := config{dial: pgxDialer}
+	for _, opt := range append(defaultOpts(), opts...) {
+		opt(&cfg)
+	}
+	if cfg.err != nil {
+		return nil, cfg.err
+	}
+	if cfg.connURL == "" {
+		return nil, errors.InvalidArgument("pgnotify connection url is required")
+	}
+
+	c := &connection{
+		log:       log,
+		config:    cfg,
+		listeners: map[uint64]listener{},
+		listening: map[string]bool{},
+		waiting:   map[string][]chan error{},
+		wake:      make(chan struct{}, 1),
+		done:      make(chan struct{}),
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	conn, err := c.connect(ctx)
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+	c.cancel = cancel
+	go c.run(ctx, conn)
+
+	return c, nil
+}
+
+func (c *connection) Listen(ctx context.Context, channel string, fn func(*Notification)) (func(context.Context) error, error) {
+	if channel == "" {
+		return nil, errors.InvalidArgument("pgnotify channel is required")
+	}
+
+	c.mu.Lock()
+	c.nextID++
+	id := c.nextID
+	c.listeners[id] = listener{channel: channel, fn: fn}
+	var listened chan error
+	if !c.listening[channel] {
+		listened = make(chan error, 1)
+		c.waiting[channel] = append(c.waiting[channel], listened)
+	}
+	c.mu.Unlock()
+
+	unlisten := func(context.Context) error {
+		c.mu.Lock()
+		delete(c.listeners, id)
+		c.mu.Unlock()
+		c.signal()
+		return nil
+	}
+	if listened == nil {
+		return unlisten, nil
+	}
+
+	c.signal()
+	select {
+	case err := <-listened:
+		if err != nil {
+			_ = unlisten(ctx)
+			return nil, err
+		}
+		return unlisten, nil
+	case <-ctx.Done():
+		_ = unlisten(ctx)
+		return nil, ctx.Err()
+	case <-c.done:
+		return nil, newErrClosed()
+	}
+}
+
+// Close stops listening and closes the connection.
+func (c *connection) Close(ctx context.Context) error {
+	c.cancel()
+	select {
+	case <-c.done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// signal wakes the connection up to listen to the channels of the listeners.
+func (c *connection) signal() {
+	select {
+	case c.wake <- struct{}{}:
+	default:
+	}
+}
+
+// run serves conn, reconnecting until ctx is done.
+func (c *connection) run(ctx context.Context, conn listenConn) {
+	defer close(c.done)
+
+	for {
+		err := c.serve(ctx, conn)
+		_ = conn.Close(context.Background())
+		c.mu.Lock()
+		clear(c.listening)
+		c.mu.Unlock()
+		if ctx.Err() != nil {
+			return
+		}
+
+		c.log.WarnContext(ctx, "pgnotify:conn -> connection lost, reconnecting", "err", err)
+		if conn = c.reconnect(ctx); conn == nil {
+			return
+		}
+		c.log.InfoContext(ctx, "pgnotify:conn -> reconnected")
+	}
+}
+
+// reconnect connects again until it succeeds or ctx is done.
+func (c *connection) reconnect(ctx context.Context) listenConn {
+	for attempt := 1; ; attempt++ {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(retry.Delay(attempt, c.config.retryOpts...)):
+		}
+
+		conn, err := c.connect(ctx)
+		if err == nil {
+			return conn
+		}
+		c.log.ErrorContext(ctx, "pgnotify:conn -> error reconnecting", "attempt", attempt, "err", err)
+	}
+}
+
+func (c *connection) connect(ctx context.Context) (listenConn, error) {
+	ctx, cancel := context.WithTimeout(ctx, c.config.connectTimeout)
+	defer cancel()
+
+	return c.config.dial(ctx, c.config.connURL)
+}
+
+// serve listens to the channels of the listeners on conn and dispatches its
+// notifications until conn fails or ctx is done.
+func (c *connection) serve(ctx context.Context, conn listenConn) error {
+	for {
+		if err := c.sync(ctx, conn); err != nil {
+			return err
+		}
+
+		waitCtx, cancel := context.WithCancel(ctx)
+		stopped := make(chan struct{})
+		go func() {
+			defer close(stopped)
+			select {
+			case <-c.wake:
+				cancel()
+			case <-waitCtx.Done():
+			}
+		}()
+		n, err := conn.WaitForNotification(waitCtx)
+		woken := waitCtx.Err() != nil
+		cancel()
+		// a wake up consumed from now on would be missed by the next sync
+		<-stopped
+
+		switch {
+		case ctx.Err() != nil:
+			return ctx.Err()
+		case err == nil:
+			c.dispatch(n)
+		case !woken || conn.IsClosed():
+			return err
+		}
+	}
+}
+
+// sync makes conn listen to the channels of the listeners, and only those.
+func (c *connection) sync(ctx context.Context, conn listenConn) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	wanted := map[string]bool{}
+	for _, l := range c.listeners {
+		wanted[l.channel] = true
+	}
+
+	for channel := range wanted {
+		if c.listening[channel] {
+			continue
+		}
+		_, err := conn.Exec(ctx, fmt.Sprintf("LISTEN %s", pgx.Identifier{channel}.Sanitize()))
+		if err != nil && !conn.IsClosed() && ctx.Err() == nil {
+			// the channel itself is at fault, e.g. its name is too long
+			c.notifyWaiting(channel, newErrListen(channel, err))
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		c.listening[channel] = true
+		c.notifyWaiting(channel, nil)
+	}
+
+	for channel := range c.listening {
+		if wanted[channel] {
+			continue
+		}
+		if _, err := conn.Exec(ctx, fmt.Sprintf("UNLISTEN %s", pgx.Identifier{channel}.Sanitize())); err != nil {
+			return err
+		}
+		delete(c.listening, channel)
+	}
+
+	return nil
+}
+
+func (c *connection) notifyWaiting(channel string, err error) {
+	for _, ch := range c.waiting[channel] {
+		ch <- err
+	}
+	delete(c.waiting, channel)
+}
+
+func (c *connection) dispatch(n *Notification) {
+	c.mu.Lock()
+	var fns []func(*Notification)
+	for _, l := range c.listeners {
+		if l.channel == n.Channel {
+			fns = append(fns, l.fn)
+		}
+	}
+	c.mu.Unlock()
+
+	for _, fn := range fns {
+		fn(n)
+	}
+}
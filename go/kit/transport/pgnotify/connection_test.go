@@ -0,0 +1,170 @@
+package pgnotify
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/dosanma1/forge/go/kit/monitoring/logger/loggertest"
+	"github.com/dosanma1/forge/go/kit/retry"
+)
+
+var errConnLost = errors.New("connection lost")
+
+// fakeServer dials fakeConns recording the statements run on them.
+type fakeServer struct {
+	mu        sync.Mutex
+	conns     []*fakeConn
+	stmts     []string
+	dialFails int
+}
+
+func (s *fakeServer) dial(context.Context, string) (listenConn, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.dialFails > 0 {
+		s.dialFails--
+		return nil, errConnLost
+	}
+
+	conn := &fakeConn{server: s, notifications: make(chan *pgconn.Notification, 10), lost: make(chan struct{})}
+	s.conns = append(s.conns, conn)
+	return conn, nil
+}
+
+func (s *fakeServer) conn(i int) *fakeConn {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.conns[i]
+}
+
+func (s *fakeServer) statements() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return append([]string(nil), s.stmts...)
+}
+
+type fakeConn struct {
+	server        *fakeServer
+	notifications chan *pgconn.Notification
+	lost          chan struct{}
+	closed        bool
+}
+
+func (c *fakeConn) Exec(_ context.Context, sql string, _ ...any) (pgconn.CommandTag, error) {
+	c.server.mu.Lock()
+	defer c.server.mu.Unlock()
+	c.server.stmts = append(c.server.stmts, sql)
+
+	return pgconn.CommandTag{}, nil
+}
+
+func (c *fakeConn) WaitForNotification(ctx context.Context) (*pgconn.Notification, error) {
+	select {
+	case n := <-c.notifications:
+		return n, nil
+	case <-c.lost:
+		c.server.mu.Lock()
+		c.closed = true
+		c.server.mu.Unlock()
+		return nil, errConnLost
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+func (c *fakeConn) IsClosed() bool {
+	c.server.mu.Lock()
+	defer c.server.mu.Unlock()
+
+	return c.closed
+}
+
+func (c *fakeConn) Close(context.Context) error {
+	return nil
+}
+
+func newTestConnection(t *testing.T, server *fakeServer) Connection {
+	t.Helper()
+
+	conn, err := NewConnection(loggertest.NewStubLogger(t),
+		WithConnURL("postgres://localhost/test"),
+		WithReconnectOptions(retry.WithConstantPolicy(time.Millisecond)),
+		func(c *config) { c.dial = server.dial },
+	)
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		require.NoError(t, conn.Close(context.Background()))
+	})
+
+	return conn
+}
+
+func TestNewConnection(t *testing.T) {
+	_, err := NewConnection(loggertest.NewStubLogger(t),
+		WithConnURL("postgres://localhost/test"),
+		func(c *config) { c.dial = (&fakeServer{dialFails: 1}).dial },
+	)
+	assert.ErrorIs(t, err, errConnLost)
+
+	_, err = NewConnection(loggertest.NewStubLogger(t), WithConnURL(""))
+	assert.Error(t, err)
+}
+
+func TestConnectionListen(t *testing.T) {
+	ctx := context.Background()
+	server := &fakeServer{}
+	conn := newTestConnection(t, server)
+
+	orders, users := make(chan string, 10), make(chan string, 10)
+	unlistenOrders, err := conn.Listen(ctx, "orders", func(n *Notification) { orders <- n.Payload })
+	require.NoError(t, err)
+	_, err = conn.Listen(ctx, "Users", func(n *Notification) { users <- n.Payload })
+	require.NoError(t, err)
+	assert.Equal(t, []string{`LISTEN "orders"`, `LISTEN "Users"`}, server.statements())
+
+	server.conn(0).notifications <- &pgconn.Notification{Channel: "orders", Payload: "1"}
+	server.conn(0).notifications <- &pgconn.Notification{Channel: "Users", Payload: "2"}
+	assert.Equal(t, "1", <-orders)
+	assert.Equal(t, "2", <-users)
+
+	require.NoError(t, unlistenOrders(ctx))
+	assert.Eventually(t, func() bool {
+		return len(server.statements()) == 3
+	}, time.Second, time.Millisecond)
+	assert.Equal(t, `UNLISTEN "orders"`, server.statements()[2])
+
+	_, err = conn.Listen(ctx, "", func(*Notification) {})
+	assert.Error(t, err)
+}
+
+func TestConnectionReconnect(t *testing.T) {
+	ctx := context.Background()
+	server := &fakeServer{}
+	conn := newTestConnection(t, server)
+
+	received := make(chan string, 10)
+	_, err := conn.Listen(ctx, "orders", func(n *Notification) { received <- n.Payload })
+	require.NoError(t, err)
+
+	server.mu.Lock()
+	server.dialFails = 2
+	server.mu.Unlock()
+	close(server.conn(0).lost)
+
+	assert.Eventually(t, func() bool {
+		return len(server.statements()) == 2
+	}, time.Second, time.Millisecond)
+	assert.Equal(t, []string{`LISTEN "orders"`, `LISTEN "orders"`}, server.statements())
+
+	server.conn(1).notifications <- &pgconn.Notification{Channel: "orders", Payload: "after reconnecting"}
+	assert.Equal(t, "after reconnecting", <-received)
+}
@@ -0,0 +1,151 @@
+package pgnotify
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+	"time"
+
+	"github.com/dosanma1/forge/go/kit/monitoring/logger"
+)
+
+type (
+	Decoder[T any] func(ctx context.Context, n *Notification) (T, error)
+
+	Handler[T any] interface {
+		Handle(ctx context.Context, event T) error
+	}
+
+	HandlerFunc[T any] func(ctx context.Context, event T) error
+
+	Consumer interface {
+		Subscribe(ctx context.Context) error
+		Unsubscribe(ctx context.Context) error
+	}
+
+	consumer[T any] struct {
+		conn    Connection
+		log     logger.Logger
+		decoder Decoder[T]
+		handler Handler[T]
+		config  consumerConfig
+
+		mu       sync.Mutex
+		unlisten func(context.Context) error
+	}
+)
+
+func (f HandlerFunc[T]) Handle(ctx context.Context, event T) error {
+	return f(ctx, event)
+}
+
+func NewConsumer[T any](
+	conn Connection,
+	log logger.Logger,
+	channel string,
+	dec Decoder[T],
+	handler Handler[T],
+	opts ...consumerOption,
+) (Consumer, error) {
+	cfg := &consumerConfig{channel: channel}
+	for _, opt := range opts {
+		opt(cfg)
+	}
+
+	return &consumer[T]{
+		conn:    conn,
+		log:     log,
+		decoder: dec,
+		handler: handler,
+		config:  *cfg,
+	}, nil
+}
+
+// Subscribe starts handling the notifications of the channel, returning once
+// the connection listens to it. The notifications are handled one at a time,
+// in the order they were sent.
+func (c *consumer[T]) Subscribe(ctx context.Context) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.unlisten != nil {
+		return nil
+	}
+
+	unlisten, err := c.conn.Listen(ctx, c.config.channel, c.handle)
+	if err != nil {
+		return err
+	}
+	c.unlisten = unlisten
+
+	c.log.InfoContext(ctx, "pgnotify:consumer -> subscribed", "channel", c.config.channel)
+	return nil
+}
+
+func (c *consumer[T]) Unsubscribe(ctx context.Context) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.unlisten == nil {
+		return nil
+	}
+
+	err := c.unlisten(ctx)
+	c.unlisten = nil
+	return err
+}
+
+func (c *consumer[T]) handle(n *Notification) {
+	ctx := context.Background()
+	if c.config.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, c.config.timeout)
+		defer cancel()
+	}
+
+	event, err := c.decoder(ctx, n)
+	if err != nil {
+		c.log.ErrorContext(ctx, "pgnotify:consumer -> error decoding event", "channel", n.Channel, "err", err)
+		return
+	}
+
+	if err := c.handler.Handle(ctx, event); err != nil {
+		c.log.ErrorContext(ctx, "pgnotify:consumer -> error handling event", "channel", n.Channel, "err", err)
+	}
+}
+
+type consumerConfig struct {
+	channel string
+	timeout time.Duration
+}
+
+type consumerOption func(*consumerConfig)
+
+// ConsumerWithTimeout cancels the context of the handling of a notification
+// lasting longer than timeout. The notifications of the connection wait for
+// the one being handled.
+func ConsumerWithTimeout(timeout time.Duration) consumerOption {
+	return func(c *consumerConfig) {
+		c.timeout = timeout
+	}
+}
+
+// JSONDecoder is a helper for JSON decoding
+func JSONDecoder[T any](ctx context.Context, n *Notification) (T, error) {
+	var v T
+	err := json.Unmarshal([]byte(n.Payload), &v)
+	return v, err
+}
+
+// IDDecoder decodes the notifications of the producers sending the ids
+// only, see ProducerWithIDsOnly, loading their values with load, e.g. the
+// Get method of a repository.
+func IDDecoder[T any](load func(ctx context.Context, id string) (T, error)) Decoder[T] {
+	return func(ctx context.Context, n *Notification) (T, error) {
+		var p idPayload
+		if err := json.Unmarshal([]byte(n.Payload), &p); err != nil {
+			var zero T
+			return zero, err
+		}
+
+		return load(ctx, p.ID)
+	}
+}
@@ -0,0 +1,47 @@
+// Package pgnotify provides a producer and consumer framework on top of the
+// PostgreSQL LISTEN/NOTIFY commands, for change notifications in deployments
+// without a message broker.
+//
+// Features:
+//   - Consumer and Producer with functional options, as the amqp and nats transports
+//   - A dedicated listening connection, reconnected with exponential backoff
+//     and listening again to its channels
+//   - JSON payloads, or the ids of the resources only to stay under the 8000
+//     bytes PostgreSQL accepts
+//   - Notifications sent along the transaction of the context, on commit
+//   - Fx dependency injection integration
+//
+// Notifications are not persisted: the ones sent while a consumer is
+// disconnected are lost, use them to trigger work that can be reconciled.
+//
+// Consumer usage:
+//
+//	conn, err := pgnotify.NewConnection(log, pgnotify.WithConnURL("postgres://..."))
+//	if err != nil {
+//	    log.Fatal(err)
+//	}
+//
+//	consumer, err := pgnotify.NewConsumer(conn, log, "orders",
+//	    pgnotify.JSONDecoder[Order],
+//	    pgnotify.HandlerFunc[Order](func(ctx context.Context, o Order) error {
+//	        return nil
+//	    }),
+//	)
+//	if err != nil {
+//	    log.Fatal(err)
+//	}
+//	err = consumer.Subscribe(ctx)
+//
+// Producer usage, sending the ids of the orders only:
+//
+//	producer, err := pgnotify.NewProducer(db, log, "orders",
+//	    pgnotify.JSONEncoder[Order], pgnotify.ProducerWithIDsOnly())
+//	if err != nil {
+//	    log.Fatal(err)
+//	}
+//	err = producer.Publish(ctx, order)
+//
+// with the consumer loading them back:
+//
+//	pgnotify.NewConsumer(conn, log, "orders", pgnotify.IDDecoder(repo.Get), handler)
+package pgnotify
@@ -0,0 +1,26 @@
+package pgnotify
+
+import (
+	"fmt"
+
+	"github.com/dosanma1/forge/go/kit/errors"
+)
+
+func newErrListen(channel string, err error) error {
+	return errors.Wrap(err, errors.CodeDatabaseError, errors.WithMessage(fmt.Sprintf("cannot listen to channel %q", channel)))
+}
+
+func newErrClosed() error {
+	return errors.ServiceUnavailable("pgnotify connection closed")
+}
+
+func newErrPayloadTooLarge(channel string, size int) error {
+	return errors.InvalidArgument(fmt.Sprintf(
+		"payload of %d bytes exceeds the %d bytes accepted on channel %q, send the ids only instead, see ProducerWithIDsOnly",
+		size, maxPayloadSize-1, channel,
+	))
+}
+
+func newErrNoID[T any]() error {
+	return errors.InvalidArgument(fmt.Sprintf("%T has no ID() string method to send its id only", *new(T)))
+}
@@ -0,0 +1,78 @@
+package pgnotify
+
+import (
+	"context"
+	"fmt"
+
+	"go.uber.org/fx"
+
+	"github.com/dosanma1/forge/go/kit/monitoring/logger"
+)
+
+// NewConnectionFx creates a new listening connection module
+func NewConnectionFx(opts ...connOption) fx.Option {
+	return fx.Module(
+		"pgnotify:conn",
+		fx.Provide(func(log logger.Logger) (Connection, error) {
+			return NewConnection(log, opts...)
+		}),
+		fx.Invoke(func(lc fx.Lifecycle, conn Connection) {
+			lc.Append(fx.Hook{
+				OnStop: func(ctx context.Context) error {
+					return conn.Close(ctx)
+				},
+			})
+		}),
+	)
+}
+
+// NewProducerFx creates a new producer module
+func NewProducerFx[T any](constructor any, annotations ...fx.Annotation) fx.Option {
+	return fx.Provide(
+		fx.Annotate(
+			constructor,
+			append(
+				annotations,
+				fx.As(new(Producer[T])),
+			)...,
+		),
+	)
+}
+
+// NewConsumerFx creates a new consumer module
+func NewConsumerFx[T any](constructor any, consumerName string, annotations ...fx.Annotation) fx.Option {
+	return fx.Module(
+		fmt.Sprintf("pgnotify:consumer:%s", consumerName),
+		fx.Provide(
+			fx.Annotate(
+				constructor,
+				append(
+					annotations,
+					fx.As(new(Consumer)),
+				)...,
+			),
+		),
+		fx.Invoke(
+			fx.Annotate(
+				func(lc fx.Lifecycle, c Consumer) {
+					lc.Append(fx.Hook{
+						OnStart: func(ctx context.Context) error {
+							return c.Subscribe(ctx)
+						},
+						OnStop: func(ctx context.Context) error {
+							return c.Unsubscribe(ctx)
+						},
+					})
+				},
+			),
+		),
+	)
+}
+
+// FxModule provides the default listening connection module, connecting to
+// the database of the DB_* environment variables.
+func FxModule() fx.Option {
+	return fx.Module("pgnotify",
+		NewConnectionFx(),
+	)
+}
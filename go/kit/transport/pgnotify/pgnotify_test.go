@@ -0,0 +1,121 @@
+package pgnotify
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"strings"
+	"testing"
+
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"modernc.org/sqlite"
+
+	apierrors "github.com/dosanma1/forge/go/kit/errors"
+	"github.com/dosanma1/forge/go/kit/monitoring/logger/loggertest"
+)
+
+type order struct {
+	OrderID string `json:"id"`
+	Lines   string `json:"lines"`
+}
+
+func (o order) ID() string { return o.OrderID }
+
+//nolint:gochecknoglobals // the sqlite functions are registered globally
+var notified = make(chan [2]string, 10)
+
+func init() {
+	// stands in for the PostgreSQL function on sqlite
+	sqlite.MustRegisterScalarFunction("pg_notify", 2, func(_ *sqlite.FunctionContext, args []driver.Value) (driver.Value, error) {
+		notified <- [2]string{args[0].(string), args[1].(string)}
+		return nil, nil
+	})
+}
+
+func newTestDB(t *testing.T) *sql.DB {
+	t.Helper()
+
+	db, err := sql.Open("sqlite", ":memory:")
+	require.NoError(t, err)
+	t.Cleanup(func() { db.Close() })
+
+	return db
+}
+
+func TestProducer(t *testing.T) {
+	ctx := context.Background()
+	db := newTestDB(t)
+	log := loggertest.NewStubLogger(t)
+
+	t.Run("publishes the JSON encoding of the values", func(t *testing.T) {
+		prod, err := NewProducer(db, log, "orders", JSONEncoder[order])
+		require.NoError(t, err)
+
+		require.NoError(t, prod.Publish(ctx, order{OrderID: "1", Lines: "2x book"}))
+		assert.Equal(t, [2]string{"orders", `{"id":"1","lines":"2x book"}`}, <-notified)
+
+		require.NoError(t, prod.Publish(ctx, order{OrderID: "2"}, OverrideChannel("archived_orders")))
+		assert.Equal(t, [2]string{"archived_orders", `{"id":"2","lines":""}`}, <-notified)
+	})
+
+	t.Run("publishes the ids only", func(t *testing.T) {
+		prod, err := NewProducer(db, log, "orders", JSONEncoder[order], ProducerWithIDsOnly())
+		require.NoError(t, err)
+
+		require.NoError(t, prod.Publish(ctx, order{OrderID: "1", Lines: strings.Repeat("x", maxPayloadSize)}))
+		assert.Equal(t, [2]string{"orders", `{"id":"1"}`}, <-notified)
+
+		_, err = NewProducer(db, log, "counts", JSONEncoder[int], ProducerWithIDsOnly())
+		assert.True(t, apierrors.Is(err, apierrors.CodeInvalidArgument))
+	})
+
+	t.Run("rejects the payloads PostgreSQL would", func(t *testing.T) {
+		prod, err := NewProducer(db, log, "orders", JSONEncoder[order])
+		require.NoError(t, err)
+
+		err = prod.Publish(ctx, order{OrderID: "1", Lines: strings.Repeat("x", maxPayloadSize)})
+		assert.True(t, apierrors.Is(err, apierrors.CodeInvalidArgument))
+		assert.Empty(t, notified)
+	})
+}
+
+func TestConsumer(t *testing.T) {
+	ctx := context.Background()
+	server := &fakeServer{}
+	conn := newTestConnection(t, server)
+	log := loggertest.NewStubLogger(t)
+
+	t.Run("handles the JSON encoded values", func(t *testing.T) {
+		received := make(chan order, 10)
+		cons, err := NewConsumer(conn, log, "orders", JSONDecoder[order], HandlerFunc[order](func(_ context.Context, o order) error {
+			received <- o
+			return nil
+		}))
+		require.NoError(t, err)
+		require.NoError(t, cons.Subscribe(ctx))
+		defer func() { require.NoError(t, cons.Unsubscribe(ctx)) }()
+
+		server.conn(0).notifications <- &pgconn.Notification{Channel: "orders", Payload: "not json"}
+		server.conn(0).notifications <- &pgconn.Notification{Channel: "orders", Payload: `{"id":"1","lines":"2x book"}`}
+		assert.Equal(t, order{OrderID: "1", Lines: "2x book"}, <-received)
+	})
+
+	t.Run("loads the values of the ids", func(t *testing.T) {
+		received := make(chan order, 10)
+		load := func(_ context.Context, id string) (order, error) {
+			return order{OrderID: id, Lines: "loaded"}, nil
+		}
+		cons, err := NewConsumer(conn, log, "order_ids", IDDecoder(load), HandlerFunc[order](func(_ context.Context, o order) error {
+			received <- o
+			return nil
+		}))
+		require.NoError(t, err)
+		require.NoError(t, cons.Subscribe(ctx))
+		defer func() { require.NoError(t, cons.Unsubscribe(ctx)) }()
+
+		server.conn(0).notifications <- &pgconn.Notification{Channel: "order_ids", Payload: `{"id":"7"}`}
+		assert.Equal(t, order{OrderID: "7", Lines: "loaded"}, <-received)
+	})
+}
@@ -0,0 +1,129 @@
+package pgnotify
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"reflect"
+
+	"github.com/dosanma1/forge/go/kit/monitoring/logger"
+	"github.com/dosanma1/forge/go/kit/persistence/sqldb"
+)
+
+// maxPayloadSize is the size PostgreSQL rejects payloads from.
+const maxPayloadSize = 8000
+
+type (
+	Encoder[T any] func(ctx context.Context, v T) ([]byte, error)
+
+	Producer[T any] interface {
+		// Publish notifies the listeners of the channel of v. Within the
+		// transaction of ctx, see sqldb.GetTx, the notification is sent on
+		// commit.
+		Publish(ctx context.Context, v T, opts ...PublishOpt) error
+	}
+
+	publishConfig struct {
+		channel string
+	}
+
+	PublishOpt func(*publishConfig)
+
+	producer[T any] struct {
+		db      *sql.DB
+		encoder Encoder[T]
+		log     logger.Logger
+		config  producerConfig
+	}
+
+	identifiable interface {
+		ID() string
+	}
+
+	// idPayload is the payload of the producers sending the ids only.
+	idPayload struct {
+		ID string `json:"id"`
+	}
+)
+
+func NewProducer[T any](
+	db *sql.DB,
+	log logger.Logger,
+	channel string,
+	enc Encoder[T],
+	opts ...ProducerOption,
+) (Producer[T], error) {
+	cfg := &producerConfig{channel: channel}
+	for _, opt := range opts {
+		opt(cfg)
+	}
+
+	if cfg.idsOnly && !reflect.TypeFor[T]().Implements(reflect.TypeFor[identifiable]()) {
+		return nil, newErrNoID[T]()
+	}
+
+	return &producer[T]{
+		db:      db,
+		encoder: enc,
+		log:     log,
+		config:  *cfg,
+	}, nil
+}
+
+// OverrideChannel publishes on channel instead of the one of the producer.
+func OverrideChannel(channel string) PublishOpt {
+	return func(p *publishConfig) {
+		p.channel = channel
+	}
+}
+
+func (p *producer[T]) Publish(ctx context.Context, v T, opts ...PublishOpt) error {
+	cfg := &publishConfig{channel: p.config.channel}
+	for _, opt := range opts {
+		opt(cfg)
+	}
+
+	payload, err := p.encode(ctx, v)
+	if err != nil {
+		return err
+	}
+	if len(payload) >= maxPayloadSize {
+		return newErrPayloadTooLarge(cfg.channel, len(payload))
+	}
+
+	p.log.DebugContext(ctx, "pgnotify:producer -> publishing notification", "channel", cfg.channel)
+
+	_, err = sqldb.GetTx(ctx, p.db).ExecContext(ctx, "SELECT pg_notify($1, $2)", cfg.channel, string(payload))
+	return err
+}
+
+func (p *producer[T]) encode(ctx context.Context, v T) ([]byte, error) {
+	if p.config.idsOnly {
+		//nolint:forcetypeassert // checked by NewProducer
+		return json.Marshal(idPayload{ID: any(v).(identifiable).ID()})
+	}
+
+	return p.encoder(ctx, v)
+}
+
+type producerConfig struct {
+	channel string
+	idsOnly bool
+}
+
+type ProducerOption func(*producerConfig)
+
+// ProducerWithIDsOnly sends the ids of the values only, as {"id":"..."},
+// rather than their encoding, so that the payloads stay under the 8000
+// bytes PostgreSQL accepts. The consumers load the values back, see
+// IDDecoder. The values must have an ID() string method.
+func ProducerWithIDsOnly() ProducerOption {
+	return func(p *producerConfig) {
+		p.idsOnly = true
+	}
+}
+
+// JSONEncoder is a helper for JSON encoding
+func JSONEncoder[T any](ctx context.Context, v T) ([]byte, error) {
+	return json.Marshal(v)
+}
@@ -0,0 +1,462 @@
+package redisstream
+
+import (
+	"context"
+	"encoding/json"
+	stderrors "errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+
+	"github.com/dosanma1/forge/go/kit/errors"
+	"github.com/dosanma1/forge/go/kit/monitoring/logger"
+	"github.com/dosanma1/forge/go/kit/retry"
+)
+
+const (
+	defaultConsumeTimeout = 10 * time.Second
+	defaultMaxGoRoutines  = 10
+	defaultBlock          = 2 * time.Second
+	defaultClaimMinIdle   = time.Minute
+)
+
+type (
+	Decoder[T any] func(ctx context.Context, payload []byte) (T, error)
+
+	Handler[T any] interface {
+		Handle(ctx context.Context, event T) error
+	}
+
+	HandlerFunc[T any] func(ctx context.Context, event T) error
+
+	Consumer interface {
+		// Subscribe creates the consumer group if needed and starts handling
+		// its messages in the background.
+		Subscribe(ctx context.Context) error
+		// Unsubscribe stops reading messages and waits for the ones being
+		// handled.
+		Unsubscribe(ctx context.Context) error
+	}
+
+	consumer[T any] struct {
+		cli     redis.UniversalClient
+		log     logger.Logger
+		decoder Decoder[T]
+		handler Handler[T]
+		config  consumerConfig
+
+		semaphore chan struct{}
+		mu        sync.Mutex
+		cancel    context.CancelFunc
+		loops     sync.WaitGroup
+		handling  sync.WaitGroup
+	}
+)
+
+func (f HandlerFunc[T]) Handle(ctx context.Context, event T) error {
+	return f(ctx, event)
+}
+
+func defaultConsumerOpts() []consumerOption {
+	host, _ := os.Hostname()
+	return []consumerOption{
+		WithConsumerName(fmt.Sprintf("%s-%d", host, os.Getpid())),
+		WithStartID("$"),
+		WithTimeout(defaultConsumeTimeout),
+		WithMaxGoRoutines(defaultMaxGoRoutines),
+		WithBlock(defaultBlock),
+		WithClaimMinIdle(defaultClaimMinIdle),
+	}
+}
+
+// NewConsumer returns a consumer of the group of the stream. The messages
+// not acknowledged, i.e. whose handling failed, are delivered again once
+// idle for the claim min idle time, see WithClaimMinIdle, and dead lettered
+// after too many deliveries, see WithDeadLetter.
+func NewConsumer[T any](
+	cli redis.UniversalClient,
+	log logger.Logger,
+	stream, group string,
+	dec Decoder[T],
+	handler Handler[T],
+	opts ...consumerOption,
+) (Consumer, error) {
+	cfg := &consumerConfig{stream: stream, group: group}
+	for _, opt := range append(defaultConsumerOpts(), opts...) {
+		opt(cfg)
+	}
+	if err := cfg.validate(); err != nil {
+		return nil, err
+	}
+
+	return &consumer[T]{
+		cli:       cli,
+		log:       log,
+		decoder:   dec,
+		handler:   handler,
+		config:    *cfg,
+		semaphore: make(chan struct{}, cfg.maxGoRoutines),
+	}, nil
+}
+
+func (c *consumer[T]) Subscribe(ctx context.Context) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.cancel != nil {
+		return nil
+	}
+
+	if err := c.createGroup(ctx); err != nil {
+		return err
+	}
+
+	runCtx, cancel := context.WithCancel(context.WithoutCancel(ctx))
+	c.cancel = cancel
+	c.loops.Add(2)
+	go c.read(runCtx)
+	go c.claim(runCtx)
+
+	c.log.InfoContext(ctx, "redisstream:consumer -> subscribed",
+		"stream", c.config.stream, "group", c.config.group, "consumer", c.config.name)
+	return nil
+}
+
+func (c *consumer[T]) Unsubscribe(ctx context.Context) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.cancel == nil {
+		return nil
+	}
+	c.cancel()
+	c.cancel = nil
+
+	done := make(chan struct{})
+	go func() {
+		c.loops.Wait()
+		c.handling.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (c *consumer[T]) createGroup(ctx context.Context) error {
+	err := c.cli.XGroupCreateMkStream(ctx, c.config.stream, c.config.group, c.config.startID).Err()
+	if err != nil && !strings.HasPrefix(err.Error(), "BUSYGROUP") {
+		return err
+	}
+
+	return nil
+}
+
+// read handles the new messages of the group until ctx is done.
+func (c *consumer[T]) read(ctx context.Context) {
+	defer c.loops.Done()
+
+	for attempt := 1; ctx.Err() == nil; {
+		streams, err := c.cli.XReadGroup(ctx, &redis.XReadGroupArgs{
+			Group:    c.config.group,
+			Consumer: c.config.name,
+			Streams:  []string{c.config.stream, ">"},
+			Count:    int64(c.config.maxGoRoutines),
+			Block:    c.config.block,
+		}).Result()
+		switch {
+		case ctx.Err() != nil:
+			return
+		case stderrors.Is(err, redis.Nil):
+			continue
+		case err != nil:
+			c.log.ErrorContext(ctx, "redisstream:consumer -> error reading messages", "stream", c.config.stream, "err", err)
+			if strings.HasPrefix(err.Error(), "NOGROUP") {
+				// the stream was deleted along with its groups
+				_ = c.createGroup(ctx)
+			}
+			c.wait(ctx, retry.Delay(attempt))
+			attempt++
+			continue
+		}
+
+		attempt = 1
+		for _, s := range streams {
+			for _, msg := range s.Messages {
+				c.dispatch(ctx, msg, 1)
+			}
+		}
+	}
+}
+
+// claim takes over the messages idle for the claim min idle time, i.e. the
+// ones whose handling failed or whose consumer is gone, until ctx is done.
+func (c *consumer[T]) claim(ctx context.Context) {
+	defer c.loops.Done()
+
+	ticker := time.NewTicker(c.config.claimMinIdle / 2)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		for start := "0-0"; ctx.Err() == nil; {
+			msgs, next, err := c.cli.XAutoClaim(ctx, &redis.XAutoClaimArgs{
+				Stream:   c.config.stream,
+				Group:    c.config.group,
+				Consumer: c.config.name,
+				MinIdle:  c.config.claimMinIdle,
+				Start:    start,
+				Count:    int64(c.config.maxGoRoutines),
+			}).Result()
+			if err != nil {
+				if ctx.Err() == nil {
+					c.log.ErrorContext(ctx, "redisstream:consumer -> error claiming messages", "stream", c.config.stream, "err", err)
+				}
+				break
+			}
+
+			deliveries := c.deliveries(ctx, msgs)
+			for _, msg := range msgs {
+				if c.config.maxDeliveries > 0 && deliveries[msg.ID] > c.config.maxDeliveries {
+					c.deadLetter(ctx, msg, deliveries[msg.ID], newErrMaxDeliveries(c.config.maxDeliveries))
+					continue
+				}
+				c.dispatch(ctx, msg, deliveries[msg.ID])
+			}
+
+			if next == "0-0" || next == "" {
+				break
+			}
+			start = next
+		}
+	}
+}
+
+// deliveries returns how many times the claimed msgs were delivered.
+func (c *consumer[T]) deliveries(ctx context.Context, msgs []redis.XMessage) map[string]int64 {
+	counts := make(map[string]int64, len(msgs))
+	if len(msgs) == 0 {
+		return counts
+	}
+
+	pending, err := c.cli.XPendingExt(ctx, &redis.XPendingExtArgs{
+		Stream:   c.config.stream,
+		Group:    c.config.group,
+		Start:    msgs[0].ID,
+		End:      msgs[len(msgs)-1].ID,
+		Count:    int64(len(msgs)),
+		Consumer: c.config.name,
+	}).Result()
+	if err != nil {
+		c.log.ErrorContext(ctx, "redisstream:consumer -> error counting deliveries", "stream", c.config.stream, "err", err)
+	}
+	for _, p := range pending {
+		counts[p.ID] = p.RetryCount
+	}
+
+	return counts
+}
+
+// dispatch handles msg once fewer than the max goroutines are handling
+// messages.
+func (c *consumer[T]) dispatch(ctx context.Context, msg redis.XMessage, deliveries int64) {
+	select {
+	case c.semaphore <- struct{}{}:
+	case <-ctx.Done():
+		// left pending, to be claimed once idle
+		return
+	}
+
+	c.handling.Add(1)
+	go func() {
+		defer c.handling.Done()
+		defer func() { <-c.semaphore }()
+		// the messages being handled are not cancelled by Unsubscribe
+		c.consume(context.WithoutCancel(ctx), msg, deliveries)
+	}()
+}
+
+func (c *consumer[T]) consume(ctx context.Context, msg redis.XMessage, deliveries int64) {
+	ctx, cancel := context.WithTimeout(ctx, c.config.timeout)
+	defer cancel()
+
+	payload, _ := msg.Values[payloadField].(string)
+	event, err := c.decoder(ctx, []byte(payload))
+	if err != nil {
+		// decoding it again would fail all the same
+		c.deadLetter(ctx, msg, deliveries, err)
+		return
+	}
+
+	if err := c.handle(ctx, event); err != nil {
+		if retry.IsPermanent(err) {
+			c.deadLetter(ctx, msg, deliveries, err)
+			return
+		}
+		c.log.ErrorContext(ctx, "redisstream:consumer -> error handling message",
+			"stream", c.config.stream, "id", msg.ID, "deliveries", deliveries, "err", err)
+		return
+	}
+
+	c.ack(ctx, msg)
+}
+
+// handle runs the handler, turning its panics into errors.
+func (c *consumer[T]) handle(ctx context.Context, event T) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = errors.InternalError(fmt.Sprintf("handler panicked: %v", r))
+		}
+	}()
+
+	return c.handler.Handle(ctx, event)
+}
+
+func (c *consumer[T]) ack(ctx context.Context, msg redis.XMessage) {
+	if err := c.cli.XAck(ctx, c.config.stream, c.config.group, msg.ID).Err(); err != nil {
+		c.log.ErrorContext(ctx, "redisstream:consumer -> error acknowledging message", "stream", c.config.stream, "id", msg.ID, "err", err)
+	}
+}
+
+// deadLetter moves msg to the dead letter stream, if any, for cause.
+func (c *consumer[T]) deadLetter(ctx context.Context, msg redis.XMessage, deliveries int64, cause error) {
+	if c.config.deadLetterStream == "" {
+		c.log.ErrorContext(ctx, "redisstream:consumer -> dropping message",
+			"stream", c.config.stream, "id", msg.ID, "deliveries", deliveries, "err", cause)
+		c.ack(ctx, msg)
+		return
+	}
+
+	err := c.cli.XAdd(ctx, &redis.XAddArgs{
+		Stream: c.config.deadLetterStream,
+		Values: map[string]any{
+			payloadField: msg.Values[payloadField],
+			"stream":     c.config.stream,
+			"group":      c.config.group,
+			"id":         msg.ID,
+			"deliveries": strconv.FormatInt(deliveries, 10),
+			"error":      cause.Error(),
+		},
+	}).Err()
+	if err != nil {
+		// left pending, to be dead lettered once claimed again
+		c.log.ErrorContext(ctx, "redisstream:consumer -> error dead lettering message", "stream", c.config.stream, "id", msg.ID, "err", err)
+		return
+	}
+
+	c.log.WarnContext(ctx, "redisstream:consumer -> message dead lettered",
+		"stream", c.config.stream, "id", msg.ID, "deliveries", deliveries, "err", cause)
+	c.ack(ctx, msg)
+}
+
+func (c *consumer[T]) wait(ctx context.Context, d time.Duration) {
+	select {
+	case <-ctx.Done():
+	case <-time.After(d):
+	}
+}
+
+type consumerConfig struct {
+	stream           string
+	group            string
+	name             string
+	startID          string
+	timeout          time.Duration
+	maxGoRoutines    uint
+	block            time.Duration
+	claimMinIdle     time.Duration
+	deadLetterStream string
+	maxDeliveries    int64
+}
+
+func (c *consumerConfig) validate() error {
+	switch {
+	case c.stream == "" || c.group == "":
+		return newErrRequired("stream and group")
+	case c.maxGoRoutines == 0:
+		return newErrRequired("max goroutines")
+	case c.claimMinIdle <= c.timeout:
+		return newErrClaimMinIdle(c.claimMinIdle, c.timeout)
+	}
+
+	return nil
+}
+
+type consumerOption func(*consumerConfig)
+
+// WithConsumerName sets the name of the consumer within its group, which
+// must be unique. Defaults to the hostname and pid.
+func WithConsumerName(name string) consumerOption {
+	return func(c *consumerConfig) {
+		c.name = name
+	}
+}
+
+// WithStartID sets the id of the first message of the group when Subscribe
+// creates it, e.g. "0" for the whole stream. Defaults to "$", i.e. the
+// messages published from then on.
+func WithStartID(id string) consumerOption {
+	return func(c *consumerConfig) {
+		c.startID = id
+	}
+}
+
+// WithTimeout cancels the context of the handling of a message lasting
+// longer than timeout. It must be shorter than the claim min idle time.
+func WithTimeout(timeout time.Duration) consumerOption {
+	return func(c *consumerConfig) {
+		c.timeout = timeout
+	}
+}
+
+func WithMaxGoRoutines(maxGoRoutines uint) consumerOption {
+	return func(c *consumerConfig) {
+		c.maxGoRoutines = maxGoRoutines
+	}
+}
+
+// WithBlock sets how long a read waits for new messages, which bounds how
+// long Unsubscribe waits for the consumer to stop reading.
+func WithBlock(block time.Duration) consumerOption {
+	return func(c *consumerConfig) {
+		c.block = block
+	}
+}
+
+// WithClaimMinIdle sets how long a message stays pending, i.e. delivered but
+// not acknowledged, before the consumers of the group claim it to handle it
+// again. Defaults to 1m.
+func WithClaimMinIdle(minIdle time.Duration) consumerOption {
+	return func(c *consumerConfig) {
+		c.claimMinIdle = minIdle
+	}
+}
+
+// WithDeadLetter moves the messages delivered more than maxDeliveries times
+// to the stream, along with the stream, group, id, deliveries and error of
+// the message. So are the messages failing to decode, or to handle with a
+// retry.Permanent error, right away, which are dropped otherwise.
+func WithDeadLetter(stream string, maxDeliveries int64) consumerOption {
+	return func(c *consumerConfig) {
+		c.deadLetterStream = stream
+		c.maxDeliveries = maxDeliveries
+	}
+}
+
+// JSONDecoder is a helper for JSON decoding
+func JSONDecoder[T any](ctx context.Context, payload []byte) (T, error) {
+	var v T
+	err := json.Unmarshal(payload, &v)
+	return v, err
+}
@@ -0,0 +1,37 @@
+// Package redisstream provides a durable queue on top of Redis Streams, with
+// the producer and consumer generics of the other transports.
+//
+// Features:
+//   - Producer appending the JSON, or custom, encoding of the values (XADD)
+//   - Stream trimming by length or age when publishing
+//   - Consumer groups (XREADGROUP), the messages being acknowledged (XACK)
+//     once handled
+//   - Stuck messages, i.e. failed or whose consumer is gone, claimed again by
+//     the consumers of the group once idle (XAUTOCLAIM)
+//   - Dead lettering after too many deliveries
+//   - Bounded concurrency, graceful shutdown and Fx dependency injection integration
+//
+// Consumer usage:
+//
+//	consumer, err := redisstream.NewConsumer(cli, log, "orders", "billing",
+//	    redisstream.JSONDecoder[Order],
+//	    redisstream.HandlerFunc[Order](func(ctx context.Context, o Order) error {
+//	        return nil
+//	    }),
+//	    redisstream.WithMaxGoRoutines(20),
+//	    redisstream.WithDeadLetter("orders:dead", 5),
+//	)
+//	if err != nil {
+//	    log.Fatal(err)
+//	}
+//	err = consumer.Subscribe(ctx)
+//
+// Producer usage:
+//
+//	producer, err := redisstream.NewProducer(cli, log, "orders",
+//	    redisstream.JSONEncoder[Order], redisstream.ProducerWithMaxAge(7*24*time.Hour))
+//	if err != nil {
+//	    log.Fatal(err)
+//	}
+//	err = producer.Publish(ctx, order)
+package redisstream
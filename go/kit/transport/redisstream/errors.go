@@ -0,0 +1,27 @@
+package redisstream
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/dosanma1/forge/go/kit/errors"
+)
+
+func newErrRequired(field string) error {
+	return errors.InvalidArgument(fmt.Sprintf("redisstream consumer %s are required", field))
+}
+
+func newErrClaimMinIdle(minIdle, timeout time.Duration) error {
+	return errors.InvalidArgument(fmt.Sprintf(
+		"claim min idle time %s must exceed the timeout %s, or the messages being handled would be claimed again",
+		minIdle, timeout,
+	))
+}
+
+func newErrTrimming() error {
+	return errors.InvalidArgument("redisstream producer trims its stream either by length or by age")
+}
+
+func newErrMaxDeliveries(maxDeliveries int64) error {
+	return errors.Conflict(fmt.Sprintf("message delivered more than %d times", maxDeliveries))
+}
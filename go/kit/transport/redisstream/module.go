@@ -0,0 +1,52 @@
+package redisstream
+
+import (
+	"context"
+	"fmt"
+
+	"go.uber.org/fx"
+)
+
+// NewProducerFx creates a new producer module
+func NewProducerFx[T any](constructor any, annotations ...fx.Annotation) fx.Option {
+	return fx.Provide(
+		fx.Annotate(
+			constructor,
+			append(
+				annotations,
+				fx.As(new(Producer[T])),
+			)...,
+		),
+	)
+}
+
+// NewConsumerFx creates a new consumer module, subscribed and unsubscribed
+// along with the application
+func NewConsumerFx[T any](constructor any, consumerName string, annotations ...fx.Annotation) fx.Option {
+	return fx.Module(
+		fmt.Sprintf("redisstream:consumer:%s", consumerName),
+		fx.Provide(
+			fx.Annotate(
+				constructor,
+				append(
+					annotations,
+					fx.As(new(Consumer)),
+				)...,
+			),
+		),
+		fx.Invoke(
+			fx.Annotate(
+				func(lc fx.Lifecycle, c Consumer) {
+					lc.Append(fx.Hook{
+						OnStart: func(ctx context.Context) error {
+							return c.Subscribe(ctx)
+						},
+						OnStop: func(ctx context.Context) error {
+							return c.Unsubscribe(ctx)
+						},
+					})
+				},
+			),
+		),
+	)
+}
@@ -0,0 +1,133 @@
+package redisstream
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+
+	"github.com/dosanma1/forge/go/kit/monitoring/logger"
+)
+
+// payloadField is the field of the stream entries holding the encoded value.
+const payloadField = "payload"
+
+type (
+	Encoder[T any] func(ctx context.Context, v T) ([]byte, error)
+
+	Producer[T any] interface {
+		// Publish appends v to the stream.
+		Publish(ctx context.Context, v T, opts ...PublishOpt) error
+	}
+
+	publishConfig struct {
+		stream string
+	}
+
+	PublishOpt func(*publishConfig)
+
+	producer[T any] struct {
+		cli     redis.UniversalClient
+		encoder Encoder[T]
+		log     logger.Logger
+		config  producerConfig
+	}
+)
+
+func NewProducer[T any](
+	cli redis.UniversalClient,
+	log logger.Logger,
+	stream string,
+	enc Encoder[T],
+	opts ...ProducerOption,
+) (Producer[T], error) {
+	cfg := &producerConfig{stream: stream, approx: true}
+	for _, opt := range opts {
+		opt(cfg)
+	}
+	if cfg.maxLen > 0 && cfg.maxAge > 0 {
+		return nil, newErrTrimming()
+	}
+
+	return &producer[T]{
+		cli:     cli,
+		encoder: enc,
+		log:     log,
+		config:  *cfg,
+	}, nil
+}
+
+// OverrideStream publishes on stream instead of the one of the producer.
+func OverrideStream(stream string) PublishOpt {
+	return func(p *publishConfig) {
+		p.stream = stream
+	}
+}
+
+func (p *producer[T]) Publish(ctx context.Context, v T, opts ...PublishOpt) error {
+	cfg := &publishConfig{stream: p.config.stream}
+	for _, opt := range opts {
+		opt(cfg)
+	}
+
+	payload, err := p.encoder(ctx, v)
+	if err != nil {
+		return err
+	}
+
+	args := &redis.XAddArgs{
+		Stream: cfg.stream,
+		Values: map[string]any{payloadField: payload},
+		MaxLen: p.config.maxLen,
+		Approx: p.config.approx,
+	}
+	if p.config.maxAge > 0 {
+		args.MinID = fmt.Sprintf("%d-0", time.Now().Add(-p.config.maxAge).UnixMilli())
+	}
+
+	p.log.DebugContext(ctx, "redisstream:producer -> publishing message", "stream", cfg.stream)
+
+	return p.cli.XAdd(ctx, args).Err()
+}
+
+type producerConfig struct {
+	stream string
+	maxLen int64
+	maxAge time.Duration
+	approx bool
+}
+
+type ProducerOption func(*producerConfig)
+
+// ProducerWithMaxLen trims the stream to about maxLen entries when
+// publishing, see ProducerWithExactTrimming. The trimmed entries are lost
+// even if not acknowledged yet. It cannot be combined with ProducerWithMaxAge.
+func ProducerWithMaxLen(maxLen int64) ProducerOption {
+	return func(p *producerConfig) {
+		p.maxLen = maxLen
+	}
+}
+
+// ProducerWithMaxAge trims the entries older than about maxAge from the
+// stream when publishing, see ProducerWithExactTrimming. The trimmed entries
+// are lost even if not acknowledged yet.
+func ProducerWithMaxAge(maxAge time.Duration) ProducerOption {
+	return func(p *producerConfig) {
+		p.maxAge = maxAge
+	}
+}
+
+// ProducerWithExactTrimming trims the stream exactly to its limits rather
+// than by whole nodes, which is less efficient.
+func ProducerWithExactTrimming() ProducerOption {
+	return func(p *producerConfig) {
+		p.approx = false
+	}
+}
+
+// JSONEncoder is a helper for JSON encoding
+func JSONEncoder[T any](ctx context.Context, v T) ([]byte, error) {
+	return json.Marshal(v)
+}
@@ -0,0 +1,210 @@
+package redisstream
+
+import (
+	"context"
+	stderrors "errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	apierrors "github.com/dosanma1/forge/go/kit/errors"
+	"github.com/dosanma1/forge/go/kit/monitoring/logger/loggertest"
+	"github.com/dosanma1/forge/go/kit/retry"
+)
+
+type order struct {
+	ID    string `json:"id"`
+	Total int    `json:"total"`
+}
+
+func newClient(t *testing.T) redis.UniversalClient {
+	t.Helper()
+
+	mr := miniredis.RunT(t)
+	cli := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	t.Cleanup(func() { cli.Close() })
+
+	return cli
+}
+
+func newProducer(t *testing.T, cli redis.UniversalClient, opts ...ProducerOption) Producer[order] {
+	t.Helper()
+
+	prod, err := NewProducer(cli, loggertest.NewStubLogger(t), "orders", JSONEncoder[order], opts...)
+	require.NoError(t, err)
+
+	return prod
+}
+
+func subscribe(t *testing.T, cli redis.UniversalClient, h HandlerFunc[order], opts ...consumerOption) Consumer {
+	t.Helper()
+
+	opts = append([]consumerOption{
+		WithBlock(10 * time.Millisecond),
+		WithTimeout(10 * time.Millisecond),
+		WithClaimMinIdle(50 * time.Millisecond),
+	}, opts...)
+	cons, err := NewConsumer(cli, loggertest.NewStubLogger(t), "orders", "billing", JSONDecoder[order], h, opts...)
+	require.NoError(t, err)
+	require.NoError(t, cons.Subscribe(context.Background()))
+	t.Cleanup(func() {
+		require.NoError(t, cons.Unsubscribe(context.Background()))
+	})
+
+	return cons
+}
+
+func pending(t *testing.T, cli redis.UniversalClient) int64 {
+	t.Helper()
+
+	p, err := cli.XPending(context.Background(), "orders", "billing").Result()
+	require.NoError(t, err)
+
+	return p.Count
+}
+
+func TestProducer(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("appends the encoding of the values", func(t *testing.T) {
+		cli := newClient(t)
+		require.NoError(t, newProducer(t, cli).Publish(ctx, order{ID: "1", Total: 10}))
+		require.NoError(t, newProducer(t, cli).Publish(ctx, order{ID: "2"}, OverrideStream("refunds")))
+
+		msgs, err := cli.XRange(ctx, "orders", "-", "+").Result()
+		require.NoError(t, err)
+		require.Len(t, msgs, 1)
+		assert.Equal(t, map[string]any{"payload": `{"id":"1","total":10}`}, msgs[0].Values)
+		assert.Equal(t, int64(1), cli.XLen(ctx, "refunds").Val())
+	})
+
+	t.Run("trims the stream", func(t *testing.T) {
+		cli := newClient(t)
+		prod := newProducer(t, cli, ProducerWithMaxLen(2), ProducerWithExactTrimming())
+		for _, id := range []string{"1", "2", "3"} {
+			require.NoError(t, prod.Publish(ctx, order{ID: id}))
+		}
+		assert.Equal(t, int64(2), cli.XLen(ctx, "orders").Val())
+
+		_, err := NewProducer(cli, loggertest.NewStubLogger(t), "orders", JSONEncoder[order],
+			ProducerWithMaxLen(2), ProducerWithMaxAge(time.Hour))
+		assert.True(t, apierrors.Is(err, apierrors.CodeInvalidArgument))
+	})
+}
+
+func TestConsumer(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("handles and acknowledges the messages", func(t *testing.T) {
+		cli := newClient(t)
+		received := make(chan order, 10)
+		subscribe(t, cli, func(_ context.Context, o order) error {
+			received <- o
+			return nil
+		})
+
+		prod := newProducer(t, cli)
+		require.NoError(t, prod.Publish(ctx, order{ID: "1", Total: 10}))
+		require.NoError(t, prod.Publish(ctx, order{ID: "2", Total: 20}))
+		assert.ElementsMatch(t, []order{{ID: "1", Total: 10}, {ID: "2", Total: 20}}, []order{<-received, <-received})
+		assert.Eventually(t, func() bool { return pending(t, cli) == 0 }, time.Second, 5*time.Millisecond)
+	})
+
+	t.Run("delivers the failed messages again", func(t *testing.T) {
+		cli := newClient(t)
+		var attempts atomic.Int32
+		handled := make(chan struct{})
+		subscribe(t, cli, func(_ context.Context, o order) error {
+			if attempts.Add(1) < 3 {
+				return stderrors.New("ledger unavailable")
+			}
+			close(handled)
+			return nil
+		})
+
+		require.NoError(t, newProducer(t, cli).Publish(ctx, order{ID: "1"}))
+		<-handled
+		assert.Eventually(t, func() bool { return pending(t, cli) == 0 }, time.Second, 5*time.Millisecond)
+		assert.Equal(t, int32(3), attempts.Load())
+	})
+
+	t.Run("dead letters the messages delivered too many times", func(t *testing.T) {
+		cli := newClient(t)
+		var attempts atomic.Int32
+		subscribe(t, cli, func(context.Context, order) error {
+			attempts.Add(1)
+			panic("ledger corrupted")
+		}, WithDeadLetter("orders:dead", 2))
+
+		require.NoError(t, newProducer(t, cli).Publish(ctx, order{ID: "1"}))
+		assert.Eventually(t, func() bool { return cli.XLen(ctx, "orders:dead").Val() == 1 }, 2*time.Second, 5*time.Millisecond)
+
+		dead, err := cli.XRange(ctx, "orders:dead", "-", "+").Result()
+		require.NoError(t, err)
+		assert.Equal(t, `{"id":"1","total":0}`, dead[0].Values["payload"])
+		assert.Equal(t, "orders", dead[0].Values["stream"])
+		assert.Equal(t, "billing", dead[0].Values["group"])
+		assert.Equal(t, "3", dead[0].Values["deliveries"])
+		assert.Equal(t, int32(2), attempts.Load())
+		assert.Eventually(t, func() bool { return pending(t, cli) == 0 }, time.Second, 5*time.Millisecond)
+	})
+
+	t.Run("dead letters the permanent failures right away", func(t *testing.T) {
+		cli := newClient(t)
+		subscribe(t, cli, func(context.Context, order) error {
+			return retry.Permanent(stderrors.New("unknown customer"))
+		}, WithDeadLetter("orders:dead", 5))
+
+		require.NoError(t, newProducer(t, cli).Publish(ctx, order{ID: "1"}))
+		assert.Eventually(t, func() bool { return cli.XLen(ctx, "orders:dead").Val() == 1 }, time.Second, 5*time.Millisecond)
+
+		dead, err := cli.XRange(ctx, "orders:dead", "-", "+").Result()
+		require.NoError(t, err)
+		assert.Equal(t, "1", dead[0].Values["deliveries"])
+		assert.Equal(t, "unknown customer", dead[0].Values["error"])
+	})
+
+	t.Run("bounds the messages handled at once", func(t *testing.T) {
+		cli := newClient(t)
+		var (
+			mu                  sync.Mutex
+			running, maxRunning int
+			wg                  sync.WaitGroup
+		)
+		wg.Add(6)
+		subscribe(t, cli, func(context.Context, order) error {
+			defer wg.Done()
+			mu.Lock()
+			running++
+			maxRunning = max(maxRunning, running)
+			mu.Unlock()
+
+			time.Sleep(5 * time.Millisecond)
+
+			mu.Lock()
+			running--
+			mu.Unlock()
+			return nil
+		}, WithMaxGoRoutines(2))
+
+		prod := newProducer(t, cli)
+		for range 6 {
+			require.NoError(t, prod.Publish(ctx, order{ID: "1"}))
+		}
+		wg.Wait()
+		assert.LessOrEqual(t, maxRunning, 2)
+	})
+
+	t.Run("requires the claim min idle time to exceed the timeout", func(t *testing.T) {
+		_, err := NewConsumer(newClient(t), loggertest.NewStubLogger(t), "orders", "billing",
+			JSONDecoder[order], HandlerFunc[order](func(context.Context, order) error { return nil }),
+			WithTimeout(time.Minute), WithClaimMinIdle(time.Minute))
+		assert.True(t, apierrors.Is(err, apierrors.CodeInvalidArgument))
+	})
+}
@@ -4,7 +4,11 @@ import (
 	"net/http"
 )
 
-const IDPath = "/{id}"
+const (
+	IDPath        = "/{id}"
+	AggregatePath = "/aggregate"
+	ExportPath    = "/export"
+)
 
 type Controller interface {
 	Version() string
@@ -48,6 +52,14 @@ func NewListEndpoint(handler http.Handler) Endpoint {
 	return NewEndpoint(http.MethodGet, "", handler)
 }
 
+func NewAggregateEndpoint(handler http.Handler) Endpoint {
+	return NewEndpoint(http.MethodGet, AggregatePath, handler)
+}
+
+func NewExportEndpoint(handler http.Handler) Endpoint {
+	return NewEndpoint(http.MethodGet, ExportPath, handler)
+}
+
 func NewUpdateEndpoint(handler http.Handler) Endpoint {
 	return NewEndpoint(http.MethodPut, IDPath, handler)
 }
@@ -4,6 +4,7 @@ import (
 	"context"
 	"net/http"
 	"strconv"
+	"strings"
 
 	"github.com/dosanma1/forge/go/kit/errors"
 	"github.com/dosanma1/forge/go/kit/jsonapi"
@@ -59,7 +60,7 @@ func transformStructuredError(err errors.Error) []*jsonapi.ErrorObject {
 				Title:  getErrorTitle(detail.Code()),
 				Detail: detail.Message(),
 				Source: &jsonapi.ErrorSource{
-					Pointer: "/data/attributes/" + detail.Field(),
+					Pointer: detailPointer(detail),
 				},
 			}
 
@@ -110,7 +111,7 @@ func transformStructuredError(err errors.Error) []*jsonapi.ErrorObject {
 			Title:  getErrorTitle(detail.Code()),
 			Detail: detail.Message(),
 			Source: &jsonapi.ErrorSource{
-				Pointer: "/data/attributes/" + detail.Field(),
+				Pointer: detailPointer(detail),
 			},
 		}
 
@@ -129,6 +130,29 @@ func transformStructuredError(err errors.Error) []*jsonapi.ErrorObject {
 	return errorObjects
 }
 
+// detailPointer returns the JSON Pointer of the field of a detail, the one
+// the detail knows of when it has one, e.g. for the details of the
+// validation package, and the one of an attribute otherwise.
+func detailPointer(detail errors.Detail) string {
+	if pd, ok := detail.(interface{ Pointer() string }); ok && pd.Pointer() != "" {
+		return pd.Pointer()
+	}
+	return attributePointer(detail.Field())
+}
+
+// attributePointer builds a JSON Pointer (RFC6901) to the attribute a detail
+// refers to. Nested field paths such as "address.street" or "tags.0" become
+// "/data/attributes/address/street" and "/data/attributes/tags/0".
+func attributePointer(field string) string {
+	segments := strings.Split(field, ".")
+	for i, s := range segments {
+		segments[i] = pointerEscaper.Replace(s)
+	}
+	return "/data/attributes/" + strings.Join(segments, "/")
+}
+
+var pointerEscaper = strings.NewReplacer("~", "~0", "/", "~1")
+
 // isFieldValidationError checks if this is a pure field validation error
 func isFieldValidationError(err errors.Error) bool {
 	code := err.Code()
@@ -2,6 +2,7 @@ package rest
 
 import (
 	"context"
+	"encoding/json"
 	"net/http"
 
 	"github.com/dosanma1/forge/go/kit/application/ctrl"
@@ -31,11 +32,12 @@ func NewJsonApiListHandler[R, DTO resource.Resource, C ctrl.Lister[R]](
 	lister C, resItemMapper func(res R) DTO, opts ...HandlerOpt,
 ) http.Handler {
 	// Create a wrapper that returns jsonapi.ListResponse[DTO] interface
-	listResponseMapper := func(res resource.ListResponse[R]) jsonapi.ListResponse[DTO] {
-		return resource.ListResponseToDTO(resItemMapper)(res)
+	listResponseMapper := func(ctx context.Context, res resource.ListResponse[R]) jsonapi.ListResponse[DTO] {
+		dto := resource.ListResponseToDTO(resItemMapper)(res)
+		return withListMeta(withPageLinks[DTO](ctx, dto, dto.Pagination), dto.Meta)
 	}
 
-	return WithJSONAPIIncludes(
+	return WithJSONAPIIncludes(withRequestURL(
 		NewHandler(
 			lister.List,
 			NewHTTPDecoder(QueryOptsFromReq()),
@@ -45,6 +47,17 @@ func NewJsonApiListHandler[R, DTO resource.Resource, C ctrl.Lister[R]](
 			),
 			append(opts, HandlerWithErrorEncoder(JsonApiErrorEncoder))...,
 		),
+	))
+}
+
+// NewJsonApiAggregateHandler serves the buckets of the aggregate= and group=
+// parameters as a meta-only JSON:API document: {"meta": {"buckets": [...]}}.
+func NewJsonApiAggregateHandler[C ctrl.Aggregator](aggregator C, opts ...HandlerOpt) http.Handler {
+	return NewHandler(
+		aggregator.Aggregate,
+		NewHTTPDecoder(QueryOptsFromReq(query.SkipDefaultPagination())),
+		jsonApiAggregateEncoder(http.StatusOK),
+		append(opts, HandlerWithErrorEncoder(JsonApiErrorEncoder))...,
 	)
 }
 
@@ -125,7 +138,7 @@ func jsonApiEncoder[I, O any](
 }
 
 func jsonApiListEncoder[I, O any](
-	itemMapper func(in I) jsonapi.ListResponse[O],
+	itemMapper func(ctx context.Context, in I) jsonapi.ListResponse[O],
 	successCode int,
 ) func(context.Context, http.ResponseWriter, any) error {
 	return newHTTPEncoderWithContentType(
@@ -133,7 +146,26 @@ func jsonApiListEncoder[I, O any](
 		func(ctx context.Context, w http.ResponseWriter, in I) error {
 			w.WriteHeader(successCode)
 
-			return jsonapi.MarshalManyPayloads(w, itemMapper(in), jsonapi.WithInclude(GetJSONAPIIncludes(ctx)...))
+			return jsonapi.MarshalManyPayloads(w, itemMapper(ctx, in), jsonapi.WithInclude(GetJSONAPIIncludes(ctx)...))
+		},
+	)
+}
+
+type aggregateDocument struct {
+	Meta aggregateMeta `json:"meta"`
+}
+
+type aggregateMeta struct {
+	Buckets []query.Bucket `json:"buckets"`
+}
+
+func jsonApiAggregateEncoder(successCode int) func(context.Context, http.ResponseWriter, any) error {
+	return newHTTPEncoderWithContentType(
+		"application/vnd.api+json; charset=utf-8",
+		func(_ context.Context, w http.ResponseWriter, in []query.Bucket) error {
+			w.WriteHeader(successCode)
+
+			return json.NewEncoder(w).Encode(aggregateDocument{Meta: aggregateMeta{Buckets: in}})
 		},
 	)
 }
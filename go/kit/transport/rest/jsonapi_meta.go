@@ -0,0 +1,40 @@
+package rest
+
+import (
+	"github.com/dosanma1/forge/go/kit/jsonapi"
+)
+
+// metaListResponse decorates a JSON:API list with a top-level meta object.
+type metaListResponse[T any] struct {
+	jsonapi.ListResponse[T]
+	meta *jsonapi.Meta
+}
+
+func (m *metaListResponse[T]) JSONAPIMeta() *jsonapi.Meta {
+	return m.meta
+}
+
+// pagedMetaListResponse carries both the pagination links and the meta.
+type pagedMetaListResponse[T any] struct {
+	*pagedListResponse[T]
+	meta *jsonapi.Meta
+}
+
+func (m *pagedMetaListResponse[T]) JSONAPIMeta() *jsonapi.Meta {
+	return m.meta
+}
+
+// withListMeta renders the meta of a list, e.g. search snippets, as the
+// top-level meta of the document. Lists without meta are returned untouched.
+func withListMeta[T any](list jsonapi.ListResponse[T], meta map[string]any) jsonapi.ListResponse[T] {
+	if len(meta) == 0 {
+		return list
+	}
+
+	m := jsonapi.Meta(meta)
+	if paged, ok := list.(*pagedListResponse[T]); ok {
+		return &pagedMetaListResponse[T]{pagedListResponse: paged, meta: &m}
+	}
+
+	return &metaListResponse[T]{ListResponse: list, meta: &m}
+}
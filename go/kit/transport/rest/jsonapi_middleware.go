@@ -3,6 +3,7 @@ package rest
 import (
 	"context"
 	"net/http"
+	"net/url"
 	"strings"
 )
 
@@ -45,3 +46,26 @@ func GetJSONAPIIncludes(ctx context.Context) []string {
 	}
 	return nil
 }
+
+type requestURLContextKeyType int
+
+const (
+	requestURLCtxKey requestURLContextKeyType = iota
+)
+
+// withRequestURL stores the request URL in the context so encoders can build
+// pagination links relative to it.
+func withRequestURL(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := context.WithValue(r.Context(), requestURLCtxKey, r.URL)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// getRequestURL extracts the request URL from the context
+func getRequestURL(ctx context.Context) *url.URL {
+	if u, ok := ctx.Value(requestURLCtxKey).(*url.URL); ok {
+		return u
+	}
+	return nil
+}
@@ -0,0 +1,44 @@
+package rest
+
+import (
+	"context"
+	"net/url"
+
+	"github.com/dosanma1/forge/go/kit/jsonapi"
+	"github.com/dosanma1/forge/go/kit/resource"
+	"github.com/dosanma1/forge/go/kit/search/query"
+)
+
+// pagedListResponse decorates a JSON:API list with the top-level pagination
+// links of a keyset paginated response.
+type pagedListResponse[T any] struct {
+	jsonapi.ListResponse[T]
+	links *jsonapi.Links
+}
+
+func (p *pagedListResponse[T]) JSONAPILinks() *jsonapi.Links {
+	return p.links
+}
+
+// withPageLinks adds self, next and prev links when the list carries keyset
+// cursors. Lists without cursors are returned untouched.
+func withPageLinks[T any](ctx context.Context, list jsonapi.ListResponse[T], pagination *resource.PaginationDTO) jsonapi.ListResponse[T] {
+	reqURL := getRequestURL(ctx)
+	if reqURL == nil || pagination == nil || (pagination.NextCursor == "" && pagination.PrevCursor == "") {
+		return list
+	}
+
+	// links are relative to the host the request was sent to
+	relURL := &url.URL{Path: reqURL.Path, RawQuery: reqURL.RawQuery}
+	links := jsonapi.Links{
+		jsonapi.KeySelfLink: reqURL.RequestURI(),
+	}
+	if pagination.NextCursor != "" {
+		links[jsonapi.KeyNextPage] = query.PageURL(relURL, query.CursorAfter, pagination.NextCursor)
+	}
+	if pagination.PrevCursor != "" {
+		links[jsonapi.KeyPreviousPage] = query.PageURL(relURL, query.CursorBefore, pagination.PrevCursor)
+	}
+
+	return &pagedListResponse[T]{ListResponse: list, links: &links}
+}
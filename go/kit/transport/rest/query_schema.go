@@ -0,0 +1,114 @@
+package rest
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/swaggest/jsonschema-go"
+	"github.com/swaggest/openapi-go"
+
+	"github.com/dosanma1/forge/go/kit/filter"
+	"github.com/dosanma1/forge/go/kit/search/query"
+)
+
+// QuerySchemaParams documents the filter[field][op] and sort query
+// parameters accepted by a list endpoint validating its queries with s.
+func QuerySchemaParams(s *query.Schema) func(oc openapi.OperationContext) error {
+	return func(oc openapi.OperationContext) error {
+		req := jsonschema.Struct{}
+		sortable := []string{}
+		for _, f := range s.Fields() {
+			if f.Sortable {
+				sortable = append(sortable, f.Name, "-"+f.Name)
+			}
+			for _, op := range f.Operators {
+				name := fmt.Sprintf("filter[%s][%s]", f.Name, query.MarshalOperator(op))
+				tag := fmt.Sprintf(`query:%q description:%q`, name, filterParamDescription(f, op))
+				if f.Required {
+					tag += ` required:"true"`
+				}
+				if f.Type == query.FieldTypeUUID && !textParam(op) {
+					tag += ` format:"uuid"`
+				}
+				req.Fields = append(req.Fields, jsonschema.Field{
+					Name:  fmt.Sprintf("F%d", len(req.Fields)),
+					Tag:   reflect.StructTag(tag),
+					Value: filterParamValue(f.Type, op),
+				})
+			}
+		}
+		if len(sortable) > 0 {
+			req.Fields = append(req.Fields, jsonschema.Field{
+				Name: fmt.Sprintf("F%d", len(req.Fields)),
+				Tag: reflect.StructTag(fmt.Sprintf(
+					`query:"sort" description:%q`,
+					"Comma separated fields to sort by, descending when prefixed by -: "+strings.Join(sortable, ", "),
+				)),
+				Value: "",
+			})
+		}
+		if len(req.Fields) > 0 {
+			oc.AddReqStructure(req)
+		}
+
+		return nil
+	}
+}
+
+// AnnotateQuery documents the query parameters of the list operation at
+// method and pattern from s, see QuerySchemaParams.
+func (c *Collector) AnnotateQuery(method, pattern string, s *query.Schema) {
+	c.AnnotateOperation(method, pattern, QuerySchemaParams(s))
+}
+
+func filterParamDescription(f query.FieldRule, op filter.Operator) string {
+	desc := fmt.Sprintf("Filter %s by %s", f.Name, query.MarshalOperator(op))
+	switch op {
+	case filter.OpIn, filter.OpNotIn, filter.OpContains, filter.OpContainsLike,
+		filter.OpHasAnyKeys, filter.OpHasAllKeys:
+		desc += ", comma separated values"
+	case filter.OpBetween:
+		desc += ", two comma separated bounds"
+	case filter.OpIs, filter.OpIsNot:
+		desc += ", null, true or false"
+	}
+
+	return fmt.Sprintf("%s (%s).", desc, f.Type)
+}
+
+// textParam reports whether the parameter of op is free text rather than a
+// single field value, e.g. comma separated lists or patterns.
+func textParam(op filter.Operator) bool {
+	switch op {
+	case filter.OpIn, filter.OpNotIn, filter.OpContains, filter.OpContainsLike, filter.OpBetween,
+		filter.OpIs, filter.OpIsNot,
+		filter.OpLike, filter.OpSearch, filter.OpFuzzy,
+		filter.OpJSONContains, filter.OpHasKey, filter.OpHasAnyKeys, filter.OpHasAllKeys, filter.OpJSONPath:
+		return true
+	default:
+		return false
+	}
+}
+
+// filterParamValue returns the sample value whose schema documents the
+// parameter.
+func filterParamValue(ft query.FieldType, op filter.Operator) any {
+	if textParam(op) {
+		return ""
+	}
+
+	switch ft {
+	case query.FieldTypeInt:
+		return 0
+	case query.FieldTypeFloat:
+		return float64(0)
+	case query.FieldTypeBool:
+		return false
+	case query.FieldTypeTime:
+		return time.Time{}
+	default:
+		return ""
+	}
+}
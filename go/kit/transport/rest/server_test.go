@@ -4,14 +4,23 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"iter"
 	"net/http"
 	"net/http/httptest"
 	"strings"
 	"testing"
+	"time"
 
+	"github.com/dosanma1/forge/go/kit/application/ctrl/ctrltest"
+	apierrors "github.com/dosanma1/forge/go/kit/errors"
+	"github.com/dosanma1/forge/go/kit/resource"
+	"github.com/dosanma1/forge/go/kit/search/query"
 	"github.com/dosanma1/forge/go/kit/transport/rest"
+	"github.com/dosanma1/forge/go/kit/validation"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
 	"github.com/stretchr/testify/require"
+	"github.com/swaggest/openapi-go/openapi3"
 )
 
 type testRequest struct {
@@ -189,6 +198,37 @@ func TestHandlerDecodingError(t *testing.T) {
 	assert.Equal(t, http.StatusInternalServerError, w.Code)
 }
 
+func TestJsonApiErrorEncoderPointers(t *testing.T) {
+	type author struct {
+		ID string `jsonapi:"primary,people"`
+	}
+	type article struct {
+		ID     string  `jsonapi:"primary,articles" validate:"uuid"`
+		Title  string  `jsonapi:"attr,title" validate:"required"`
+		Author *author `jsonapi:"rel,author" validate:"required"`
+	}
+
+	w := httptest.NewRecorder()
+	rest.JsonApiErrorEncoder(context.Background(), validation.Struct(article{ID: "1"}), w)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	var body struct {
+		Errors []struct {
+			Source *struct {
+				Pointer string `json:"pointer"`
+			} `json:"source"`
+		} `json:"errors"`
+	}
+	require.NoError(t, json.NewDecoder(w.Body).Decode(&body))
+	var pointers []string
+	for _, e := range body.Errors {
+		if e.Source != nil {
+			pointers = append(pointers, e.Source.Pointer)
+		}
+	}
+	assert.ElementsMatch(t, []string{"/data/id", "/data/attributes/title", "/data/relationships/author"}, pointers)
+}
+
 // TestJSONEncoder tests JSON response encoding
 func TestJSONEncoder(t *testing.T) {
 	encoder := rest.RestJSONEncoder(
@@ -210,3 +250,253 @@ func TestJSONEncoder(t *testing.T) {
 	require.NoError(t, err)
 	assert.Equal(t, resp, decoded)
 }
+
+func TestJsonApiAggregateHandler(t *testing.T) {
+	aggregator := ctrltest.NewAggregator(t)
+	aggregator.EXPECT().Aggregate(mock.Anything, mock.Anything).
+		RunAndReturn(func(_ context.Context, opts []query.Option) ([]query.Bucket, error) {
+			agg := query.New(opts...).Aggregation()
+			require.NotNil(t, agg)
+			assert.Equal(t, []query.Metric{query.Count(), query.Sum("amount")}, agg.Metrics)
+			assert.Equal(t, []query.GroupKey{{Field: "status"}}, agg.GroupBy)
+
+			return []query.Bucket{
+				{Keys: map[string]any{"status": "open"}, Metrics: map[string]any{"count": int64(2), "sum_amount": 10.5}},
+			}, nil
+		})
+
+	req := httptest.NewRequest(http.MethodGet, "/orders/aggregate?aggregate=count,sum:amount&group=status", nil)
+	w := httptest.NewRecorder()
+	rest.NewJsonApiAggregateHandler(aggregator).ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t,
+		`{"meta":{"buckets":[{"keys":{"status":"open"},"metrics":{"count":2,"sum_amount":10.5}}]}}`,
+		w.Body.String(),
+	)
+}
+
+func TestJsonApiListHandlerMeta(t *testing.T) {
+	lister := ctrltest.NewLister[*resource.RestDTO](t)
+	lister.EXPECT().List(mock.Anything, mock.Anything).
+		RunAndReturn(func(_ context.Context, opts []query.Option) (resource.ListResponse[*resource.RestDTO], error) {
+			q := query.New(opts...)
+			assert.Equal(t, []string{query.SortRelevance}, q.Sorting().Keys())
+			require.NotNil(t, query.SearchFilter(q))
+			assert.Equal(t, "red fox, quick", query.SearchFilter(q).Value())
+
+			return resource.NewListResponse(
+				[]*resource.RestDTO{{RID: "1", RType: "articles"}}, 1,
+				resource.WithMeta(query.MetaSnippets, map[string]map[string]string{"1": {"title": "the <mark>red</mark> fox"}}),
+			), nil
+		})
+
+	req := httptest.NewRequest(http.MethodGet, "/articles?filter[text][search]=red+fox,+quick&sort=-relevance", nil)
+	w := httptest.NewRecorder()
+	rest.NewJsonApiListHandler(lister, func(r *resource.RestDTO) *resource.RestDTO { return r }).ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	var doc struct {
+		Meta map[string]any `json:"meta"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &doc))
+	assert.Equal(t, map[string]any{"1": map[string]any{"title": "the <mark>red</mark> fox"}}, doc.Meta[query.MetaSnippets])
+}
+
+func TestStreamHandler(t *testing.T) {
+	rows := []*resource.RestDTO{{RID: "1", RType: "articles"}, {RID: "2", RType: "articles"}}
+	seq := func(items []*resource.RestDTO, err error) iter.Seq2[*resource.RestDTO, error] {
+		return func(yield func(*resource.RestDTO, error) bool) {
+			for _, item := range items {
+				if !yield(item, nil) {
+					return
+				}
+			}
+			if err != nil {
+				yield(nil, err)
+			}
+		}
+	}
+	columns := []rest.ExportColumn[*resource.RestDTO]{
+		rest.Column("id", func(r *resource.RestDTO) any { return r.RID }),
+		rest.Column("type", func(r *resource.RestDTO) any { return r.RType }),
+	}
+
+	schema := query.MustSchemaOf[testArticleDTO]()
+	timeout := apierrors.Timeout("statement timeout on articles_pkey")
+
+	tests := []struct {
+		name      string
+		rawQuery  string
+		accept    string
+		columns   []rest.ExportColumn[*resource.RestDTO]
+		items     []*resource.RestDTO
+		err       error
+		wantCode  int
+		wantType  string
+		wantBody  string
+		wantErr   error
+		skipCalls bool
+	}{
+		{
+			name:     "ndjson with columns",
+			columns:  columns,
+			items:    rows,
+			wantCode: http.StatusOK,
+			wantType: rest.ContentTypeNDJSON,
+			wantBody: "{\"id\":\"1\",\"type\":\"articles\"}\n{\"id\":\"2\",\"type\":\"articles\"}\n",
+		},
+		{
+			name:     "csv",
+			accept:   "text/csv",
+			columns:  columns,
+			items:    rows,
+			wantCode: http.StatusOK,
+			wantType: "text/csv; charset=utf-8",
+			wantBody: "id,type\n1,articles\n2,articles\n",
+		},
+		{
+			name:     "empty csv keeps the header",
+			accept:   "text/csv",
+			columns:  columns,
+			wantCode: http.StatusOK,
+			wantType: "text/csv; charset=utf-8",
+			wantBody: "id,type\n",
+		},
+		{
+			name:     "error before the first row",
+			columns:  columns,
+			err:      apierrors.InvalidArgument("bad filter"),
+			wantCode: http.StatusBadRequest,
+			wantType: "application/vnd.api+json",
+		},
+		{
+			name:     "error after the first row",
+			columns:  columns,
+			items:    rows[:1],
+			err:      assert.AnError,
+			wantCode: http.StatusOK,
+			wantType: rest.ContentTypeNDJSON,
+			wantBody: "{\"id\":\"1\",\"type\":\"articles\"}\n{\"code\":\"INTERNAL_ERROR\",\"error\":\"export failed\"}\n",
+			wantErr:  assert.AnError,
+		},
+		{
+			name:     "coded error after the first row",
+			columns:  columns,
+			items:    rows[:1],
+			err:      timeout,
+			wantCode: http.StatusOK,
+			wantType: rest.ContentTypeNDJSON,
+			wantBody: "{\"id\":\"1\",\"type\":\"articles\"}\n{\"code\":\"TIMEOUT\",\"error\":\"export failed\"}\n",
+			wantErr:  timeout,
+		},
+		{
+			name:      "query outside the schema",
+			rawQuery:  "filter[body][eq]=x",
+			columns:   columns,
+			wantCode:  http.StatusBadRequest,
+			wantType:  "application/vnd.api+json",
+			skipCalls: true,
+		},
+		{
+			name:      "filter value of the wrong type",
+			rawQuery:  "filter[views][gte]=many",
+			columns:   columns,
+			wantCode:  http.StatusBadRequest,
+			wantType:  "application/vnd.api+json",
+			skipCalls: true,
+		},
+		{
+			name:      "csv without columns",
+			accept:    "text/csv",
+			wantCode:  http.StatusNotAcceptable,
+			wantType:  "application/vnd.api+json",
+			skipCalls: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			streamer := ctrltest.NewStreamer[*resource.RestDTO](t)
+			if !tt.skipCalls {
+				streamer.EXPECT().Stream(mock.Anything, mock.Anything).Return(seq(tt.items, tt.err))
+			}
+
+			req := httptest.NewRequest(http.MethodGet, "/articles/export?"+tt.rawQuery, nil)
+			if tt.accept != "" {
+				req.Header.Set("Accept", tt.accept)
+			}
+			w := httptest.NewRecorder()
+			var handled error
+			rest.NewStreamHandler(streamer, schema, tt.columns,
+				rest.StreamFlushEvery(1),
+				rest.StreamWithErrorHandler(errorHandlerFunc(func(_ context.Context, err error) { handled = err })),
+			).ServeHTTP(w, req)
+
+			assert.Equal(t, tt.wantCode, w.Code)
+			assert.Equal(t, tt.wantType, w.Header().Get("Content-Type"))
+			if tt.wantBody != "" {
+				assert.Equal(t, tt.wantBody, w.Body.String())
+			}
+			assert.Equal(t, tt.wantErr, handled)
+		})
+	}
+}
+
+type errorHandlerFunc func(ctx context.Context, err error)
+
+func (f errorHandlerFunc) Handle(ctx context.Context, err error) {
+	f(ctx, err)
+}
+
+type testArticleDTO struct {
+	ID        string    `jsonapi:"primary,articles" search:"filter=eq,in;type=uuid"`
+	Title     string    `jsonapi:"attr,title" search:"filter=eq,like,search;sort"`
+	Views     int       `jsonapi:"attr,views" search:"filter=gte,lte;sort;column=view_count"`
+	Draft     bool      `jsonapi:"attr,draft" search:"filter"`
+	CreatedAt time.Time `jsonapi:"attr,createdAt" search:"filter=gte,lte;sort"`
+	Body      string    `jsonapi:"attr,body"`
+}
+
+func TestQuerySchemaParams(t *testing.T) {
+	schema := query.MustSchemaOf[testArticleDTO]()
+
+	c := rest.NewCollector(openapi3.NewReflector())
+	c.AnnotateQuery(http.MethodGet, "/articles", schema)
+	require.NoError(t, c.CollectOperation(http.MethodGet, "/articles"))
+
+	spec, err := json.Marshal(c.SpecSchema())
+	require.NoError(t, err)
+	doc := struct {
+		Paths map[string]map[string]struct {
+			Parameters []struct {
+				Name   string `json:"name"`
+				In     string `json:"in"`
+				Schema struct {
+					Type   string `json:"type"`
+					Format string `json:"format"`
+				} `json:"schema"`
+			} `json:"parameters"`
+		} `json:"paths"`
+	}{}
+	require.NoError(t, json.Unmarshal(spec, &doc))
+
+	params := map[string]string{}
+	for _, p := range doc.Paths["/articles"]["get"].Parameters {
+		assert.Equal(t, "query", p.In)
+		params[p.Name] = p.Schema.Type + p.Schema.Format
+	}
+	assert.Equal(t, map[string]string{
+		"filter[id][eq]":         "stringuuid",
+		"filter[id][in]":         "string",
+		"filter[title][eq]":      "string",
+		"filter[title][like]":    "string",
+		"filter[title][search]":  "string",
+		"filter[views][gte]":     "integer",
+		"filter[views][lte]":     "integer",
+		"filter[draft][eq]":      "boolean",
+		"filter[createdAt][gte]": "stringdate-time",
+		"filter[createdAt][lte]": "stringdate-time",
+		"sort":                   "string",
+	}, params)
+}
@@ -0,0 +1,307 @@
+package rest
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/dosanma1/forge/go/kit/application/ctrl"
+	"github.com/dosanma1/forge/go/kit/errors"
+	"github.com/dosanma1/forge/go/kit/resource"
+	"github.com/dosanma1/forge/go/kit/search/query"
+	"github.com/dosanma1/forge/go/kit/transport"
+)
+
+const (
+	ContentTypeNDJSON = "application/x-ndjson"
+	ContentTypeCSV    = "text/csv"
+
+	defaultStreamFlushEvery = 100
+)
+
+// ExportColumn maps a resource to a CSV column or to a member of the NDJSON
+// objects of an export.
+type ExportColumn[R any] struct {
+	Name  string
+	Value func(R) any
+}
+
+func Column[R any](name string, value func(R) any) ExportColumn[R] {
+	return ExportColumn[R]{Name: name, Value: value}
+}
+
+type (
+	StreamHandlerOpt    func(c *streamHandlerConfig)
+	streamHandlerConfig struct {
+		flushEvery   int
+		errorEncoder ErrorEncoder
+		errorHandler transport.ErrorHandler
+	}
+)
+
+// StreamFlushEvery flushes the response every rows rows so that clients
+// receive the export progressively. Defaults to 100.
+func StreamFlushEvery(rows int) StreamHandlerOpt {
+	return func(c *streamHandlerConfig) {
+		if rows > 0 {
+			c.flushEvery = rows
+		}
+	}
+}
+
+// StreamWithErrorEncoder encodes the errors happening before the first row
+// is written. Defaults to JsonApiErrorEncoder.
+func StreamWithErrorEncoder(ee ErrorEncoder) StreamHandlerOpt {
+	return func(c *streamHandlerConfig) {
+		c.errorEncoder = ee
+	}
+}
+
+// StreamWithErrorHandler handles the errors ending an export after its
+// first row, which clients only see as a generic error. Defaults to logging
+// them.
+func StreamWithErrorHandler(eh transport.ErrorHandler) StreamHandlerOpt {
+	return func(c *streamHandlerConfig) {
+		c.errorHandler = eh
+	}
+}
+
+type logStreamErrorHandler struct{}
+
+func (logStreamErrorHandler) Handle(_ context.Context, err error) {
+	log.Printf("REST STREAM ERROR: %v", err)
+}
+
+type streamHandler[R resource.Resource, C ctrl.Streamer[R]] struct {
+	streamer C
+	schema   *query.Schema
+	columns  []ExportColumn[R]
+	streamHandlerConfig
+}
+
+// NewStreamHandler exports the resources matching the query parameters as
+// CSV when the Accept header asks for text/csv and as NDJSON otherwise. The
+// query parameters are validated against the schema of the resource before
+// streaming. Rows are written as they are read, so exports do not hold the
+// whole result in memory. NDJSON rows hold the columns, or the JSON encoding
+// of the resource when no columns are given; CSV exports require columns.
+//
+// Once the first row is written the status can no longer change: a later
+// failure ends NDJSON exports with an {"error": "export failed", "code":
+// "..."} line, the code being the one of the errors package, and truncates
+// CSV exports. The failure itself goes to the error handler.
+func NewStreamHandler[R resource.Resource, C ctrl.Streamer[R]](
+	streamer C, schema *query.Schema, columns []ExportColumn[R], opts ...StreamHandlerOpt,
+) http.Handler {
+	h := &streamHandler[R, C]{
+		streamer: streamer,
+		schema:   schema,
+		columns:  columns,
+		streamHandlerConfig: streamHandlerConfig{
+			flushEvery:   defaultStreamFlushEvery,
+			errorEncoder: JsonApiErrorEncoder,
+			errorHandler: logStreamErrorHandler{},
+		},
+	}
+	for _, opt := range opts {
+		opt(&h.streamHandlerConfig)
+	}
+
+	return h
+}
+
+func (h *streamHandler[R, C]) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	opts, err := query.ParseOptsFromHTTPReq(r, query.SkipDefaultPagination())
+	if err != nil {
+		h.errorEncoder(ctx, err, w)
+		return
+	}
+	if err := h.schema.Validate(query.New(opts...)); err != nil {
+		h.errorEncoder(ctx, err, w)
+		return
+	}
+
+	csvExport := strings.Contains(r.Header.Get("Accept"), ContentTypeCSV)
+	if csvExport && len(h.columns) == 0 {
+		h.errorEncoder(ctx, errors.InvalidArgument(
+			"csv export is not available for this resource", errors.WithHTTPStatus(http.StatusNotAcceptable),
+		), w)
+		return
+	}
+	var ew exportWriter[R]
+	if csvExport {
+		ew = &csvExportWriter[R]{w: csv.NewWriter(w), columns: h.columns}
+	} else {
+		ew = &ndjsonExportWriter[R]{w: w, columns: h.columns}
+	}
+
+	rc := http.NewResponseController(w)
+	written := 0
+	start := func() error {
+		w.Header().Set("Content-Type", ew.contentType())
+		w.WriteHeader(http.StatusOK)
+		return ew.begin()
+	}
+	for res, err := range h.streamer.Stream(ctx, opts) {
+		if err != nil {
+			if written == 0 {
+				h.errorEncoder(ctx, err, w)
+				return
+			}
+			h.errorHandler.Handle(ctx, err)
+			ew.fail(err)
+			_ = rc.Flush()
+			return
+		}
+		if written == 0 {
+			if start() != nil {
+				return
+			}
+		}
+		if ew.write(res) != nil {
+			return
+		}
+		written++
+		if written%h.flushEvery == 0 {
+			if ew.flush() != nil {
+				return
+			}
+			_ = rc.Flush()
+		}
+	}
+	if written == 0 && start() != nil {
+		return
+	}
+	_ = ew.flush()
+	_ = rc.Flush()
+}
+
+type exportWriter[R any] interface {
+	contentType() string
+	begin() error
+	write(res R) error
+	flush() error
+	fail(err error)
+}
+
+type ndjsonExportWriter[R any] struct {
+	w       io.Writer
+	columns []ExportColumn[R]
+}
+
+func (ew *ndjsonExportWriter[R]) contentType() string {
+	return ContentTypeNDJSON
+}
+
+func (ew *ndjsonExportWriter[R]) begin() error {
+	return nil
+}
+
+// write renders the columns in their order, which a map would not keep.
+func (ew *ndjsonExportWriter[R]) write(res R) error {
+	if len(ew.columns) == 0 {
+		return json.NewEncoder(ew.w).Encode(res)
+	}
+
+	line := bytes.Buffer{}
+	line.WriteByte('{')
+	for i, col := range ew.columns {
+		if i > 0 {
+			line.WriteByte(',')
+		}
+		name, err := json.Marshal(col.Name)
+		if err != nil {
+			return err
+		}
+		value, err := json.Marshal(col.Value(res))
+		if err != nil {
+			return err
+		}
+		line.Write(name)
+		line.WriteByte(':')
+		line.Write(value)
+	}
+	line.WriteString("}\n")
+	_, err := ew.w.Write(line.Bytes())
+
+	return err
+}
+
+func (ew *ndjsonExportWriter[R]) flush() error {
+	return nil
+}
+
+// fail ends the export with the code of err only, its message may hold
+// details of the storage.
+func (ew *ndjsonExportWriter[R]) fail(err error) {
+	code := errors.CodeInternalError
+	if apiErr, ok := errors.As(err); ok {
+		code = apiErr.Code()
+	}
+	_ = json.NewEncoder(ew.w).Encode(map[string]string{"error": "export failed", "code": code.String()})
+}
+
+type csvExportWriter[R any] struct {
+	w       *csv.Writer
+	columns []ExportColumn[R]
+}
+
+func (ew *csvExportWriter[R]) contentType() string {
+	return ContentTypeCSV + "; charset=utf-8"
+}
+
+func (ew *csvExportWriter[R]) begin() error {
+	header := make([]string, len(ew.columns))
+	for i, col := range ew.columns {
+		header[i] = col.Name
+	}
+	return ew.w.Write(header)
+}
+
+func (ew *csvExportWriter[R]) write(res R) error {
+	record := make([]string, len(ew.columns))
+	for i, col := range ew.columns {
+		record[i] = csvValue(col.Value(res))
+	}
+	return ew.w.Write(record)
+}
+
+func (ew *csvExportWriter[R]) flush() error {
+	ew.w.Flush()
+	return ew.w.Error()
+}
+
+func (ew *csvExportWriter[R]) fail(error) {
+	ew.w.Flush()
+}
+
+func csvValue(v any) string {
+	switch val := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return val
+	case []byte:
+		return string(val)
+	case time.Time:
+		return val.Format(time.RFC3339Nano)
+	case *time.Time:
+		if val == nil {
+			return ""
+		}
+		return val.Format(time.RFC3339Nano)
+	case fmt.Stringer:
+		return val.String()
+	default:
+		return fmt.Sprint(val)
+	}
+}
@@ -0,0 +1,304 @@
+package validation
+
+import (
+	"fmt"
+	"net/mail"
+	"net/url"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/google/uuid"
+
+	"github.com/dosanma1/forge/go/kit/errors"
+)
+
+func builtinRules() map[string]Rule {
+	return map[string]Rule{
+		"required":         required,
+		"required_with":    requiredWith,
+		"required_without": requiredWithout,
+		"required_if":      requiredIf,
+		"min":              sizeRule(func(c int) bool { return c >= 0 }, "must be at least %s"),
+		"max":              sizeRule(func(c int) bool { return c <= 0 }, "must be at most %s"),
+		"len":              sizeRule(func(c int) bool { return c == 0 }, "must be exactly %s"),
+		"gt":               sizeRule(func(c int) bool { return c > 0 }, "must be greater than %s"),
+		"gte":              sizeRule(func(c int) bool { return c >= 0 }, "must be greater than or equal to %s"),
+		"lt":               sizeRule(func(c int) bool { return c < 0 }, "must be less than %s"),
+		"lte":              sizeRule(func(c int) bool { return c <= 0 }, "must be less than or equal to %s"),
+		"eq":               equals(true),
+		"ne":               equals(false),
+		"oneof":            oneOf,
+		"email":            formatRule("email", isEmail),
+		"url":              formatRule("url", isURL),
+		"uuid":             formatRule("uuid", isUUID),
+		"alpha":            formatRule("alpha", runesMatch(unicode.IsLetter)),
+		"alphanum":         formatRule("alphanum", runesMatch(isAlphaNum)),
+		"numeric":          formatRule("numeric", isNumeric),
+		"eqfield":          fieldRule(func(c int) bool { return c == 0 }, "must be equal to %s"),
+		"nefield":          fieldRule(func(c int) bool { return c != 0 }, "must not be equal to %s"),
+		"gtfield":          fieldRule(func(c int) bool { return c > 0 }, "must be greater than %s"),
+		"gtefield":         fieldRule(func(c int) bool { return c >= 0 }, "must be greater than or equal to %s"),
+		"ltfield":          fieldRule(func(c int) bool { return c < 0 }, "must be less than %s"),
+		"ltefield":         fieldRule(func(c int) bool { return c <= 0 }, "must be less than or equal to %s"),
+	}
+}
+
+func builtinPresenceRules() map[string]bool {
+	return map[string]bool{
+		"required":         true,
+		"required_with":    true,
+		"required_without": true,
+		"required_if":      true,
+	}
+}
+
+func violation(code errors.Code, format string, args ...any) error {
+	return errors.New(code, errors.WithMessage(fmt.Sprintf(format, args...)))
+}
+
+func missing() error {
+	return violation(errors.CodeMissingField, "This field is required")
+}
+
+func required(f Field) error {
+	if !f.Present {
+		return missing()
+	}
+	return nil
+}
+
+// requiredWith: "required_with=Other" fails when Other is present and the field is not.
+func requiredWith(f Field) error {
+	if _, ok := f.Sibling(f.Param); ok && !f.Present {
+		return missing()
+	}
+	return nil
+}
+
+// requiredWithout: "required_without=Other" fails when neither field is present.
+func requiredWithout(f Field) error {
+	if _, ok := f.Sibling(f.Param); !ok && !f.Present {
+		return missing()
+	}
+	return nil
+}
+
+// requiredIf: "required_if=Other value" fails when Other equals value and the field is not present.
+func requiredIf(f Field) error {
+	name, want, _ := strings.Cut(f.Param, " ")
+	other, ok := f.Sibling(name)
+	if ok && fmt.Sprint(other.Interface()) == want && !f.Present {
+		return missing()
+	}
+	return nil
+}
+
+// sizeRule compares lengths for strings, slices and maps and values for numbers.
+func sizeRule(accept func(cmp int) bool, msg string) Rule {
+	return func(f Field) error {
+		cmp, err := compareParam(f.Value, f.Param)
+		if err != nil {
+			return err
+		}
+		if accept(cmp) {
+			return nil
+		}
+		switch f.Value.Kind() {
+		case reflect.String:
+			return violation(errors.CodeOutOfRange, msg+" characters", f.Param)
+		case reflect.Slice, reflect.Array, reflect.Map:
+			return violation(errors.CodeOutOfRange, msg+" items", f.Param)
+		}
+		return violation(errors.CodeOutOfRange, msg, f.Param)
+	}
+}
+
+func compareParam(v reflect.Value, param string) (int, error) {
+	switch v.Kind() {
+	case reflect.String:
+		n, err := strconv.Atoi(param)
+		if err != nil {
+			return 0, invalidParam(param)
+		}
+		return compareInt(int64(utf8.RuneCountInString(v.String())), int64(n)), nil
+	case reflect.Slice, reflect.Array, reflect.Map:
+		n, err := strconv.Atoi(param)
+		if err != nil {
+			return 0, invalidParam(param)
+		}
+		return compareInt(int64(v.Len()), int64(n)), nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := strconv.ParseInt(param, 10, 64)
+		if err != nil {
+			return 0, invalidParam(param)
+		}
+		return compareInt(v.Int(), n), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n, err := strconv.ParseUint(param, 10, 64)
+		if err != nil {
+			return 0, invalidParam(param)
+		}
+		return compareUint(v.Uint(), n), nil
+	case reflect.Float32, reflect.Float64:
+		n, err := strconv.ParseFloat(param, 64)
+		if err != nil {
+			return 0, invalidParam(param)
+		}
+		return compareFloat(v.Float(), n), nil
+	}
+
+	return 0, errors.InternalError(fmt.Sprintf("validation: cannot compare %s", v.Kind()))
+}
+
+func invalidParam(param string) error {
+	return errors.InternalError(fmt.Sprintf("validation: invalid rule parameter %q", param))
+}
+
+func equals(want bool) Rule {
+	return func(f Field) error {
+		if (fmt.Sprint(f.Value.Interface()) == f.Param) == want {
+			return nil
+		}
+		if want {
+			return violation(errors.CodeInvalidArgument, "must be equal to %s", f.Param)
+		}
+		return violation(errors.CodeInvalidArgument, "must not be equal to %s", f.Param)
+	}
+}
+
+// oneOf: "oneof=a b c" accepts any of the space separated values.
+func oneOf(f Field) error {
+	allowed := strings.Fields(f.Param)
+	got := fmt.Sprint(f.Value.Interface())
+	for _, a := range allowed {
+		if a == got {
+			return nil
+		}
+	}
+
+	return violation(errors.CodeInvalidArgument, "must be one of [%s]", strings.Join(allowed, ", "))
+}
+
+func formatRule(format string, valid func(string) bool) Rule {
+	return func(f Field) error {
+		if f.Value.Kind() != reflect.String {
+			return errors.InternalError(fmt.Sprintf("validation: %s rule requires a string, got %s", format, f.Value.Kind()))
+		}
+		if valid(f.Value.String()) {
+			return nil
+		}
+		return violation(errors.CodeInvalidFormat, "Invalid format (expected: %s)", format)
+	}
+}
+
+func isEmail(s string) bool {
+	addr, err := mail.ParseAddress(s)
+	return err == nil && addr.Address == s
+}
+
+func isURL(s string) bool {
+	u, err := url.ParseRequestURI(s)
+	return err == nil && u.Scheme != "" && u.Host != ""
+}
+
+func isUUID(s string) bool {
+	return uuid.Validate(s) == nil
+}
+
+func isNumeric(s string) bool {
+	_, err := strconv.ParseFloat(s, 64)
+	return err == nil
+}
+
+func isAlphaNum(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r)
+}
+
+func runesMatch(match func(rune) bool) func(string) bool {
+	return func(s string) bool {
+		if s == "" {
+			return false
+		}
+		for _, r := range s {
+			if !match(r) {
+				return false
+			}
+		}
+		return true
+	}
+}
+
+// fieldRule compares the field against a sibling field named by the rule
+// parameter, e.g. "gtfield=StartsAt". Nil or null siblings are not compared.
+func fieldRule(accept func(cmp int) bool, msg string) Rule {
+	return func(f Field) error {
+		other, _ := f.Sibling(f.Param)
+		if !other.IsValid() {
+			return nil
+		}
+		cmp, err := compareValues(f.Value, other)
+		if err != nil {
+			return err
+		}
+		if accept(cmp) {
+			return nil
+		}
+		return violation(errors.CodeInvalidArgument, msg, f.Param)
+	}
+}
+
+func compareValues(a, b reflect.Value) (int, error) {
+	switch {
+	case a.Type() == timeType && b.Type() == timeType:
+		return a.Interface().(time.Time).Compare(b.Interface().(time.Time)), nil
+	case a.CanInt() && b.CanInt():
+		return compareInt(a.Int(), b.Int()), nil
+	case a.CanUint() && b.CanUint():
+		return compareUint(a.Uint(), b.Uint()), nil
+	case a.CanFloat() && b.CanFloat():
+		return compareFloat(a.Float(), b.Float()), nil
+	case a.Kind() == reflect.String && b.Kind() == reflect.String:
+		return strings.Compare(a.String(), b.String()), nil
+	case a.Type() == b.Type() && a.Comparable():
+		if a.Equal(b) {
+			return 0, nil
+		}
+		return 1, nil
+	}
+
+	return 0, errors.InternalError(fmt.Sprintf("validation: cannot compare %s with %s", a.Type(), b.Type()))
+}
+
+func compareInt(a, b int64) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
+
+func compareUint(a, b uint64) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
+
+func compareFloat(a, b float64) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
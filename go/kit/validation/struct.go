@@ -0,0 +1,478 @@
+package validation
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/dosanma1/forge/go/kit/errors"
+)
+
+const (
+	tagValidate = "validate"
+	tagJSONAPI  = "jsonapi"
+	tagJSON     = "json"
+
+	ruleSeparator  = ","
+	paramSeparator = "="
+	pathSeparator  = "."
+
+	ruleSkip      = "-"
+	ruleOmitEmpty = "omitempty"
+	ruleDive      = "dive"
+)
+
+var timeType = reflect.TypeOf(time.Time{})
+
+// Rule validates a single field. A nil return means the field is valid.
+//
+// When the returned error is an errors.Error, its code and message are used
+// for the resulting detail; any other error is reported as
+// errors.CodeInvalidArgument with the error text as message.
+type Rule func(f Field) error
+
+// Field is the view of a struct field handed to a Rule.
+type Field struct {
+	// Path is the JSON:API path of the field, e.g. "address.street" or "tags.0".
+	Path string
+	// Value is the field value with pointers and jsonapi.NullableAttr unwrapped.
+	// It is invalid when the field holds nil or an explicit null.
+	Value reflect.Value
+	// Present reports whether the field holds a non-nil, non-zero value.
+	Present bool
+	// Param is the rule parameter, e.g. "3" for "min=3".
+	Param string
+
+	parent reflect.Value
+}
+
+// Sibling returns the unwrapped value of another field of the same struct,
+// looked up by its Go name. It is used by cross-field rules.
+func (f Field) Sibling(name string) (reflect.Value, bool) {
+	if !f.parent.IsValid() {
+		return reflect.Value{}, false
+	}
+	sf := f.parent.FieldByName(name)
+	if !sf.IsValid() {
+		return reflect.Value{}, false
+	}
+	return unwrap(sf)
+}
+
+// Validator validates structs using `validate` struct tags.
+type Validator struct {
+	mu     sync.RWMutex
+	rules  map[string]Rule
+	always map[string]bool
+	fields sync.Map // reflect.Type -> []fieldMeta
+}
+
+type Option func(v *Validator)
+
+// WithRule registers a custom rule under the given tag name.
+func WithRule(name string, rule Rule) Option {
+	return func(v *Validator) {
+		v.rules[name] = rule
+	}
+}
+
+// WithPresenceRule registers a custom rule that is evaluated even when the
+// field is empty, the way `required` and `required_with` are.
+func WithPresenceRule(name string, rule Rule) Option {
+	return func(v *Validator) {
+		v.rules[name] = rule
+		v.always[name] = true
+	}
+}
+
+func New(opts ...Option) *Validator {
+	v := &Validator{
+		rules:  builtinRules(),
+		always: builtinPresenceRules(),
+	}
+	for _, opt := range opts {
+		opt(v)
+	}
+
+	return v
+}
+
+// RegisterRule adds or replaces a rule after construction.
+func (v *Validator) RegisterRule(name string, rule Rule) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	v.rules[name] = rule
+}
+
+var defaultValidator = New()
+
+// RegisterRule adds or replaces a rule on the package level validator.
+func RegisterRule(name string, rule Rule) {
+	defaultValidator.RegisterRule(name, rule)
+}
+
+// Struct validates s with the package level validator.
+func Struct(s any) error {
+	return defaultValidator.Struct(s)
+}
+
+// Func adapts a validator to the validation func signature expected by the
+// usecase constructors. A nil validator uses the package level one.
+func Func[R any](v *Validator) func(context.Context, R) error {
+	if v == nil {
+		v = defaultValidator
+	}
+	return func(_ context.Context, r R) error {
+		return v.Struct(r)
+	}
+}
+
+// Struct validates every tagged field of s, descending into nested structs,
+// slices and maps. All failures are collected into a single
+// errors.ValidationFailed whose details carry the JSON:API field paths.
+func (v *Validator) Struct(s any) error {
+	rv := reflect.ValueOf(s)
+	for rv.Kind() == reflect.Pointer || rv.Kind() == reflect.Interface {
+		if rv.IsNil() {
+			return errors.InvalidArgument("request cannot be zero value")
+		}
+		rv = rv.Elem()
+	}
+	if rv.Kind() != reflect.Struct {
+		return errors.InvalidArgument(fmt.Sprintf("validation: expected a struct, got %s", rv.Kind()))
+	}
+
+	var details []errors.Detail
+	if err := v.validateStruct(rv, "", "", &details); err != nil {
+		return err
+	}
+	if len(details) == 0 {
+		return nil
+	}
+
+	return errors.ValidationFailed("Validation failed", errors.WithDetails(details...))
+}
+
+func (v *Validator) validateStruct(rv reflect.Value, prefix, pointer string, details *[]errors.Detail) error {
+	for _, meta := range v.structFields(rv.Type()) {
+		fv, err := rv.FieldByIndexErr(meta.index)
+		if err != nil {
+			// nil embedded pointer, nothing to validate
+			continue
+		}
+		path, fieldPointer := joinPath(prefix, meta.name), joinPointer(pointer, meta.name)
+		if prefix == "" {
+			fieldPointer = meta.pointer
+		}
+		if err := v.validateValue(fv, path, fieldPointer, meta.rules, meta.elemRules, rv, details); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (v *Validator) validateValue(
+	fv reflect.Value, path, pointer string, rules, elemRules []ruleCall, parent reflect.Value, details *[]errors.Detail,
+) error {
+	val, present := unwrap(fv)
+
+	omitEmpty := false
+	for _, rc := range rules {
+		if rc.name == ruleOmitEmpty {
+			omitEmpty = true
+			continue
+		}
+		rule, always, err := v.lookup(rc.name)
+		if err != nil {
+			return err
+		}
+		if !always && (!val.IsValid() || (omitEmpty && !present)) {
+			continue
+		}
+		ruleErr := rule(Field{Path: path, Value: val, Present: present, Param: rc.param, parent: parent})
+		if apiErr, ok := errors.As(ruleErr); ok && apiErr.Code() == errors.CodeInternalError {
+			return ruleErr
+		}
+		if ruleErr != nil {
+			// report only the first failing rule of a field
+			*details = append(*details, toDetail(path, pointer, val, ruleErr))
+			break
+		}
+	}
+
+	if !val.IsValid() || (omitEmpty && !present) {
+		return nil
+	}
+
+	return v.descend(val, path, pointer, elemRules, details)
+}
+
+func (v *Validator) descend(val reflect.Value, path, pointer string, elemRules []ruleCall, details *[]errors.Detail) error {
+	switch val.Kind() {
+	case reflect.Struct:
+		if val.Type() == timeType {
+			return nil
+		}
+		return v.validateStruct(val, path, pointer, details)
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < val.Len(); i++ {
+			idx := strconv.Itoa(i)
+			err := v.validateValue(
+				val.Index(i), joinPath(path, idx), joinPointer(pointer, idx), elemRules, nil, reflect.Value{}, details,
+			)
+			if err != nil {
+				return err
+			}
+		}
+	case reflect.Map:
+		iter := val.MapRange()
+		for iter.Next() {
+			key := fmt.Sprint(iter.Key().Interface())
+			err := v.validateValue(
+				iter.Value(), joinPath(path, key), joinPointer(pointer, key), elemRules, nil, reflect.Value{}, details,
+			)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+func (v *Validator) lookup(name string) (Rule, bool, error) {
+	v.mu.RLock()
+	defer v.mu.RUnlock()
+	rule, ok := v.rules[name]
+	if !ok {
+		return nil, false, errors.InternalError(fmt.Sprintf("validation: unknown rule %q", name))
+	}
+
+	return rule, v.always[name], nil
+}
+
+type ruleCall struct {
+	name  string
+	param string
+}
+
+type fieldMeta struct {
+	index []int
+	name  string
+	// pointer is the JSON Pointer of the field at the top level of a document
+	pointer   string
+	rules     []ruleCall
+	elemRules []ruleCall
+}
+
+func (v *Validator) structFields(t reflect.Type) []fieldMeta {
+	if cached, ok := v.fields.Load(t); ok {
+		return cached.([]fieldMeta)
+	}
+
+	var metas []fieldMeta
+	for _, sf := range reflect.VisibleFields(t) {
+		if !sf.IsExported() || sf.Anonymous {
+			continue
+		}
+		tag := sf.Tag.Get(tagValidate)
+		if tag == ruleSkip {
+			continue
+		}
+		rules, elemRules := parseTag(tag)
+		if len(rules) == 0 && len(elemRules) == 0 && !mayNest(sf.Type) {
+			continue
+		}
+		name := fieldName(sf)
+		metas = append(metas, fieldMeta{
+			index:     sf.Index,
+			name:      name,
+			pointer:   fieldPointer(sf, name),
+			rules:     rules,
+			elemRules: elemRules,
+		})
+	}
+
+	v.fields.Store(t, metas)
+	return metas
+}
+
+func parseTag(tag string) (rules, elemRules []ruleCall) {
+	if tag == "" {
+		return nil, nil
+	}
+	dive := false
+	for _, part := range strings.Split(tag, ruleSeparator) {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		if part == ruleDive {
+			dive = true
+			continue
+		}
+		name, param, _ := strings.Cut(part, paramSeparator)
+		rc := ruleCall{name: name, param: param}
+		if dive {
+			elemRules = append(elemRules, rc)
+		} else {
+			rules = append(rules, rc)
+		}
+	}
+
+	return rules, elemRules
+}
+
+// fieldName resolves the name a field is exposed with: the JSON:API
+// attribute or relationship name, then the json name, then the Go name.
+func fieldName(sf reflect.StructField) string {
+	if tag, ok := sf.Tag.Lookup(tagJSONAPI); ok {
+		parts := strings.Split(tag, ",")
+		switch {
+		case len(parts) > 0 && parts[0] == "primary":
+			return "id"
+		case len(parts) > 1 && parts[1] != "":
+			return parts[1]
+		}
+	}
+	if tag, ok := sf.Tag.Lookup(tagJSON); ok {
+		name, _, _ := strings.Cut(tag, ",")
+		if name != "" && name != "-" {
+			return name
+		}
+	}
+
+	return sf.Name
+}
+
+// fieldPointer returns the JSON Pointer (RFC6901) of a top level field in a
+// JSON:API document: /data/id for the primary field, /data/relationships/name
+// for relationships and /data/attributes/name otherwise.
+func fieldPointer(sf reflect.StructField, name string) string {
+	tag, _ := sf.Tag.Lookup(tagJSONAPI)
+	kind, _, _ := strings.Cut(tag, ",")
+	// relationships are tagged rel or polyrelation, optionally with a suffix
+	kind, _, _ = strings.Cut(kind, ":")
+	switch kind {
+	case "primary":
+		return "/data/id"
+	case "rel", "polyrelation":
+		return joinPointer("/data/relationships", name)
+	}
+
+	return joinPointer("/data/attributes", name)
+}
+
+func mayNest(t reflect.Type) bool {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	if isNullable(t) {
+		return mayNest(t.Elem())
+	}
+	switch t.Kind() {
+	case reflect.Struct:
+		return t != timeType
+	case reflect.Slice, reflect.Array, reflect.Map:
+		return mayNest(t.Elem())
+	case reflect.Interface:
+		return true
+	}
+
+	return false
+}
+
+// isNullable reports whether t has the shape of jsonapi.NullableAttr or
+// jsonapi.NullableRelationship (map[bool]T).
+func isNullable(t reflect.Type) bool {
+	return t.Kind() == reflect.Map && t.Key().Kind() == reflect.Bool
+}
+
+// unwrap dereferences pointers, interfaces and nullable attributes. The
+// returned value is invalid when nothing is set.
+func unwrap(v reflect.Value) (reflect.Value, bool) {
+	indirect := false
+	for {
+		switch {
+		case !v.IsValid():
+			return v, false
+		case v.Kind() == reflect.Pointer || v.Kind() == reflect.Interface:
+			if v.IsNil() {
+				return reflect.Value{}, false
+			}
+			v = v.Elem()
+			indirect = true
+		case isNullable(v.Type()):
+			val := v.MapIndex(reflect.ValueOf(true))
+			if !val.IsValid() {
+				return reflect.Value{}, false
+			}
+			v = val
+			indirect = true
+		default:
+			if indirect {
+				return v, true
+			}
+			return v, !isEmpty(v)
+		}
+	}
+}
+
+func isEmpty(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.String, reflect.Slice, reflect.Map, reflect.Array:
+		return v.Len() == 0
+	}
+
+	return v.IsZero()
+}
+
+// pointerDetail is a detail that knows the JSON Pointer of its field, which
+// the JSON:API error encoders use as the source of the error.
+type pointerDetail struct {
+	errors.Detail
+	pointer string
+}
+
+// Pointer returns the JSON Pointer (RFC6901) of the field, e.g.
+// /data/attributes/address/street or /data/relationships/author.
+func (d pointerDetail) Pointer() string {
+	return d.pointer
+}
+
+func (d pointerDetail) MarshalJSON() ([]byte, error) {
+	return json.Marshal(d.Detail)
+}
+
+func toDetail(path, pointer string, val reflect.Value, err error) errors.Detail {
+	var value any
+	if val.IsValid() && val.CanInterface() {
+		value = val.Interface()
+	}
+	code, message := errors.CodeInvalidArgument, err.Error()
+	if apiErr, ok := errors.As(err); ok {
+		code, message = apiErr.Code(), apiErr.Message()
+	}
+
+	return pointerDetail{Detail: errors.NewDetail(path, code, message, value), pointer: pointer}
+}
+
+func joinPointer(pointer, segment string) string {
+	return pointer + "/" + pointerEscaper.Replace(segment)
+}
+
+var pointerEscaper = strings.NewReplacer("~", "~0", "/", "~1")
+
+func joinPath(prefix, name string) string {
+	if prefix == "" {
+		return name
+	}
+
+	return prefix + pathSeparator + name
+}
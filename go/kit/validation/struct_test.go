@@ -0,0 +1,241 @@
+package validation_test
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/dosanma1/forge/go/kit/errors"
+	"github.com/dosanma1/forge/go/kit/jsonapi"
+	"github.com/dosanma1/forge/go/kit/validation"
+)
+
+type address struct {
+	Street string `jsonapi:"attr,street" validate:"required"`
+	Zip    string `jsonapi:"attr,zip" validate:"omitempty,numeric,len=5"`
+}
+
+type tag struct {
+	Name string `json:"name" validate:"required,alphanum"`
+}
+
+type user struct {
+	ID        string                               `jsonapi:"primary,users" validate:"omitempty,uuid"`
+	Name      string                               `jsonapi:"attr,name" validate:"required,min=3"`
+	Email     string                               `jsonapi:"attr,email" validate:"required,email"`
+	Role      string                               `jsonapi:"attr,role" validate:"oneof=admin member"`
+	Age       *int                                 `jsonapi:"attr,age" validate:"omitempty,gte=18"`
+	Nickname  jsonapi.NullableAttr[string]         `jsonapi:"attr,nickname" validate:"omitempty,max=5"`
+	Address   *address                             `jsonapi:"attr,address"`
+	Tags      []tag                                `jsonapi:"attr,tags" validate:"max=2"`
+	Scopes    []string                             `jsonapi:"attr,scopes" validate:"dive,oneof=read write"`
+	StartsAt  time.Time                            `jsonapi:"attr,starts_at"`
+	EndsAt    time.Time                            `jsonapi:"attr,ends_at" validate:"gtfield=StartsAt"`
+	Password  string                               `jsonapi:"attr,password" validate:"required_with=Email"`
+	Confirm   string                               `jsonapi:"attr,confirm" validate:"eqfield=Password"`
+	Reason    string                               `jsonapi:"attr,reason" validate:"required_if=Role admin"`
+	Extension jsonapi.NullableAttr[*address]       `jsonapi:"attr,extension"`
+	Labels    map[string]jsonapi.NullableAttr[int] `jsonapi:"attr,labels" validate:"dive,lte=10"`
+}
+
+func validUser() *user {
+	age := 20
+	now := time.Now()
+	return &user{
+		Name:     "alice",
+		Email:    "alice@example.com",
+		Role:     "member",
+		Age:      &age,
+		Nickname: jsonapi.NewNullableAttrWithValue("al"),
+		Address:  &address{Street: "Main", Zip: "12345"},
+		Tags:     []tag{{Name: "one"}},
+		Scopes:   []string{"read"},
+		StartsAt: now,
+		EndsAt:   now.Add(time.Hour),
+		Password: "secret",
+		Confirm:  "secret",
+	}
+}
+
+func detailsByField(t *testing.T, err error) map[string]errors.Code {
+	t.Helper()
+	apiErr, ok := errors.As(err)
+	require.True(t, ok)
+	assert.Equal(t, errors.CodeValidationFailed, apiErr.Code())
+
+	got := map[string]errors.Code{}
+	for _, d := range apiErr.Details() {
+		got[d.Field()] = d.Code()
+	}
+	return got
+}
+
+func TestStruct(t *testing.T) {
+	age := 16
+	tests := []struct {
+		name   string
+		mutate func(u *user)
+		want   map[string]errors.Code
+	}{
+		{
+			name:   "valid struct",
+			mutate: func(u *user) {},
+		},
+		{
+			name: "top level rules use jsonapi attribute names",
+			mutate: func(u *user) {
+				u.ID = "not-a-uuid"
+				u.Name = "al"
+				u.Email = "nope"
+				u.Role = "owner"
+				u.Age = &age
+			},
+			want: map[string]errors.Code{
+				"id":    errors.CodeInvalidFormat,
+				"name":  errors.CodeOutOfRange,
+				"email": errors.CodeInvalidFormat,
+				"role":  errors.CodeInvalidArgument,
+				"age":   errors.CodeOutOfRange,
+			},
+		},
+		{
+			name: "nested structs and slices",
+			mutate: func(u *user) {
+				u.Address = &address{Zip: "12"}
+				u.Tags = []tag{{Name: "ok"}, {Name: "no way"}, {Name: ""}}
+				u.Scopes = []string{"read", "delete"}
+			},
+			want: map[string]errors.Code{
+				"address.street": errors.CodeMissingField,
+				"address.zip":    errors.CodeOutOfRange,
+				"tags":           errors.CodeOutOfRange,
+				"tags.1.name":    errors.CodeInvalidFormat,
+				"tags.2.name":    errors.CodeMissingField,
+				"scopes.1":       errors.CodeInvalidArgument,
+			},
+		},
+		{
+			name: "nullable attributes",
+			mutate: func(u *user) {
+				u.Nickname = jsonapi.NewNullableAttrWithValue("toolong")
+				u.Extension = jsonapi.NewNullableAttrWithValue(&address{})
+				u.Labels = map[string]jsonapi.NullableAttr[int]{
+					"ok":   jsonapi.NewNullableAttrWithValue(1),
+					"big":  jsonapi.NewNullableAttrWithValue(11),
+					"null": jsonapi.NewNullNullableAttr[int](),
+				}
+			},
+			want: map[string]errors.Code{
+				"nickname":         errors.CodeOutOfRange,
+				"extension.street": errors.CodeMissingField,
+				"labels.big":       errors.CodeOutOfRange,
+			},
+		},
+		{
+			name: "null nullable attribute skips rules",
+			mutate: func(u *user) {
+				u.Nickname = jsonapi.NewNullNullableAttr[string]()
+				u.Extension = jsonapi.NewNullNullableAttr[*address]()
+			},
+		},
+		{
+			name: "cross field rules",
+			mutate: func(u *user) {
+				u.EndsAt = u.StartsAt.Add(-time.Hour)
+				u.Password = ""
+				u.Confirm = "other"
+				u.Role = "admin"
+			},
+			want: map[string]errors.Code{
+				"ends_at":  errors.CodeInvalidArgument,
+				"password": errors.CodeMissingField,
+				"confirm":  errors.CodeInvalidArgument,
+				"reason":   errors.CodeMissingField,
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			u := validUser()
+			tt.mutate(u)
+
+			err := validation.Struct(u)
+			if tt.want == nil {
+				assert.NoError(t, err)
+				return
+			}
+			assert.Equal(t, tt.want, detailsByField(t, err))
+		})
+	}
+}
+
+func TestStructInvalidInput(t *testing.T) {
+	var u *user
+	assert.True(t, errors.Is(validation.Struct(u), errors.CodeInvalidArgument))
+	assert.True(t, errors.Is(validation.Struct("text"), errors.CodeInvalidArgument))
+}
+
+func TestCustomRule(t *testing.T) {
+	type slug struct {
+		Value string `json:"value" validate:"slug"`
+	}
+
+	t.Run("unknown rule is a configuration error", func(t *testing.T) {
+		err := validation.New().Struct(slug{Value: "a"})
+		assert.True(t, errors.Is(err, errors.CodeInternalError))
+	})
+
+	t.Run("registered rule reports its own code", func(t *testing.T) {
+		v := validation.New(validation.WithRule("slug", func(f validation.Field) error {
+			if f.Value.String() != "a-b" {
+				return errors.InvalidFormat(f.Path, f.Value.String(), "slug")
+			}
+			return nil
+		}))
+		assert.NoError(t, v.Struct(slug{Value: "a-b"}))
+		assert.Equal(t, map[string]errors.Code{"value": errors.CodeInvalidFormat}, detailsByField(t, v.Struct(slug{Value: "A B"})))
+	})
+
+	t.Run("plain errors become invalid argument", func(t *testing.T) {
+		v := validation.New()
+		v.RegisterRule("slug", func(f validation.Field) error {
+			return fmt.Errorf("not a slug")
+		})
+		assert.Equal(t, map[string]errors.Code{"value": errors.CodeInvalidArgument}, detailsByField(t, v.Struct(slug{Value: "x"})))
+	})
+}
+
+func TestStructPointers(t *testing.T) {
+	type author struct {
+		ID string `jsonapi:"primary,people"`
+	}
+	type article struct {
+		ID      string   `jsonapi:"primary,articles" validate:"uuid"`
+		Title   string   `jsonapi:"attr,title" validate:"required"`
+		Address address  `jsonapi:"attr,address"`
+		Author  *author  `jsonapi:"rel,author" validate:"required"`
+		Tags    []string `jsonapi:"attr,tags/names" validate:"dive,alpha"`
+	}
+
+	err := validation.Struct(article{ID: "1", Tags: []string{"1"}})
+	apiErr, ok := errors.As(err)
+	require.True(t, ok)
+
+	got := map[string]string{}
+	for _, d := range apiErr.Details() {
+		pd, ok := d.(interface{ Pointer() string })
+		require.True(t, ok)
+		got[d.Field()] = pd.Pointer()
+	}
+	assert.Equal(t, map[string]string{
+		"id":             "/data/id",
+		"title":          "/data/attributes/title",
+		"address.street": "/data/attributes/address/street",
+		"author":         "/data/relationships/author",
+		"tags/names.0":   "/data/attributes/tags~1names/0",
+	}, got)
+}